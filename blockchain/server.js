@@ -298,6 +298,74 @@ app.get('/transactions/:address', requireGanache, async (req, res) => {
     }
 });
 
+// Load the deployed IPNSRegistry contract
+async function getRegistryContract() {
+    const buildPath = path.join(__dirname, 'build', 'contracts', 'IPNSRegistry.json');
+    const contractJson = JSON.parse(await fs.readFile(buildPath));
+
+    const networkId = '1337';
+    if (!contractJson.networks[networkId]) {
+        throw new Error('Contract not deployed to network 1337 (Ganache). Please run truffle migrate.');
+    }
+
+    return new web3.eth.Contract(contractJson.abi, contractJson.networks[networkId].address);
+}
+
+// Endpoint to add or update an IPNS record on-chain
+app.post('/ipns-records', requireGanache, async (req, res) => {
+    try {
+        const { identifier, ipnsName, fromAccount } = req.body;
+
+        if (!identifier || !ipnsName) {
+            return res.status(400).json({ error: 'Missing required fields: identifier, ipnsName' });
+        }
+
+        const contract = await getRegistryContract();
+        const from = fromAccount || (await web3.eth.getAccounts())[0];
+
+        const receipt = await contract.methods.addRecord(identifier, ipnsName).send({ from, gas: 300000 });
+
+        console.log('IPNS record updated:', identifier, '->', ipnsName, 'tx:', receipt.transactionHash);
+        res.json({
+            success: true,
+            identifier,
+            ipnsName,
+            transactionHash: receipt.transactionHash,
+            blockNumber: receipt.blockNumber
+        });
+    } catch (error) {
+        console.error('Error updating IPNS record:', error);
+        res.status(500).json({
+            error: 'Could not update IPNS record',
+            message: error.message
+        });
+    }
+});
+
+// Endpoint to read an IPNS record
+app.get('/ipns-records/:identifier', requireGanache, async (req, res) => {
+    try {
+        const contract = await getRegistryContract();
+        const record = await contract.methods.getRecord(req.params.identifier).call();
+
+        if (!record.exists) {
+            return res.status(404).json({ error: 'Record not found' });
+        }
+
+        res.json({
+            identifier: req.params.identifier,
+            ipnsName: record.ipnsName,
+            timestamp: Number(record.timestamp)
+        });
+    } catch (error) {
+        console.error('Error reading IPNS record:', error);
+        res.status(500).json({
+            error: 'Could not read IPNS record',
+            message: error.message
+        });
+    }
+});
+
 const PORT = process.env.PORT || 3001;
 app.listen(PORT, () => {
     console.log(`Blockchain server running on http://localhost:${PORT}`);
@@ -311,6 +379,8 @@ app.listen(PORT, () => {
     console.log('  GET  /contract-address');
     console.log('  POST /transaction');
     console.log('  GET  /transactions/:address');
+    console.log('  POST /ipns-records');
+    console.log('  GET  /ipns-records/:identifier');
     console.log('');
     console.log('Testing Ganache connection...');
     testGanacheConnection();
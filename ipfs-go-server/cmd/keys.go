@@ -0,0 +1,252 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+
+	"ipfs-go-server/internal/config"
+	"ipfs-go-server/internal/handlers"
+	"ipfs-go-server/internal/ipfs"
+	"ipfs-go-server/internal/keystore"
+	"ipfs-go-server/internal/models"
+)
+
+const passphraseEnv = "TABLE_KEY_PASSPHRASE"
+
+// runKeyCommand handles the key backup and payload decryption subcommands.
+// It reports whether args named a subcommand at all, so main can fall
+// through to serving.
+func runKeyCommand(args []string) (bool, error) {
+	if len(args) == 0 {
+		return false, nil
+	}
+
+	switch args[0] {
+	case "export-key":
+		return true, exportKeyCommand(args[1:])
+	case "import-key":
+		return true, importKeyCommand(args[1:])
+	case "backup":
+		return true, backupCommand(args[1:])
+	case "restore":
+		return true, restoreCommand(args[1:])
+	case "decrypt-payload":
+		return true, decryptPayloadCommand(args[1:])
+	}
+	return false, nil
+}
+
+type keyFlags struct {
+	fs         *flag.FlagSet
+	config     *string
+	api        *string
+	passphrase *string
+}
+
+func newKeyFlags(name string) *keyFlags {
+	fs := flag.NewFlagSet(name, flag.ExitOnError)
+	return &keyFlags{
+		fs:         fs,
+		config:     fs.String("config", "", "server configuration file naming the data directory and IPFS API (default $CONFIG_FILE)"),
+		api:        fs.String("api", "", "IPFS API address (default from the server configuration)"),
+		passphrase: fs.String("passphrase", "", "passphrase protecting the key material (default $"+passphraseEnv+")"),
+	}
+}
+
+func (f *keyFlags) parse(args []string) (*ipfs.IPFSClient, string, error) {
+	f.fs.Parse(args)
+
+	// The registry and IPFS API are the server's, so commands work on the
+	// same instance the configuration describes
+	var configArgs []string
+	if *f.config != "" {
+		configArgs = []string{"-config", *f.config}
+	}
+	cfg, err := config.Load(configArgs)
+	if err != nil {
+		return nil, "", fmt.Errorf("invalid configuration: %w", err)
+	}
+	handlers.InitializeConfig(cfg)
+	api := *f.api
+	if api == "" {
+		api = cfg.IPFS.API
+	}
+
+	passphrase := *f.passphrase
+	if passphrase == "" {
+		passphrase = os.Getenv(passphraseEnv)
+	}
+	if passphrase == "" {
+		return nil, "", errors.New("a passphrase is required (-passphrase or $" + passphraseEnv + ")")
+	}
+
+	return ipfs.NewIPFSClient(api), passphrase, nil
+}
+
+func exportKeyCommand(args []string) error {
+	f := newKeyFlags("export-key")
+	tableID := f.fs.String("table", "", "ID or name of the table whose key to export, prefixed with namespace/ outside the default namespace")
+	out := f.fs.String("out", "", "output file (default stdout)")
+
+	client, passphrase, err := f.parse(args)
+	if err != nil {
+		return err
+	}
+
+	registry, err := handlers.LoadRegistry()
+	if err != nil {
+		return fmt.Errorf("failed to load registry: %w", err)
+	}
+	info, ok := registry.Lookup(*tableID)
+	if !ok {
+		info, ok = registry.LookupName(*tableID)
+	}
+	if !ok {
+		return fmt.Errorf("table %q not found in registry", *tableID)
+	}
+
+	env, err := handlers.ExportTableKey(context.Background(), client, info, passphrase)
+	if err != nil {
+		return err
+	}
+	return writeJSON(*out, env)
+}
+
+func importKeyCommand(args []string) error {
+	f := newKeyFlags("import-key")
+	in := f.fs.String("in", "", "key envelope file (default stdin)")
+
+	client, passphrase, err := f.parse(args)
+	if err != nil {
+		return err
+	}
+
+	var env keystore.KeyEnvelope
+	if err := readJSON(*in, &env); err != nil {
+		return err
+	}
+
+	info, err := handlers.ImportTableKey(context.Background(), client, &env, passphrase)
+	if err != nil {
+		return err
+	}
+
+	registry, err := handlers.LoadRegistry()
+	if err != nil {
+		return fmt.Errorf("failed to load registry: %w", err)
+	}
+	ns, _ := models.SplitTableRef(info.ID)
+	if _, exists := registry.Namespaces[ns]; !exists {
+		return fmt.Errorf("namespace %q not found in registry; create it first", ns)
+	}
+	registry.Put(info)
+	if err := handlers.WriteRegistry(registry); err != nil {
+		return fmt.Errorf("failed to save registry: %w", err)
+	}
+
+	fmt.Fprintf(os.Stderr, "imported key %s for table %s (%s)\n", info.KeyName, info.ID, info.IPNSName)
+	return nil
+}
+
+func backupCommand(args []string) error {
+	f := newKeyFlags("backup")
+	out := f.fs.String("out", "", "output file (default stdout)")
+
+	client, passphrase, err := f.parse(args)
+	if err != nil {
+		return err
+	}
+
+	registry, err := handlers.LoadRegistry()
+	if err != nil {
+		return fmt.Errorf("failed to load registry: %w", err)
+	}
+
+	bundle, err := handlers.CreateBackup(context.Background(), client, registry, passphrase)
+	if err != nil {
+		return err
+	}
+	return writeJSON(*out, bundle)
+}
+
+func restoreCommand(args []string) error {
+	f := newKeyFlags("restore")
+	in := f.fs.String("in", "", "backup bundle file (default stdin)")
+
+	client, passphrase, err := f.parse(args)
+	if err != nil {
+		return err
+	}
+
+	var bundle handlers.BackupBundle
+	if err := readJSON(*in, &bundle); err != nil {
+		return err
+	}
+
+	restored, failed := handlers.RestoreBackup(context.Background(), client, &bundle, passphrase)
+
+	registry, err := handlers.LoadRegistry()
+	if err != nil {
+		return fmt.Errorf("failed to load registry: %w", err)
+	}
+	for ns, partition := range restored.Namespaces {
+		if _, exists := registry.Namespaces[ns]; !exists {
+			registry.Namespace(ns).Quota = partition.Quota
+		}
+	}
+	tables := restored.Tables()
+	for _, info := range tables {
+		registry.Put(info)
+	}
+	if err := handlers.WriteRegistry(registry); err != nil {
+		return fmt.Errorf("failed to save registry: %w", err)
+	}
+
+	fmt.Fprintf(os.Stderr, "restored %d tables\n", len(tables))
+	if len(failed) > 0 {
+		ids := make([]string, 0, len(failed))
+		for id := range failed {
+			ids = append(ids, id)
+		}
+		sort.Strings(ids)
+		for _, id := range ids {
+			fmt.Fprintf(os.Stderr, "failed to restore %s: %v\n", id, failed[id])
+		}
+		return fmt.Errorf("%d tables could not be restored", len(failed))
+	}
+	return nil
+}
+
+func readJSON(path string, v interface{}) error {
+	var r io.Reader = os.Stdin
+	if path != "" {
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		r = f
+	}
+	return json.NewDecoder(r).Decode(v)
+}
+
+func writeJSON(path string, v interface{}) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	data = append(data, '\n')
+
+	if path == "" {
+		_, err = os.Stdout.Write(data)
+		return err
+	}
+	// Key material: keep it private to the current user.
+	return os.WriteFile(path, data, 0600)
+}
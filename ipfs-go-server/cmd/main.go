@@ -1,11 +1,20 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"flag"
 	"log"
 	"net/http"
 	"os"
+	"os/signal"
+	"strings"
+	"syscall"
 	"time"
 
+	"ipfs-go-server/internal/audit"
+	"ipfs-go-server/internal/chain"
+	"ipfs-go-server/internal/config"
 	"ipfs-go-server/internal/handlers"
 	"ipfs-go-server/internal/ipfs"
 
@@ -14,7 +23,7 @@ import (
 
 func loggingMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		log.Printf("[SERVER] %s %s - Headers: %v", r.Method, r.URL.Path, r.Header)
+		log.Printf("[SERVER] %s %s - Headers: %v", r.Method, r.URL.Path, redactHeaders(r.Header))
 		log.Printf("[SERVER] Remote Address: %s", r.RemoteAddr)
 		log.Printf("[SERVER] User-Agent: %s", r.Header.Get("User-Agent"))
 		log.Printf("[SERVER] Content-Type: %s", r.Header.Get("Content-Type"))
@@ -25,26 +34,159 @@ func loggingMiddleware(next http.Handler) http.Handler {
 	})
 }
 
+// redactHeaders hides credentials from the request log
+func redactHeaders(header http.Header) http.Header {
+	redacted := header.Clone()
+	for _, name := range []string{"Authorization", "X-Api-Key"} {
+		if redacted.Get(name) != "" {
+			redacted.Set(name, "[REDACTED]")
+		}
+	}
+	return redacted
+}
+
 func main() {
 	// Set up detailed logging
 	log.SetFlags(log.LstdFlags | log.Lshortfile)
 	log.SetOutput(os.Stdout)
 
+	// Key subcommands run against the daemon or offline, then exit
+	if handled, err := runKeyCommand(os.Args[1:]); handled {
+		if err != nil {
+			log.Fatalf("[MAIN] %s failed: %v", os.Args[1], err)
+		}
+		return
+	}
+
+	// Settings come from defaults, -config or $CONFIG_FILE, the
+	// environment and flags, in increasing precedence
+	cfg, err := config.Load(os.Args[1:])
+	if errors.Is(err, flag.ErrHelp) {
+		return
+	}
+	if err != nil {
+		log.Fatalf("[MAIN] Invalid configuration: %v", err)
+	}
+	if cfg.File != "" {
+		log.Printf("[MAIN] Loaded configuration from %s", cfg.File)
+	}
+	if err := os.MkdirAll(cfg.DataDir, 0755); err != nil {
+		log.Fatalf("[MAIN] Failed to create data directory: %v", err)
+	}
+	handlers.InitializeConfig(cfg)
+	log.Printf("[MAIN] Keeping data in %s, registry in %s", cfg.DataDir, cfg.Path(cfg.RegistryFile))
+
 	log.Println("[MAIN] Starting IPFS Table Server...")
 
-	// Initialize IPFS client with default API address
-	log.Println("[MAIN] Initializing IPFS client on localhost:5001")
-	ipfsClient := ipfs.NewIPFSClient("localhost:5001")
+	// Background services run until SIGINT or SIGTERM starts a shutdown
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	log.Printf("[MAIN] Initializing IPFS client on %s", cfg.IPFS.API)
+	ipfsClient := ipfs.NewIPFSClient(cfg.IPFS.API)
+
+	// Bound reads of snapshots and gateway content from IPFS
+	ipfsClient.SetMaxReadSize(cfg.IPFS.MaxReadSize)
+
+	// Per-operation deadlines of IPFS calls
+	ipfs.SetTimeout(ipfs.OpAdd, time.Duration(cfg.IPFS.Timeouts.Add))
+	ipfs.SetTimeout(ipfs.OpCat, time.Duration(cfg.IPFS.Timeouts.Cat))
+	ipfs.SetTimeout(ipfs.OpResolve, time.Duration(cfg.IPFS.Timeouts.Resolve))
+	ipfs.SetTimeout(ipfs.OpPublish, time.Duration(cfg.IPFS.Timeouts.Publish))
+	ipfs.SetTimeout(ipfs.OpKeys, time.Duration(cfg.IPFS.Timeouts.Keys))
+
+	// Announce and follow table heads over pubsub when requested
+	if cfg.IPFS.Pubsub {
+		log.Println("[MAIN] Enabling pubsub table announcements")
+		handlers.EnablePubsub(ctx, ipfsClient)
+	}
+
+	// Load the key private tables are encrypted for before loading tables
+	if err := handlers.InitializeEncryption(); err != nil {
+		log.Fatalf("[MAIN] Failed to load encryption key: %v", err)
+	}
 
 	// Initialize handlers with persistence
 	log.Println("[MAIN] Initializing handlers with persistence...")
-	if err := handlers.InitializeStorage(ipfsClient); err != nil {
+	if err := handlers.InitializeStorage(ctx, ipfsClient); err != nil {
 		log.Printf("[MAIN] Warning: Failed to load existing tables: %v", err)
 	}
 
+	// Require API keys unless explicitly running an open development server
+	if cfg.AuthDisabled {
+		log.Println("[MAIN] Warning: Authentication is disabled, every endpoint is open")
+	} else if err := handlers.InitializeAuth(); err != nil {
+		log.Fatalf("[MAIN] Failed to load API keys: %v", err)
+	}
+
+	// Record every table mutation
+	if err := handlers.InitializeAudit(); err != nil {
+		log.Fatalf("[MAIN] Failed to open audit log: %v", err)
+	}
+
+	// Load publisher keys for signed versions
+	if err := handlers.InitializePublishers(cfg.RequireSignedVersions); err != nil {
+		log.Fatalf("[MAIN] Failed to load publisher keys: %v", err)
+	}
+
+	// Mirror key rotations on-chain when a blockchain server is configured
+	if url := cfg.ChainRegistryURL; url != "" {
+		log.Printf("[MAIN] Using on-chain IPNS registry at %s", url)
+		handlers.SetChainRegistry(chain.NewRegistry(url))
+	}
+
+	// Deliver table events to registered webhooks
+	if err := handlers.InitializeWebhooks(ctx); err != nil {
+		log.Printf("[MAIN] Warning: Failed to load webhooks: %v", err)
+	}
+
+	// Tables from before generated IDs still use their name as ID
+	handlers.MigrateTableIDs(ctx)
+
+	// Trackers announced by torrents built from uploads
+	trackers := cfg.Tracker.Trackers
+
+	// Track the torrents of our own tables, and announce them here first
+	if cfg.Tracker.Disabled {
+		log.Println("[MAIN] Embedded tracker is disabled")
+	} else {
+		announceURL, err := handlers.StartTracker(ctx, cfg.Tracker.AnnounceURL, cfg.Tracker.Passkey, cfg.Tracker.UDPAddr)
+		if err != nil {
+			log.Fatalf("[MAIN] Failed to start tracker: %v", err)
+		}
+		trackers = append([]string{announceURL}, trackers...)
+	}
+
+	// Web seeds of payloads added to IPFS point at this server's gateway
+	handlers.InitializeTorrents(trackers, cfg.Gateway.URL)
+
+	// Serve table content over /ipfs and /ipns, and anything else allowed
+	handlers.InitializeGateway(cfg.Gateway.Open, cfg.Gateway.Allow)
+
+	// Seed uploaded versions whose payloads are held locally
+	if cfg.Seeding.Disabled {
+		log.Println("[MAIN] Seeding is disabled")
+	} else {
+		policy := handlers.SeedPolicy{
+			KeepVersions: cfg.Seeding.KeepVersions,
+			MaxAge:       time.Duration(cfg.Seeding.MaxAge),
+			RatioLimit:   cfg.Seeding.RatioLimit,
+		}
+		if err := handlers.StartSeeding(ctx, cfg.Seeding.ListenAddr, policy, 5*time.Minute); err != nil {
+			log.Printf("[MAIN] Warning: Failed to start seeding: %v", err)
+		}
+	}
+
+	// Notice versions whose torrents have lost their seeders
+	handlers.StartAvailabilityChecker(ctx, time.Duration(cfg.AvailabilityInterval))
+
+	// Keep IPNS records of owned tables from expiring
+	handlers.StartRepublisher(ctx, ipfsClient, time.Duration(cfg.RepublishInterval))
+
 	router := mux.NewRouter()
 
-	// Add logging middleware
+	// Add request ID and logging middleware
+	router.Use(audit.RequestIDMiddleware)
 	router.Use(loggingMiddleware)
 
 	// Add a root handler
@@ -80,25 +222,102 @@ func main() {
 
 	// Configure server with timeouts and larger body limits
 	server := &http.Server{
-		Addr:           ":8081",
+		Addr:           cfg.ListenAddr,
 		Handler:        router,
-		ReadTimeout:    30 * time.Second,
-		WriteTimeout:   30 * time.Second,
-		IdleTimeout:    60 * time.Second,
+		ReadTimeout:    time.Duration(cfg.ReadTimeout),
+		WriteTimeout:   time.Duration(cfg.WriteTimeout),
+		IdleTimeout:    time.Duration(cfg.IdleTimeout),
 		MaxHeaderBytes: 1 << 20, // 1MB
 	}
 
-	log.Println("[MAIN] Server is running on :8081")
+	log.Printf("[MAIN] Server is running on %s", cfg.ListenAddr)
 	log.Println("[MAIN] Available endpoints:")
 	log.Println("[MAIN]   GET  / - Health check")
+	log.Println("[MAIN]   GET  /health - IPNS republisher health")
 	log.Println("[MAIN]   GET  /tables - Get all tables")
 	log.Println("[MAIN]   POST /tables - Create a new table")
+	log.Println("[MAIN]   POST /tables/follow - Mirror a table from another server")
 	log.Println("[MAIN]   GET  /tables/{id} - Get a specific table")
 	log.Println("[MAIN]   PUT  /tables/{id} - Update a table")
 	log.Println("[MAIN]   DELETE /tables/{id} - Delete a table")
 	log.Println("[MAIN]   POST /tables/{id}/append - Append item to table")
+	log.Println("[MAIN]   POST /tables/{id}/upload - Build a torrent of a file and append it")
+	log.Println("[MAIN]   POST /tables/{id}/upload/encrypted - Encrypt a file and append its torrent")
+	log.Println("[MAIN]   GET  /torrents - Seeded torrents and their peers")
+	log.Println("[MAIN]   GET  /tables/{id}/health - Seeders and leechers of each version")
+	log.Println("[MAIN]   GET  /announce - BitTorrent tracker announce")
+	log.Println("[MAIN]   GET  /scrape - BitTorrent tracker scrape")
+	log.Println("[MAIN]   GET  /ipfs/{cid}/... - Gateway for table snapshots and payloads")
+	log.Println("[MAIN]   GET  /ipns/{name}/... - Gateway for table IPNS names")
+	log.Println("[MAIN]   GET  /namespaces - List namespaces with their quotas and usage")
+	log.Println("[MAIN]   POST /namespaces - Create a namespace")
+	log.Println("[MAIN]   PUT  /namespaces/{ns} - Set a namespace's quota")
+	log.Println("[MAIN]   DELETE /namespaces/{ns} - Delete an empty namespace")
+	log.Println("[MAIN]   *    /ns/{ns}/tables/... - Table endpoints within a namespace")
+	log.Println("[MAIN]   GET  /audit - Query the audit log")
+	log.Println("[MAIN]   GET  /config - Running configuration, secrets masked")
+	log.Println("[MAIN]   GET  /encryption/key - This server's public key for private tables")
+	log.Println("[MAIN]   GET  /tables/{id}/recipients - Who can decrypt a private table")
+	log.Println("[MAIN]   POST /tables/{id}/recipients - Share a private table with a key")
+	log.Println("[MAIN]   DELETE /tables/{id}/recipients/{recipientId} - Unshare and rotate the data key")
+	log.Println("[MAIN]   GET  /encryption/keys - Public keys of the file encryption service")
+	log.Println("[MAIN]   POST /encryption/encrypt/{smime,aes} - Encrypt an uploaded file")
+	log.Println("[MAIN]   POST /encryption/decrypt/{smime,aes} - Decrypt an uploaded file")
+	log.Println("[MAIN]   GET  /encryption/download/{filename} - Download an encryption result")
+	log.Println("[MAIN]   GET  /tables/{id}/verify - Check version signatures")
+	log.Println("[MAIN]   GET  /publishers - List publisher keys")
+	log.Println("[MAIN]   POST /publishers - Register a publisher key")
+	log.Println("[MAIN]   DELETE /publishers/{id} - Revoke a publisher key")
+	log.Println("[MAIN]   GET  /tables/{id}/acl - Table ACL and effective permissions")
+	log.Println("[MAIN]   POST /tables/{id}/acl/grant - Grant a role on a table")
+	log.Println("[MAIN]   POST /tables/{id}/acl/revoke - Revoke a role on a table")
+	log.Println("[MAIN]   GET  /events - Stream changes to all tables (SSE)")
+	log.Println("[MAIN]   GET  /tables/{id}/events - Stream changes to a table (SSE)")
+	log.Println("[MAIN]   GET  /ws - Stream table changes over WebSocket")
+	log.Println("[MAIN]   GET  /webhooks - List webhooks")
+	log.Println("[MAIN]   POST /webhooks - Register a webhook")
+	log.Println("[MAIN]   DELETE /webhooks/{id} - Remove a webhook")
+	log.Println("[MAIN]   GET  /webhooks/{id}/deliveries - Webhook delivery log")
+	log.Println("[MAIN]   POST /webhooks/{id}/deliveries/{deliveryId}/replay - Redeliver an event")
+	log.Println("[MAIN]   GET  /auth/whoami - Identity of the calling API key")
+	log.Println("[MAIN]   GET  /auth/keys - List API keys")
+	log.Println("[MAIN]   POST /auth/keys - Create an API key")
+	log.Println("[MAIN]   DELETE /auth/keys/{id} - Revoke an API key")
+	log.Println("[MAIN]   POST /tables/{id}/key/export - Export encrypted IPNS key")
+	log.Println("[MAIN]   POST /keys/import - Import encrypted IPNS key")
+	log.Println("[MAIN]   POST /backup - Download registry and key backup")
+	log.Println("[MAIN]   POST /backup/restore - Restore registry and key backup")
+	log.Println("[MAIN]   POST /tables/{id}/rotate-key - Move table to a new IPNS key")
+	log.Println("[MAIN]   GET  /tables/{id}/publish-settings - Get IPNS record lifetime and TTL")
+	log.Println("[MAIN]   PUT  /tables/{id}/publish-settings - Set IPNS record lifetime and TTL")
+
+	// Streams would hold a shutdown up until its deadline
+	server.RegisterOnShutdown(handlers.CloseStreams)
+
+	go func() {
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Fatalf("[MAIN] Failed to start server: %v", err)
+		}
+	}()
+
+	<-ctx.Done()
+	// A second signal stops the server without waiting
+	stop()
+	log.Printf("[MAIN] Shutting down, waiting up to %s for requests and publishes...", time.Duration(cfg.ShutdownTimeout))
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), time.Duration(cfg.ShutdownTimeout))
+	defer cancel()
 
-	if err := server.ListenAndServe(); err != nil {
-		log.Fatalf("[MAIN] Failed to start server: %v", err)
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		log.Printf("[MAIN] Warning: Requests still open at shutdown: %v", err)
+	}
+
+	pending, lost := handlers.Shutdown(shutdownCtx)
+	if len(pending) > 0 {
+		log.Printf("[MAIN] %d table(s) could not be published and will be retried on the next start: %s", len(pending), strings.Join(pending, ", "))
+	}
+	if len(lost) > 0 {
+		log.Printf("[MAIN] Error: Unpublished changes to %d table(s) were lost: %s", len(lost), strings.Join(lost, ", "))
 	}
+	log.Println("[MAIN] Server stopped")
 }
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"ipfs-go-server/internal/encryption"
+	"ipfs-go-server/internal/models"
+)
+
+// decryptPayloadCommand decrypts a downloaded encrypted torrent payload
+// with a recipient's private key. It needs no running daemon.
+func decryptPayloadCommand(args []string) error {
+	fs := flag.NewFlagSet("decrypt-payload", flag.ExitOnError)
+	versionFile := fs.String("version", "", "JSON of the table version (default stdin)")
+	keyFile := fs.String("key", "encryption_key.pem", "recipient RSA private key")
+	in := fs.String("in", "", "downloaded payload")
+	out := fs.String("out", "", "output file (default stdout)")
+	fs.Parse(args)
+
+	if *in == "" {
+		return errors.New("-in is required")
+	}
+
+	var version models.TorrentVersion
+	if err := readJSON(*versionFile, &version); err != nil {
+		return fmt.Errorf("failed to read version: %w", err)
+	}
+	if version.Encryption == nil {
+		return errors.New("version payload is not encrypted")
+	}
+	if version.Encryption.Algorithm != encryption.PayloadAlgorithm {
+		return fmt.Errorf("unsupported payload encryption %s", version.Encryption.Algorithm)
+	}
+
+	data, err := os.ReadFile(*keyFile)
+	if err != nil {
+		return err
+	}
+	ring, err := encryption.ParseKeyring(data)
+	if err != nil {
+		return err
+	}
+	wrapped, ok := version.Encryption.KeyFor(ring.Recipient().ID)
+	if !ok {
+		return fmt.Errorf("version is not encrypted for key %s", ring.Recipient().ID)
+	}
+	key, err := encryption.UnwrapContentKey(wrapped.WrappedKey, ring.PrivateKey(), version.Hash)
+	if err != nil {
+		return err
+	}
+
+	src, err := os.Open(*in)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	var dst io.Writer = os.Stdout
+	if *out != "" {
+		f, err := os.OpenFile(*out, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		dst = f
+	}
+	return encryption.DecryptStream(dst, src, key)
+}
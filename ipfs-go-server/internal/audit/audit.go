@@ -0,0 +1,181 @@
+package audit
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// Action names a mutation recorded in the audit log
+type Action string
+
+const (
+	ActionCreate    Action = "create"
+	ActionUpdate    Action = "update"
+	ActionAppend    Action = "append"
+	ActionDelete    Action = "delete"
+	ActionRotateKey Action = "rotate-key"
+	ActionGrant     Action = "acl-grant"
+	ActionRevoke    Action = "acl-revoke"
+	ActionMoveID    Action = "move-id"
+
+	// Namespace actions name the namespace in Detail; TableID is empty
+	ActionCreateNamespace Action = "namespace-create"
+	ActionUpdateNamespace Action = "namespace-update"
+	ActionDeleteNamespace Action = "namespace-delete"
+)
+
+// DefaultLimit caps query results when no limit is given
+const DefaultLimit = 100
+
+// Entry is one audit record. BeforeCID and AfterCID are the table's
+// snapshot before and after the change, when known.
+type Entry struct {
+	ID        uint64    `json:"id"`
+	Time      time.Time `json:"time"`
+	Action    Action    `json:"action"`
+	TableID   string    `json:"tableId"`
+	Actor     string    `json:"actor"`
+	SourceIP  string    `json:"sourceIp,omitempty"`
+	RequestID string    `json:"requestId,omitempty"`
+	BeforeCID string    `json:"beforeCid,omitempty"`
+	AfterCID  string    `json:"afterCid,omitempty"`
+	Detail    string    `json:"detail,omitempty"`
+}
+
+// Query filters audit entries. Zero fields match everything.
+type Query struct {
+	TableID string
+	Actor   string
+	Action  Action
+	Since   time.Time
+	Until   time.Time
+	Limit   int
+}
+
+func (q Query) matches(e Entry) bool {
+	switch {
+	case q.TableID != "" && e.TableID != q.TableID:
+		return false
+	case q.Actor != "" && e.Actor != q.Actor:
+		return false
+	case q.Action != "" && e.Action != q.Action:
+		return false
+	case !q.Since.IsZero() && e.Time.Before(q.Since):
+		return false
+	case !q.Until.IsZero() && !e.Time.Before(q.Until):
+		return false
+	}
+	return true
+}
+
+// Log is an append-only audit log stored as one JSON entry per line
+type Log struct {
+	path string
+
+	mu   sync.Mutex
+	file *os.File
+	seq  uint64
+}
+
+// Open opens the log at path, creating it if needed, and continues its
+// numbering
+func Open(path string) (*Log, error) {
+	l := &Log{path: path}
+
+	if err := l.scan(func(e Entry) { l.seq = e.ID }); err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to read audit log: %w", err)
+	}
+
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open audit log: %w", err)
+	}
+	l.file = file
+	return l, nil
+}
+
+// Record appends an entry, numbering and timestamping it
+func (l *Log) Record(e Entry) (Entry, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	e.ID = l.seq + 1
+	e.Time = time.Now().UTC()
+
+	line, err := json.Marshal(e)
+	if err != nil {
+		return e, err
+	}
+	if _, err := l.file.Write(append(line, '\n')); err != nil {
+		return e, fmt.Errorf("failed to write audit entry: %w", err)
+	}
+	if err := l.file.Sync(); err != nil {
+		return e, fmt.Errorf("failed to sync audit log: %w", err)
+	}
+
+	l.seq = e.ID
+	return e, nil
+}
+
+// Search returns the most recent entries matching q, oldest first
+func (l *Log) Search(q Query) ([]Entry, error) {
+	if q.Limit <= 0 {
+		q.Limit = DefaultLimit
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	// Keep a ring of the last q.Limit matches
+	ring := make([]Entry, 0, q.Limit)
+	start := 0
+	err := l.scan(func(e Entry) {
+		if !q.matches(e) {
+			return
+		}
+		if len(ring) < q.Limit {
+			ring = append(ring, e)
+			return
+		}
+		ring[start] = e
+		start = (start + 1) % q.Limit
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return append(ring[start:], ring[:start]...), nil
+}
+
+// Close closes the log file
+func (l *Log) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return l.file.Close()
+}
+
+// scan calls fn for every entry in the file. Lines that do not parse, such
+// as a partial line left by a crash, are skipped.
+func (l *Log) scan(fn func(Entry)) error {
+	file, err := os.Open(l.path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 1<<20)
+	for scanner.Scan() {
+		var e Entry
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			continue
+		}
+		fn(e)
+	}
+	return scanner.Err()
+}
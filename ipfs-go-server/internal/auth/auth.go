@@ -0,0 +1,149 @@
+package auth
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"strings"
+)
+
+// Scope grants access to a class of endpoints. Scopes are ordered:
+// tables:admin implies tables:write, which implies tables:read.
+type Scope string
+
+const (
+	ScopeRead  Scope = "tables:read"
+	ScopeWrite Scope = "tables:write"
+	ScopeAdmin Scope = "tables:admin"
+)
+
+var scopeRank = map[Scope]int{
+	ScopeRead:  1,
+	ScopeWrite: 2,
+	ScopeAdmin: 3,
+}
+
+// Valid reports whether the scope is one this server knows
+func (s Scope) Valid() bool {
+	_, ok := scopeRank[s]
+	return ok
+}
+
+// Identity is the caller a request was authenticated as
+type Identity struct {
+	KeyID  string  `json:"keyId,omitempty"`
+	Name   string  `json:"name"`
+	Scopes []Scope `json:"scopes"`
+}
+
+// Anonymous is the identity of every request when authentication is
+// disabled. It holds every scope.
+var Anonymous = &Identity{Name: "anonymous", Scopes: []Scope{ScopeAdmin}}
+
+// Principal is how table ACLs refer to the identity: its key ID, which is
+// never reused, or its name when it has no key
+func (id *Identity) Principal() string {
+	if id.KeyID != "" {
+		return id.KeyID
+	}
+	return id.Name
+}
+
+// HasScope reports whether the identity was granted scope, directly or
+// through a broader one
+func (id *Identity) HasScope(scope Scope) bool {
+	return hasScope(id.Scopes, scope)
+}
+
+func hasScope(granted []Scope, scope Scope) bool {
+	for _, g := range granted {
+		if scopeRank[g] >= scopeRank[scope] {
+			return true
+		}
+	}
+	return false
+}
+
+type contextKey struct{}
+
+// WithIdentity returns a context carrying the caller's identity
+func WithIdentity(ctx context.Context, id *Identity) context.Context {
+	return context.WithValue(ctx, contextKey{}, id)
+}
+
+// FromContext returns the identity the request was authenticated as
+func FromContext(ctx context.Context) (*Identity, bool) {
+	id, ok := ctx.Value(contextKey{}).(*Identity)
+	return id, ok
+}
+
+// Actor names the caller for logs and audit records
+func Actor(ctx context.Context) string {
+	if id, ok := FromContext(ctx); ok {
+		return id.Name
+	}
+	return "anonymous"
+}
+
+// Require authenticates the request and checks that the caller holds scope
+// before calling next. A nil store disables authentication.
+func Require(store *Store, scope Scope, next http.HandlerFunc) http.HandlerFunc {
+	return require(store, scope, false, next)
+}
+
+// RequireStream is Require for EventSource and WebSocket endpoints, which
+// also accept the API key as ?api_key=. Browsers cannot set headers on
+// those connections.
+func RequireStream(store *Store, scope Scope, next http.HandlerFunc) http.HandlerFunc {
+	return require(store, scope, true, next)
+}
+
+func require(store *Store, scope Scope, allowQuery bool, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if store == nil {
+			next(w, r.WithContext(WithIdentity(r.Context(), Anonymous)))
+			return
+		}
+
+		token := tokenFromRequest(r, allowQuery)
+		if token == "" {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="torrentchain"`)
+			http.Error(w, "API key required", http.StatusUnauthorized)
+			return
+		}
+
+		id, err := store.Authenticate(token)
+		if err != nil {
+			log.Printf("[AUTH] Rejected invalid API key from %s", r.RemoteAddr)
+			w.Header().Set("WWW-Authenticate", `Bearer realm="torrentchain", error="invalid_token"`)
+			http.Error(w, "Invalid API key", http.StatusUnauthorized)
+			return
+		}
+
+		if !id.HasScope(scope) {
+			log.Printf("[AUTH] Key %s (%s) lacks scope %s for %s %s", id.KeyID, id.Name, scope, r.Method, r.URL.Path)
+			http.Error(w, "API key lacks scope "+string(scope), http.StatusForbidden)
+			return
+		}
+
+		next(w, r.WithContext(WithIdentity(r.Context(), id)))
+	}
+}
+
+// tokenFromRequest reads the API key from the Authorization or X-API-Key
+// header, or with allowQuery from ?api_key= on GET requests. Query strings
+// end up in access logs and Referer headers, so only streams allow it.
+func tokenFromRequest(r *http.Request, allowQuery bool) string {
+	if header := r.Header.Get("Authorization"); header != "" {
+		if token, ok := strings.CutPrefix(header, "Bearer "); ok {
+			return strings.TrimSpace(token)
+		}
+	}
+	if token := r.Header.Get("X-API-Key"); token != "" {
+		return token
+	}
+	if allowQuery && r.Method == http.MethodGet {
+		return r.URL.Query().Get("api_key")
+	}
+	return ""
+}
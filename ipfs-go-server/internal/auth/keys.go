@@ -0,0 +1,251 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"ipfs-go-server/pkg/utils"
+)
+
+// tokenPrefix marks API keys issued by this server
+const tokenPrefix = "tck_"
+
+var (
+	ErrInvalidKey   = errors.New("invalid API key")
+	ErrNotFound     = errors.New("API key not found")
+	ErrUnknownScope = errors.New("unknown scope")
+	ErrLastAdmin    = errors.New("cannot revoke the last tables:admin key")
+	ErrNameTaken    = errors.New("an API key with this name already exists")
+)
+
+// APIKey is a stored API key. Only the SHA-256 hash of the token is kept;
+// the token itself is shown once, when the key is created.
+type APIKey struct {
+	ID        string    `json:"id"`
+	Name      string    `json:"name"`
+	Prefix    string    `json:"prefix"`
+	Hash      string    `json:"hash,omitempty"`
+	Scopes    []Scope   `json:"scopes"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// Store keeps API keys in a JSON file
+type Store struct {
+	path string
+
+	mu     sync.RWMutex
+	keys   map[string]*APIKey // by ID
+	byHash map[string]*APIKey
+}
+
+// NewStore loads the keys saved at path, if any
+func NewStore(path string) (*Store, error) {
+	s := &Store{
+		path:   path,
+		keys:   make(map[string]*APIKey),
+		byHash: make(map[string]*APIKey),
+	}
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read API keys: %w", err)
+	}
+
+	var keys []*APIKey
+	if err := json.Unmarshal(data, &keys); err != nil {
+		return nil, fmt.Errorf("failed to parse API keys: %w", err)
+	}
+	for _, key := range keys {
+		s.keys[key.ID] = key
+		s.byHash[key.Hash] = key
+	}
+	return s, nil
+}
+
+// Empty reports whether no keys have been created yet
+func (s *Store) Empty() bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return len(s.keys) == 0
+}
+
+// Create issues a new key and returns it together with its token. Names are
+// unique among live keys so grants can name a key; table ACLs hold key IDs,
+// which are never reused.
+func (s *Store) Create(name string, scopes []Scope) (*APIKey, string, error) {
+	if len(scopes) == 0 {
+		return nil, "", fmt.Errorf("%w: at least one scope is required", ErrUnknownScope)
+	}
+	for _, scope := range scopes {
+		if !scope.Valid() {
+			return nil, "", fmt.Errorf("%w: %s", ErrUnknownScope, scope)
+		}
+	}
+
+	id, err := utils.GenerateUniqueID()
+	if err != nil {
+		return nil, "", err
+	}
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, "", err
+	}
+	token := tokenPrefix + hex.EncodeToString(secret)
+
+	key := &APIKey{
+		ID:        id,
+		Name:      name,
+		Prefix:    token[:len(tokenPrefix)+8],
+		Hash:      hashToken(token),
+		Scopes:    scopes,
+		CreatedAt: time.Now().UTC(),
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.findByName(name) != nil {
+		return nil, "", ErrNameTaken
+	}
+
+	s.keys[key.ID] = key
+	s.byHash[key.Hash] = key
+	if err := s.save(); err != nil {
+		delete(s.keys, key.ID)
+		delete(s.byHash, key.Hash)
+		return nil, "", err
+	}
+
+	out := *key
+	return &out, token, nil
+}
+
+// Revoke deletes a key. The last key with tables:admin cannot be revoked,
+// since nobody could manage keys afterwards.
+func (s *Store) Revoke(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key, exists := s.keys[id]
+	if !exists {
+		return ErrNotFound
+	}
+
+	if hasScope(key.Scopes, ScopeAdmin) {
+		admins := 0
+		for _, other := range s.keys {
+			if hasScope(other.Scopes, ScopeAdmin) {
+				admins++
+			}
+		}
+		if admins == 1 {
+			return ErrLastAdmin
+		}
+	}
+
+	delete(s.keys, id)
+	delete(s.byHash, key.Hash)
+	if err := s.save(); err != nil {
+		s.keys[id] = key
+		s.byHash[key.Hash] = key
+		return err
+	}
+	return nil
+}
+
+// List returns every key, oldest first, without hashes
+func (s *Store) List() []APIKey {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	keys := make([]APIKey, 0, len(s.keys))
+	for _, key := range s.keys {
+		out := *key
+		out.Hash = ""
+		keys = append(keys, out)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].CreatedAt.Before(keys[j].CreatedAt)
+	})
+	return keys
+}
+
+// Authenticate returns the identity a token belongs to
+func (s *Store) Authenticate(token string) (*Identity, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	key, exists := s.byHash[hashToken(token)]
+	if !exists {
+		return nil, ErrInvalidKey
+	}
+	return &Identity{KeyID: key.ID, Name: key.Name, Scopes: key.Scopes}, nil
+}
+
+// Identity returns the identity of the key with the given name
+func (s *Store) Identity(name string) (*Identity, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	key := s.findByName(name)
+	if key == nil {
+		return nil, ErrNotFound
+	}
+	return &Identity{KeyID: key.ID, Name: key.Name, Scopes: key.Scopes}, nil
+}
+
+// IdentityByID returns the identity of the key with the given ID
+func (s *Store) IdentityByID(id string) (*Identity, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	key, exists := s.keys[id]
+	if !exists {
+		return nil, ErrNotFound
+	}
+	return &Identity{KeyID: key.ID, Name: key.Name, Scopes: key.Scopes}, nil
+}
+
+// findByName must be called with s.mu held
+func (s *Store) findByName(name string) *APIKey {
+	for _, key := range s.keys {
+		if key.Name == name {
+			return key
+		}
+	}
+	return nil
+}
+
+// save writes the keys to disk. Must be called with s.mu held.
+func (s *Store) save() error {
+	keys := make([]*APIKey, 0, len(s.keys))
+	for _, key := range s.keys {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].CreatedAt.Before(keys[j].CreatedAt)
+	})
+
+	data, err := json.MarshalIndent(keys, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(s.path, data, 0600)
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,54 @@
+package chain
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Registry records table IPNS names in the IPNSRegistry contract through the
+// blockchain server's /ipns-records endpoint.
+type Registry struct {
+	baseURL string
+	client  *http.Client
+}
+
+func NewRegistry(baseURL string) *Registry {
+	return &Registry{
+		baseURL: strings.TrimRight(baseURL, "/"),
+		client:  &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// SetRecord adds or updates the on-chain record for identifier and returns
+// the transaction hash.
+func (r *Registry) SetRecord(identifier, ipnsName string) (string, error) {
+	body, err := json.Marshal(map[string]string{
+		"identifier": identifier,
+		"ipnsName":   ipnsName,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	resp, err := r.client.Post(r.baseURL+"/ipns-records", "application/json", bytes.NewReader(body))
+	if err != nil {
+		return "", fmt.Errorf("failed to reach blockchain server: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var out struct {
+		TransactionHash string `json:"transactionHash"`
+		Error           string `json:"error"`
+		Message         string `json:"message"`
+	}
+	json.NewDecoder(resp.Body).Decode(&out)
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("blockchain server returned %d: %s %s", resp.StatusCode, out.Error, out.Message)
+	}
+	return out.TransactionHash, nil
+}
@@ -0,0 +1,281 @@
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"time"
+
+	"ipfs-go-server/internal/models"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Config holds the server's settings. Each is read from its default, then
+// the YAML file, then its environment variable, then its flag, each
+// overriding the one before.
+type Config struct {
+	// File the settings were read from, if any
+	File string `yaml:"-" json:"file,omitempty"`
+
+	ListenAddr string `yaml:"listenAddr" json:"listenAddr"`
+	// DataDir holds the registry, keys, logs and payloads. Relative file
+	// names below are resolved against it.
+	DataDir      string `yaml:"dataDir" json:"dataDir"`
+	RegistryFile string `yaml:"registryFile" json:"registryFile"`
+
+	ReadTimeout     models.Duration `yaml:"readTimeout" json:"readTimeout"`
+	WriteTimeout    models.Duration `yaml:"writeTimeout" json:"writeTimeout"`
+	IdleTimeout     models.Duration `yaml:"idleTimeout" json:"idleTimeout"`
+	ShutdownTimeout models.Duration `yaml:"shutdownTimeout" json:"shutdownTimeout"`
+
+	AuthDisabled          bool   `yaml:"authDisabled" json:"authDisabled"`
+	RequireSignedVersions bool   `yaml:"requireSignedVersions" json:"requireSignedVersions"`
+	ChainRegistryURL      string `yaml:"chainRegistryUrl" json:"chainRegistryUrl,omitempty"`
+
+	AvailabilityInterval models.Duration `yaml:"availabilityInterval" json:"availabilityInterval"`
+	RepublishInterval    models.Duration `yaml:"republishInterval" json:"republishInterval"`
+
+	IPFS    IPFS    `yaml:"ipfs" json:"ipfs"`
+	Tracker Tracker `yaml:"tracker" json:"tracker"`
+	Gateway Gateway `yaml:"gateway" json:"gateway"`
+	Seeding Seeding `yaml:"seeding" json:"seeding"`
+}
+
+// IPFS configures the daemon the server talks to
+type IPFS struct {
+	API         string   `yaml:"api" json:"api"`
+	MaxReadSize int64    `yaml:"maxReadSize" json:"maxReadSize"`
+	Pubsub      bool     `yaml:"pubsub" json:"pubsub"`
+	Timeouts    Timeouts `yaml:"timeouts" json:"timeouts"`
+}
+
+// Timeouts are the deadlines of each kind of IPFS call. Zero removes one.
+type Timeouts struct {
+	Add     models.Duration `yaml:"add" json:"add"`
+	Cat     models.Duration `yaml:"cat" json:"cat"`
+	Resolve models.Duration `yaml:"resolve" json:"resolve"`
+	Publish models.Duration `yaml:"publish" json:"publish"`
+	Keys    models.Duration `yaml:"keys" json:"keys"`
+}
+
+// Tracker configures the embedded tracker and the trackers torrents list
+type Tracker struct {
+	Disabled    bool     `yaml:"disabled" json:"disabled"`
+	AnnounceURL string   `yaml:"announceUrl" json:"announceUrl"`
+	UDPAddr     string   `yaml:"udpAddr" json:"udpAddr"`
+	Passkey     string   `yaml:"passkey" json:"passkey,omitempty"`
+	Trackers    []string `yaml:"trackers" json:"trackers"`
+}
+
+// Gateway configures /ipfs and /ipns and the web seeds pointing at them
+type Gateway struct {
+	URL   string   `yaml:"url" json:"url"`
+	Open  bool     `yaml:"open" json:"open"`
+	Allow []string `yaml:"allow" json:"allow"`
+}
+
+// Seeding configures which uploaded versions the server seeds
+type Seeding struct {
+	Disabled     bool            `yaml:"disabled" json:"disabled"`
+	ListenAddr   string          `yaml:"listenAddr" json:"listenAddr"`
+	KeepVersions int             `yaml:"keepVersions" json:"keepVersions"`
+	MaxAge       models.Duration `yaml:"maxAge" json:"maxAge"`
+	RatioLimit   float64         `yaml:"ratioLimit" json:"ratioLimit"`
+}
+
+// Default returns the settings used when nothing overrides them. URLs that
+// point back at the server are left empty and follow ListenAddr.
+func Default() *Config {
+	return &Config{
+		ListenAddr:           ":8081",
+		DataDir:              ".",
+		RegistryFile:         "tables_registry.json",
+		ReadTimeout:          models.Duration(30 * time.Second),
+		WriteTimeout:         models.Duration(30 * time.Second),
+		IdleTimeout:          models.Duration(60 * time.Second),
+		ShutdownTimeout:      models.Duration(30 * time.Second),
+		AvailabilityInterval: models.Duration(30 * time.Minute),
+		RepublishInterval:    models.Duration(10 * time.Minute),
+		IPFS: IPFS{
+			API:         "localhost:5001",
+			MaxReadSize: 64 << 20,
+			Timeouts: Timeouts{
+				Add:     models.Duration(5 * time.Minute),
+				Cat:     models.Duration(time.Minute),
+				Resolve: models.Duration(10 * time.Second),
+				Publish: models.Duration(2 * time.Minute),
+				Keys:    models.Duration(30 * time.Second),
+			},
+		},
+		Tracker: Tracker{
+			UDPAddr: ":6969",
+		},
+		Seeding: Seeding{
+			ListenAddr: ":6881",
+		},
+	}
+}
+
+// Load reads the settings for args, the server's command-line arguments.
+// The file comes from -config or $CONFIG_FILE.
+func Load(args []string) (*Config, error) {
+	// The file is read before the environment and flags, but named by them
+	first := Default()
+	if err := first.apply(args, io.Discard); err != nil && !errors.Is(err, flag.ErrHelp) {
+		return nil, err
+	}
+
+	cfg := Default()
+	cfg.File = first.File
+	if cfg.File != "" {
+		if err := cfg.readFile(cfg.File); err != nil {
+			return nil, err
+		}
+	}
+	if err := cfg.apply(args, os.Stderr); err != nil {
+		return nil, err
+	}
+
+	cfg.fillDerived()
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// apply overrides settings with the environment, then with args
+func (c *Config) apply(args []string, output io.Writer) error {
+	fs := flag.NewFlagSet("ipfs-go-server", flag.ContinueOnError)
+	fs.SetOutput(output)
+	for _, s := range c.settings() {
+		if value, ok := os.LookupEnv(s.env); ok {
+			if err := s.value.Set(value); err != nil {
+				return fmt.Errorf("invalid %s %q: %w", s.env, value, err)
+			}
+		}
+		fs.Var(s.value, s.flag, s.usage+" ($"+s.env+")")
+	}
+	return fs.Parse(args)
+}
+
+// readFile overrides settings with those in a YAML file. Unknown keys are
+// rejected so typos do not go unnoticed.
+func (c *Config) readFile(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open config file: %w", err)
+	}
+	defer f.Close()
+
+	dec := yaml.NewDecoder(f)
+	dec.KnownFields(true)
+	if err := dec.Decode(c); err != nil && err != io.EOF {
+		return fmt.Errorf("failed to read config file %s: %w", path, err)
+	}
+	return nil
+}
+
+// fillDerived points URLs left empty at the server's own listen address
+func (c *Config) fillDerived() {
+	_, port, err := net.SplitHostPort(c.ListenAddr)
+	if err != nil {
+		return
+	}
+	if c.Gateway.URL == "" {
+		c.Gateway.URL = "http://localhost:" + port
+	}
+	if c.Tracker.AnnounceURL == "" {
+		c.Tracker.AnnounceURL = "http://localhost:" + port + "/announce"
+	}
+}
+
+// Validate reports every setting that is out of range
+func (c *Config) Validate() error {
+	var errs []error
+	check := func(ok bool, format string, args ...interface{}) {
+		if !ok {
+			errs = append(errs, fmt.Errorf(format, args...))
+		}
+	}
+
+	_, _, err := net.SplitHostPort(c.ListenAddr)
+	check(err == nil, "listenAddr must be host:port, got %q", c.ListenAddr)
+	check(c.DataDir != "", "dataDir must be set")
+	check(c.RegistryFile != "", "registryFile must be set")
+	check(c.IPFS.API != "", "ipfs.api must be set")
+	check(c.IPFS.MaxReadSize > 0, "ipfs.maxReadSize must be positive")
+
+	durations := []struct {
+		name     string
+		value    models.Duration
+		positive bool
+	}{
+		{"readTimeout", c.ReadTimeout, false},
+		{"writeTimeout", c.WriteTimeout, false},
+		{"idleTimeout", c.IdleTimeout, false},
+		{"shutdownTimeout", c.ShutdownTimeout, true},
+		{"availabilityInterval", c.AvailabilityInterval, true},
+		{"republishInterval", c.RepublishInterval, true},
+		{"ipfs.timeouts.add", c.IPFS.Timeouts.Add, false},
+		{"ipfs.timeouts.cat", c.IPFS.Timeouts.Cat, false},
+		{"ipfs.timeouts.resolve", c.IPFS.Timeouts.Resolve, false},
+		{"ipfs.timeouts.publish", c.IPFS.Timeouts.Publish, false},
+		{"ipfs.timeouts.keys", c.IPFS.Timeouts.Keys, false},
+		{"seeding.maxAge", c.Seeding.MaxAge, false},
+	}
+	for _, d := range durations {
+		if d.positive {
+			check(d.value > 0, "%s must be positive", d.name)
+		} else {
+			check(d.value >= 0, "%s must not be negative", d.name)
+		}
+	}
+
+	check(validURL(c.Gateway.URL), "gateway.url must be an http or https URL, got %q", c.Gateway.URL)
+	if !c.Tracker.Disabled {
+		check(validURL(c.Tracker.AnnounceURL), "tracker.announceUrl must be an http or https URL, got %q", c.Tracker.AnnounceURL)
+	}
+	if c.ChainRegistryURL != "" {
+		check(validURL(c.ChainRegistryURL), "chainRegistryUrl must be an http or https URL, got %q", c.ChainRegistryURL)
+	}
+
+	check(c.Seeding.KeepVersions >= 0, "seeding.keepVersions must not be negative")
+	check(c.Seeding.RatioLimit >= 0, "seeding.ratioLimit must not be negative")
+
+	if info, err := os.Stat(c.DataDir); err == nil && !info.IsDir() {
+		errs = append(errs, fmt.Errorf("dataDir %s is not a directory", c.DataDir))
+	}
+	return errors.Join(errs...)
+}
+
+func validURL(raw string) bool {
+	u, err := url.Parse(raw)
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
+
+// Path resolves a file name against the data directory
+func (c *Config) Path(name string) string {
+	if filepath.IsAbs(name) {
+		return name
+	}
+	return filepath.Join(c.DataDir, name)
+}
+
+// Redacted returns a copy that is safe to show: secrets are masked
+func (c *Config) Redacted() *Config {
+	redacted := *c
+	if redacted.Tracker.Passkey != "" {
+		redacted.Tracker.Passkey = "[REDACTED]"
+	}
+	if u, err := url.Parse(redacted.ChainRegistryURL); err == nil && u.User != nil {
+		u.User = url.User("[REDACTED]")
+		redacted.ChainRegistryURL = u.String()
+	}
+	return &redacted
+}
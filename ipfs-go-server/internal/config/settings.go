@@ -0,0 +1,156 @@
+package config
+
+import (
+	"flag"
+	"strconv"
+	"strings"
+	"time"
+
+	"ipfs-go-server/internal/models"
+)
+
+// setting binds one field of a Config to its environment variable and flag
+type setting struct {
+	flag  string
+	env   string
+	usage string
+	value flag.Value
+}
+
+// settings lists every setting that can be overridden, bound to c
+func (c *Config) settings() []setting {
+	return []setting{
+		{"config", "CONFIG_FILE", "YAML configuration file", (*stringValue)(&c.File)},
+		{"listen", "LISTEN_ADDR", "address the HTTP server listens on", (*stringValue)(&c.ListenAddr)},
+		{"data-dir", "DATA_DIR", "directory holding the registry, keys, logs and payloads", (*stringValue)(&c.DataDir)},
+		{"registry", "REGISTRY_FILE", "table registry file, relative to the data directory", (*stringValue)(&c.RegistryFile)},
+		{"read-timeout", "HTTP_READ_TIMEOUT", "longest time to read a request", (*durationValue)(&c.ReadTimeout)},
+		{"write-timeout", "HTTP_WRITE_TIMEOUT", "longest time to write a response", (*durationValue)(&c.WriteTimeout)},
+		{"idle-timeout", "HTTP_IDLE_TIMEOUT", "how long idle connections are kept open", (*durationValue)(&c.IdleTimeout)},
+		{"shutdown-timeout", "SHUTDOWN_TIMEOUT", "how long shutdown waits for requests and publishes", (*durationValue)(&c.ShutdownTimeout)},
+		{"auth-disabled", "AUTH_DISABLED", "serve every endpoint without API keys", (*boolValue)(&c.AuthDisabled)},
+		{"require-signed-versions", "REQUIRE_SIGNED_VERSIONS", "reject versions without a publisher signature", (*boolValue)(&c.RequireSignedVersions)},
+		{"chain-registry-url", "CHAIN_REGISTRY_URL", "blockchain server mirroring key rotations", (*stringValue)(&c.ChainRegistryURL)},
+		{"availability-interval", "AVAILABILITY_INTERVAL", "how often version swarms are checked", (*durationValue)(&c.AvailabilityInterval)},
+		{"republish-interval", "REPUBLISH_INTERVAL", "how often IPNS records are checked for republishing", (*durationValue)(&c.RepublishInterval)},
+
+		{"ipfs-api", "IPFS_API", "IPFS API address", (*stringValue)(&c.IPFS.API)},
+		{"ipfs-max-read-size", "IPFS_MAX_READ_SIZE", "largest snapshot or gateway object read from IPFS, in bytes", (*int64Value)(&c.IPFS.MaxReadSize)},
+		{"pubsub", "ENABLE_PUBSUB", "announce and follow table heads over pubsub", (*boolValue)(&c.IPFS.Pubsub)},
+		{"ipfs-timeout-add", "IPFS_TIMEOUT_ADD", "deadline of IPFS adds", (*durationValue)(&c.IPFS.Timeouts.Add)},
+		{"ipfs-timeout-cat", "IPFS_TIMEOUT_CAT", "deadline of IPFS reads", (*durationValue)(&c.IPFS.Timeouts.Cat)},
+		{"ipfs-timeout-resolve", "IPFS_TIMEOUT_RESOLVE", "deadline of IPNS resolves", (*durationValue)(&c.IPFS.Timeouts.Resolve)},
+		{"ipfs-timeout-publish", "IPFS_TIMEOUT_PUBLISH", "deadline of IPNS publishes", (*durationValue)(&c.IPFS.Timeouts.Publish)},
+		{"ipfs-timeout-keys", "IPFS_TIMEOUT_KEYS", "deadline of keystore calls", (*durationValue)(&c.IPFS.Timeouts.Keys)},
+
+		{"tracker-disabled", "TRACKER_DISABLED", "do not run the embedded tracker", (*boolValue)(&c.Tracker.Disabled)},
+		{"tracker-announce-url", "TRACKER_ANNOUNCE_URL", "public announce URL of the embedded tracker", (*stringValue)(&c.Tracker.AnnounceURL)},
+		{"tracker-udp-addr", "TRACKER_UDP_ADDR", "address the UDP tracker listens on", (*stringValue)(&c.Tracker.UDPAddr)},
+		{"tracker-passkey", "TRACKER_PASSKEY", "passkey announces must carry", (*stringValue)(&c.Tracker.Passkey)},
+		{"trackers", "TORRENT_TRACKERS", "comma-separated trackers torrents also list", (*listValue)(&c.Tracker.Trackers)},
+
+		{"gateway-url", "GATEWAY_URL", "public URL of the gateway, used in web seeds", (*stringValue)(&c.Gateway.URL)},
+		{"gateway-open", "GATEWAY_OPEN", "serve any CID or IPNS name from the gateway", (*boolValue)(&c.Gateway.Open)},
+		{"gateway-allow", "GATEWAY_ALLOW", "comma-separated CIDs and names the gateway also serves", (*listValue)(&c.Gateway.Allow)},
+
+		{"seeding-disabled", "SEEDING_DISABLED", "do not seed uploaded versions", (*boolValue)(&c.Seeding.Disabled)},
+		{"seed-listen-addr", "SEED_LISTEN_ADDR", "address the seeding client listens on", (*stringValue)(&c.Seeding.ListenAddr)},
+		{"seed-keep-versions", "SEED_KEEP_VERSIONS", "seed only the newest versions of each table, 0 for all", (*intValue)(&c.Seeding.KeepVersions)},
+		{"seed-max-age", "SEED_MAX_AGE", "stop seeding versions older than this, 0 for never", (*durationValue)(&c.Seeding.MaxAge)},
+		{"seed-ratio-limit", "SEED_RATIO_LIMIT", "stop seeding past this upload ratio, 0 for never", (*floatValue)(&c.Seeding.RatioLimit)},
+	}
+}
+
+type stringValue string
+
+func (v *stringValue) String() string { return string(*v) }
+
+func (v *stringValue) Set(s string) error {
+	*v = stringValue(s)
+	return nil
+}
+
+type boolValue bool
+
+func (v *boolValue) String() string { return strconv.FormatBool(bool(*v)) }
+
+func (v *boolValue) Set(s string) error {
+	b, err := strconv.ParseBool(s)
+	if err != nil {
+		return err
+	}
+	*v = boolValue(b)
+	return nil
+}
+
+// IsBoolFlag lets boolean flags be given without a value
+func (v *boolValue) IsBoolFlag() bool { return true }
+
+type intValue int
+
+func (v *intValue) String() string { return strconv.Itoa(int(*v)) }
+
+func (v *intValue) Set(s string) error {
+	n, err := strconv.Atoi(s)
+	if err != nil {
+		return err
+	}
+	*v = intValue(n)
+	return nil
+}
+
+type int64Value int64
+
+func (v *int64Value) String() string { return strconv.FormatInt(int64(*v), 10) }
+
+func (v *int64Value) Set(s string) error {
+	n, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return err
+	}
+	*v = int64Value(n)
+	return nil
+}
+
+type floatValue float64
+
+func (v *floatValue) String() string { return strconv.FormatFloat(float64(*v), 'g', -1, 64) }
+
+func (v *floatValue) Set(s string) error {
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return err
+	}
+	*v = floatValue(f)
+	return nil
+}
+
+type durationValue models.Duration
+
+func (v *durationValue) String() string { return time.Duration(*v).String() }
+
+func (v *durationValue) Set(s string) error {
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*v = durationValue(d)
+	return nil
+}
+
+// listValue is a comma-separated list. Setting it replaces the list, so a
+// flag overrides the file instead of adding to it.
+type listValue []string
+
+func (v *listValue) String() string { return strings.Join(*v, ",") }
+
+func (v *listValue) Set(s string) error {
+	var items []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	*v = items
+	return nil
+}
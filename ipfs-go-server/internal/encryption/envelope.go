@@ -0,0 +1,147 @@
+package encryption
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// ivSize is the AES-CBC IV length used by the Node encryption service
+const ivSize = aes.BlockSize
+
+var ErrBadEnvelope = errors.New("envelope cannot be decrypted with this key")
+
+// Envelope is the hybrid AES/RSA format written by the Node encryption
+// service: the data is encrypted with AES-256-CBC, and the AES key and IV
+// are each encrypted separately with RSA-OAEP (SHA-1, Node's default for
+// crypto.publicEncrypt). All fields are base64.
+type Envelope struct {
+	EncryptedData string `json:"encryptedData"`
+	EncryptedKey  string `json:"encryptedKey"`
+	EncryptedIV   string `json:"encryptedIV"`
+}
+
+// UnmarshalJSON also accepts the ciphertext under "data"
+func (e *Envelope) UnmarshalJSON(b []byte) error {
+	var raw struct {
+		EncryptedData string `json:"encryptedData"`
+		Data          string `json:"data"`
+		EncryptedKey  string `json:"encryptedKey"`
+		EncryptedIV   string `json:"encryptedIV"`
+	}
+	if err := json.Unmarshal(b, &raw); err != nil {
+		return err
+	}
+
+	e.EncryptedData = raw.EncryptedData
+	if e.EncryptedData == "" {
+		e.EncryptedData = raw.Data
+	}
+	e.EncryptedKey = raw.EncryptedKey
+	e.EncryptedIV = raw.EncryptedIV
+	return nil
+}
+
+// SealEnvelope encrypts data under a fresh AES key and IV, both wrapped for
+// the RSA public key
+func SealEnvelope(data []byte, pub *rsa.PublicKey) (*Envelope, error) {
+	key := make([]byte, dataKeySize)
+	iv := make([]byte, ivSize)
+	if _, err := rand.Read(key); err != nil {
+		return nil, err
+	}
+	if _, err := rand.Read(iv); err != nil {
+		return nil, err
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	ciphertext := pad(data, block.BlockSize())
+	cipher.NewCBCEncrypter(block, iv).CryptBlocks(ciphertext, ciphertext)
+
+	encryptedKey, err := rsa.EncryptOAEP(sha1.New(), rand.Reader, pub, key, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encrypt AES key: %w", err)
+	}
+	encryptedIV, err := rsa.EncryptOAEP(sha1.New(), rand.Reader, pub, iv, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encrypt IV: %w", err)
+	}
+
+	return &Envelope{
+		EncryptedData: base64.StdEncoding.EncodeToString(ciphertext),
+		EncryptedKey:  base64.StdEncoding.EncodeToString(encryptedKey),
+		EncryptedIV:   base64.StdEncoding.EncodeToString(encryptedIV),
+	}, nil
+}
+
+// OpenEnvelope decrypts an envelope with the RSA private key it was sealed for
+func OpenEnvelope(env *Envelope, priv *rsa.PrivateKey) ([]byte, error) {
+	encryptedKey, err := base64.StdEncoding.DecodeString(env.EncryptedKey)
+	if err != nil {
+		return nil, fmt.Errorf("invalid encryptedKey: %w", err)
+	}
+	encryptedIV, err := base64.StdEncoding.DecodeString(env.EncryptedIV)
+	if err != nil {
+		return nil, fmt.Errorf("invalid encryptedIV: %w", err)
+	}
+	ciphertext, err := base64.StdEncoding.DecodeString(env.EncryptedData)
+	if err != nil {
+		return nil, fmt.Errorf("invalid encryptedData: %w", err)
+	}
+
+	key, err := rsa.DecryptOAEP(sha1.New(), nil, priv, encryptedKey, nil)
+	if err != nil {
+		return nil, ErrBadEnvelope
+	}
+	iv, err := rsa.DecryptOAEP(sha1.New(), nil, priv, encryptedIV, nil)
+	if err != nil || len(iv) != ivSize {
+		return nil, ErrBadEnvelope
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, ErrBadEnvelope
+	}
+	return decryptCBC(block, iv, ciphertext)
+}
+
+// decryptCBC decrypts and unpads CBC ciphertext
+func decryptCBC(block cipher.Block, iv, ciphertext []byte) ([]byte, error) {
+	size := block.BlockSize()
+	if len(ciphertext) == 0 || len(ciphertext)%size != 0 {
+		return nil, ErrBadEnvelope
+	}
+
+	plaintext := make([]byte, len(ciphertext))
+	cipher.NewCBCDecrypter(block, iv).CryptBlocks(plaintext, ciphertext)
+	return unpad(plaintext, size)
+}
+
+// pad applies PKCS#7 padding, returning a new slice
+func pad(data []byte, size int) []byte {
+	n := size - len(data)%size
+	return append(append([]byte(nil), data...), bytes.Repeat([]byte{byte(n)}, n)...)
+}
+
+func unpad(data []byte, size int) ([]byte, error) {
+	n := int(data[len(data)-1])
+	if n == 0 || n > size || n > len(data) {
+		return nil, ErrBadEnvelope
+	}
+	for _, b := range data[len(data)-n:] {
+		if int(b) != n {
+			return nil, ErrBadEnvelope
+		}
+	}
+	return data[:len(data)-n], nil
+}
@@ -0,0 +1,180 @@
+package encryption
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+func readFixture(t *testing.T, name string) []byte {
+	t.Helper()
+
+	data, err := os.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+// envelopeKey is the RSA key the Node fixtures were sealed for
+func envelopeKey(t *testing.T) *rsa.PrivateKey {
+	t.Helper()
+
+	block, _ := pem.Decode(readFixture(t, "envelope_key.pem"))
+	if block == nil {
+		t.Fatal("envelope_key.pem is not PEM encoded")
+	}
+	key, err := parsePrivateKey(block)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+func readEnvelope(t *testing.T, name string) *Envelope {
+	t.Helper()
+
+	var env Envelope
+	if err := json.Unmarshal(readFixture(t, name), &env); err != nil {
+		t.Fatal(err)
+	}
+	return &env
+}
+
+func TestOpenNodeEnvelope(t *testing.T) {
+	key := envelopeKey(t)
+	tests := []struct{ envelope, plaintext string }{
+		{"envelope.json", "plaintext.txt"},
+		{"envelope_smime.json", "smime_message.pem"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.envelope, func(t *testing.T) {
+			got, err := OpenEnvelope(readEnvelope(t, tt.envelope), key)
+			if err != nil {
+				t.Fatalf("OpenEnvelope: %v", err)
+			}
+			if want := readFixture(t, tt.plaintext); !bytes.Equal(got, want) {
+				t.Fatalf("OpenEnvelope = %q, want %q", got, want)
+			}
+		})
+	}
+}
+
+func TestOpenEnvelopeAcceptsDataField(t *testing.T) {
+	env := readEnvelope(t, "envelope.json")
+	data, _ := json.Marshal(map[string]string{
+		"data":         env.EncryptedData,
+		"encryptedKey": env.EncryptedKey,
+		"encryptedIV":  env.EncryptedIV,
+	})
+
+	var aliased Envelope
+	if err := json.Unmarshal(data, &aliased); err != nil {
+		t.Fatal(err)
+	}
+	got, err := OpenEnvelope(&aliased, envelopeKey(t))
+	if err != nil {
+		t.Fatalf("OpenEnvelope: %v", err)
+	}
+	if want := readFixture(t, "plaintext.txt"); !bytes.Equal(got, want) {
+		t.Fatalf("OpenEnvelope = %q, want %q", got, want)
+	}
+}
+
+func TestOpenEnvelopeWithAnotherKey(t *testing.T) {
+	other, err := rsa.GenerateKey(rand.Reader, rsaKeyBits)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := OpenEnvelope(readEnvelope(t, "envelope.json"), other); !errors.Is(err, ErrBadEnvelope) {
+		t.Fatalf("OpenEnvelope with another key = %v, want ErrBadEnvelope", err)
+	}
+}
+
+// TestSealEnvelopeRoundTrip decrypts a Node envelope and encrypts the
+// content again for the same key
+func TestSealEnvelopeRoundTrip(t *testing.T) {
+	key := envelopeKey(t)
+	plaintext, err := OpenEnvelope(readEnvelope(t, "envelope.json"), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	env, err := SealEnvelope(plaintext, &key.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := json.Marshal(env)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var fields map[string]string
+	json.Unmarshal(data, &fields)
+	for _, field := range []string{"encryptedData", "encryptedKey", "encryptedIV"} {
+		if fields[field] == "" {
+			t.Fatalf("sealed envelope has no %s: %s", field, data)
+		}
+	}
+
+	var decoded Envelope
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	got, err := OpenEnvelope(&decoded, key)
+	if err != nil {
+		t.Fatalf("OpenEnvelope: %v", err)
+	}
+	if !bytes.Equal(got, plaintext) {
+		t.Fatalf("OpenEnvelope = %q, want %q", got, plaintext)
+	}
+}
+
+// decryptWithAES is the Node service's decryption, run against envelopes
+// sealed here
+const decryptWithAES = `
+const crypto = require('crypto');
+const fs = require('fs');
+const rsaPrivateKey = fs.readFileSync(process.argv[1], 'utf8');
+const encryptedPackage = JSON.parse(fs.readFileSync(process.argv[2], 'utf8'));
+const aesKey = crypto.privateDecrypt(rsaPrivateKey, Buffer.from(encryptedPackage.encryptedKey, 'base64'));
+const iv = crypto.privateDecrypt(rsaPrivateKey, Buffer.from(encryptedPackage.encryptedIV, 'base64'));
+const decipher = crypto.createDecipheriv('aes-256-cbc', aesKey, iv);
+let decrypted = decipher.update(encryptedPackage.encryptedData, 'base64', 'utf8');
+decrypted += decipher.final('utf8');
+process.stdout.write(decrypted);
+`
+
+func TestSealEnvelopeReadableByNode(t *testing.T) {
+	node, err := exec.LookPath("node")
+	if err != nil {
+		t.Skip("node is not installed")
+	}
+
+	key := envelopeKey(t)
+	plaintext := readFixture(t, "plaintext.txt")
+	env, err := SealEnvelope(plaintext, &key.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ := json.MarshalIndent(env, "", "  ")
+	path := filepath.Join(t.TempDir(), "envelope.json")
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	out, err := exec.Command(node, "-e", decryptWithAES, filepath.Join("testdata", "envelope_key.pem"), path).Output()
+	if err != nil {
+		t.Fatalf("node could not decrypt the envelope: %v", err)
+	}
+	if !bytes.Equal(out, plaintext) {
+		t.Fatalf("node decrypted %q, want %q", out, plaintext)
+	}
+}
@@ -0,0 +1,195 @@
+package encryption
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+)
+
+// rsaKeyBits matches the key size used by the Node encryption service
+const rsaKeyBits = 2048
+
+var ErrInvalidPublicKey = errors.New("public key must be a PEM encoded RSA key")
+
+// Recipient is a public key that private table snapshots are wrapped for
+type Recipient struct {
+	ID        string `json:"id"`
+	Name      string `json:"name,omitempty"`
+	PublicKey string `json:"publicKey"` // PEM, SubjectPublicKeyInfo
+
+	key *rsa.PublicKey
+}
+
+// ParseRecipient decodes a PEM public key into a recipient
+func ParseRecipient(name, publicKeyPEM string) (Recipient, error) {
+	key, err := parsePublicKey(publicKeyPEM)
+	if err != nil {
+		return Recipient{}, err
+	}
+	return Recipient{
+		ID:        Fingerprint(key),
+		Name:      name,
+		PublicKey: publicKeyPEM,
+		key:       key,
+	}, nil
+}
+
+// Fingerprint identifies an RSA public key by the hash of its DER encoding
+func Fingerprint(key *rsa.PublicKey) string {
+	der, _ := x509.MarshalPKIXPublicKey(key)
+	sum := sha256.Sum256(der)
+	return hex.EncodeToString(sum[:16])
+}
+
+func parsePublicKey(publicKeyPEM string) (*rsa.PublicKey, error) {
+	block, _ := pem.Decode([]byte(publicKeyPEM))
+	if block == nil {
+		return nil, ErrInvalidPublicKey
+	}
+
+	parsed, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		// Also accept PKCS#1 "RSA PUBLIC KEY" blocks
+		if key, err := x509.ParsePKCS1PublicKey(block.Bytes); err == nil {
+			return key, nil
+		}
+		return nil, fmt.Errorf("%w: %v", ErrInvalidPublicKey, err)
+	}
+	key, ok := parsed.(*rsa.PublicKey)
+	if !ok {
+		return nil, ErrInvalidPublicKey
+	}
+	return key, nil
+}
+
+// Keyring holds this server's private key, which it uses to read private
+// tables it is a recipient of
+type Keyring struct {
+	key       *rsa.PrivateKey
+	recipient Recipient
+}
+
+// LoadOrCreateKeyring reads the private key at path, generating and saving
+// a new one on first use
+func LoadOrCreateKeyring(path string) (*Keyring, error) {
+	key, err := LoadOrCreateKey(path)
+	if err != nil {
+		return nil, err
+	}
+	return newKeyring(key)
+}
+
+// LoadOrCreateKey reads a PEM encoded RSA private key, generating and saving
+// a new one on first use
+func LoadOrCreateKey(path string) (*rsa.PrivateKey, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return createKey(path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read encryption key: %w", err)
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("encryption key %s is not PEM encoded", path)
+	}
+	return parsePrivateKey(block)
+}
+
+// ParseKeyring decodes a PEM RSA private key into a keyring
+func ParseKeyring(data []byte) (*Keyring, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("encryption key is not PEM encoded")
+	}
+	key, err := parsePrivateKey(block)
+	if err != nil {
+		return nil, err
+	}
+	return newKeyring(key)
+}
+
+func createKey(path string) (*rsa.PrivateKey, error) {
+	key, err := rsa.GenerateKey(rand.Reader, rsaKeyBits)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate encryption key: %w", err)
+	}
+
+	data, err := encodePrivateKey(key)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		return nil, fmt.Errorf("failed to save encryption key: %w", err)
+	}
+	return key, nil
+}
+
+func encodePrivateKey(key *rsa.PrivateKey) ([]byte, error) {
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return nil, err
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), nil
+}
+
+// parsePrivateKey decodes a PKCS#8 or PKCS#1 RSA private key. node-forge
+// writes PKCS#1 "RSA PRIVATE KEY" blocks.
+func parsePrivateKey(block *pem.Block) (*rsa.PrivateKey, error) {
+	if block.Type == "RSA PRIVATE KEY" {
+		key, err := x509.ParsePKCS1PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse encryption key: %w", err)
+		}
+		return key, nil
+	}
+
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse encryption key: %w", err)
+	}
+	key, ok := parsed.(*rsa.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("encryption key is not an RSA key")
+	}
+	return key, nil
+}
+
+// PublicKeyPEM encodes an RSA public key as a PEM SubjectPublicKeyInfo
+func PublicKeyPEM(key *rsa.PublicKey) (string, error) {
+	der, err := x509.MarshalPKIXPublicKey(key)
+	if err != nil {
+		return "", err
+	}
+	return string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})), nil
+}
+
+func newKeyring(key *rsa.PrivateKey) (*Keyring, error) {
+	publicPEM, err := PublicKeyPEM(&key.PublicKey)
+	if err != nil {
+		return nil, err
+	}
+
+	recipient, err := ParseRecipient("", publicPEM)
+	if err != nil {
+		return nil, err
+	}
+	return &Keyring{key: key, recipient: recipient}, nil
+}
+
+// Recipient returns this server as a recipient
+func (k *Keyring) Recipient() Recipient {
+	return k.recipient
+}
+
+// PrivateKey returns the RSA private key
+func (k *Keyring) PrivateKey() *rsa.PrivateKey {
+	return k.key
+}
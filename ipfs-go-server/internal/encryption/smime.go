@@ -0,0 +1,353 @@
+package encryption
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/des"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"time"
+)
+
+// Certificate attributes match the Node encryption service
+const (
+	smimeCommonName   = "TrueTorrent"
+	smimeOrganization = "TrueTorrent Inc."
+	smimeValidity     = 365 * 24 * time.Hour
+	pkcs7PEMType      = "PKCS7"
+)
+
+var (
+	oidData          = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 1}
+	oidEnvelopedData = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 3}
+	oidRSAEncryption = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 1, 1}
+	oidAES128CBC     = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 1, 2}
+	oidAES192CBC     = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 1, 22}
+	oidAES256CBC     = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 1, 42}
+	oidDESEDE3CBC    = asn1.ObjectIdentifier{1, 2, 840, 113549, 3, 7}
+)
+
+var (
+	ErrNotSMIME          = errors.New("data is not a PEM encoded PKCS#7 enveloped message")
+	ErrNotSMIMERecipient = errors.New("message is not encrypted for this certificate")
+)
+
+// PKCS#7 EnvelopedData (RFC 2315 section 10), as written by node-forge
+type contentInfo struct {
+	ContentType asn1.ObjectIdentifier
+	Content     asn1.RawValue // [0] EXPLICIT
+}
+
+type envelopedData struct {
+	Version              int
+	RecipientInfos       []recipientInfo `asn1:"set"`
+	EncryptedContentInfo encryptedContentInfo
+}
+
+type recipientInfo struct {
+	Version                int
+	IssuerAndSerialNumber  issuerAndSerial
+	KeyEncryptionAlgorithm pkix.AlgorithmIdentifier
+	EncryptedKey           []byte
+}
+
+type issuerAndSerial struct {
+	Issuer       asn1.RawValue
+	SerialNumber *big.Int
+}
+
+type encryptedContentInfo struct {
+	ContentType                asn1.ObjectIdentifier
+	ContentEncryptionAlgorithm pkix.AlgorithmIdentifier
+	EncryptedContent           asn1.RawValue // [0] IMPLICIT OCTET STRING
+}
+
+// SMIMEIdentity is a self-signed certificate and its private key
+type SMIMEIdentity struct {
+	Certificate *x509.Certificate
+	key         *rsa.PrivateKey
+}
+
+// LoadOrCreateSMIME reads the key and certificate at path, generating them
+// on first use. An expired certificate is renewed for the same key, so
+// messages encrypted for it can still be read.
+func LoadOrCreateSMIME(path string) (*SMIMEIdentity, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		key, err := rsa.GenerateKey(rand.Reader, rsaKeyBits)
+		if err != nil {
+			return nil, fmt.Errorf("failed to generate S/MIME key: %w", err)
+		}
+		return createSMIME(path, key)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read S/MIME key: %w", err)
+	}
+
+	id, err := ParseSMIMEIdentity(data)
+	if err != nil {
+		return nil, err
+	}
+	if time.Now().After(id.Certificate.NotAfter) {
+		return createSMIME(path, id.key)
+	}
+	return id, nil
+}
+
+// ParseSMIMEIdentity decodes a PEM private key and certificate
+func ParseSMIMEIdentity(data []byte) (*SMIMEIdentity, error) {
+	id := &SMIMEIdentity{}
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			break
+		}
+
+		switch block.Type {
+		case "CERTIFICATE":
+			cert, err := x509.ParseCertificate(block.Bytes)
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse S/MIME certificate: %w", err)
+			}
+			id.Certificate = cert
+		case "PRIVATE KEY", "RSA PRIVATE KEY":
+			key, err := parsePrivateKey(block)
+			if err != nil {
+				return nil, err
+			}
+			id.key = key
+		}
+	}
+
+	if id.Certificate == nil || id.key == nil {
+		return nil, fmt.Errorf("S/MIME identity needs both a certificate and a private key")
+	}
+	return id, nil
+}
+
+func createSMIME(path string, key *rsa.PrivateKey) (*SMIMEIdentity, error) {
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 64))
+	if err != nil {
+		return nil, err
+	}
+
+	name := pkix.Name{CommonName: smimeCommonName, Organization: []string{smimeOrganization}}
+	template := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      name,
+		Issuer:       name,
+		NotBefore:    time.Now(),
+		NotAfter:     time.Now().Add(smimeValidity),
+		KeyUsage:     x509.KeyUsageKeyEncipherment | x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageEmailProtection},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create S/MIME certificate: %w", err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, err
+	}
+
+	keyPEM, err := encodePrivateKey(key)
+	if err != nil {
+		return nil, err
+	}
+	data := append(keyPEM, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})...)
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		return nil, fmt.Errorf("failed to save S/MIME key: %w", err)
+	}
+	return &SMIMEIdentity{Certificate: cert, key: key}, nil
+}
+
+// CertificatePEM returns the PEM encoded certificate
+func (id *SMIMEIdentity) CertificatePEM() string {
+	return string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: id.Certificate.Raw}))
+}
+
+// EncryptSMIME wraps content in a PEM PKCS#7 enveloped message for cert,
+// using AES-256-CBC and RSA PKCS#1 v1.5 key transport like node-forge
+func EncryptSMIME(content []byte, cert *x509.Certificate) ([]byte, error) {
+	pub, ok := cert.PublicKey.(*rsa.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("S/MIME certificate does not hold an RSA key")
+	}
+
+	key := make([]byte, dataKeySize)
+	iv := make([]byte, aes.BlockSize)
+	if _, err := rand.Read(key); err != nil {
+		return nil, err
+	}
+	if _, err := rand.Read(iv); err != nil {
+		return nil, err
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	ciphertext := pad(content, block.BlockSize())
+	cipher.NewCBCEncrypter(block, iv).CryptBlocks(ciphertext, ciphertext)
+
+	encryptedKey, err := rsa.EncryptPKCS1v15(rand.Reader, pub, key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encrypt content key: %w", err)
+	}
+	ivParam, err := asn1.Marshal(iv)
+	if err != nil {
+		return nil, err
+	}
+
+	enveloped, err := asn1.Marshal(envelopedData{
+		RecipientInfos: []recipientInfo{{
+			IssuerAndSerialNumber: issuerAndSerial{
+				Issuer:       asn1.RawValue{FullBytes: cert.RawIssuer},
+				SerialNumber: cert.SerialNumber,
+			},
+			KeyEncryptionAlgorithm: pkix.AlgorithmIdentifier{Algorithm: oidRSAEncryption, Parameters: asn1.NullRawValue},
+			EncryptedKey:           encryptedKey,
+		}},
+		EncryptedContentInfo: encryptedContentInfo{
+			ContentType:                oidData,
+			ContentEncryptionAlgorithm: pkix.AlgorithmIdentifier{Algorithm: oidAES256CBC, Parameters: asn1.RawValue{FullBytes: ivParam}},
+			EncryptedContent:           asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, Bytes: ciphertext},
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	der, err := asn1.Marshal(contentInfo{
+		ContentType: oidEnvelopedData,
+		Content:     asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, IsCompound: true, Bytes: enveloped},
+	})
+	if err != nil {
+		return nil, err
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: pkcs7PEMType, Bytes: der}), nil
+}
+
+// DecryptSMIME opens a PEM PKCS#7 enveloped message encrypted for id
+func DecryptSMIME(data []byte, id *SMIMEIdentity) ([]byte, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, ErrNotSMIME
+	}
+
+	var info contentInfo
+	if _, err := asn1.Unmarshal(block.Bytes, &info); err != nil || !info.ContentType.Equal(oidEnvelopedData) {
+		return nil, ErrNotSMIME
+	}
+	if info.Content.Class != asn1.ClassContextSpecific || info.Content.Tag != 0 {
+		return nil, ErrNotSMIME
+	}
+	var env envelopedData
+	if _, err := asn1.Unmarshal(info.Content.Bytes, &env); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrNotSMIME, err)
+	}
+
+	recipient, err := findRecipient(env.RecipientInfos, id.Certificate)
+	if err != nil {
+		return nil, err
+	}
+	if !recipient.KeyEncryptionAlgorithm.Algorithm.Equal(oidRSAEncryption) {
+		return nil, fmt.Errorf("unsupported key encryption algorithm %s", recipient.KeyEncryptionAlgorithm.Algorithm)
+	}
+
+	content := env.EncryptedContentInfo
+	newCipher, keySize, err := contentCipher(content.ContentEncryptionAlgorithm.Algorithm)
+	if err != nil {
+		return nil, err
+	}
+	var iv []byte
+	if _, err := asn1.Unmarshal(content.ContentEncryptionAlgorithm.Parameters.FullBytes, &iv); err != nil {
+		return nil, fmt.Errorf("%w: missing IV", ErrNotSMIME)
+	}
+	ciphertext, err := encryptedContent(content.EncryptedContent)
+	if err != nil {
+		return nil, err
+	}
+
+	// A session key decrypt never reveals whether the RSA padding was valid
+	key := make([]byte, keySize)
+	if _, err := rand.Read(key); err != nil {
+		return nil, err
+	}
+	if err := rsa.DecryptPKCS1v15SessionKey(nil, id.key, recipient.EncryptedKey, key); err != nil {
+		return nil, err
+	}
+
+	c, err := newCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(iv) != c.BlockSize() {
+		return nil, fmt.Errorf("%w: bad IV length", ErrNotSMIME)
+	}
+	return decryptCBC(c, iv, ciphertext)
+}
+
+// findRecipient picks the recipient entry for cert. The Node service always
+// issues serial 01 with the same subject, so a lone recipient is accepted
+// as is, like node-forge does.
+func findRecipient(recipients []recipientInfo, cert *x509.Certificate) (recipientInfo, error) {
+	for _, r := range recipients {
+		if r.IssuerAndSerialNumber.SerialNumber != nil &&
+			r.IssuerAndSerialNumber.SerialNumber.Cmp(cert.SerialNumber) == 0 &&
+			bytes.Equal(r.IssuerAndSerialNumber.Issuer.FullBytes, cert.RawIssuer) {
+			return r, nil
+		}
+	}
+	if len(recipients) == 1 {
+		return recipients[0], nil
+	}
+	return recipientInfo{}, ErrNotSMIMERecipient
+}
+
+func contentCipher(alg asn1.ObjectIdentifier) (func([]byte) (cipher.Block, error), int, error) {
+	switch {
+	case alg.Equal(oidAES256CBC):
+		return aes.NewCipher, 32, nil
+	case alg.Equal(oidAES192CBC):
+		return aes.NewCipher, 24, nil
+	case alg.Equal(oidAES128CBC):
+		return aes.NewCipher, 16, nil
+	case alg.Equal(oidDESEDE3CBC):
+		return des.NewTripleDESCipher, 24, nil
+	}
+	return nil, 0, fmt.Errorf("unsupported content encryption algorithm %s", alg)
+}
+
+// encryptedContent returns the [0] IMPLICIT OCTET STRING, which may be
+// split into constructed segments
+func encryptedContent(raw asn1.RawValue) ([]byte, error) {
+	if raw.Class != asn1.ClassContextSpecific || raw.Tag != 0 {
+		return nil, fmt.Errorf("%w: missing encrypted content", ErrNotSMIME)
+	}
+	if !raw.IsCompound {
+		return raw.Bytes, nil
+	}
+
+	var out []byte
+	rest := raw.Bytes
+	for len(rest) > 0 {
+		var segment []byte
+		var err error
+		if rest, err = asn1.Unmarshal(rest, &segment); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrNotSMIME, err)
+		}
+		out = append(out, segment...)
+	}
+	return out, nil
+}
@@ -0,0 +1,118 @@
+package encryption
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+func smimeIdentity(t *testing.T) *SMIMEIdentity {
+	t.Helper()
+
+	id, err := ParseSMIMEIdentity(readFixture(t, "smime_identity.pem"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return id
+}
+
+// The S/MIME layer holds the base64 of the file, like the Node service
+// writes it
+func decodeSMIME(t *testing.T, message []byte, id *SMIMEIdentity) []byte {
+	t.Helper()
+
+	content, err := DecryptSMIME(message, id)
+	if err != nil {
+		t.Fatalf("DecryptSMIME: %v", err)
+	}
+	decoded, err := base64.StdEncoding.DecodeString(string(content))
+	if err != nil {
+		t.Fatalf("S/MIME content is not base64: %v", err)
+	}
+	return decoded
+}
+
+func TestDecryptSMIMEFixture(t *testing.T) {
+	got := decodeSMIME(t, readFixture(t, "smime_message.pem"), smimeIdentity(t))
+	if want := readFixture(t, "plaintext.txt"); !bytes.Equal(got, want) {
+		t.Fatalf("DecryptSMIME = %q, want %q", got, want)
+	}
+}
+
+// TestDecryptSMIMEInsideEnvelope undoes /encrypt/smime followed by
+// /encrypt/aes
+func TestDecryptSMIMEInsideEnvelope(t *testing.T) {
+	message, err := OpenEnvelope(readEnvelope(t, "envelope_smime.json"), envelopeKey(t))
+	if err != nil {
+		t.Fatalf("OpenEnvelope: %v", err)
+	}
+	got := decodeSMIME(t, message, smimeIdentity(t))
+	if want := readFixture(t, "plaintext.txt"); !bytes.Equal(got, want) {
+		t.Fatalf("decrypted %q, want %q", got, want)
+	}
+}
+
+func TestDecryptSMIMERejectsOtherData(t *testing.T) {
+	id := smimeIdentity(t)
+	for _, data := range [][]byte{readFixture(t, "plaintext.txt"), readFixture(t, "smime_identity.pem")} {
+		if _, err := DecryptSMIME(data, id); !errors.Is(err, ErrNotSMIME) {
+			t.Fatalf("DecryptSMIME = %v, want ErrNotSMIME", err)
+		}
+	}
+}
+
+// TestEncryptSMIMERoundTrip decrypts the fixture and encrypts its content
+// again for the same certificate
+func TestEncryptSMIMERoundTrip(t *testing.T) {
+	id := smimeIdentity(t)
+	content, err := DecryptSMIME(readFixture(t, "smime_message.pem"), id)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	message, err := EncryptSMIME(content, id.Certificate)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.HasPrefix(message, []byte("-----BEGIN PKCS7-----")) {
+		t.Fatalf("EncryptSMIME did not write a PEM PKCS7 message: %.40q", message)
+	}
+	got, err := DecryptSMIME(message, id)
+	if err != nil {
+		t.Fatalf("DecryptSMIME: %v", err)
+	}
+	if !bytes.Equal(got, content) {
+		t.Fatalf("DecryptSMIME = %q, want %q", got, content)
+	}
+}
+
+func TestEncryptSMIMEReadableByOpenSSL(t *testing.T) {
+	openssl, err := exec.LookPath("openssl")
+	if err != nil {
+		t.Skip("openssl is not installed")
+	}
+
+	id := smimeIdentity(t)
+	content := []byte(base64.StdEncoding.EncodeToString(readFixture(t, "plaintext.txt")))
+	message, err := EncryptSMIME(content, id.Certificate)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "message.pem")
+	if err := os.WriteFile(path, message, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	identity := filepath.Join("testdata", "smime_identity.pem")
+	out, err := exec.Command(openssl, "smime", "-decrypt", "-binary", "-inform", "PEM", "-in", path, "-recip", identity, "-inkey", identity).Output()
+	if err != nil {
+		t.Fatalf("openssl could not decrypt the message: %v", err)
+	}
+	if !bytes.Equal(out, content) {
+		t.Fatalf("openssl decrypted %q, want %q", out, content)
+	}
+}
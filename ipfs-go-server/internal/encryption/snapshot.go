@@ -0,0 +1,212 @@
+package encryption
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+const (
+	// SnapshotType marks an encrypted table snapshot in IPFS
+	SnapshotType    = "encrypted-table"
+	snapshotVersion = 1
+
+	dataAlgorithm = "AES-256-GCM"
+	keyAlgorithm  = "RSA-OAEP-256"
+	dataKeySize   = 32
+)
+
+var (
+	ErrNotRecipient = errors.New("this server is not a recipient of the table")
+	ErrNoRecipients = errors.New("a private table needs at least one recipient")
+	ErrCorrupt      = errors.New("encrypted snapshot is corrupt or was tampered with")
+)
+
+// WrappedKey is a table's data key encrypted for one recipient
+type WrappedKey struct {
+	Recipient
+	WrappedKey string `json:"wrappedKey"`
+}
+
+// SealedSnapshot is what a private table publishes instead of its JSON.
+// The table ID is authenticated, so a snapshot cannot be passed off as
+// another table's.
+type SealedSnapshot struct {
+	Type         string       `json:"type"`
+	Version      int          `json:"version"`
+	TableID      string       `json:"tableId"`
+	Algorithm    string       `json:"algorithm"`
+	KeyAlgorithm string       `json:"keyAlgorithm"`
+	Nonce        string       `json:"nonce"`
+	Ciphertext   string       `json:"ciphertext"`
+	Recipients   []WrappedKey `json:"recipients"`
+}
+
+// TableKey is the data key of a private table and who it is shared with
+type TableKey struct {
+	dataKey    []byte
+	recipients []Recipient
+}
+
+// NewTableKey generates a data key shared with the given recipients
+func NewTableKey(recipients []Recipient) (*TableKey, error) {
+	if len(recipients) == 0 {
+		return nil, ErrNoRecipients
+	}
+
+	dataKey := make([]byte, dataKeySize)
+	if _, err := rand.Read(dataKey); err != nil {
+		return nil, err
+	}
+	return &TableKey{dataKey: dataKey, recipients: UniqueRecipients(recipients)}, nil
+}
+
+// Recipients returns who the data key is wrapped for
+func (k *TableKey) Recipients() []Recipient {
+	return append([]Recipient(nil), k.recipients...)
+}
+
+// WithRecipients returns a key for a new recipient list. Removing anyone
+// generates a fresh data key, since the removed recipient already holds the
+// old one; adding recipients keeps it.
+func (k *TableKey) WithRecipients(recipients []Recipient) (*TableKey, error) {
+	recipients = UniqueRecipients(recipients)
+	if len(recipients) == 0 {
+		return nil, ErrNoRecipients
+	}
+
+	keep := make(map[string]bool, len(recipients))
+	for _, r := range recipients {
+		keep[r.ID] = true
+	}
+	for _, r := range k.recipients {
+		if !keep[r.ID] {
+			return NewTableKey(recipients)
+		}
+	}
+	return &TableKey{dataKey: k.dataKey, recipients: recipients}, nil
+}
+
+// Seal encrypts a table snapshot and wraps the data key for every recipient
+func (k *TableKey) Seal(tableID string, plaintext []byte) ([]byte, error) {
+	gcm, err := newGCM(k.dataKey)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+
+	sealed := SealedSnapshot{
+		Type:         SnapshotType,
+		Version:      snapshotVersion,
+		TableID:      tableID,
+		Algorithm:    dataAlgorithm,
+		KeyAlgorithm: keyAlgorithm,
+		Nonce:        base64.StdEncoding.EncodeToString(nonce),
+		Ciphertext:   base64.StdEncoding.EncodeToString(gcm.Seal(nil, nonce, plaintext, []byte(tableID))),
+	}
+
+	for _, r := range k.recipients {
+		if r.key == nil {
+			if r, err = ParseRecipient(r.Name, r.PublicKey); err != nil {
+				return nil, err
+			}
+		}
+		wrapped, err := rsa.EncryptOAEP(sha256.New(), rand.Reader, r.key, k.dataKey, []byte(tableID))
+		if err != nil {
+			return nil, fmt.Errorf("failed to wrap data key for %s: %w", r.ID, err)
+		}
+		sealed.Recipients = append(sealed.Recipients, WrappedKey{
+			Recipient:  r,
+			WrappedKey: base64.StdEncoding.EncodeToString(wrapped),
+		})
+	}
+
+	return json.Marshal(sealed)
+}
+
+// Open decrypts a sealed snapshot with the keyring and returns the table
+// JSON along with the table's key, so later snapshots can be sealed for the
+// same recipients
+func Open(sealed *SealedSnapshot, ring *Keyring) ([]byte, *TableKey, error) {
+	if sealed.Version != snapshotVersion || sealed.Algorithm != dataAlgorithm || sealed.KeyAlgorithm != keyAlgorithm {
+		return nil, nil, fmt.Errorf("unsupported encrypted snapshot (version %d, %s, %s)", sealed.Version, sealed.Algorithm, sealed.KeyAlgorithm)
+	}
+	if ring == nil {
+		return nil, nil, ErrNotRecipient
+	}
+
+	var wrapped string
+	recipients := make([]Recipient, 0, len(sealed.Recipients))
+	for _, w := range sealed.Recipients {
+		recipients = append(recipients, w.Recipient)
+		if w.ID == ring.Recipient().ID {
+			wrapped = w.WrappedKey
+		}
+	}
+	if wrapped == "" {
+		return nil, nil, ErrNotRecipient
+	}
+
+	wrappedBytes, err := base64.StdEncoding.DecodeString(wrapped)
+	if err != nil {
+		return nil, nil, ErrCorrupt
+	}
+	dataKey, err := rsa.DecryptOAEP(sha256.New(), rand.Reader, ring.PrivateKey(), wrappedBytes, []byte(sealed.TableID))
+	if err != nil {
+		return nil, nil, ErrCorrupt
+	}
+
+	nonce, err := base64.StdEncoding.DecodeString(sealed.Nonce)
+	if err != nil {
+		return nil, nil, ErrCorrupt
+	}
+	ciphertext, err := base64.StdEncoding.DecodeString(sealed.Ciphertext)
+	if err != nil {
+		return nil, nil, ErrCorrupt
+	}
+
+	gcm, err := newGCM(dataKey)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(nonce) != gcm.NonceSize() {
+		return nil, nil, ErrCorrupt
+	}
+	plaintext, err := gcm.Open(nil, nonce, ciphertext, []byte(sealed.TableID))
+	if err != nil {
+		return nil, nil, ErrCorrupt
+	}
+
+	return plaintext, &TableKey{dataKey: dataKey, recipients: recipients}, nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// UniqueRecipients drops repeated recipients, keeping the first of each
+func UniqueRecipients(recipients []Recipient) []Recipient {
+	seen := make(map[string]bool, len(recipients))
+	out := make([]Recipient, 0, len(recipients))
+	for _, r := range recipients {
+		if seen[r.ID] {
+			continue
+		}
+		seen[r.ID] = true
+		out = append(out, r)
+	}
+	return out
+}
@@ -0,0 +1,175 @@
+package encryption
+
+import (
+	"bufio"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Payloads are encrypted in segments so files of any size can be encrypted
+// and decrypted as streams. Each segment is sealed with AES-256-GCM under a
+// nonce made of a random prefix, the segment counter and a flag marking the
+// last segment, so segments cannot be reordered, dropped or truncated.
+const (
+	// PayloadAlgorithm and ContentKeyAlgorithm name the formats in
+	// ContentEncryption
+	PayloadAlgorithm    = "AES-256-GCM-STREAM-64K"
+	ContentKeyAlgorithm = keyAlgorithm
+
+	streamMagic       = "TTS1"
+	streamSegmentSize = 64 << 10
+	streamPrefixSize  = 7
+	streamTagSize     = 16
+)
+
+var ErrBadPayload = errors.New("payload is corrupt, truncated or was encrypted with another key")
+
+// NewContentKey generates a fresh payload key
+func NewContentKey() ([]byte, error) {
+	key := make([]byte, dataKeySize)
+	if _, err := rand.Read(key); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// EncryptedSize returns the size of a payload of size bytes once encrypted
+func EncryptedSize(size int64) int64 {
+	segments := size / streamSegmentSize
+	if size%streamSegmentSize != 0 || size == 0 {
+		segments++
+	}
+	return int64(len(streamMagic)+streamPrefixSize) + size + segments*streamTagSize
+}
+
+// EncryptStream encrypts src into dst under key
+func EncryptStream(dst io.Writer, src io.Reader, key []byte) error {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return err
+	}
+
+	prefix := make([]byte, streamPrefixSize)
+	if _, err := rand.Read(prefix); err != nil {
+		return err
+	}
+	if _, err := dst.Write(append([]byte(streamMagic), prefix...)); err != nil {
+		return err
+	}
+
+	in := bufio.NewReaderSize(src, streamSegmentSize)
+	buf := make([]byte, streamSegmentSize)
+	sealed := make([]byte, 0, streamSegmentSize+streamTagSize)
+	for counter := uint32(0); ; counter++ {
+		n, err := io.ReadFull(in, buf)
+		if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+			return err
+		}
+		last := err != nil
+		if !last {
+			// A full segment is the last one if nothing follows it
+			if _, err := in.Peek(1); err == io.EOF {
+				last = true
+			}
+		}
+
+		sealed = gcm.Seal(sealed[:0], segmentNonce(prefix, counter, last), buf[:n], nil)
+		if _, err := dst.Write(sealed); err != nil {
+			return err
+		}
+		if last {
+			return nil
+		}
+		if counter == ^uint32(0) {
+			return fmt.Errorf("payload is too large to encrypt")
+		}
+	}
+}
+
+// DecryptStream decrypts a payload written by EncryptStream
+func DecryptStream(dst io.Writer, src io.Reader, key []byte) error {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return err
+	}
+
+	header := make([]byte, len(streamMagic)+streamPrefixSize)
+	if _, err := io.ReadFull(src, header); err != nil || string(header[:len(streamMagic)]) != streamMagic {
+		return ErrBadPayload
+	}
+	prefix := header[len(streamMagic):]
+
+	in := bufio.NewReaderSize(src, streamSegmentSize+streamTagSize)
+	buf := make([]byte, streamSegmentSize+streamTagSize)
+	plain := make([]byte, 0, streamSegmentSize)
+	for counter := uint32(0); ; counter++ {
+		n, err := io.ReadFull(in, buf)
+		if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+			return err
+		}
+		last := err != nil
+		if !last {
+			if _, err := in.Peek(1); err == io.EOF {
+				last = true
+			}
+		}
+
+		plain, err = gcm.Open(plain[:0], segmentNonce(prefix, counter, last), buf[:n], nil)
+		if err != nil {
+			return ErrBadPayload
+		}
+		if _, err := dst.Write(plain); err != nil {
+			return err
+		}
+		if last {
+			return nil
+		}
+	}
+}
+
+func segmentNonce(prefix []byte, counter uint32, last bool) []byte {
+	nonce := make([]byte, 0, streamPrefixSize+5)
+	nonce = append(nonce, prefix...)
+	nonce = binary.BigEndian.AppendUint32(nonce, counter)
+	if last {
+		return append(nonce, 1)
+	}
+	return append(nonce, 0)
+}
+
+// WrapContentKey encrypts a payload key for a recipient with RSA-OAEP-256.
+// The label binds the wrapped key to one payload, usually its info hash.
+func WrapContentKey(key []byte, r Recipient, label string) (string, error) {
+	if r.key == nil {
+		parsed, err := ParseRecipient(r.Name, r.PublicKey)
+		if err != nil {
+			return "", err
+		}
+		r = parsed
+	}
+
+	wrapped, err := rsa.EncryptOAEP(sha256.New(), rand.Reader, r.key, key, []byte(label))
+	if err != nil {
+		return "", fmt.Errorf("failed to wrap content key for %s: %w", r.ID, err)
+	}
+	return base64.StdEncoding.EncodeToString(wrapped), nil
+}
+
+// UnwrapContentKey decrypts a payload key wrapped by WrapContentKey
+func UnwrapContentKey(wrapped string, priv *rsa.PrivateKey, label string) ([]byte, error) {
+	data, err := base64.StdEncoding.DecodeString(wrapped)
+	if err != nil {
+		return nil, ErrBadPayload
+	}
+	key, err := rsa.DecryptOAEP(sha256.New(), nil, priv, data, []byte(label))
+	if err != nil || len(key) != dataKeySize {
+		return nil, ErrBadPayload
+	}
+	return key, nil
+}
@@ -0,0 +1,62 @@
+// Writes the envelope fixtures with the hybrid AES/RSA code of
+// main_backend/encryption.js, copied as is so it runs without the service's
+// dependencies. Run from this directory: node gen_envelopes.js
+const crypto = require('crypto');
+const fs = require('fs');
+
+function generateAESKeyPair() {
+    const aesKey = crypto.randomBytes(32); // 256-bit key
+    const iv = crypto.randomBytes(16); // 128-bit IV
+
+    const { publicKey, privateKey } = crypto.generateKeyPairSync('rsa', {
+        modulusLength: 2048,
+        publicKeyEncoding: {
+            type: 'spki',
+            format: 'pem'
+        },
+        privateKeyEncoding: {
+            type: 'pkcs8',
+            format: 'pem'
+        }
+    });
+
+    return {
+        aesKey,
+        iv,
+        rsaPublicKey: publicKey,
+        rsaPrivateKey: privateKey
+    };
+}
+
+function encryptWithAES(data, aesKey, iv, rsaPublicKey) {
+    try {
+        // Encrypt data with AES
+        const cipher = crypto.createCipheriv('aes-256-cbc', aesKey, iv);
+
+        let encrypted = cipher.update(data, 'utf8', 'base64');
+        encrypted += cipher.final('base64');
+
+        // Encrypt AES key with RSA
+        const encryptedAESKey = crypto.publicEncrypt(rsaPublicKey, aesKey);
+        const encryptedIV = crypto.publicEncrypt(rsaPublicKey, iv);
+
+        return {
+            encryptedData: encrypted,
+            encryptedKey: encryptedAESKey.toString('base64'),
+            encryptedIV: encryptedIV.toString('base64')
+        };
+    } catch (error) {
+        throw new Error(`AES encryption failed: ${error.message}`);
+    }
+}
+
+const aesKeys = generateAESKeyPair();
+fs.writeFileSync('envelope_key.pem', aesKeys.rsaPrivateKey);
+
+// What POST /encrypt/aes writes for a text file, and for the S/MIME message
+// POST /encrypt/smime wrote before it
+for (const [input, output] of [['plaintext.txt', 'envelope.json'], ['smime_message.pem', 'envelope_smime.json']]) {
+    const fileContent = fs.readFileSync(input, 'utf8');
+    const encryptedPackage = encryptWithAES(fileContent, aesKeys.aesKey, aesKeys.iv, aesKeys.rsaPublicKey);
+    fs.writeFileSync(output, JSON.stringify(encryptedPackage, null, 2));
+}
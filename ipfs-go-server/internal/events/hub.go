@@ -0,0 +1,145 @@
+package events
+
+import (
+	"sync"
+
+	"ipfs-go-server/internal/models"
+	"ipfs-go-server/internal/storage"
+)
+
+// subscriberBuffer is how many events a slow subscriber may fall behind
+// before further events are dropped for it
+const subscriberBuffer = 64
+
+// Message is a table event numbered in the order the hub saw it, so
+// streaming clients can tell when they missed something.
+type Message struct {
+	ID uint64 `json:"id"`
+	storage.Event
+
+	// ACL is the table's ACL when the event happened. Streams check access
+	// against it, since a deleted table's ACL is gone by the time its last
+	// event is sent.
+	ACL models.TableACL `json:"-"`
+}
+
+// Subscription receives the events of the tables it was created for
+type Subscription struct {
+	C <-chan Message
+
+	hub *Hub
+	ch  chan Message
+
+	mu      sync.Mutex
+	tables  map[string]bool // nil means every table
+	dropped uint64
+}
+
+// Hub fans storage events out to streaming subscribers
+type Hub struct {
+	mu   sync.Mutex
+	seq  uint64
+	subs map[*Subscription]struct{}
+}
+
+func NewHub() *Hub {
+	return &Hub{subs: make(map[*Subscription]struct{})}
+}
+
+// Publish delivers an event, and the ACL of its table, to every
+// interested subscriber without blocking
+func (h *Hub) Publish(event storage.Event, acl models.TableACL) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.seq++
+	msg := Message{ID: h.seq, Event: event, ACL: acl}
+
+	for sub := range h.subs {
+		if !sub.Wants(event.TableID) {
+			continue
+		}
+		select {
+		case sub.ch <- msg:
+		default:
+			sub.mu.Lock()
+			sub.dropped++
+			sub.mu.Unlock()
+		}
+	}
+}
+
+// Subscribe returns a subscription to the given tables, or to every table
+// when none are given.
+func (h *Hub) Subscribe(tableIDs ...string) *Subscription {
+	ch := make(chan Message, subscriberBuffer)
+	sub := &Subscription{C: ch, hub: h, ch: ch}
+	if len(tableIDs) > 0 {
+		sub.tables = make(map[string]bool, len(tableIDs))
+		for _, id := range tableIDs {
+			sub.tables[id] = true
+		}
+	}
+
+	h.mu.Lock()
+	h.subs[sub] = struct{}{}
+	h.mu.Unlock()
+	return sub
+}
+
+// Close stops delivery to the subscription
+func (sub *Subscription) Close() {
+	sub.hub.mu.Lock()
+	defer sub.hub.mu.Unlock()
+
+	delete(sub.hub.subs, sub)
+}
+
+// Wants reports whether the subscription receives events for tableID
+func (sub *Subscription) Wants(tableID string) bool {
+	sub.mu.Lock()
+	defer sub.mu.Unlock()
+
+	return sub.tables == nil || sub.tables[tableID]
+}
+
+// Add widens the subscription to more tables. "*" subscribes to every table.
+func (sub *Subscription) Add(tableIDs ...string) {
+	sub.mu.Lock()
+	defer sub.mu.Unlock()
+
+	for _, id := range tableIDs {
+		if id == "*" {
+			sub.tables = nil
+			return
+		}
+		if sub.tables == nil {
+			continue
+		}
+		sub.tables[id] = true
+	}
+}
+
+// Remove narrows the subscription. "*" unsubscribes from every table.
+func (sub *Subscription) Remove(tableIDs ...string) {
+	sub.mu.Lock()
+	defer sub.mu.Unlock()
+
+	for _, id := range tableIDs {
+		if id == "*" {
+			sub.tables = make(map[string]bool)
+			return
+		}
+		if sub.tables != nil {
+			delete(sub.tables, id)
+		}
+	}
+}
+
+// Dropped returns how many events were lost because the subscriber was slow
+func (sub *Subscription) Dropped() uint64 {
+	sub.mu.Lock()
+	defer sub.mu.Unlock()
+
+	return sub.dropped
+}
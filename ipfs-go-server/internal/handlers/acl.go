@@ -0,0 +1,340 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+
+	"ipfs-go-server/internal/audit"
+	"ipfs-go-server/internal/auth"
+	"ipfs-go-server/internal/models"
+)
+
+// tableACLs holds each table's ACL. Guarded by tablesMu.
+var tableACLs = make(map[string]models.TableACL)
+
+// lookupACL returns a copy of a table's ACL
+func lookupACL(id string) models.TableACL {
+	tablesMu.RLock()
+	defer tablesMu.RUnlock()
+
+	return tableACLs[id].Clone()
+}
+
+// setACL replaces a table's ACL
+func setACL(id string, acl models.TableACL) {
+	tablesMu.Lock()
+	defer tablesMu.Unlock()
+
+	tableACLs[id] = acl.Clone()
+}
+
+// changeACL applies change to a table's ACL under one lock, so concurrent
+// grants and revokes do not undo each other. The ACL is left alone when
+// change fails.
+func changeACL(id string, change func(acl *models.TableACL) error) (models.TableACL, error) {
+	tablesMu.Lock()
+	defer tablesMu.Unlock()
+
+	acl := tableACLs[id].Clone()
+	if err := change(&acl); err != nil {
+		return acl, err
+	}
+	tableACLs[id] = acl
+	return acl.Clone(), nil
+}
+
+// convertACLs moves ACLs that refer to API keys by name over to key IDs and
+// returns how many it converted. Names are only matched against the keys
+// that exist now, so a key created later under a revoked key's name does
+// not inherit its access.
+func convertACLs() int {
+	if apiKeys == nil {
+		return 0
+	}
+
+	tablesMu.Lock()
+	defer tablesMu.Unlock()
+
+	converted := 0
+	for id, acl := range tableACLs {
+		if acl.KeyIDs {
+			continue
+		}
+		acl.ToKeyIDs(liveKeyID)
+		tableACLs[id] = acl
+		converted++
+	}
+	return converted
+}
+
+// liveKeyID returns the ID of the API key that holds name now
+func liveKeyID(name string) (string, bool) {
+	id, err := apiKeys.Identity(name)
+	if err != nil {
+		return "", false
+	}
+	return id.KeyID, true
+}
+
+// effectiveRole is the role an identity holds on a table. Keys with
+// tables:admin act as owners of every table. Tables created before
+// ownership existed stay writable by anyone until they are given an owner.
+func effectiveRole(id *auth.Identity, tableID string) models.Role {
+	return roleIn(id, lookupACL(tableID))
+}
+
+// roleIn is the role an identity holds under a table's ACL
+func roleIn(id *auth.Identity, acl models.TableACL) models.Role {
+	if id.HasScope(auth.ScopeAdmin) {
+		return models.RoleOwner
+	}
+	if acl.Unowned() {
+		return models.RoleWriter
+	}
+	return acl.RoleOf(id.Principal())
+}
+
+// Permissions are what an identity may do with a table once both its scopes
+// and its role are taken into account
+type Permissions struct {
+	Role   models.Role `json:"role"`
+	Read   bool        `json:"read"`
+	Write  bool        `json:"write"`
+	Delete bool        `json:"delete"`
+	Manage bool        `json:"manage"` // grant and revoke access
+}
+
+func permissionsFor(id *auth.Identity, tableID string) Permissions {
+	role := effectiveRole(id, tableID)
+	owner := role.Includes(models.RoleOwner) && id.HasScope(auth.ScopeWrite)
+	return Permissions{
+		Role:   role,
+		Read:   role.Includes(models.RoleReader) && id.HasScope(auth.ScopeRead),
+		Write:  role.Includes(models.RoleWriter) && id.HasScope(auth.ScopeWrite),
+		Delete: owner,
+		Manage: owner,
+	}
+}
+
+// canAccess reports whether the caller holds at least role on a table
+func canAccess(r *http.Request, tableID string, role models.Role) bool {
+	id, ok := auth.FromContext(r.Context())
+	if !ok {
+		return false
+	}
+	return effectiveRole(id, tableID).Includes(role)
+}
+
+// authorizeTable writes a 403 response and returns false unless the caller
+// holds at least role on the table
+func authorizeTable(w http.ResponseWriter, r *http.Request, tableID string, role models.Role) bool {
+	if canAccess(r, tableID, role) {
+		return true
+	}
+
+	log.Printf("[ACL] %s denied %s access to table %s", auth.Actor(r.Context()), role, tableID)
+	http.Error(w, "You do not have "+string(role)+" access to this table", http.StatusForbidden)
+	return false
+}
+
+// ownedBy returns the ACL of a table created by the caller
+func ownedBy(r *http.Request) models.TableACL {
+	owner := auth.Anonymous.Principal()
+	if id, ok := auth.FromContext(r.Context()); ok {
+		owner = id.Principal()
+	}
+	return models.TableACL{Owner: owner, KeyIDs: true}
+}
+
+func getACLHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		tableID := requestTableID(r)
+		log.Printf("[ACL] Get handler called for ID: %s", tableID)
+
+		if _, exists := lookupTable(tableID); !exists {
+			http.Error(w, "Table not found", http.StatusNotFound)
+			return
+		}
+		if !authorizeTable(w, r, tableID, models.RoleReader) {
+			return
+		}
+
+		// Owners may check what another key can do
+		id, _ := auth.FromContext(r.Context())
+		if principal := r.URL.Query().Get("principal"); principal != "" && principal != id.Name && principal != id.KeyID {
+			if !authorizeTable(w, r, tableID, models.RoleOwner) {
+				return
+			}
+			other, err := principalIdentity(principal)
+			if err != nil {
+				http.Error(w, "API key not found", http.StatusNotFound)
+				return
+			}
+			id = other
+		}
+
+		acl := lookupACL(tableID)
+		response := map[string]interface{}{
+			"id":          tableID,
+			"acl":         acl,
+			"keyNames":    keyNames(acl),
+			"principal":   id.Principal(),
+			"name":        id.Name,
+			"permissions": permissionsFor(id, tableID),
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(response)
+	}
+}
+
+// principalIdentity looks up the identity behind an API key ID or name
+func principalIdentity(principal string) (*auth.Identity, error) {
+	if apiKeys == nil {
+		return nil, auth.ErrNotFound
+	}
+	if id, err := apiKeys.IdentityByID(principal); err == nil {
+		return id, nil
+	}
+	return apiKeys.Identity(principal)
+}
+
+// keyNames maps the key IDs in an ACL to the names of the live keys
+// holding them
+func keyNames(acl models.TableACL) map[string]string {
+	names := make(map[string]string)
+	if apiKeys == nil {
+		return names
+	}
+	principals := append([]string{acl.Owner}, acl.Writers...)
+	for _, principal := range append(principals, acl.Readers...) {
+		if id, err := apiKeys.IdentityByID(principal); err == nil {
+			names[principal] = id.Name
+		}
+	}
+	return names
+}
+
+// errOwnerRevoke is returned when a revocation names the table's owner
+var errOwnerRevoke = errors.New("owner cannot be revoked")
+
+// aclRequest is the body of grant and revoke requests. Principal may be a
+// key's name or ID; the ACL stores the ID.
+type aclRequest struct {
+	Principal string      `json:"principal"`
+	Role      models.Role `json:"role"`
+}
+
+func grantHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		tableID := requestTableID(r)
+		log.Printf("[ACL] Grant handler called for ID: %s by %s", tableID, auth.Actor(r.Context()))
+
+		req, ok := decodeACLRequest(w, r, tableID, false)
+		if !ok {
+			return
+		}
+
+		acl, err := changeACL(tableID, func(acl *models.TableACL) error {
+			return acl.Grant(req.Principal, req.Role)
+		})
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		updateACL(w, tableID, acl)
+		log.Printf("[ACL] Granted %s on table %s to %s", req.Role, tableID, req.Principal)
+
+		entry := newAuditEntry(r, audit.ActionGrant, tableID)
+		entry.Detail = fmt.Sprintf("%s to %s", req.Role, req.Principal)
+		recordAudit(entry)
+	}
+}
+
+func revokeHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		tableID := requestTableID(r)
+		log.Printf("[ACL] Revoke handler called for ID: %s by %s", tableID, auth.Actor(r.Context()))
+
+		req, ok := decodeACLRequest(w, r, tableID, true)
+		if !ok {
+			return
+		}
+
+		acl, err := changeACL(tableID, func(acl *models.TableACL) error {
+			if req.Principal == acl.Owner {
+				return errOwnerRevoke
+			}
+			acl.Revoke(req.Principal)
+			return nil
+		})
+		if err != nil {
+			http.Error(w, "The owner cannot be revoked; grant ownership to another key instead", http.StatusConflict)
+			return
+		}
+		updateACL(w, tableID, acl)
+		log.Printf("[ACL] Revoked access to table %s from %s", tableID, req.Principal)
+
+		entry := newAuditEntry(r, audit.ActionRevoke, tableID)
+		entry.Detail = req.Principal
+		recordAudit(entry)
+	}
+}
+
+// decodeACLRequest checks that the table exists and that the caller may
+// manage it, then parses the request body. Revocations may name a key that
+// no longer exists, to clean up its entries.
+func decodeACLRequest(w http.ResponseWriter, r *http.Request, tableID string, revoke bool) (aclRequest, bool) {
+	var req aclRequest
+
+	if _, exists := lookupTable(tableID); !exists {
+		http.Error(w, "Table not found", http.StatusNotFound)
+		return req, false
+	}
+	if !authorizeTable(w, r, tableID, models.RoleOwner) {
+		return req, false
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		log.Printf("[ACL] Error parsing JSON: %v", err)
+		http.Error(w, "Invalid JSON format", http.StatusBadRequest)
+		return req, false
+	}
+	if req.Principal == "" {
+		http.Error(w, "principal is required", http.StatusBadRequest)
+		return req, false
+	}
+	if req.Principal != models.Everyone && apiKeys != nil {
+		id, err := principalIdentity(req.Principal)
+		if err == nil {
+			req.Principal = id.KeyID
+		} else if !revoke {
+			http.Error(w, "No API key named "+req.Principal, http.StatusNotFound)
+			return req, false
+		}
+	}
+	return req, true
+}
+
+// updateACL persists the registry after an ACL change and responds with
+// the new ACL
+func updateACL(w http.ResponseWriter, tableID string, acl models.TableACL) {
+	if err := saveRegistry(); err != nil {
+		log.Printf("[ACL] Warning: Failed to save registry: %v", err)
+	}
+
+	response := map[string]interface{}{
+		"success":  true,
+		"id":       tableID,
+		"acl":      acl,
+		"keyNames": keyNames(acl),
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(response)
+}
@@ -0,0 +1,114 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"ipfs-go-server/internal/audit"
+	"ipfs-go-server/internal/auth"
+)
+
+const auditFile = "audit.log"
+
+// auditLog records every table mutation; nil until InitializeAudit
+var auditLog *audit.Log
+
+// InitializeAudit opens the audit log
+func InitializeAudit() error {
+	l, err := audit.Open(dataPath(auditFile))
+	if err != nil {
+		return err
+	}
+
+	auditLog = l
+	log.Printf("[AUDIT] Recording mutations to %s", dataPath(auditFile))
+	return nil
+}
+
+// newAuditEntry describes a mutation made by a request. Handlers fill in
+// the snapshot CIDs and pass it to recordAudit once the change is done.
+func newAuditEntry(r *http.Request, action audit.Action, tableID string) audit.Entry {
+	return audit.Entry{
+		Action:    action,
+		TableID:   tableID,
+		Actor:     auth.Actor(r.Context()),
+		SourceIP:  audit.SourceIP(r),
+		RequestID: audit.RequestID(r.Context()),
+	}
+}
+
+// recordAudit writes an entry to the audit log. Failures are logged rather
+// than failing the request, since the mutation has already happened.
+func recordAudit(entry audit.Entry) {
+	if auditLog == nil {
+		return
+	}
+	if _, err := auditLog.Record(entry); err != nil {
+		log.Printf("[AUDIT] ERROR: Failed to record %s of table %s by %s: %v", entry.Action, entry.TableID, entry.Actor, err)
+	}
+}
+
+// headCID returns the snapshot a table currently points to
+func headCID(tableID string) string {
+	stor, exists := lookupTable(tableID)
+	if !exists {
+		return ""
+	}
+	return stor.GetPublishStatus().HeadCID
+}
+
+func auditHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		log.Printf("[AUDIT] Query handler called by %s", auth.Actor(r.Context()))
+		if auditLog == nil {
+			http.Error(w, "Audit log is not enabled", http.StatusServiceUnavailable)
+			return
+		}
+
+		params := r.URL.Query()
+		query := audit.Query{
+			TableID: params.Get("table"),
+			Actor:   params.Get("actor"),
+			Action:  audit.Action(params.Get("action")),
+		}
+
+		var err error
+		if v := params.Get("since"); v != "" {
+			if query.Since, err = time.Parse(time.RFC3339, v); err != nil {
+				http.Error(w, "since must be an RFC 3339 time", http.StatusBadRequest)
+				return
+			}
+		}
+		if v := params.Get("until"); v != "" {
+			if query.Until, err = time.Parse(time.RFC3339, v); err != nil {
+				http.Error(w, "until must be an RFC 3339 time", http.StatusBadRequest)
+				return
+			}
+		}
+		if v := params.Get("limit"); v != "" {
+			if query.Limit, err = strconv.Atoi(v); err != nil || query.Limit < 1 {
+				http.Error(w, "limit must be a positive integer", http.StatusBadRequest)
+				return
+			}
+		}
+
+		entries, err := auditLog.Search(query)
+		if err != nil {
+			log.Printf("[AUDIT] Error searching audit log: %v", err)
+			http.Error(w, "Failed to read audit log: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		response := map[string]interface{}{
+			"entries": entries,
+			"count":   len(entries),
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(response)
+	}
+}
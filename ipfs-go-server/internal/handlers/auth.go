@@ -0,0 +1,205 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+
+	"ipfs-go-server/internal/auth"
+
+	"github.com/gorilla/mux"
+)
+
+const (
+	apiKeysFile        = "api_keys.json"
+	bootstrapTokenFile = "bootstrap_token"
+)
+
+// apiKeys authenticates requests; nil while authentication is disabled
+var apiKeys *auth.Store
+
+// InitializeAuth loads the API keys and turns on authentication. When no
+// keys exist yet, an admin key is created and its token written to
+// bootstrapTokenFile.
+func InitializeAuth() error {
+	store, err := auth.NewStore(dataPath(apiKeysFile))
+	if err != nil {
+		return err
+	}
+
+	if store.Empty() {
+		key, token, err := store.Create("bootstrap", []auth.Scope{auth.ScopeAdmin})
+		if err != nil {
+			return err
+		}
+		// Logs are kept and shipped elsewhere, so the token only goes to a
+		// file readable by the server's user
+		tokenFile := dataPath(bootstrapTokenFile)
+		if err := writeSecret(tokenFile, token+"\n"); err != nil {
+			return fmt.Errorf("failed to write bootstrap token: %w", err)
+		}
+		log.Printf("[AUTH] Created bootstrap admin key %s; its token is in %s, store it and delete the file", key.ID, tokenFile)
+	}
+
+	apiKeys = store
+	log.Printf("[AUTH] Loaded %d API keys", len(store.List()))
+
+	// ACLs written before they held key IDs name keys by name
+	if converted := convertACLs(); converted > 0 {
+		log.Printf("[AUTH] Converted %d table ACLs from key names to key IDs", converted)
+		if err := saveRegistry(); err != nil {
+			return fmt.Errorf("failed to save converted ACLs: %w", err)
+		}
+	}
+	return nil
+}
+
+// protect requires an API key with the given scope for a route
+func protect(scope auth.Scope, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		auth.Require(apiKeys, scope, next)(w, r)
+	}
+}
+
+// protectStream is protect for event streams, which may also pass the API
+// key as ?api_key=
+func protectStream(scope auth.Scope, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		auth.RequireStream(apiKeys, scope, next)(w, r)
+	}
+}
+
+func whoamiHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, _ := auth.FromContext(r.Context())
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(id)
+	}
+}
+
+func requireAuthEnabled(w http.ResponseWriter) bool {
+	if apiKeys == nil {
+		http.Error(w, "Authentication is disabled", http.StatusServiceUnavailable)
+		return false
+	}
+	return true
+}
+
+func listAPIKeysHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !requireAuthEnabled(w) {
+			return
+		}
+
+		keys := apiKeys.List()
+		response := map[string]interface{}{
+			"keys":  keys,
+			"count": len(keys),
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(response)
+	}
+}
+
+func createAPIKeyHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		log.Printf("[API_KEYS] Create handler called by %s", auth.Actor(r.Context()))
+		if !requireAuthEnabled(w) {
+			return
+		}
+
+		var req struct {
+			Name   string       `json:"name"`
+			Scopes []auth.Scope `json:"scopes"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			log.Printf("[API_KEYS] Error parsing JSON: %v", err)
+			http.Error(w, "Invalid JSON format", http.StatusBadRequest)
+			return
+		}
+		if req.Name == "" {
+			http.Error(w, "Key name is required", http.StatusBadRequest)
+			return
+		}
+
+		key, token, err := apiKeys.Create(req.Name, req.Scopes)
+		if err != nil {
+			log.Printf("[API_KEYS] Error creating key: %v", err)
+			if errors.Is(err, auth.ErrUnknownScope) {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			if errors.Is(err, auth.ErrNameTaken) {
+				http.Error(w, err.Error(), http.StatusConflict)
+				return
+			}
+			http.Error(w, "Failed to create API key: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		log.Printf("[API_KEYS] Created key %s (%s) with scopes %v", key.ID, key.Name, key.Scopes)
+
+		key.Hash = ""
+		response := map[string]interface{}{
+			"key":   key,
+			"token": token,
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(response)
+	}
+}
+
+func revokeAPIKeyHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := mux.Vars(r)["id"]
+		log.Printf("[API_KEYS] Revoke handler called for ID: %s by %s", id, auth.Actor(r.Context()))
+		if !requireAuthEnabled(w) {
+			return
+		}
+
+		if err := apiKeys.Revoke(id); err != nil {
+			switch {
+			case errors.Is(err, auth.ErrNotFound):
+				http.Error(w, "API key not found", http.StatusNotFound)
+			case errors.Is(err, auth.ErrLastAdmin):
+				http.Error(w, err.Error(), http.StatusConflict)
+			default:
+				http.Error(w, "Failed to revoke API key: "+err.Error(), http.StatusInternalServerError)
+			}
+			return
+		}
+
+		response := map[string]interface{}{
+			"success": true,
+			"message": "API key revoked successfully",
+			"id":      id,
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(response)
+	}
+}
+
+// writeSecret replaces path with content readable only by the owner
+func writeSecret(path, content string) error {
+	os.Remove(path)
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := f.WriteString(content); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,286 @@
+package handlers
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"log"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"ipfs-go-server/internal/models"
+	"ipfs-go-server/internal/torrent"
+)
+
+const (
+	availabilityWorkers = 8
+	availabilityTimeout = 30 * time.Second // per version, across all its trackers
+)
+
+// availabilityState is what the availability checker reports
+type availabilityState struct {
+	mu        sync.Mutex
+	running   bool
+	interval  time.Duration
+	lastSweep time.Time
+}
+
+var availabilityChecker = &availabilityState{}
+
+// StartAvailabilityChecker asks the trackers of every table version how
+// many seeders and leechers it has, every interval until ctx is cancelled.
+// Results are recorded on the versions; a table is republished when one of
+// its versions becomes alive, dead or unknown.
+func StartAvailabilityChecker(ctx context.Context, interval time.Duration) {
+	availabilityChecker.mu.Lock()
+	availabilityChecker.running = true
+	availabilityChecker.interval = interval
+	availabilityChecker.mu.Unlock()
+
+	log.Printf("[AVAILABILITY] Availability checker started, checking every %s", interval)
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			checkAvailability(ctx)
+
+			select {
+			case <-ctx.Done():
+				availabilityChecker.mu.Lock()
+				availabilityChecker.running = false
+				availabilityChecker.mu.Unlock()
+				log.Printf("[AVAILABILITY] Availability checker stopped")
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// checkAvailability runs one sweep. Each torrent is checked once, however
+// many tables list it.
+func checkAvailability(ctx context.Context) {
+	tables := allTables()
+
+	targets := make(map[string][]string)
+	for _, stor := range tables {
+		var versions []models.TorrentVersion
+		if err := json.Unmarshal([]byte(stor.GetTable().Data), &versions); err != nil {
+			continue
+		}
+		for _, v := range versions {
+			if v.Hash != "" {
+				targets[v.Hash] = appendUnique(targets[v.Hash], versionTrackers(v)...)
+			}
+		}
+	}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	results := make(map[string]models.Availability, len(targets))
+	slots := make(chan struct{}, availabilityWorkers)
+	for hash, trackers := range targets {
+		wg.Add(1)
+		slots <- struct{}{}
+		go func() {
+			defer func() { <-slots; wg.Done() }()
+			result := checkVersion(ctx, hash, trackers)
+			mu.Lock()
+			results[hash] = result
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+	if ctx.Err() != nil {
+		return
+	}
+
+	dead := 0
+	for _, result := range results {
+		if result.Status == models.AvailabilityDead {
+			dead++
+		}
+	}
+
+	for id, stor := range tables {
+		if !stor.RecordAvailability(results) || stor.IsMirror() {
+			continue
+		}
+		goBackground(ctx, func(ctx context.Context) {
+			if _, err := stor.BackgroundSaveToIPFS(ctx); err != nil {
+				log.Printf("[AVAILABILITY] Failed to save availability of table %s: %v", id, err)
+			}
+		})
+	}
+
+	availabilityChecker.mu.Lock()
+	availabilityChecker.lastSweep = time.Now()
+	availabilityChecker.mu.Unlock()
+	log.Printf("[AVAILABILITY] Checked %d torrents, %d dead", len(results), dead)
+}
+
+// checkVersion scrapes every tracker of a torrent and keeps the highest
+// counts, since trackers each see part of the swarm
+func checkVersion(ctx context.Context, hash string, trackers []string) models.Availability {
+	result := models.Availability{Status: models.AvailabilityUnknown, CheckedAt: time.Now().UTC()}
+
+	infoHash, ok := trackerHash(hash)
+	if !ok {
+		result.Error = "hash is not an info hash"
+		return result
+	}
+	if len(trackers) == 0 {
+		result.Error = "no trackers to ask"
+		return result
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, availabilityTimeout)
+	defer cancel()
+
+	var lastErr error
+	for _, tracker := range trackers {
+		scrape, err := torrent.Scrape(ctx, tracker, infoHash)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		result.Trackers++
+		result.Seeders = max(result.Seeders, scrape.Seeders)
+		result.Leechers = max(result.Leechers, scrape.Leechers)
+	}
+
+	switch {
+	case result.Trackers == 0:
+		result.Error = lastErr.Error()
+	case result.Seeders > 0:
+		result.Status = models.AvailabilityAlive
+	default:
+		result.Status = models.AvailabilityDead
+	}
+	return result
+}
+
+// trackerHash is the 20-byte hash trackers know a version by: its v1 info
+// hash, or the truncated v2 hash of a v2-only torrent
+func trackerHash(hash string) ([sha1.Size]byte, bool) {
+	var infoHash [sha1.Size]byte
+	raw, err := hex.DecodeString(hash)
+	if err != nil || (len(raw) != sha1.Size && len(raw) != 32) {
+		return infoHash, false
+	}
+	copy(infoHash[:], raw)
+	return infoHash, true
+}
+
+// versionTrackers are the trackers in a version's magnet link plus the
+// ones this server announces to
+func versionTrackers(v models.TorrentVersion) []string {
+	var trackers []string
+	if magnet, err := url.Parse(v.MagnetLink); err == nil {
+		trackers = append(trackers, magnet.Query()["tr"]...)
+	}
+	for _, tier := range defaultTrackers {
+		trackers = appendUnique(trackers, tier...)
+	}
+	return trackers
+}
+
+func appendUnique(list []string, items ...string) []string {
+	for _, item := range items {
+		found := false
+		for _, existing := range list {
+			if existing == item {
+				found = true
+				break
+			}
+		}
+		if !found {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
+func tableHealthHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := requestTableID(r)
+		log.Printf("[AVAILABILITY] Health handler called for ID: %s", id)
+
+		stor, exists := lookupTable(id)
+		if !exists {
+			http.Error(w, "Table not found", http.StatusNotFound)
+			return
+		}
+		if !authorizeTable(w, r, id, models.RoleReader) {
+			return
+		}
+
+		var versions []models.TorrentVersion
+		if err := json.Unmarshal([]byte(stor.GetTable().Data), &versions); err != nil {
+			http.Error(w, "Table data is not a list of versions: "+err.Error(), http.StatusUnprocessableEntity)
+			return
+		}
+
+		seeder.mu.Lock()
+		client := seeder.client
+		seeder.mu.Unlock()
+
+		status := "ok"
+		results := make([]map[string]interface{}, 0, len(versions))
+		dead := make([]int, 0)
+		for _, v := range versions {
+			if v.Hash == "" {
+				continue
+			}
+			result := map[string]interface{}{
+				"version":    v.Version,
+				"hash":       v.Hash,
+				"fileName":   v.FileName,
+				"status":     models.AvailabilityUnknown,
+				"seededHere": client != nil && client.Torrent(v.Hash) != nil,
+			}
+			if a := v.Availability; a != nil {
+				result["status"] = a.Status
+				result["seeders"] = a.Seeders
+				result["leechers"] = a.Leechers
+				result["trackers"] = a.Trackers
+				result["checkedAt"] = a.CheckedAt
+				if a.Error != "" {
+					result["error"] = a.Error
+				}
+				if a.Status == models.AvailabilityDead {
+					dead = append(dead, v.Version)
+					status = "degraded"
+				}
+			}
+			results = append(results, result)
+		}
+
+		availabilityChecker.mu.Lock()
+		running := availabilityChecker.running
+		interval := availabilityChecker.interval
+		lastSweep := availabilityChecker.lastSweep
+		availabilityChecker.mu.Unlock()
+
+		response := map[string]interface{}{
+			"id":           id,
+			"status":       status,
+			"versions":     results,
+			"deadVersions": dead,
+			"checker": map[string]interface{}{
+				"running":   running,
+				"interval":  interval.String(),
+				"lastSweep": lastSweep,
+			},
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(response)
+	}
+}
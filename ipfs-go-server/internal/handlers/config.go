@@ -0,0 +1,36 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"ipfs-go-server/internal/auth"
+	"ipfs-go-server/internal/config"
+)
+
+// serverConfig is the configuration the server runs with
+var serverConfig = config.Default()
+
+// InitializeConfig keeps the registry and every other file the handlers
+// write in the configured data directory. Call it before the other
+// Initialize functions.
+func InitializeConfig(cfg *config.Config) {
+	serverConfig = cfg
+	persistenceFile = cfg.Path(cfg.RegistryFile)
+}
+
+// dataPath resolves a file name against the data directory
+func dataPath(name string) string {
+	return serverConfig.Path(name)
+}
+
+// configHandler shows the running configuration with secrets masked
+func configHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		log.Printf("[CONFIG] Configuration requested by %s", auth.Actor(r.Context()))
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(serverConfig.Redacted())
+	}
+}
@@ -0,0 +1,211 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+
+	"ipfs-go-server/internal/auth"
+	"ipfs-go-server/internal/encryption"
+	"ipfs-go-server/internal/models"
+	"ipfs-go-server/internal/storage"
+
+	"github.com/gorilla/mux"
+)
+
+const encryptionKeyFile = "encryption_key.pem"
+
+// serverKeyring is this server's recipient key for private tables
+var serverKeyring *encryption.Keyring
+
+// InitializeEncryption loads this server's recipient key, creating it on
+// first start, so private tables can be read and written. It also loads the
+// keys of the file encryption service.
+func InitializeEncryption() error {
+	ring, err := encryption.LoadOrCreateKeyring(dataPath(encryptionKeyFile))
+	if err != nil {
+		return err
+	}
+
+	serverKeyring = ring
+	storage.SetKeyring(ring)
+	log.Printf("[ENCRYPTION] Recipient key %s loaded", ring.Recipient().ID)
+
+	return initializeFileEncryption()
+}
+
+// newTableKey creates the data key of a new private table. This server is
+// always a recipient, otherwise it could not publish later versions.
+func newTableKey(req map[string]interface{}) (*encryption.TableKey, error) {
+	if serverKeyring == nil {
+		return nil, fmt.Errorf("encryption is not enabled on this server")
+	}
+
+	extra, err := parseRecipients(req["recipients"])
+	if err != nil {
+		return nil, err
+	}
+	return encryption.NewTableKey(append([]encryption.Recipient{serverKeyring.Recipient()}, extra...))
+}
+
+// parseRecipients reads a JSON list of {name, publicKey}
+func parseRecipients(raw interface{}) ([]encryption.Recipient, error) {
+	if raw == nil {
+		return nil, nil
+	}
+	list, ok := raw.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("recipients must be a list of {name, publicKey}")
+	}
+
+	recipients := make([]encryption.Recipient, 0, len(list))
+	for _, item := range list {
+		fields, _ := item.(map[string]interface{})
+		r, err := encryption.ParseRecipient(getStringField(fields, "name", ""), getStringField(fields, "publicKey", ""))
+		if err != nil {
+			return nil, err
+		}
+		recipients = append(recipients, r)
+	}
+	return recipients, nil
+}
+
+func serverKeyHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if serverKeyring == nil {
+			http.Error(w, "Encryption is not enabled", http.StatusServiceUnavailable)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(serverKeyring.Recipient())
+	}
+}
+
+// privateTable looks up a private table and checks the caller holds role
+func privateTable(w http.ResponseWriter, r *http.Request, id string, role models.Role) (*storage.Storage, bool) {
+	stor, exists := lookupTable(id)
+	if !exists {
+		http.Error(w, "Table not found", http.StatusNotFound)
+		return nil, false
+	}
+	if !authorizeTable(w, r, id, role) {
+		return nil, false
+	}
+	if !stor.IsPrivate() {
+		http.Error(w, "Table is not private", http.StatusConflict)
+		return nil, false
+	}
+	return stor, true
+}
+
+func listRecipientsHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := requestTableID(r)
+		stor, ok := privateTable(w, r, id, models.RoleReader)
+		if !ok {
+			return
+		}
+
+		recipients := stor.GetRecipients()
+		response := map[string]interface{}{
+			"id":         id,
+			"recipients": recipients,
+			"count":      len(recipients),
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(response)
+	}
+}
+
+func addRecipientHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := requestTableID(r)
+		log.Printf("[ENCRYPTION] Add recipient called for ID: %s by %s", id, auth.Actor(r.Context()))
+
+		stor, ok := privateTable(w, r, id, models.RoleOwner)
+		if !ok || rejectMirror(w, stor) {
+			return
+		}
+
+		var req struct {
+			Name      string `json:"name"`
+			PublicKey string `json:"publicKey"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			log.Printf("[ENCRYPTION] Error parsing JSON: %v", err)
+			http.Error(w, "Invalid JSON format", http.StatusBadRequest)
+			return
+		}
+		recipient, err := encryption.ParseRecipient(req.Name, req.PublicKey)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		updateRecipients(w, r, stor, append(stor.GetRecipients(), recipient))
+		log.Printf("[ENCRYPTION] Table %s now encrypted for %s", id, recipient.ID)
+	}
+}
+
+func removeRecipientHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, recipientID := requestTableID(r), mux.Vars(r)["recipientId"]
+		log.Printf("[ENCRYPTION] Remove recipient %s called for ID: %s by %s", recipientID, id, auth.Actor(r.Context()))
+
+		stor, ok := privateTable(w, r, id, models.RoleOwner)
+		if !ok || rejectMirror(w, stor) {
+			return
+		}
+		if recipientID == serverKeyring.Recipient().ID {
+			http.Error(w, "This server's key cannot be removed; it is needed to publish the table", http.StatusConflict)
+			return
+		}
+
+		current := stor.GetRecipients()
+		remaining := make([]encryption.Recipient, 0, len(current))
+		for _, recipient := range current {
+			if recipient.ID != recipientID {
+				remaining = append(remaining, recipient)
+			}
+		}
+		if len(remaining) == len(current) {
+			http.Error(w, "Recipient not found", http.StatusNotFound)
+			return
+		}
+
+		// Removing a recipient rotates the data key
+		updateRecipients(w, r, stor, remaining)
+		log.Printf("[ENCRYPTION] Table %s no longer encrypted for %s", id, recipientID)
+	}
+}
+
+// updateRecipients republishes the table for the new recipients and
+// responds with them
+func updateRecipients(w http.ResponseWriter, r *http.Request, stor *storage.Storage, recipients []encryption.Recipient) {
+	if err := stor.SetRecipients(r.Context(), recipients); err != nil {
+		log.Printf("[ENCRYPTION] Error updating recipients: %v", err)
+		status := http.StatusInternalServerError
+		if errors.Is(err, encryption.ErrNoRecipients) {
+			status = http.StatusBadRequest
+		}
+		http.Error(w, "Failed to update recipients: "+err.Error(), status)
+		return
+	}
+
+	response := map[string]interface{}{
+		"success":    true,
+		"id":         stor.GetTable().ID,
+		"recipients": stor.GetRecipients(),
+		"headCid":    stor.GetPublishStatus().HeadCID,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(response)
+}
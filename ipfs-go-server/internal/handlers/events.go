@@ -0,0 +1,231 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"ipfs-go-server/internal/auth"
+	"ipfs-go-server/internal/events"
+	"ipfs-go-server/internal/models"
+	"ipfs-go-server/internal/storage"
+
+	"github.com/gorilla/websocket"
+)
+
+const (
+	// streamHeartbeat keeps idle streams from being closed by proxies
+	streamHeartbeat = 15 * time.Second
+	wsWriteTimeout  = 10 * time.Second
+)
+
+// eventHub streams table changes to SSE and WebSocket clients
+var eventHub = events.NewHub()
+
+// streamsClosed ends every stream when the server shuts down, since
+// shutting down waits for open requests
+var (
+	streamsClosed    = make(chan struct{})
+	closeStreamsOnce sync.Once
+)
+
+// CloseStreams ends open SSE and WebSocket streams
+func CloseStreams() {
+	closeStreamsOnce.Do(func() { close(streamsClosed) })
+}
+
+// Events carry the ACL their table had when they happened. Hooks run with
+// the table locked; lookupACL only takes tablesMu, which is never held
+// while waiting for a table.
+func init() {
+	storage.OnChange(func(event storage.Event) {
+		eventHub.Publish(event, lookupACL(event.TableID))
+	})
+}
+
+var upgrader = websocket.Upgrader{
+	// The API is served without origin restrictions; streams are read-only
+	CheckOrigin: func(r *http.Request) bool { return true },
+}
+
+// tableEventsHandler streams one table's events as Server-Sent Events
+func tableEventsHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := requestTableID(r)
+		log.Printf("[EVENTS] SSE stream opened for table %s", id)
+
+		if _, exists := lookupTable(id); !exists {
+			log.Printf("[EVENTS] Table not found: %s", id)
+			http.Error(w, "Table not found", http.StatusNotFound)
+			return
+		}
+		if !authorizeTable(w, r, id, models.RoleReader) {
+			return
+		}
+
+		serveSSE(w, r, eventHub.Subscribe(id))
+		log.Printf("[EVENTS] SSE stream closed for table %s", id)
+	}
+}
+
+// allEventsHandler streams the events of every table the caller can read as
+// Server-Sent Events
+func allEventsHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		log.Printf("[EVENTS] SSE stream opened for all tables")
+		serveSSE(w, r, eventHub.Subscribe())
+		log.Printf("[EVENTS] SSE stream closed for all tables")
+	}
+}
+
+func serveSSE(w http.ResponseWriter, r *http.Request, sub *events.Subscription) {
+	defer sub.Close()
+
+	// Streams outlive the server's write timeout
+	rc := http.NewResponseController(w)
+	rc.SetWriteDeadline(time.Time{})
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.WriteHeader(http.StatusOK)
+
+	fmt.Fprintf(w, "event: ready\ndata: {}\n\n")
+	if err := rc.Flush(); err != nil {
+		log.Printf("[EVENTS] Streaming not supported: %v", err)
+		return
+	}
+
+	heartbeat := time.NewTicker(streamHeartbeat)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-streamsClosed:
+			return
+		case <-heartbeat.C:
+			fmt.Fprintf(w, ": heartbeat\n\n")
+		case msg := <-sub.C:
+			if !canAccessEvent(r, msg) {
+				continue
+			}
+			data, _ := json.Marshal(msg)
+			fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", msg.ID, msg.Type, data)
+		}
+		if err := rc.Flush(); err != nil {
+			return
+		}
+	}
+}
+
+// canAccessEvent reports whether the caller could read the table when the
+// event happened
+func canAccessEvent(r *http.Request, msg events.Message) bool {
+	id, ok := auth.FromContext(r.Context())
+	if !ok {
+		return false
+	}
+	return roleIn(id, msg.ACL).Includes(models.RoleReader)
+}
+
+// wsRequest is sent by WebSocket clients to change what they receive
+type wsRequest struct {
+	Action string   `json:"action"` // "subscribe" or "unsubscribe"
+	Tables []string `json:"tables"` // table IDs, or "*" for every table
+}
+
+// websocketHandler multiplexes the events of any number of tables over one
+// WebSocket. Clients start subscribed to nothing.
+func websocketHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			log.Printf("[EVENTS] WebSocket upgrade failed: %v", err)
+			return
+		}
+		defer conn.Close()
+		log.Printf("[EVENTS] WebSocket opened from %s", r.RemoteAddr)
+
+		sub := eventHub.Subscribe()
+		sub.Remove("*")
+		defer sub.Close()
+
+		// Only this goroutine writes; the reader hands requests over
+		requests := make(chan wsRequest)
+		done := make(chan struct{})
+		quit := make(chan struct{})
+		defer close(quit)
+		go func() {
+			defer close(done)
+			for {
+				var req wsRequest
+				if err := conn.ReadJSON(&req); err != nil {
+					return
+				}
+				select {
+				case requests <- req:
+				case <-quit:
+					return
+				}
+			}
+		}()
+
+		ping := time.NewTicker(streamHeartbeat)
+		defer ping.Stop()
+
+		for {
+			var err error
+			select {
+			case <-done:
+				log.Printf("[EVENTS] WebSocket closed from %s", r.RemoteAddr)
+				return
+			case <-streamsClosed:
+				conn.WriteControl(websocket.CloseMessage,
+					websocket.FormatCloseMessage(websocket.CloseGoingAway, "server shutting down"),
+					time.Now().Add(wsWriteTimeout))
+				return
+			case req := <-requests:
+				err = handleWSRequest(conn, sub, req)
+			case msg := <-sub.C:
+				if !canAccessEvent(r, msg) {
+					continue
+				}
+				conn.SetWriteDeadline(time.Now().Add(wsWriteTimeout))
+				err = conn.WriteJSON(msg)
+			case <-ping.C:
+				err = conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(wsWriteTimeout))
+			}
+			if err != nil {
+				log.Printf("[EVENTS] WebSocket write failed: %v", err)
+				return
+			}
+		}
+	}
+}
+
+func handleWSRequest(conn *websocket.Conn, sub *events.Subscription, req wsRequest) error {
+	response := map[string]interface{}{
+		"type":   "ack",
+		"action": req.Action,
+		"tables": req.Tables,
+	}
+
+	switch req.Action {
+	case "subscribe":
+		sub.Add(req.Tables...)
+	case "unsubscribe":
+		sub.Remove(req.Tables...)
+	default:
+		response["type"] = "error"
+		response["error"] = "unknown action, expected subscribe or unsubscribe"
+	}
+
+	conn.SetWriteDeadline(time.Now().Add(wsWriteTimeout))
+	return conn.WriteJSON(response)
+}
@@ -0,0 +1,48 @@
+package handlers
+
+import (
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"ipfs-go-server/internal/auth"
+	"ipfs-go-server/internal/events"
+	"ipfs-go-server/internal/models"
+	"ipfs-go-server/internal/storage"
+)
+
+func TestDeletedEventOnlyReachesReaders(t *testing.T) {
+	useTables(t)
+
+	stor := storage.NewStorageWithIPNS(nil, "release", "release", "", models.KeyName("release"), "k51release")
+	storeTable("release", stor)
+	setACL("release", models.TableACL{Owner: "key-owner", Readers: []string{"key-reader"}, KeyIDs: true})
+
+	sub := eventHub.Subscribe("release")
+	defer sub.Close()
+	removeTable("release")
+
+	var msg events.Message
+	select {
+	case msg = <-sub.C:
+	case <-time.After(time.Second):
+		t.Fatal("no event for the deleted table")
+	}
+	if msg.Type != storage.EventDeleted {
+		t.Fatalf("event = %s, want %s", msg.Type, storage.EventDeleted)
+	}
+
+	// Access is checked against the ACL the table had, even though it is
+	// gone by the time the event is sent
+	as := func(keyID string) bool {
+		r := httptest.NewRequest("GET", "/events", nil)
+		id := &auth.Identity{KeyID: keyID, Name: keyID, Scopes: []auth.Scope{auth.ScopeRead}}
+		return canAccessEvent(r.WithContext(auth.WithIdentity(r.Context(), id)), msg)
+	}
+	if !as("key-reader") || !as("key-owner") {
+		t.Fatal("the table's readers do not see it deleted")
+	}
+	if as("key-other") {
+		t.Fatal("a key without access to the table sees it deleted")
+	}
+}
@@ -0,0 +1,253 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"ipfs-go-server/internal/auth"
+	"ipfs-go-server/internal/encryption"
+
+	"github.com/gorilla/mux"
+)
+
+// The file encryption service replaces main_backend/encryption.js. Uploads
+// and results are kept in uploadsDir and fetched with the download route,
+// as the encryption page expects.
+const (
+	envelopeKeyFile = "envelope_key.pem"
+	smimeKeyFile    = "smime_key.pem"
+	uploadsDir      = "uploads"
+	maxUploadSize   = 50 << 20
+)
+
+var (
+	envelopeKey   *rsa.PrivateKey
+	smimeIdentity *encryption.SMIMEIdentity
+)
+
+func initializeFileEncryption() error {
+	key, err := encryption.LoadOrCreateKey(dataPath(envelopeKeyFile))
+	if err != nil {
+		return err
+	}
+	identity, err := encryption.LoadOrCreateSMIME(dataPath(smimeKeyFile))
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dataPath(uploadsDir), 0700); err != nil {
+		return fmt.Errorf("failed to create uploads directory: %w", err)
+	}
+
+	envelopeKey, smimeIdentity = key, identity
+	log.Printf("[ENCRYPTION] File encryption keys loaded, S/MIME certificate valid until %s",
+		identity.Certificate.NotAfter.Format(time.RFC3339))
+	return nil
+}
+
+// writeFileError responds with {"error": message}, which the encryption
+// page shows to the user
+func writeFileError(w http.ResponseWriter, message string, status int) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{"error": message})
+}
+
+func fileKeysHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		smimePublicKey, err := encryption.PublicKeyPEM(smimeIdentity.Certificate.PublicKey.(*rsa.PublicKey))
+		if err != nil {
+			writeFileError(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		rsaPublicKey, err := encryption.PublicKeyPEM(&envelopeKey.PublicKey)
+		if err != nil {
+			writeFileError(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		response := map[string]interface{}{
+			"smimeCertificate": smimeIdentity.CertificatePEM(),
+			"smimePublicKey":   smimePublicKey,
+			"rsaPublicKey":     rsaPublicKey,
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(response)
+	}
+}
+
+// fileOperation turns an uploaded file into a stored result
+type fileOperation struct {
+	action      string // e.g. "S/MIME encryption", used in logs and errors
+	message     string
+	prefix, ext string // the result is saved as <prefix>_<time>_<random><ext>
+	resultField string
+	transform   func(content []byte) ([]byte, error)
+}
+
+// fileHandler reads the uploaded "file" field, transforms it and saves the
+// result in uploadsDir
+func fileHandler(op fileOperation) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		log.Printf("[ENCRYPTION] %s called by %s", op.action, auth.Actor(r.Context()))
+
+		r.Body = http.MaxBytesReader(w, r.Body, maxUploadSize+1<<20)
+		file, header, err := r.FormFile("file")
+		if err != nil {
+			var tooLarge *http.MaxBytesError
+			if errors.As(err, &tooLarge) {
+				writeFileError(w, "File too large", http.StatusBadRequest)
+				return
+			}
+			writeFileError(w, "No file uploaded", http.StatusBadRequest)
+			return
+		}
+		defer file.Close()
+
+		content, err := io.ReadAll(io.LimitReader(file, maxUploadSize+1))
+		if err != nil {
+			writeFileError(w, "Failed to read upload: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		if len(content) > maxUploadSize {
+			writeFileError(w, "File too large", http.StatusBadRequest)
+			return
+		}
+
+		result, err := op.transform(content)
+		if err != nil {
+			log.Printf("[ENCRYPTION] %s failed: %v", op.action, err)
+			writeFileError(w, fmt.Sprintf("%s failed: %v", op.action, err), http.StatusInternalServerError)
+			return
+		}
+
+		name, err := outputName(op.prefix, op.ext)
+		if err != nil {
+			writeFileError(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if err := os.WriteFile(filepath.Join(dataPath(uploadsDir), name), result, 0600); err != nil {
+			log.Printf("[ENCRYPTION] Error saving %s: %v", name, err)
+			writeFileError(w, "Failed to save result", http.StatusInternalServerError)
+			return
+		}
+		log.Printf("[ENCRYPTION] %s wrote %s (%d bytes)", op.action, name, len(result))
+
+		response := map[string]interface{}{
+			"message":      op.message,
+			op.resultField: name,
+			"originalName": header.Filename,
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(response)
+	}
+}
+
+// outputName adds a random suffix so results cannot be guessed from the time
+func outputName(prefix, ext string) (string, error) {
+	suffix := make([]byte, 8)
+	if _, err := rand.Read(suffix); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%s_%d_%s%s", prefix, time.Now().UnixMilli(), hex.EncodeToString(suffix), ext), nil
+}
+
+// The S/MIME layer encrypts the base64 of the file, like the Node service,
+// so the two can read each other's messages
+func encryptSMIMEHandler() http.HandlerFunc {
+	return fileHandler(fileOperation{
+		action:      "S/MIME encryption",
+		message:     "File encrypted with S/MIME successfully",
+		prefix:      "encrypted_smime",
+		ext:         ".pem",
+		resultField: "encryptedFile",
+		transform: func(content []byte) ([]byte, error) {
+			return encryption.EncryptSMIME([]byte(base64.StdEncoding.EncodeToString(content)), smimeIdentity.Certificate)
+		},
+	})
+}
+
+func decryptSMIMEHandler() http.HandlerFunc {
+	return fileHandler(fileOperation{
+		action:      "S/MIME decryption",
+		message:     "File decrypted from S/MIME successfully",
+		prefix:      "decrypted_smime",
+		ext:         ".bin",
+		resultField: "decryptedFile",
+		transform: func(content []byte) ([]byte, error) {
+			decrypted, err := encryption.DecryptSMIME(content, smimeIdentity)
+			if err != nil {
+				return nil, err
+			}
+			return base64.StdEncoding.DecodeString(string(decrypted))
+		},
+	})
+}
+
+func encryptAESHandler() http.HandlerFunc {
+	return fileHandler(fileOperation{
+		action:      "AES encryption",
+		message:     "File encrypted with AES successfully",
+		prefix:      "encrypted_aes",
+		ext:         ".json",
+		resultField: "encryptedFile",
+		transform: func(content []byte) ([]byte, error) {
+			envelope, err := encryption.SealEnvelope(content, &envelopeKey.PublicKey)
+			if err != nil {
+				return nil, err
+			}
+			return json.MarshalIndent(envelope, "", "  ")
+		},
+	})
+}
+
+func decryptAESHandler() http.HandlerFunc {
+	return fileHandler(fileOperation{
+		action:      "AES decryption",
+		message:     "File decrypted from AES successfully",
+		prefix:      "decrypted_aes",
+		ext:         ".pem",
+		resultField: "decryptedFile",
+		transform: func(content []byte) ([]byte, error) {
+			var envelope encryption.Envelope
+			if err := json.Unmarshal(content, &envelope); err != nil {
+				return nil, fmt.Errorf("invalid envelope: %w", err)
+			}
+			return encryption.OpenEnvelope(&envelope, envelopeKey)
+		},
+	})
+}
+
+func downloadFileHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		filename := mux.Vars(r)["filename"]
+		if filename != filepath.Base(filename) || strings.HasPrefix(filename, ".") {
+			writeFileError(w, "File not found", http.StatusNotFound)
+			return
+		}
+
+		path := filepath.Join(dataPath(uploadsDir), filename)
+		if _, err := os.Stat(path); err != nil {
+			writeFileError(w, "File not found", http.StatusNotFound)
+			return
+		}
+
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+		http.ServeFile(w, r, path)
+	}
+}
@@ -0,0 +1,287 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"ipfs-go-server/internal/ipfs"
+	"ipfs-go-server/internal/models"
+	"ipfs-go-server/internal/storage"
+	"ipfs-go-server/internal/torrent"
+
+	"github.com/gorilla/mux"
+)
+
+// Responses for CIDs never change, so they may be cached for good. IPNS
+// names move, so their responses are only cached briefly.
+const (
+	immutableCacheControl = "public, max-age=29030400, immutable"
+	ipnsCacheControl      = "public, max-age=60"
+)
+
+// payloadFile is a version payload that was added to IPFS
+type payloadFile struct {
+	hash      string
+	size      int64
+	createdAt time.Time
+}
+
+// gatewayIndex caches what belongs to the server's tables: snapshot and
+// moved pointer CIDs, version payloads and IPNS names with the CID each was
+// last published with. Table events only mark it stale, since hooks run
+// with the table locked.
+var gatewayIndex struct {
+	once      sync.Once
+	stale     atomic.Bool
+	mu        sync.Mutex
+	payloads  map[string]payloadFile
+	snapshots map[string]bool
+	names     map[string]string
+}
+
+// gatewayAllowlist limits the gateway to the CIDs and names of known
+// tables, plus any listed in extra, unless it is open
+var gatewayAllowlist struct {
+	open  bool
+	extra map[string]bool
+}
+
+// InitializeGateway configures which CIDs and IPNS names /ipfs and /ipns
+// serve. An open gateway serves anything the IPFS daemon can fetch.
+func InitializeGateway(open bool, extra []string) {
+	gatewayAllowlist.open = open
+	gatewayAllowlist.extra = make(map[string]bool)
+	for _, item := range extra {
+		if item = strings.TrimSpace(item); item != "" {
+			gatewayAllowlist.extra[item] = true
+		}
+	}
+	if open {
+		log.Printf("[GATEWAY] Warning: Gateway is open, any CID or IPNS name is served")
+	} else {
+		log.Printf("[GATEWAY] Gateway serves table content and %d more CIDs or names", len(gatewayAllowlist.extra))
+	}
+}
+
+// refreshGatewayIndex rebuilds the index if a table changed since it was
+// built. Must be called with gatewayIndex.mu held.
+func refreshGatewayIndex() {
+	gatewayIndex.once.Do(func() {
+		gatewayIndex.stale.Store(true)
+		storage.OnChange(func(storage.Event) { gatewayIndex.stale.Store(true) })
+	})
+	if !gatewayIndex.stale.Swap(false) {
+		return
+	}
+
+	payloads := make(map[string]payloadFile)
+	snapshots := make(map[string]bool)
+	names := make(map[string]string)
+	for _, stor := range allTables() {
+		head := stor.GetPublishStatus().HeadCID
+		if head != "" {
+			snapshots[head] = true
+		}
+		if name := stor.GetIPNSName(); name != "" {
+			names[name] = head
+		}
+		for _, rotation := range stor.GetKeyHistory() {
+			for _, cid := range []string{rotation.SnapshotCID, rotation.PointerCID} {
+				if cid != "" {
+					snapshots[cid] = true
+				}
+			}
+			if rotation.OldIPNSName != "" {
+				names[rotation.OldIPNSName] = rotation.PointerCID
+			}
+		}
+
+		var versions []models.TorrentVersion
+		if err := json.Unmarshal([]byte(stor.GetTable().Data), &versions); err != nil {
+			continue
+		}
+		for _, v := range versions {
+			if v.CID != "" {
+				payloads[v.CID] = payloadFile{hash: v.Hash, size: v.FileSize, createdAt: v.CreatedAt}
+			}
+		}
+	}
+	gatewayIndex.payloads = payloads
+	gatewayIndex.snapshots = snapshots
+	gatewayIndex.names = names
+}
+
+// lookupPayload returns the version payload stored in IPFS under cid
+func lookupPayload(cid string) (payloadFile, bool) {
+	gatewayIndex.mu.Lock()
+	defer gatewayIndex.mu.Unlock()
+
+	refreshGatewayIndex()
+	file, ok := gatewayIndex.payloads[cid]
+	return file, ok
+}
+
+// gatewayAllowsCID reports whether /ipfs may serve the CID
+func gatewayAllowsCID(cid string) bool {
+	if gatewayAllowlist.open || gatewayAllowlist.extra[cid] {
+		return true
+	}
+
+	gatewayIndex.mu.Lock()
+	defer gatewayIndex.mu.Unlock()
+
+	refreshGatewayIndex()
+	_, payload := gatewayIndex.payloads[cid]
+	return payload || gatewayIndex.snapshots[cid]
+}
+
+// gatewayName reports whether /ipns may serve the name, and the CID this
+// server last published under it, if it is a table's
+func gatewayName(name string) (string, bool) {
+	gatewayIndex.mu.Lock()
+	defer gatewayIndex.mu.Unlock()
+
+	refreshGatewayIndex()
+	head, known := gatewayIndex.names[name]
+	return head, known || gatewayAllowlist.open || gatewayAllowlist.extra[name]
+}
+
+// heldPayload opens the local copy of a torrent's payload, if this server
+// still has it
+func heldPayload(infoHash string) (*os.File, bool) {
+	if !payloadHeld(infoHash) {
+		return nil, false
+	}
+	data, err := os.ReadFile(filepath.Join(payloadDir(infoHash), infoHash+torrentExtension))
+	if err != nil {
+		return nil, false
+	}
+	meta, err := torrent.Parse(data)
+	if err != nil || filepath.Base(meta.Name) != meta.Name {
+		return nil, false
+	}
+	f, err := os.Open(filepath.Join(payloadDir(infoHash), meta.Name))
+	if err != nil {
+		return nil, false
+	}
+	return f, true
+}
+
+// contentType picks a type from the file extension, then recognises JSON
+// such as table snapshots, then sniffs the first bytes
+func contentType(name string, data []byte) string {
+	if t := mime.TypeByExtension(path.Ext(name)); t != "" {
+		return t
+	}
+	if json.Valid(data) {
+		return "application/json"
+	}
+	return http.DetectContentType(data)
+}
+
+// serveIPFS writes the file at cid/subPath with range support. Version
+// payloads are streamed, from the local copy while the server holds one;
+// anything else is fetched whole.
+func serveIPFS(w http.ResponseWriter, r *http.Request, ipfsClient *ipfs.IPFSClient, cid, subPath string) {
+	etag := `"` + cid + `"`
+	if subPath != "" {
+		etag = `"` + cid + "/" + subPath + `"`
+	}
+	w.Header().Set("Etag", etag)
+	w.Header().Set("X-Ipfs-Path", r.URL.Path)
+
+	// Web seed downloads of large payloads outlive the server's write timeout
+	http.NewResponseController(w).SetWriteDeadline(time.Time{})
+
+	if file, ok := lookupPayload(cid); ok && subPath == "" {
+		var content io.ReadSeeker
+		if f, held := heldPayload(file.hash); held {
+			defer f.Close()
+			content = f
+		} else {
+			f := ipfsClient.OpenFile(r.Context(), cid, file.size)
+			defer f.Close()
+			content = f
+		}
+		// ServeContent sniffs the type from the start of the payload
+		http.ServeContent(w, r, "", file.createdAt, content)
+		return
+	}
+
+	target := cid
+	if subPath != "" {
+		target += "/" + subPath
+	}
+	data, err := ipfsClient.GetData(r.Context(), target)
+	if err != nil {
+		log.Printf("[GATEWAY] Failed to fetch %s: %v", target, err)
+		w.Header().Del("Cache-Control")
+		w.Header().Del("Etag")
+		http.Error(w, "Failed to fetch from IPFS: "+err.Error(), http.StatusBadGateway)
+		return
+	}
+	content := []byte(data)
+	w.Header().Set("Content-Type", contentType(subPath, content))
+	http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(content))
+}
+
+// gatewayIPFSHandler serves /ipfs/{cid} and paths below it. It is the web
+// seed of payloads added to IPFS.
+func gatewayIPFSHandler(ipfsClient *ipfs.IPFSClient) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		vars := mux.Vars(r)
+		cid, subPath := vars["cid"], strings.Trim(vars["path"], "/")
+		log.Printf("[GATEWAY] GET /ipfs/%s/%s (range: %q)", cid, subPath, r.Header.Get("Range"))
+
+		if !gatewayAllowsCID(cid) {
+			http.Error(w, "CID does not belong to a table on this server", http.StatusForbidden)
+			return
+		}
+
+		w.Header().Set("Cache-Control", immutableCacheControl)
+		serveIPFS(w, r, ipfsClient, cid, subPath)
+	}
+}
+
+// gatewayIPNSHandler serves /ipns/{name} and paths below it by resolving
+// the name. A table's own name falls back to the snapshot this server last
+// published under it when the name does not resolve.
+func gatewayIPNSHandler(ipfsClient *ipfs.IPFSClient) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		vars := mux.Vars(r)
+		name, subPath := vars["name"], strings.Trim(vars["path"], "/")
+		log.Printf("[GATEWAY] GET /ipns/%s/%s", name, subPath)
+
+		head, allowed := gatewayName(name)
+		if !allowed {
+			http.Error(w, "Name does not belong to a table on this server", http.StatusForbidden)
+			return
+		}
+
+		cid, err := ipfsClient.ResolveIPNS(r.Context(), name)
+		if err != nil || cid == "" || strings.Contains(cid, "/") {
+			if head == "" {
+				log.Printf("[GATEWAY] Failed to resolve %s: %v", name, err)
+				http.Error(w, "Failed to resolve IPNS name", http.StatusBadGateway)
+				return
+			}
+			log.Printf("[GATEWAY] Warning: Failed to resolve %s, serving last published %s: %v", name, head, err)
+			cid = head
+		}
+
+		w.Header().Set("Cache-Control", ipnsCacheControl)
+		w.Header().Set("X-Ipfs-Roots", cid)
+		serveIPFS(w, r, ipfsClient, cid, subPath)
+	}
+}
@@ -1,39 +1,162 @@
 package handlers
 
 import (
+	"context"
 	"encoding/json"
+	"fmt"
 	"io"
 	"log"
 	"net/http"
 	"os"
 	"path/filepath"
+	"strings"
+	"sync"
+	"time"
 
+	"ipfs-go-server/internal/audit"
+	"ipfs-go-server/internal/auth"
 	"ipfs-go-server/internal/ipfs"
+	"ipfs-go-server/internal/models"
 	"ipfs-go-server/internal/storage"
 
 	"github.com/gorilla/mux"
 )
 
-const (
-	persistenceFile = "tables_registry.json"
-)
+// persistenceFile is the table registry, moved by InitializeConfig
+var persistenceFile = "tables_registry.json"
 
-var tableStorage = make(map[string]*storage.Storage)
+var (
+	tableStorage = make(map[string]*storage.Storage)
+	tablesMu     sync.RWMutex
+)
 
+// TableRegistry is the registry file, partitioned by namespace
 type TableRegistry struct {
+	Namespaces map[string]*NamespaceRegistry `json:"namespaces"`
+
+	// Legacy holds the tables of registries written before namespaces
+	// existed; migrate moves them into the default namespace
+	Legacy map[string]TableInfo `json:"tables,omitempty"`
+}
+
+// NamespaceRegistry is a namespace's partition of the registry. Tables are
+// keyed by their ID within the namespace.
+type NamespaceRegistry struct {
+	Quota  models.Quota         `json:"quota"`
 	Tables map[string]TableInfo `json:"tables"`
 }
 
+// NewTableRegistry returns a registry holding only the empty default
+// namespace
+func NewTableRegistry() TableRegistry {
+	registry := TableRegistry{
+		Namespaces: make(map[string]*NamespaceRegistry),
+	}
+	registry.Namespace(models.DefaultNamespace)
+	return registry
+}
+
+// Namespace returns the partition of ns, adding it if missing
+func (reg *TableRegistry) Namespace(ns string) *NamespaceRegistry {
+	if reg.Namespaces == nil {
+		reg.Namespaces = make(map[string]*NamespaceRegistry)
+	}
+	partition, ok := reg.Namespaces[ns]
+	if !ok {
+		partition = &NamespaceRegistry{}
+		reg.Namespaces[ns] = partition
+	}
+	if partition.Tables == nil {
+		partition.Tables = make(map[string]TableInfo)
+	}
+	return partition
+}
+
+// Lookup returns the entry of a table by its server-wide ID
+func (reg *TableRegistry) Lookup(id string) (TableInfo, bool) {
+	ns, local := models.SplitTableRef(id)
+	partition, ok := reg.Namespaces[ns]
+	if !ok {
+		return TableInfo{}, false
+	}
+	info, ok := partition.Tables[local]
+	return info, ok
+}
+
+// LookupName returns the entry of a table by its name, "namespace/name"
+// outside the default namespace
+func (reg *TableRegistry) LookupName(ref string) (TableInfo, bool) {
+	ns, name := models.SplitTableRef(ref)
+	partition, ok := reg.Namespaces[ns]
+	if !ok {
+		return TableInfo{}, false
+	}
+	for _, info := range partition.Tables {
+		if info.Name == name {
+			return info, true
+		}
+	}
+	return TableInfo{}, false
+}
+
+// Put adds or replaces a table's entry in its namespace
+func (reg *TableRegistry) Put(info TableInfo) {
+	ns, local := models.SplitTableRef(info.ID)
+	reg.Namespace(ns).Tables[local] = info
+}
+
+// Tables returns every table's entry by server-wide ID
+func (reg *TableRegistry) Tables() map[string]TableInfo {
+	tables := make(map[string]TableInfo)
+	for _, partition := range reg.Namespaces {
+		for _, info := range partition.Tables {
+			tables[info.ID] = info
+		}
+	}
+	return tables
+}
+
+// migrate moves the tables of a registry from before namespaces into the
+// default namespace and returns how many it moved
+func (reg *TableRegistry) migrate() int {
+	moved := len(reg.Legacy)
+	reg.Namespace(models.DefaultNamespace)
+	for id, info := range reg.Legacy {
+		if info.ID == "" {
+			info.ID = id
+		}
+		reg.Put(info)
+	}
+	reg.Legacy = nil
+	return moved
+}
+
+// TableInfo is a table's registry entry. ID is the server-wide ID,
+// "namespace/id" outside the default namespace.
 type TableInfo struct {
-	ID       string `json:"id"`
-	Name     string `json:"name"`
-	KeyName  string `json:"keyName"`
-	IPNSName string `json:"ipnsName"`
+	ID             string               `json:"id"`
+	FormerIDs      []string             `json:"formerIds,omitempty"`
+	Name           string               `json:"name"`
+	KeyName        string               `json:"keyName"`
+	IPNSName       string               `json:"ipnsName"`
+	KeyHistory     []models.KeyRotation `json:"keyHistory,omitempty"`
+	RecordLifetime models.Duration      `json:"recordLifetime,omitempty"`
+	RecordTTL      models.Duration      `json:"recordTtl,omitempty"`
+	ACL            models.TableACL      `json:"acl"`
+
+	// Snapshot holding changes that were not published before the server
+	// stopped; it is published on the next start
+	PendingCID string `json:"pendingCid,omitempty"`
+
+	// Sequence of the last pubsub announcement a mirror applied; older
+	// ones are refused
+	AnnounceSeq uint64 `json:"announceSeq,omitempty"`
 }
 
 // InitializeStorage loads existing tables from persistence
-func InitializeStorage(ipfsClient *ipfs.IPFSClient) error {
+func InitializeStorage(ctx context.Context, ipfsClient *ipfs.IPFSClient) error {
 	log.Println("[PERSISTENCE] Loading existing tables...")
+	storage.SetMaxSnapshotSize(ipfsClient.MaxReadSize())
 
 	// Try to load from persistence file
 	if _, err := os.Stat(persistenceFile); os.IsNotExist(err) {
@@ -41,53 +164,132 @@ func InitializeStorage(ipfsClient *ipfs.IPFSClient) error {
 		return nil
 	}
 
-	data, err := os.ReadFile(persistenceFile)
+	registry, err := LoadRegistry()
 	if err != nil {
 		return err
 	}
 
-	var registry TableRegistry
-	if err := json.Unmarshal(data, &registry); err != nil {
-		return err
+	// Restore namespaces, then their tables from IPFS
+	for ns, partition := range registry.Namespaces {
+		setNamespace(ns, partition.Quota)
 	}
-
-	// Restore tables from IPFS
-	for id, info := range registry.Tables {
+	for id, info := range registry.Tables() {
 		log.Printf("[PERSISTENCE] Restoring table: %s (%s)", info.Name, id)
 
 		// Create storage instance
-		stor := storage.NewStorageWithIPNS(ipfsClient.GetShell(), info.ID, info.Name, "", info.KeyName, info.IPNSName)
+		stor := newStorageFromInfo(ipfsClient, info)
 
 		// Try to load table data from IPFS
-		if err := stor.LoadTable(); err != nil {
+		if err := stor.LoadTable(ctx); err != nil {
 			log.Printf("[PERSISTENCE] Warning: Failed to load table %s from IPFS: %v", id, err)
-			continue
+			if info.PendingCID == "" {
+				continue
+			}
+		}
+
+		// Changes the last run could not publish take over from IPNS
+		if info.PendingCID != "" {
+			if err := stor.RestoreUnpublished(ctx, info.PendingCID); err != nil {
+				log.Printf("[PERSISTENCE] Warning: Failed to restore unpublished snapshot %s of table %s: %v", info.PendingCID, id, err)
+				continue
+			}
+			goBackground(ctx, func(ctx context.Context) { publishPending(ctx, id, stor) })
 		}
 
-		tableStorage[id] = stor
+		setACL(id, info.ACL)
+		storeTable(id, stor)
 		log.Printf("[PERSISTENCE] Successfully restored table: %s", info.Name)
 	}
 
-	log.Printf("[PERSISTENCE] Loaded %d tables from persistence", len(tableStorage))
+	log.Printf("[PERSISTENCE] Loaded %d tables from persistence", len(allTables()))
 	return nil
 }
 
-// saveRegistry saves the current table registry to disk
-func saveRegistry() error {
-	registry := TableRegistry{
-		Tables: make(map[string]TableInfo),
+// newStorageFromInfo creates the storage for a registry entry
+func newStorageFromInfo(ipfsClient *ipfs.IPFSClient, info TableInfo) *storage.Storage {
+	stor := storage.NewStorageWithIPNS(ipfsClient.GetShell(), info.ID, info.Name, "", info.KeyName, info.IPNSName)
+	stor.SetFormerIDs(info.FormerIDs)
+	stor.SetKeyHistory(info.KeyHistory)
+	stor.SetPublishSettings(time.Duration(info.RecordLifetime), time.Duration(info.RecordTTL))
+	stor.SetAnnounceSequence(info.AnnounceSeq)
+	return stor
+}
+
+// lookupTable returns the storage for a table ID
+func lookupTable(id string) (*storage.Storage, bool) {
+	tablesMu.RLock()
+	defer tablesMu.RUnlock()
+
+	stor, exists := tableStorage[id]
+	return stor, exists
+}
+
+// storeTable adds or replaces a table in memory and hooks it up to pubsub
+func storeTable(id string, stor *storage.Storage) {
+	tablesMu.Lock()
+	tableStorage[id] = stor
+	tablesMu.Unlock()
+
+	attachPubsub(stor)
+}
+
+// removeTable drops a table from memory and returns its storage. The ACL
+// goes last, so the deleted event only reaches those who could read the
+// table.
+func removeTable(id string) (*storage.Storage, bool) {
+	tablesMu.Lock()
+	stor, exists := tableStorage[id]
+	delete(tableStorage, id)
+	tablesMu.Unlock()
+
+	if exists {
+		detachPubsub(stor)
+		stor.Delete()
 	}
 
+	tablesMu.Lock()
+	delete(tableACLs, id)
+	tablesMu.Unlock()
+	return stor, exists
+}
+
+// allTables returns a copy of the in-memory tables so callers can iterate
+// without holding the lock during slow IPFS calls
+func allTables() map[string]*storage.Storage {
+	tablesMu.RLock()
+	defer tablesMu.RUnlock()
+
+	tables := make(map[string]*storage.Storage, len(tableStorage))
 	for id, stor := range tableStorage {
-		table := stor.GetTable()
-		registry.Tables[id] = TableInfo{
-			ID:       table.ID,
-			Name:     table.Name,
-			KeyName:  stor.GetKeyName(),
-			IPNSName: stor.GetIPNSName(),
-		}
+		tables[id] = stor
+	}
+	return tables
+}
+
+// LoadRegistry reads the table registry from disk. A missing file yields an
+// empty registry.
+func LoadRegistry() (TableRegistry, error) {
+	registry := NewTableRegistry()
+
+	data, err := os.ReadFile(persistenceFile)
+	if os.IsNotExist(err) {
+		return registry, nil
+	}
+	if err != nil {
+		return registry, err
+	}
+
+	if err := json.Unmarshal(data, &registry); err != nil {
+		return registry, err
+	}
+	if moved := registry.migrate(); moved > 0 {
+		log.Printf("[PERSISTENCE] Moved %d tables into the %s namespace", moved, models.DefaultNamespace)
 	}
+	return registry, nil
+}
 
+// WriteRegistry replaces the registry on disk.
+func WriteRegistry(registry TableRegistry) error {
 	data, err := json.Marshal(registry)
 	if err != nil {
 		return err
@@ -102,16 +304,144 @@ func saveRegistry() error {
 	return os.WriteFile(persistenceFile, data, 0644)
 }
 
+// currentRegistry builds a registry snapshot of the tables held in memory
+func currentRegistry() TableRegistry {
+	registry := NewTableRegistry()
+
+	for ns, quota := range allNamespaces() {
+		registry.Namespace(ns).Quota = quota
+	}
+	for id, stor := range allTables() {
+		table := stor.GetTable()
+		lifetime, ttl := stor.GetPublishSettings()
+		pending, _ := stor.Unpublished()
+		registry.Put(TableInfo{
+			ID:             id,
+			FormerIDs:      table.FormerIDs,
+			Name:           table.Name,
+			KeyName:        stor.GetKeyName(),
+			IPNSName:       stor.GetIPNSName(),
+			KeyHistory:     stor.GetKeyHistory(),
+			RecordLifetime: models.Duration(lifetime),
+			RecordTTL:      models.Duration(ttl),
+			ACL:            lookupACL(id),
+			PendingCID:     pending,
+			AnnounceSeq:    stor.AnnounceSequence(),
+		})
+	}
+	return registry
+}
+
+// saveRegistry saves the current table registry to disk
+func saveRegistry() error {
+	return WriteRegistry(currentRegistry())
+}
+
 func RegisterTableRoutes(router *mux.Router, ipfsClient *ipfs.IPFSClient) {
 	log.Println("[HANDLERS] Registering table routes...")
 
+	// Routes require an API key with the given scope unless authentication
+	// is disabled. /health stays open for monitoring.
+
+	// Table routes address the default namespace and, under /ns/{ns}, any
+	// other namespace
+
 	// Main CRUD endpoints
-	router.HandleFunc("/tables", getAllTablesHandler()).Methods("GET")
-	router.HandleFunc("/tables", createTableHandlerNew(ipfsClient)).Methods("POST")
-	router.HandleFunc("/tables/{id}", getTableHandler()).Methods("GET")
-	router.HandleFunc("/tables/{id}", updateTableHandler()).Methods("PUT")
-	router.HandleFunc("/tables/{id}", deleteTableHandler()).Methods("DELETE")
-	router.HandleFunc("/tables/{id}/append", AppendToTable(ipfsClient)).Methods("POST")
+	tableRoute(router, "/tables", auth.ScopeRead, getAllTablesHandler(), "GET")
+	tableRoute(router, "/tables", auth.ScopeWrite, createTableHandlerNew(ipfsClient), "POST")
+	router.HandleFunc("/tables/follow", protect(auth.ScopeAdmin, followTableHandler(ipfsClient))).Methods("POST")
+	tableRoute(router, "/tables/{id}", auth.ScopeRead, getTableHandler(), "GET")
+	tableRoute(router, "/tables/{id}", auth.ScopeWrite, updateTableHandler(), "PUT")
+	tableRoute(router, "/tables/{id}", auth.ScopeWrite, deleteTableHandler(), "DELETE")
+	tableRoute(router, "/tables/{id}/append", auth.ScopeWrite, AppendToTable(ipfsClient), "POST")
+	tableRoute(router, "/tables/{id}/upload", auth.ScopeWrite, uploadHandler(ipfsClient), "POST")
+	tableRoute(router, "/tables/{id}/upload/encrypted", auth.ScopeWrite, uploadEncryptedHandler(ipfsClient), "POST")
+	router.HandleFunc("/torrents", protect(auth.ScopeRead, listTorrentsHandler())).Methods("GET")
+	tableRoute(router, "/tables/{id}/health", auth.ScopeRead, tableHealthHandler(), "GET")
+
+	// Table access control
+	tableRoute(router, "/tables/{id}/acl", auth.ScopeRead, getACLHandler(), "GET")
+	tableRoute(router, "/tables/{id}/acl/grant", auth.ScopeWrite, grantHandler(), "POST")
+	tableRoute(router, "/tables/{id}/acl/revoke", auth.ScopeWrite, revokeHandler(), "POST")
+
+	// Version signing
+	router.HandleFunc("/publishers", protect(auth.ScopeRead, listPublishersHandler())).Methods("GET")
+	router.HandleFunc("/publishers", protect(auth.ScopeWrite, registerPublisherHandler())).Methods("POST")
+	router.HandleFunc("/publishers/{id}", protect(auth.ScopeRead, getPublisherHandler())).Methods("GET")
+	router.HandleFunc("/publishers/{id}", protect(auth.ScopeWrite, revokePublisherHandler())).Methods("DELETE")
+	tableRoute(router, "/tables/{id}/verify", auth.ScopeRead, verifyTableHandler(), "GET")
+
+	// Private tables
+	router.HandleFunc("/encryption/key", protect(auth.ScopeRead, serverKeyHandler())).Methods("GET")
+	tableRoute(router, "/tables/{id}/recipients", auth.ScopeRead, listRecipientsHandler(), "GET")
+	tableRoute(router, "/tables/{id}/recipients", auth.ScopeWrite, addRecipientHandler(), "POST")
+	tableRoute(router, "/tables/{id}/recipients/{recipientId}", auth.ScopeWrite, removeRecipientHandler(), "DELETE")
+
+	// File encryption, ported from main_backend/encryption.js
+	router.HandleFunc("/encryption/keys", protect(auth.ScopeRead, fileKeysHandler())).Methods("GET")
+	router.HandleFunc("/encryption/encrypt/smime", protect(auth.ScopeWrite, encryptSMIMEHandler())).Methods("POST")
+	router.HandleFunc("/encryption/encrypt/aes", protect(auth.ScopeWrite, encryptAESHandler())).Methods("POST")
+	router.HandleFunc("/encryption/decrypt/smime", protect(auth.ScopeWrite, decryptSMIMEHandler())).Methods("POST")
+	router.HandleFunc("/encryption/decrypt/aes", protect(auth.ScopeWrite, decryptAESHandler())).Methods("POST")
+	router.HandleFunc("/encryption/download/{filename}", protect(auth.ScopeRead, downloadFileHandler())).Methods("GET")
+
+	// Namespaces and their quotas
+	router.HandleFunc("/namespaces", protect(auth.ScopeRead, listNamespacesHandler())).Methods("GET")
+	router.HandleFunc("/namespaces", protect(auth.ScopeAdmin, createNamespaceHandler())).Methods("POST")
+	router.HandleFunc("/namespaces/{ns}", protect(auth.ScopeRead, getNamespaceHandler())).Methods("GET")
+	router.HandleFunc("/namespaces/{ns}", protect(auth.ScopeAdmin, updateNamespaceHandler())).Methods("PUT")
+	router.HandleFunc("/namespaces/{ns}", protect(auth.ScopeAdmin, deleteNamespaceHandler())).Methods("DELETE")
+
+	// Audit log
+	router.HandleFunc("/audit", protect(auth.ScopeAdmin, auditHandler())).Methods("GET")
+
+	// Running configuration, with secrets masked
+	router.HandleFunc("/config", protect(auth.ScopeAdmin, configHandler())).Methods("GET")
+
+	// Key backup endpoints
+	tableRoute(router, "/tables/{id}/key/export", auth.ScopeAdmin, exportKeyHandler(ipfsClient), "POST")
+	router.HandleFunc("/keys/import", protect(auth.ScopeAdmin, importKeyHandler(ipfsClient))).Methods("POST")
+	router.HandleFunc("/backup", protect(auth.ScopeAdmin, createBackupHandler(ipfsClient))).Methods("POST")
+	router.HandleFunc("/backup/restore", protect(auth.ScopeAdmin, restoreBackupHandler(ipfsClient))).Methods("POST")
+	tableRoute(router, "/tables/{id}/rotate-key", auth.ScopeAdmin, rotateKeyHandler(), "POST")
+
+	// IPNS record settings and republisher health
+	tableRoute(router, "/tables/{id}/publish-settings", auth.ScopeRead, getPublishSettingsHandler(), "GET")
+	tableRoute(router, "/tables/{id}/publish-settings", auth.ScopeAdmin, updatePublishSettingsHandler(), "PUT")
+	router.HandleFunc("/health", healthHandler()).Methods("GET")
+
+	// Embedded BitTorrent tracker. BitTorrent clients cannot send API keys,
+	// so these are guarded by the tracker passkey instead.
+	router.HandleFunc("/announce", trackerAnnounceHandler()).Methods("GET")
+	router.HandleFunc("/scrape", trackerScrapeHandler()).Methods("GET")
+
+	// Read-only IPFS gateway for table snapshots and payloads. Payloads are
+	// web seeds, so it is open for the same reason and guarded by an
+	// allowlist instead.
+	router.HandleFunc("/ipfs/{cid}", gatewayIPFSHandler(ipfsClient)).Methods("GET", "HEAD")
+	router.HandleFunc("/ipfs/{cid}/{path:.*}", gatewayIPFSHandler(ipfsClient)).Methods("GET", "HEAD")
+	router.HandleFunc("/ipns/{name}", gatewayIPNSHandler(ipfsClient)).Methods("GET", "HEAD")
+	router.HandleFunc("/ipns/{name}/{path:.*}", gatewayIPNSHandler(ipfsClient)).Methods("GET", "HEAD")
+
+	// Change streams
+	router.HandleFunc("/events", protectStream(auth.ScopeRead, allEventsHandler())).Methods("GET")
+	router.HandleFunc("/tables/{id}/events", protectStream(auth.ScopeRead, tableEventsHandler())).Methods("GET")
+	router.HandleFunc("/ns/{ns}/tables/{id}/events", protectStream(auth.ScopeRead, inNamespace(tableEventsHandler()))).Methods("GET")
+	router.HandleFunc("/ws", protectStream(auth.ScopeRead, websocketHandler())).Methods("GET")
+
+	// Outbound webhooks
+	router.HandleFunc("/webhooks", protect(auth.ScopeAdmin, listWebhooksHandler())).Methods("GET")
+	router.HandleFunc("/webhooks", protect(auth.ScopeAdmin, createWebhookHandler())).Methods("POST")
+	router.HandleFunc("/webhooks/{id}", protect(auth.ScopeAdmin, getWebhookHandler())).Methods("GET")
+	router.HandleFunc("/webhooks/{id}", protect(auth.ScopeAdmin, deleteWebhookHandler())).Methods("DELETE")
+	router.HandleFunc("/webhooks/{id}/deliveries", protect(auth.ScopeAdmin, listDeliveriesHandler())).Methods("GET")
+	router.HandleFunc("/webhooks/{id}/deliveries/{deliveryId}/replay", protect(auth.ScopeAdmin, replayDeliveryHandler())).Methods("POST")
+
+	// API keys
+	router.HandleFunc("/auth/whoami", protect(auth.ScopeRead, whoamiHandler())).Methods("GET")
+	router.HandleFunc("/auth/keys", protect(auth.ScopeAdmin, listAPIKeysHandler())).Methods("GET")
+	router.HandleFunc("/auth/keys", protect(auth.ScopeAdmin, createAPIKeyHandler())).Methods("POST")
+	router.HandleFunc("/auth/keys/{id}", protect(auth.ScopeAdmin, revokeAPIKeyHandler())).Methods("DELETE")
 
 	log.Println("[HANDLERS] Table routes registered successfully")
 }
@@ -125,17 +455,24 @@ func getAllTablesHandler() http.HandlerFunc {
 		// Check for force refresh parameter
 		forceRefresh := r.URL.Query().Get("refresh") == "true"
 
-		// Get all tables from storage
+		// Get the namespace's tables from storage
+		ns := requestNamespace(r)
 		tables := make([]map[string]interface{}, 0)
-		for _, storage := range tableStorage {
+		for id, storage := range namespaceTables(ns) {
+			if !canAccess(r, id, models.RoleReader) {
+				continue
+			}
+
 			// Only reload from IPFS if explicitly requested
 			if forceRefresh {
 				log.Printf("[GET_ALL_TABLES] Force refresh requested, reloading table %s from IPFS", storage.GetTable().ID)
-				if err := storage.LoadTable(); err != nil {
+				if err := storage.LoadTable(r.Context()); err != nil {
 					log.Printf("[GET_ALL_TABLES] Warning: Failed to load table %s from IPFS: %v", storage.GetTable().ID, err)
 					// Continue with cached data
 				} else {
 					log.Printf("[GET_ALL_TABLES] Successfully reloaded table %s", storage.GetTable().ID)
+					// A mirror may have followed its origin to a new key
+					followMirror(storage)
 				}
 			} else {
 				log.Printf("[GET_ALL_TABLES] Using cached data for table %s", storage.GetTable().ID)
@@ -144,11 +481,13 @@ func getAllTablesHandler() http.HandlerFunc {
 			table := storage.GetTable()
 			tableInfo := map[string]interface{}{
 				"id":          table.ID,
+				"namespace":   ns,
 				"name":        table.Name,
 				"description": table.Description,
 				"createdAt":   table.CreatedAt,
 				"updatedAt":   table.UpdatedAt,
 				"ipns_name":   storage.GetIPNSName(),
+				"private":     storage.IsPrivate(),
 				"status":      "active",
 				"cached":      !forceRefresh,
 			}
@@ -156,8 +495,9 @@ func getAllTablesHandler() http.HandlerFunc {
 		}
 
 		response := map[string]interface{}{
-			"tables": tables,
-			"count":  len(tables),
+			"namespace": ns,
+			"tables":    tables,
+			"count":     len(tables),
 		}
 
 		responseJSON, _ := json.Marshal(response)
@@ -170,7 +510,7 @@ func getAllTablesHandler() http.HandlerFunc {
 
 func createTableHandlerNew(ipfsClient *ipfs.IPFSClient) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		log.Printf("[CREATE_TABLE_NEW] Handler called")
+		log.Printf("[CREATE_TABLE_NEW] Handler called by %s", auth.Actor(r.Context()))
 
 		// Read the request body
 		body, err := io.ReadAll(r.Body)
@@ -198,38 +538,87 @@ func createTableHandlerNew(ipfsClient *ipfs.IPFSClient) http.HandlerFunc {
 		description := getStringField(req, "description", "")
 		data := getStringField(req, "data", "[]")
 
+		lifetime, ttl, err := parsePublishSettings(req)
+		if err != nil {
+			log.Printf("[CREATE_TABLE_NEW] Invalid IPNS record settings: %v", err)
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
 		log.Printf("[CREATE_TABLE_NEW] Using table name: %s", tableName)
 
-		// Check if table already exists by name
-		for _, stor := range tableStorage {
-			if stor.GetTable().Name == tableName {
-				log.Printf("[CREATE_TABLE_NEW] Table already exists: %s", tableName)
-				http.Error(w, "Table with this name already exists", http.StatusConflict)
-				return
-			}
+		// Names are unique within a namespace
+		ns := requestNamespace(r)
+		if strings.Contains(tableName, "/") {
+			http.Error(w, "Table names cannot contain '/'", http.StatusBadRequest)
+			return
+		}
+		if other, taken := tableNamed(ns, tableName); taken {
+			log.Printf("[CREATE_TABLE_NEW] Table %s already exists in namespace %s: %s", other, ns, tableName)
+			http.Error(w, "Table with this name already exists", http.StatusConflict)
+			return
+		}
+		size, err := dataSize(data)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
 		}
+		releaseQuota, ok := holdQuota(w, ns, 1, size)
+		if !ok {
+			return
+		}
+		defer releaseQuota()
 
-		// Create new storage instance with unique ID
-		tableID := tableName // Use name as ID for now, but could generate UUID
+		// Create new storage instance with a generated ID; the name can change later
+		tableID, err := newTableID(ns)
+		if err != nil {
+			log.Printf("[CREATE_TABLE_NEW] Error generating table ID: %v", err)
+			http.Error(w, "Failed to generate table ID", http.StatusInternalServerError)
+			return
+		}
 		storage := storage.NewStorage(ipfsClient.GetShell(), tableID, tableName, description)
+		storage.SetPublishSettings(lifetime, ttl)
+
+		// Private tables are published encrypted
+		if private, _ := req["private"].(bool); private {
+			key, err := newTableKey(req)
+			if err != nil {
+				log.Printf("[CREATE_TABLE_NEW] Invalid encryption settings: %v", err)
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			storage.SetEncryption(key)
+		}
 
-		// If data is provided, try to parse and add it
+		// If data is provided, try to parse and add it. Its versions are
+		// checked like appended ones.
 		if data != "" && data != "[]" {
-			if err := storage.UpdateTableData("", "", data); err != nil {
+			verified, status, err := verifyTableData([]string{tableID}, "", data)
+			if err != nil {
+				log.Printf("[CREATE_TABLE_NEW] Rejected initial data: %v", err)
+				http.Error(w, err.Error(), status)
+				return
+			}
+			if err := storage.UpdateTableData(r.Context(), "", "", verified); err != nil {
 				log.Printf("[CREATE_TABLE_NEW] Warning: Failed to parse initial data: %v", err)
 			}
 		}
 
 		// Save initial table to get hash
-		hash, err := storage.SaveInitialTable()
+		hash, err := storage.SaveInitialTable(r.Context())
 		if err != nil {
 			log.Printf("[CREATE_TABLE_NEW] Error creating table: %v", err)
 			http.Error(w, "Failed to create table: "+err.Error(), http.StatusInternalServerError)
 			return
 		}
 
-		// Store in memory using table ID as key
-		tableStorage[tableID] = storage
+		// Store in memory using table ID as key, owned by the caller
+		setACL(tableID, ownedBy(r))
+		storeTable(tableID, storage)
+
+		entry := newAuditEntry(r, audit.ActionCreate, tableID)
+		entry.AfterCID = hash
+		recordAudit(entry)
 
 		// Save registry to disk
 		if err := saveRegistry(); err != nil {
@@ -239,12 +628,14 @@ func createTableHandlerNew(ipfsClient *ipfs.IPFSClient) http.HandlerFunc {
 		table := storage.GetTable()
 		response := map[string]interface{}{
 			"id":          table.ID,
+			"namespace":   ns,
 			"name":        table.Name,
 			"description": table.Description,
 			"data":        table.Data,
 			"createdAt":   table.CreatedAt,
 			"updatedAt":   table.UpdatedAt,
 			"ipns_name":   storage.GetIPNSName(),
+			"private":     storage.IsPrivate(),
 			"hash":        hash,
 			"status":      "created",
 			"success":     true,
@@ -261,13 +652,12 @@ func createTableHandlerNew(ipfsClient *ipfs.IPFSClient) http.HandlerFunc {
 
 func getTableHandler() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		vars := mux.Vars(r)
-		id := vars["id"]
+		id := requestTableID(r)
 		log.Printf("[GET_TABLE] Handler called for ID: %s", id)
 
 		w.Header().Set("Content-Type", "application/json")
 
-		storage, exists := tableStorage[id]
+		storage, exists := lookupTable(id)
 		if !exists {
 			log.Printf("[GET_TABLE] Table not found: %s", id)
 			response := map[string]interface{}{
@@ -281,6 +671,9 @@ func getTableHandler() http.HandlerFunc {
 			w.Write(responseJSON)
 			return
 		}
+		if !authorizeTable(w, r, id, models.RoleReader) {
+			return
+		}
 
 		// Always use cached data for fast response
 		log.Printf("[GET_TABLE] Using cached data for table: %s", id)
@@ -288,12 +681,14 @@ func getTableHandler() http.HandlerFunc {
 		table := storage.GetTable()
 		response := map[string]interface{}{
 			"id":          table.ID,
+			"namespace":   requestNamespace(r),
 			"name":        table.Name,
 			"description": table.Description,
 			"data":        table.Data,
 			"createdAt":   table.CreatedAt,
 			"updatedAt":   table.UpdatedAt,
 			"ipns_name":   storage.GetIPNSName(),
+			"private":     storage.IsPrivate(),
 			"status":      "active",
 		}
 
@@ -305,9 +700,8 @@ func getTableHandler() http.HandlerFunc {
 
 func updateTableHandler() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		vars := mux.Vars(r)
-		id := vars["id"]
-		log.Printf("[UPDATE_TABLE] Handler called for ID: %s", id)
+		id := requestTableID(r)
+		log.Printf("[UPDATE_TABLE] Handler called for ID: %s by %s", id, auth.Actor(r.Context()))
 
 		// Read the request body
 		body, err := io.ReadAll(r.Body)
@@ -325,28 +719,79 @@ func updateTableHandler() http.HandlerFunc {
 			return
 		}
 
-		storage := tableStorage[id]
-		if storage == nil {
+		storage, exists := lookupTable(id)
+		if !exists {
 			log.Printf("[UPDATE_TABLE] Table not found: %s", id)
 			http.Error(w, "Table not found", http.StatusNotFound)
 			return
 		}
+		if !authorizeTable(w, r, id, models.RoleWriter) {
+			return
+		}
+		if rejectMirror(w, storage) {
+			return
+		}
 
 		// Extract update fields
 		name := getStringField(req, "name", "")
 		description := getStringField(req, "description", "")
 		data := getStringField(req, "data", "")
 
+		if strings.Contains(name, "/") {
+			http.Error(w, "Table names cannot contain '/'", http.StatusBadRequest)
+			return
+		}
+		if name != "" {
+			ns, _ := models.SplitTableRef(id)
+			if other, taken := tableNamed(ns, name); taken && other != id {
+				log.Printf("[UPDATE_TABLE] Name %s is already used by table %s", name, other)
+				http.Error(w, "Table with this name already exists", http.StatusConflict)
+				return
+			}
+		}
+
+		// A new version list is checked like appended versions, and what it
+		// adds to the payload bytes counts against the namespace quota
+		if data != "" {
+			table := storage.GetTable()
+			verified, status, err := verifyTableData(table.IDs(), table.Data, data)
+			if err != nil {
+				log.Printf("[UPDATE_TABLE] Rejected data for table %s: %v", id, err)
+				http.Error(w, err.Error(), status)
+				return
+			}
+			data = verified
+
+			size, err := dataSize(data)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			current, _ := dataSize(table.Data)
+			if growth := size - current; growth > 0 {
+				ns, _ := models.SplitTableRef(id)
+				releaseQuota, ok := holdQuota(w, ns, 0, growth)
+				if !ok {
+					return
+				}
+				defer releaseQuota()
+			}
+		}
+
 		log.Printf("[UPDATE_TABLE] Updating table %s with data length: %d", id, len(data))
+		entry := newAuditEntry(r, audit.ActionUpdate, id)
+		entry.BeforeCID = storage.GetPublishStatus().HeadCID
 
 		// Update the table - this will save to IPFS
-		if err := storage.UpdateTableData(name, description, data); err != nil {
+		if err := storage.UpdateTableData(r.Context(), name, description, data); err != nil {
 			log.Printf("[UPDATE_TABLE] Error updating table: %v", err)
 			http.Error(w, "Failed to update table: "+err.Error(), http.StatusInternalServerError)
 			return
 		}
 
 		log.Printf("[UPDATE_TABLE] Table %s updated successfully", id)
+		entry.AfterCID = storage.GetPublishStatus().HeadCID
+		recordAudit(entry)
 
 		// Save registry to disk
 		if err := saveRegistry(); err != nil {
@@ -376,20 +821,23 @@ func updateTableHandler() http.HandlerFunc {
 
 func deleteTableHandler() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		vars := mux.Vars(r)
-		id := vars["id"]
-		log.Printf("[DELETE_TABLE] Handler called for ID: %s", id)
+		id := requestTableID(r)
+		log.Printf("[DELETE_TABLE] Handler called for ID: %s by %s", id, auth.Actor(r.Context()))
 
-		_, exists := tableStorage[id]
-		if !exists {
-			log.Printf("[DELETE_TABLE] Table not found: %s", id)
-			http.Error(w, "Table not found", http.StatusNotFound)
+		if _, exists := lookupTable(id); exists && !authorizeTable(w, r, id, models.RoleOwner) {
 			return
 		}
+		entry := newAuditEntry(r, audit.ActionDelete, id)
+		entry.BeforeCID = headCID(id)
 
 		// Remove from storage
-		delete(tableStorage, id)
+		if _, exists := removeTable(id); !exists {
+			log.Printf("[DELETE_TABLE] Table not found: %s", id)
+			http.Error(w, "Table not found", http.StatusNotFound)
+			return
+		}
 		log.Printf("[DELETE_TABLE] Table deleted from memory: %s", id)
+		recordAudit(entry)
 
 		// Save registry to disk
 		if err := saveRegistry(); err != nil {
@@ -413,69 +861,69 @@ func deleteTableHandler() http.HandlerFunc {
 // Fixed AppendToTable function - Fast version
 func AppendToTable(ipfsClient *ipfs.IPFSClient) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		vars := mux.Vars(r)
-		tableID := vars["id"]
+		tableID := requestTableID(r)
 
-		log.Printf("[APPEND] === Starting FAST append operation for table: %s ===", tableID)
+		log.Printf("[APPEND] === Starting FAST append operation for table: %s by %s ===", tableID, auth.Actor(r.Context()))
 
 		// Get existing table storage
-		stor, exists := tableStorage[tableID]
+		stor, exists := lookupTable(tableID)
 		if !exists {
 			log.Printf("[APPEND] Table not found: %s", tableID)
 			http.Error(w, "Table not found", http.StatusNotFound)
 			return
 		}
+		if !authorizeTable(w, r, tableID, models.RoleWriter) {
+			return
+		}
+		if rejectMirror(w, stor) {
+			return
+		}
 
 		// Parse new item from request body
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			log.Printf("[APPEND] Error reading body: %v", err)
+			http.Error(w, "Error reading request body", http.StatusBadRequest)
+			return
+		}
 		var newItem interface{}
-		if err := json.NewDecoder(r.Body).Decode(&newItem); err != nil {
+		if err := json.Unmarshal(body, &newItem); err != nil {
 			log.Printf("[APPEND] Error parsing JSON: %v", err)
 			http.Error(w, "Invalid JSON", http.StatusBadRequest)
 			return
 		}
 		log.Printf("[APPEND] Parsed new item: %+v", newItem)
 
-		// Get current table data
-		table := stor.GetTable()
-		log.Printf("[APPEND] Current data count before append")
-
-		// Parse existing data
-		var existingData []interface{}
-		if err := json.Unmarshal([]byte(table.Data), &existingData); err != nil {
-			log.Printf("[APPEND] Error parsing existing data: %v", err)
-			existingData = []interface{}{} // Initialize empty if parsing fails
-		}
-
-		// Append new item
-		existingData = append(existingData, newItem)
-		log.Printf("[APPEND] New data count after append: %d", len(existingData))
-
-		// Convert back to JSON string
-		updatedDataBytes, err := json.MarshalIndent(existingData, "", "  ")
+		// Versions describing a payload count against the namespace quota
+		size, err := payloadSize(newItem)
 		if err != nil {
-			log.Printf("[APPEND] Error marshaling data: %v", err)
-			http.Error(w, "Failed to serialize data", http.StatusInternalServerError)
+			http.Error(w, err.Error(), http.StatusBadRequest)
 			return
 		}
+		if size > 0 {
+			ns, _ := models.SplitTableRef(tableID)
+			releaseQuota, ok := holdQuota(w, ns, 0, size)
+			if !ok {
+				return
+			}
+			defer releaseQuota()
+		}
 
-		// FAST UPDATE: Only update in-memory data and create new IPFS hash
-		// Don't wait for IPNS propagation
-		log.Printf("[APPEND] Performing fast in-memory update...")
-		if err := stor.FastUpdateData(string(updatedDataBytes)); err != nil {
-			log.Printf("[APPEND] ERROR: Failed to update table: %v", err)
-			http.Error(w, "Failed to save table", http.StatusInternalServerError)
+		// Check the publisher signature, if any
+		newItem, status, err := verifyAppendedItem(stor.GetTable().IDs(), body, newItem)
+		if err != nil {
+			log.Printf("[APPEND] Rejected version for table %s: %v", tableID, err)
+			http.Error(w, err.Error(), status)
 			return
 		}
 
-		log.Printf("[APPEND] Successfully appended item to table %s", tableID)
-
-		// Save registry to disk (fast operation)
-		if err := saveRegistry(); err != nil {
-			log.Printf("[APPEND] Warning: Failed to save registry: %v", err)
+		updatedTable, err := appendItem(r, stor, newItem)
+		if err != nil {
+			http.Error(w, "Failed to append item: "+err.Error(), http.StatusInternalServerError)
+			return
 		}
 
 		// Get updated table data for response
-		updatedTable := stor.GetTable()
 		response := map[string]interface{}{
 			"success":     true,
 			"message":     "Item appended successfully",
@@ -490,17 +938,71 @@ func AppendToTable(ipfsClient *ipfs.IPFSClient) http.HandlerFunc {
 		json.NewEncoder(w).Encode(response)
 
 		log.Printf("[APPEND] === Fast append operation completed ===")
+	}
+}
 
-		// OPTIONAL: Trigger background IPFS/IPNS update (don't wait for it)
-		go func() {
-			log.Printf("[APPEND] Background: Starting IPFS/IPNS update for table %s", tableID)
-			if err := stor.BackgroundSaveToIPFS(); err != nil {
-				log.Printf("[APPEND] Background: Failed to save to IPFS: %v", err)
-			} else {
-				log.Printf("[APPEND] Background: Successfully saved to IPFS")
-			}
-		}()
+// appendItem adds an item to a table's data in memory, then publishes the
+// new snapshot to IPFS/IPNS in the background
+func appendItem(r *http.Request, stor *storage.Storage, newItem interface{}) (*models.Table, error) {
+	// Get current table data
+	table := stor.GetTable()
+	tableID := table.ID
+	log.Printf("[APPEND] Current data count before append")
+
+	// Parse existing data
+	var existingData []interface{}
+	if err := json.Unmarshal([]byte(table.Data), &existingData); err != nil {
+		log.Printf("[APPEND] Error parsing existing data: %v", err)
+		existingData = []interface{}{} // Initialize empty if parsing fails
+	}
+
+	// Append new item
+	existingData = append(existingData, newItem)
+	log.Printf("[APPEND] New data count after append: %d", len(existingData))
+
+	// Convert back to JSON string
+	updatedDataBytes, err := json.MarshalIndent(existingData, "", "  ")
+	if err != nil {
+		log.Printf("[APPEND] Error marshaling data: %v", err)
+		return nil, fmt.Errorf("failed to serialize data: %w", err)
+	}
+
+	// The new snapshot is only known once the background save finishes
+	entry := newAuditEntry(r, audit.ActionAppend, tableID)
+	entry.BeforeCID = stor.GetPublishStatus().HeadCID
+
+	// FAST UPDATE: Only update in-memory data and create new IPFS hash
+	// Don't wait for IPNS propagation
+	log.Printf("[APPEND] Performing fast in-memory update...")
+	if err := stor.FastUpdateData(string(updatedDataBytes)); err != nil {
+		log.Printf("[APPEND] ERROR: Failed to update table: %v", err)
+		return nil, fmt.Errorf("failed to save table: %w", err)
 	}
+
+	log.Printf("[APPEND] Successfully appended item to table %s", tableID)
+
+	// Save registry to disk (fast operation)
+	if err := saveRegistry(); err != nil {
+		log.Printf("[APPEND] Warning: Failed to save registry: %v", err)
+	}
+
+	// OPTIONAL: Trigger background IPFS/IPNS update (don't wait for it).
+	// It outlives the request, so only the per-operation deadlines and
+	// shutdown bound it.
+	goBackground(r.Context(), func(ctx context.Context) {
+		log.Printf("[APPEND] Background: Starting IPFS/IPNS update for table %s", tableID)
+		hash, err := stor.BackgroundSaveToIPFS(ctx)
+		entry.AfterCID = hash
+		if err != nil {
+			log.Printf("[APPEND] Background: Failed to save to IPFS: %v", err)
+			entry.Detail = "background save failed: " + err.Error()
+		} else {
+			log.Printf("[APPEND] Background: Successfully saved to IPFS")
+		}
+		recordAudit(entry)
+	})
+
+	return stor.GetTable(), nil
 }
 
 // Helper function to safely extract string fields from request
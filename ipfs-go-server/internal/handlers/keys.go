@@ -0,0 +1,419 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"time"
+
+	"ipfs-go-server/internal/auth"
+	"ipfs-go-server/internal/ipfs"
+	"ipfs-go-server/internal/keystore"
+	"ipfs-go-server/internal/models"
+)
+
+// backupBundleVersion 2 partitions the registry by namespace; version 1
+// bundles are still restored, into the default namespace
+const backupBundleVersion = 2
+
+// ErrKeyConflict is returned when the keystore already holds a different key
+// under the name an import wants to use.
+var ErrKeyConflict = errors.New("a different key with this name already exists")
+
+// BackupBundle holds the registry and every table's encrypted IPNS key.
+// RotatedKeys are the keys tables were rotated away from and still
+// republish moved pointers with.
+type BackupBundle struct {
+	Version     int                     `json:"version"`
+	CreatedAt   time.Time               `json:"createdAt"`
+	Registry    TableRegistry           `json:"registry"`
+	Keys        []*keystore.KeyEnvelope `json:"keys"`
+	RotatedKeys []*keystore.KeyEnvelope `json:"rotatedKeys,omitempty"`
+}
+
+// ExportTableKey reads a table's IPNS private key from the keystore and seals
+// it with passphrase.
+func ExportTableKey(ctx context.Context, ipfsClient *ipfs.IPFSClient, info TableInfo, passphrase string) (*keystore.KeyEnvelope, error) {
+	key, err := ipfsClient.ExportKey(ctx, info.KeyName)
+	if err != nil {
+		return nil, err
+	}
+	return keystore.Seal(info.ID, info.Name, info.KeyName, info.IPNSName, key, passphrase)
+}
+
+// ImportTableKey opens env and adds its key to the keystore. Importing a key
+// that is already present is a no-op.
+func ImportTableKey(ctx context.Context, ipfsClient *ipfs.IPFSClient, env *keystore.KeyEnvelope, passphrase string) (TableInfo, error) {
+	info := TableInfo{
+		ID:       env.TableID,
+		Name:     env.TableName,
+		KeyName:  env.KeyName,
+		IPNSName: env.IPNSName,
+	}
+
+	key, err := env.Open(passphrase)
+	if err != nil {
+		return info, err
+	}
+
+	existing, err := ipfsClient.FindKey(ctx, env.KeyName)
+	if err != nil {
+		return info, err
+	}
+	if existing != "" {
+		if env.IPNSName != "" && existing != env.IPNSName {
+			return info, fmt.Errorf("%w: %s", ErrKeyConflict, env.KeyName)
+		}
+		info.IPNSName = existing
+		return info, nil
+	}
+
+	id, err := ipfsClient.ImportKey(ctx, env.KeyName, key)
+	if err != nil {
+		return info, err
+	}
+	if env.IPNSName != "" && id != env.IPNSName {
+		return info, fmt.Errorf("imported key %s resolves to %s, expected %s", env.KeyName, id, env.IPNSName)
+	}
+
+	info.IPNSName = id
+	return info, nil
+}
+
+// CreateBackup exports every table in registry into a single bundle.
+func CreateBackup(ctx context.Context, ipfsClient *ipfs.IPFSClient, registry TableRegistry, passphrase string) (*BackupBundle, error) {
+	tables := registry.Tables()
+	ids := make([]string, 0, len(tables))
+	for id, info := range tables {
+		// Mirrors have no key of ours to back up
+		if info.KeyName == "" {
+			continue
+		}
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	bundle := &BackupBundle{
+		Version:   backupBundleVersion,
+		CreatedAt: time.Now().UTC(),
+		Registry:  registry,
+		Keys:      make([]*keystore.KeyEnvelope, 0, len(ids)),
+	}
+
+	for _, id := range ids {
+		env, err := ExportTableKey(ctx, ipfsClient, tables[id], passphrase)
+		if err != nil {
+			return nil, fmt.Errorf("failed to export key for table %s: %w", id, err)
+		}
+		bundle.Keys = append(bundle.Keys, env)
+
+		// Moved pointers at unretired old names are republished with the
+		// old keys, so they are backed up too
+		for _, rotation := range tables[id].KeyHistory {
+			if rotation.Retired {
+				continue
+			}
+			old := tables[id]
+			old.KeyName, old.IPNSName = rotation.OldKeyName, rotation.OldIPNSName
+			env, err := ExportTableKey(ctx, ipfsClient, old, passphrase)
+			if err != nil {
+				return nil, fmt.Errorf("failed to export old key %s of table %s: %w", rotation.OldKeyName, id, err)
+			}
+			bundle.RotatedKeys = append(bundle.RotatedKeys, env)
+		}
+	}
+
+	return bundle, nil
+}
+
+// RestoreBackup imports every key in bundle. It returns the registry entries
+// that were restored and the per-table errors for those that were not.
+func RestoreBackup(ctx context.Context, ipfsClient *ipfs.IPFSClient, bundle *BackupBundle, passphrase string) (TableRegistry, map[string]error) {
+	restored := NewTableRegistry()
+	failed := make(map[string]error)
+
+	if bundle.Version < 1 || bundle.Version > backupBundleVersion {
+		failed["*"] = fmt.Errorf("unsupported backup bundle version %d", bundle.Version)
+		return restored, failed
+	}
+	bundle.Registry.migrate()
+
+	for _, env := range bundle.Keys {
+		info, err := ImportTableKey(ctx, ipfsClient, env, passphrase)
+		if err != nil {
+			failed[env.TableID] = err
+			continue
+		}
+		if original, ok := bundle.Registry.Lookup(info.ID); ok {
+			info.KeyHistory = original.KeyHistory
+			info.RecordLifetime = original.RecordLifetime
+			info.RecordTTL = original.RecordTTL
+			info.ACL = original.ACL
+		}
+		restored.Put(info)
+	}
+
+	// The keys moved pointers are republished with; the tables restore
+	// without them, but their old names expire
+	for _, env := range bundle.RotatedKeys {
+		if _, err := ImportTableKey(ctx, ipfsClient, env, passphrase); err != nil {
+			failed[fmt.Sprintf("%s (old key %s)", env.TableID, env.KeyName)] = err
+		}
+	}
+
+	// Namespaces come back with their quotas
+	for ns, partition := range restored.Namespaces {
+		if original, ok := bundle.Registry.Namespaces[ns]; ok {
+			partition.Quota = original.Quota
+		}
+	}
+
+	return restored, failed
+}
+
+// registerImportedTable loads an imported table from IPNS and adds it to the
+// in-memory registry.
+func registerImportedTable(ctx context.Context, ipfsClient *ipfs.IPFSClient, info TableInfo) error {
+	stor := newStorageFromInfo(ipfsClient, info)
+	if err := stor.LoadTable(ctx); err != nil {
+		return err
+	}
+
+	setACL(info.ID, info.ACL)
+	if !info.ACL.KeyIDs {
+		convertACLs()
+	}
+	storeTable(info.ID, stor)
+	return nil
+}
+
+func exportKeyHandler(ipfsClient *ipfs.IPFSClient) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := requestTableID(r)
+		log.Printf("[KEY_EXPORT] Handler called for ID: %s by %s", id, auth.Actor(r.Context()))
+
+		var req struct {
+			Passphrase string `json:"passphrase"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			log.Printf("[KEY_EXPORT] Error parsing JSON: %v", err)
+			http.Error(w, "Invalid JSON format", http.StatusBadRequest)
+			return
+		}
+		if req.Passphrase == "" {
+			http.Error(w, "passphrase is required", http.StatusBadRequest)
+			return
+		}
+
+		stor, exists := lookupTable(id)
+		if !exists {
+			log.Printf("[KEY_EXPORT] Table not found: %s", id)
+			http.Error(w, "Table not found", http.StatusNotFound)
+			return
+		}
+		if rejectMirror(w, stor) {
+			return
+		}
+
+		registry := currentRegistry()
+		info, _ := registry.Lookup(id)
+		env, err := ExportTableKey(r.Context(), ipfsClient, info, req.Passphrase)
+		if err != nil {
+			log.Printf("[KEY_EXPORT] Error exporting key %s: %v", stor.GetKeyName(), err)
+			http.Error(w, "Failed to export key: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		log.Printf("[KEY_EXPORT] Exported key %s for table %s", env.KeyName, id)
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(env)
+	}
+}
+
+func importKeyHandler(ipfsClient *ipfs.IPFSClient) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		log.Printf("[KEY_IMPORT] Handler called by %s", auth.Actor(r.Context()))
+
+		var req struct {
+			Passphrase string                `json:"passphrase"`
+			Envelope   *keystore.KeyEnvelope `json:"envelope"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			log.Printf("[KEY_IMPORT] Error parsing JSON: %v", err)
+			http.Error(w, "Invalid JSON format", http.StatusBadRequest)
+			return
+		}
+		if req.Envelope == nil || req.Passphrase == "" {
+			http.Error(w, "envelope and passphrase are required", http.StatusBadRequest)
+			return
+		}
+		if _, exists := lookupTable(req.Envelope.TableID); exists {
+			log.Printf("[KEY_IMPORT] Table already exists: %s", req.Envelope.TableID)
+			http.Error(w, "Table with this ID already exists", http.StatusConflict)
+			return
+		}
+		ns, _ := models.SplitTableRef(req.Envelope.TableID)
+		if _, exists := lookupNamespace(ns); !exists {
+			http.Error(w, "Namespace "+ns+" not found; create it first", http.StatusNotFound)
+			return
+		}
+		if other, taken := tableNamed(ns, req.Envelope.TableName); taken {
+			log.Printf("[KEY_IMPORT] Name %s is already used by table %s", req.Envelope.TableName, other)
+			http.Error(w, "Table with this name already exists", http.StatusConflict)
+			return
+		}
+		releaseQuota, ok := holdQuota(w, ns, 1, 0)
+		if !ok {
+			return
+		}
+		defer releaseQuota()
+
+		info, err := ImportTableKey(r.Context(), ipfsClient, req.Envelope, req.Passphrase)
+		if err != nil {
+			log.Printf("[KEY_IMPORT] Error importing key: %v", err)
+			status := http.StatusInternalServerError
+			if errors.Is(err, keystore.ErrBadPassphrase) {
+				status = http.StatusUnauthorized
+			} else if errors.Is(err, ErrKeyConflict) {
+				status = http.StatusConflict
+			}
+			http.Error(w, "Failed to import key: "+err.Error(), status)
+			return
+		}
+
+		info.ACL = ownedBy(r)
+		if err := registerImportedTable(r.Context(), ipfsClient, info); err != nil {
+			log.Printf("[KEY_IMPORT] Key imported but table %s could not be loaded: %v", info.ID, err)
+			http.Error(w, "Key imported but table could not be resolved from IPNS: "+err.Error(), http.StatusBadGateway)
+			return
+		}
+
+		if err := saveRegistry(); err != nil {
+			log.Printf("[KEY_IMPORT] Warning: Failed to save registry: %v", err)
+		}
+
+		log.Printf("[KEY_IMPORT] Imported table %s (%s)", info.ID, info.IPNSName)
+
+		response := map[string]interface{}{
+			"success":   true,
+			"id":        info.ID,
+			"name":      info.Name,
+			"keyName":   info.KeyName,
+			"ipns_name": info.IPNSName,
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(response)
+	}
+}
+
+func createBackupHandler(ipfsClient *ipfs.IPFSClient) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		log.Printf("[BACKUP] Handler called by %s", auth.Actor(r.Context()))
+
+		var req struct {
+			Passphrase string `json:"passphrase"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			log.Printf("[BACKUP] Error parsing JSON: %v", err)
+			http.Error(w, "Invalid JSON format", http.StatusBadRequest)
+			return
+		}
+		if req.Passphrase == "" {
+			http.Error(w, "passphrase is required", http.StatusBadRequest)
+			return
+		}
+
+		bundle, err := CreateBackup(r.Context(), ipfsClient, currentRegistry(), req.Passphrase)
+		if err != nil {
+			log.Printf("[BACKUP] Error creating backup: %v", err)
+			http.Error(w, "Failed to create backup: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		log.Printf("[BACKUP] Backup created with %d keys", len(bundle.Keys))
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="tables-backup-%d.json"`, bundle.CreatedAt.Unix()))
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(bundle)
+	}
+}
+
+func restoreBackupHandler(ipfsClient *ipfs.IPFSClient) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		log.Printf("[RESTORE] Handler called by %s", auth.Actor(r.Context()))
+
+		var req struct {
+			Passphrase string        `json:"passphrase"`
+			Bundle     *BackupBundle `json:"bundle"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			log.Printf("[RESTORE] Error parsing JSON: %v", err)
+			http.Error(w, "Invalid JSON format", http.StatusBadRequest)
+			return
+		}
+		if req.Bundle == nil || req.Passphrase == "" {
+			http.Error(w, "bundle and passphrase are required", http.StatusBadRequest)
+			return
+		}
+
+		restored, failed := RestoreBackup(r.Context(), ipfsClient, req.Bundle, req.Passphrase)
+
+		// Namespaces missing here are created with the quota they had
+		for ns, partition := range restored.Namespaces {
+			if _, exists := lookupNamespace(ns); !exists {
+				setNamespace(ns, partition.Quota)
+			}
+		}
+
+		tables := restored.Tables()
+		loaded := make([]string, 0, len(tables))
+		skipped := make([]string, 0)
+		for id, info := range tables {
+			if _, exists := lookupTable(id); exists {
+				skipped = append(skipped, id)
+				continue
+			}
+			if err := registerImportedTable(r.Context(), ipfsClient, info); err != nil {
+				failed[id] = err
+				continue
+			}
+			loaded = append(loaded, id)
+		}
+		sort.Strings(loaded)
+		sort.Strings(skipped)
+
+		if len(loaded) > 0 {
+			if err := saveRegistry(); err != nil {
+				log.Printf("[RESTORE] Warning: Failed to save registry: %v", err)
+			}
+		}
+
+		errs := make(map[string]string, len(failed))
+		for id, err := range failed {
+			log.Printf("[RESTORE] Failed to restore table %s: %v", id, err)
+			errs[id] = err.Error()
+		}
+
+		log.Printf("[RESTORE] Restored %d tables, skipped %d, failed %d", len(loaded), len(skipped), len(errs))
+
+		response := map[string]interface{}{
+			"success":  len(errs) == 0,
+			"restored": loaded,
+			"skipped":  skipped,
+			"failed":   errs,
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(response)
+	}
+}
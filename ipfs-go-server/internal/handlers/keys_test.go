@@ -0,0 +1,66 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"ipfs-go-server/internal/ipfs"
+	"ipfs-go-server/internal/models"
+)
+
+func TestBackupKeepsUnretiredRotatedKeys(t *testing.T) {
+	// Keys missing from the fake daemon must not be read from a real keystore
+	t.Setenv("IPFS_PATH", t.TempDir())
+	ctx := context.Background()
+
+	origin, _ := newFakeIPFS(t)
+	current, kept, retired := []byte("current key"), []byte("kept key"), []byte("retired key")
+	origin.keys["torrentchain.release-2"] = current
+	origin.keys["torrentchain.release-1"] = kept
+
+	registry := NewTableRegistry()
+	registry.Put(TableInfo{
+		ID:       "release",
+		Name:     "release",
+		KeyName:  "torrentchain.release-2",
+		IPNSName: fakeKeyID(current),
+		KeyHistory: []models.KeyRotation{
+			{OldKeyName: "torrentchain.release", OldIPNSName: fakeKeyID(retired), NewKeyName: "torrentchain.release-1", Retired: true},
+			{OldKeyName: "torrentchain.release-1", OldIPNSName: fakeKeyID(kept), NewKeyName: "torrentchain.release-2"},
+		},
+	})
+
+	bundle, err := CreateBackup(ctx, ipfs.NewIPFSClient(origin.url), registry, "correct horse")
+	if err != nil {
+		t.Fatalf("CreateBackup: %v", err)
+	}
+	if len(bundle.Keys) != 1 || len(bundle.RotatedKeys) != 1 || bundle.RotatedKeys[0].KeyName != "torrentchain.release-1" {
+		t.Fatalf("bundle holds %d keys and rotated keys %v, want the current key and torrentchain.release-1", len(bundle.Keys), bundle.RotatedKeys)
+	}
+
+	// A fresh node gets the old key back, so it can keep republishing the
+	// moved pointer at the old name
+	node, _ := newFakeIPFS(t)
+	restored, failed := RestoreBackup(ctx, ipfs.NewIPFSClient(node.url), bundle, "correct horse")
+	if len(failed) > 0 {
+		t.Fatalf("RestoreBackup failed: %v", failed)
+	}
+	if _, ok := restored.Lookup("release"); !ok {
+		t.Fatal("table was not restored")
+	}
+	if !bytes.Equal(node.keys["torrentchain.release-2"], current) || !bytes.Equal(node.keys["torrentchain.release-1"], kept) {
+		t.Fatalf("restored keystore holds %v, want the current and the unretired old key", node.keys)
+	}
+	if _, exists := node.keys["torrentchain.release"]; exists {
+		t.Fatal("a retired key was restored")
+	}
+
+	// An old key that cannot be restored is reported, the table still is
+	node.keys["torrentchain.release-1"] = []byte("another key")
+	delete(node.keys, "torrentchain.release-2")
+	restored, failed = RestoreBackup(ctx, ipfs.NewIPFSClient(node.url), bundle, "correct horse")
+	if _, ok := restored.Lookup("release"); !ok || len(failed) != 1 {
+		t.Fatalf("RestoreBackup with a conflicting old key restored %v and failed %v, want the table and one failure", restored.Tables(), failed)
+	}
+}
@@ -0,0 +1,391 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"sort"
+	"sync"
+
+	"ipfs-go-server/internal/audit"
+	"ipfs-go-server/internal/auth"
+	"ipfs-go-server/internal/models"
+	"ipfs-go-server/internal/storage"
+
+	"github.com/gorilla/mux"
+)
+
+// ErrQuotaExceeded is returned when a change would take a namespace past
+// its quota
+var ErrQuotaExceeded = errors.New("namespace quota exceeded")
+
+// namespaces holds each namespace's quota. Guarded by tablesMu.
+var namespaces = map[string]models.Quota{models.DefaultNamespace: {}}
+
+// lookupNamespace returns a namespace's quota
+func lookupNamespace(ns string) (models.Quota, bool) {
+	tablesMu.RLock()
+	defer tablesMu.RUnlock()
+
+	quota, exists := namespaces[ns]
+	return quota, exists
+}
+
+// setNamespace adds a namespace or replaces its quota
+func setNamespace(ns string, quota models.Quota) {
+	tablesMu.Lock()
+	defer tablesMu.Unlock()
+
+	namespaces[ns] = quota
+}
+
+// allNamespaces returns a copy of every namespace's quota
+func allNamespaces() map[string]models.Quota {
+	tablesMu.RLock()
+	defer tablesMu.RUnlock()
+
+	quotas := make(map[string]models.Quota, len(namespaces))
+	for ns, quota := range namespaces {
+		quotas[ns] = quota
+	}
+	return quotas
+}
+
+// namespaceTables returns the tables of a namespace by server-wide ID
+func namespaceTables(ns string) map[string]*storage.Storage {
+	tables := make(map[string]*storage.Storage)
+	for id, stor := range allTables() {
+		if tableNS, _ := models.SplitTableRef(id); tableNS == ns {
+			tables[id] = stor
+		}
+	}
+	return tables
+}
+
+// namespaceUsage counts a namespace's tables and the payload bytes of their
+// versions
+func namespaceUsage(ns string) models.Usage {
+	var usage models.Usage
+	for _, stor := range namespaceTables(ns) {
+		usage.Tables++
+
+		var versions []models.TorrentVersion
+		if err := json.Unmarshal([]byte(stor.GetTable().Data), &versions); err != nil {
+			continue
+		}
+		for _, v := range versions {
+			usage.Bytes += v.FileSize
+		}
+	}
+	return usage
+}
+
+// payloadSize returns the fileSize of an item about to be stored in a
+// table. Quotas add sizes up, so a size must be a whole, non-negative
+// number of bytes. Items without one count as zero.
+func payloadSize(item interface{}) (int64, error) {
+	fields, ok := item.(map[string]interface{})
+	if !ok || fields["fileSize"] == nil {
+		return 0, nil
+	}
+	size, ok := fields["fileSize"].(float64)
+	if !ok || size < 0 || size != math.Trunc(size) || size >= math.MaxInt64 {
+		return 0, fmt.Errorf("fileSize must be a whole number of bytes, got %v", fields["fileSize"])
+	}
+	return int64(size), nil
+}
+
+// dataSize returns the summed fileSize of the versions in table data
+func dataSize(data string) (int64, error) {
+	var items []interface{}
+	if err := json.Unmarshal([]byte(data), &items); err != nil {
+		return 0, nil
+	}
+
+	var total int64
+	for i, item := range items {
+		size, err := payloadSize(item)
+		if err != nil {
+			return 0, fmt.Errorf("version %d: %w", i, err)
+		}
+		total += size
+	}
+	return total, nil
+}
+
+// Quota checks reserve the growth they allow until it shows up in the
+// namespace's usage, so concurrent creates, follows and uploads cannot all
+// fit into the same headroom. quotaMu is separate from tablesMu because
+// counting usage takes each table's lock, and tables call back into the
+// handlers while holding theirs.
+var (
+	quotaMu  sync.Mutex
+	reserved = make(map[string]models.Usage)
+)
+
+// reserveQuota returns an error unless a namespace may grow by the given
+// number of tables and payload bytes, and holds that growth until release
+// is called. Call release once the growth is part of the namespace's
+// tables, or when it is abandoned.
+func reserveQuota(ns string, tables int, bytes int64) (release func(), err error) {
+	quotaMu.Lock()
+	defer quotaMu.Unlock()
+
+	quota, exists := lookupNamespace(ns)
+	if !exists {
+		return nil, fmt.Errorf("namespace %s not found", ns)
+	}
+	usage, held := namespaceUsage(ns), reserved[ns]
+	usage.Tables += held.Tables
+	usage.Bytes += held.Bytes
+	if err := quota.Allows(usage, tables, bytes); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrQuotaExceeded, err)
+	}
+	reserved[ns] = models.Usage{Tables: held.Tables + tables, Bytes: held.Bytes + bytes}
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			quotaMu.Lock()
+			defer quotaMu.Unlock()
+
+			held := reserved[ns]
+			held.Tables -= tables
+			held.Bytes -= bytes
+			if held == (models.Usage{}) {
+				delete(reserved, ns)
+			} else {
+				reserved[ns] = held
+			}
+		})
+	}, nil
+}
+
+// holdQuota reserves growth of a namespace like reserveQuota, or writes a
+// 403 response and returns false when the quota refuses it
+func holdQuota(w http.ResponseWriter, ns string, tables int, bytes int64) (release func(), ok bool) {
+	release, err := reserveQuota(ns, tables, bytes)
+	if err == nil {
+		return release, true
+	}
+
+	log.Printf("[NAMESPACES] Refused growth of namespace %s: %v", ns, err)
+	http.Error(w, err.Error(), http.StatusForbidden)
+	return nil, false
+}
+
+// requestNamespace is the namespace a request addresses; routes outside
+// /ns/{ns} address the default namespace
+func requestNamespace(r *http.Request) string {
+	if ns := mux.Vars(r)["ns"]; ns != "" {
+		return ns
+	}
+	return models.DefaultNamespace
+}
+
+// requestTableID is the server-wide ID of the table a request addresses.
+// {id} may also be the table's name within the namespace.
+func requestTableID(r *http.Request) string {
+	ns, ref := requestNamespace(r), mux.Vars(r)["id"]
+	id := models.TableRef(ns, ref)
+	if _, exists := lookupTable(id); !exists {
+		if named, ok := tableNamed(ns, ref); ok {
+			return named
+		}
+	}
+	return id
+}
+
+// tableRoute registers a table route at path for the default namespace and
+// under /ns/{ns} for every namespace
+func tableRoute(router *mux.Router, path string, scope auth.Scope, h http.HandlerFunc, methods ...string) {
+	router.HandleFunc(path, protect(scope, h)).Methods(methods...)
+	router.HandleFunc("/ns/{ns}"+path, protect(scope, inNamespace(h))).Methods(methods...)
+}
+
+// inNamespace answers 404 for namespaces that do not exist
+func inNamespace(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if _, exists := lookupNamespace(mux.Vars(r)["ns"]); !exists {
+			http.Error(w, "Namespace not found", http.StatusNotFound)
+			return
+		}
+		next(w, r)
+	}
+}
+
+// namespaceInfo describes a namespace in responses
+func namespaceInfo(ns string, quota models.Quota) map[string]interface{} {
+	return map[string]interface{}{
+		"name":  ns,
+		"quota": quota,
+		"usage": namespaceUsage(ns),
+	}
+}
+
+func listNamespacesHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		quotas := allNamespaces()
+		names := make([]string, 0, len(quotas))
+		for ns := range quotas {
+			names = append(names, ns)
+		}
+		sort.Strings(names)
+
+		list := make([]map[string]interface{}, 0, len(names))
+		for _, ns := range names {
+			list = append(list, namespaceInfo(ns, quotas[ns]))
+		}
+
+		response := map[string]interface{}{
+			"namespaces": list,
+			"count":      len(list),
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(response)
+	}
+}
+
+func getNamespaceHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ns := mux.Vars(r)["ns"]
+		quota, exists := lookupNamespace(ns)
+		if !exists {
+			http.Error(w, "Namespace not found", http.StatusNotFound)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(namespaceInfo(ns, quota))
+	}
+}
+
+func createNamespaceHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		log.Printf("[NAMESPACES] Create handler called by %s", auth.Actor(r.Context()))
+
+		var req struct {
+			Name  string       `json:"name"`
+			Quota models.Quota `json:"quota"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			log.Printf("[NAMESPACES] Error parsing JSON: %v", err)
+			http.Error(w, "Invalid JSON format", http.StatusBadRequest)
+			return
+		}
+		if err := models.ValidateNamespace(req.Name); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := req.Quota.Validate(); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if _, exists := lookupNamespace(req.Name); exists {
+			http.Error(w, "Namespace already exists", http.StatusConflict)
+			return
+		}
+
+		setNamespace(req.Name, req.Quota)
+		if err := saveRegistry(); err != nil {
+			log.Printf("[NAMESPACES] Warning: Failed to save registry: %v", err)
+		}
+		log.Printf("[NAMESPACES] Created namespace %s", req.Name)
+
+		entry := newAuditEntry(r, audit.ActionCreateNamespace, "")
+		entry.Detail = req.Name
+		recordAudit(entry)
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(namespaceInfo(req.Name, req.Quota))
+	}
+}
+
+func updateNamespaceHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ns := mux.Vars(r)["ns"]
+		log.Printf("[NAMESPACES] Update handler called for %s by %s", ns, auth.Actor(r.Context()))
+
+		if _, exists := lookupNamespace(ns); !exists {
+			http.Error(w, "Namespace not found", http.StatusNotFound)
+			return
+		}
+
+		var req struct {
+			Quota models.Quota `json:"quota"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			log.Printf("[NAMESPACES] Error parsing JSON: %v", err)
+			http.Error(w, "Invalid JSON format", http.StatusBadRequest)
+			return
+		}
+		if err := req.Quota.Validate(); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		// A quota below the current usage only stops further growth
+		setNamespace(ns, req.Quota)
+		if err := saveRegistry(); err != nil {
+			log.Printf("[NAMESPACES] Warning: Failed to save registry: %v", err)
+		}
+		log.Printf("[NAMESPACES] Namespace %s quota is now %+v", ns, req.Quota)
+
+		entry := newAuditEntry(r, audit.ActionUpdateNamespace, "")
+		entry.Detail = fmt.Sprintf("%s: maxTables=%d maxBytes=%d", ns, req.Quota.MaxTables, req.Quota.MaxBytes)
+		recordAudit(entry)
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(namespaceInfo(ns, req.Quota))
+	}
+}
+
+func deleteNamespaceHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ns := mux.Vars(r)["ns"]
+		log.Printf("[NAMESPACES] Delete handler called for %s by %s", ns, auth.Actor(r.Context()))
+
+		if ns == models.DefaultNamespace {
+			http.Error(w, "The default namespace cannot be deleted", http.StatusConflict)
+			return
+		}
+		if _, exists := lookupNamespace(ns); !exists {
+			http.Error(w, "Namespace not found", http.StatusNotFound)
+			return
+		}
+		if tables := len(namespaceTables(ns)); tables > 0 {
+			http.Error(w, fmt.Sprintf("Namespace still holds %d tables; delete them first", tables), http.StatusConflict)
+			return
+		}
+
+		tablesMu.Lock()
+		delete(namespaces, ns)
+		tablesMu.Unlock()
+
+		if err := saveRegistry(); err != nil {
+			log.Printf("[NAMESPACES] Warning: Failed to save registry: %v", err)
+		}
+		log.Printf("[NAMESPACES] Deleted namespace %s", ns)
+
+		entry := newAuditEntry(r, audit.ActionDeleteNamespace, "")
+		entry.Detail = ns
+		recordAudit(entry)
+
+		response := map[string]interface{}{
+			"success": true,
+			"name":    ns,
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(response)
+	}
+}
@@ -0,0 +1,87 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"ipfs-go-server/internal/auth"
+	"ipfs-go-server/internal/models"
+	"ipfs-go-server/internal/storage"
+
+	"github.com/gorilla/mux"
+)
+
+func TestPayloadSize(t *testing.T) {
+	tests := []struct {
+		item string
+		want int64
+		ok   bool
+	}{
+		{`{"fileSize": 42}`, 42, true},
+		{`{"fileSize": 0}`, 0, true},
+		{`{"hash": "aaaa"}`, 0, true},
+		{`"not a version"`, 0, true},
+		{`{"fileSize": -5}`, 0, false},
+		{`{"fileSize": 1.5}`, 0, false},
+		{`{"fileSize": "42"}`, 0, false},
+		{`{"fileSize": 1e300}`, 0, false},
+	}
+	for _, tt := range tests {
+		var item interface{}
+		json.Unmarshal([]byte(tt.item), &item)
+		size, err := payloadSize(item)
+		if (err == nil) != tt.ok || size != tt.want {
+			t.Errorf("payloadSize(%s) = %d, %v; want %d (ok: %v)", tt.item, size, err, tt.want, tt.ok)
+		}
+	}
+}
+
+// quotaRequest sends a request for the table id to h as an admin
+func quotaRequest(h http.HandlerFunc, method, id, body string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(method, "/tables/"+id, strings.NewReader(body))
+	r = mux.SetURLVars(r, map[string]string{"id": id})
+	r = r.WithContext(auth.WithIdentity(r.Context(), auth.Anonymous))
+	w := httptest.NewRecorder()
+	h(w, r)
+	return w
+}
+
+func TestTableDataCountsAgainstQuota(t *testing.T) {
+	useTables(t)
+	savedQuota, _ := lookupNamespace(models.DefaultNamespace)
+	setNamespace(models.DefaultNamespace, models.Quota{MaxBytes: 100})
+	t.Cleanup(func() { setNamespace(models.DefaultNamespace, savedQuota) })
+
+	stor := storage.NewStorageWithIPNS(nil, "release", "release", "", models.KeyName("release"), "k51release")
+	if err := stor.FastUpdateData(`[{"hash": "aaaa", "fileSize": 60}]`); err != nil {
+		t.Fatal(err)
+	}
+	storeTable("release", stor)
+
+	// Replacing the versions with larger ones is refused past the quota
+	w := quotaRequest(updateTableHandler(), http.MethodPut, "release", `{"data": "[{\"hash\": \"bbbb\", \"fileSize\": 200}]"}`)
+	if w.Code != http.StatusForbidden {
+		t.Fatalf("PUT past the quota = %d %s, want 403", w.Code, w.Body)
+	}
+	w = quotaRequest(updateTableHandler(), http.MethodPut, "release", `{"data": "[{\"hash\": \"bbbb\", \"fileSize\": -500}]"}`)
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("PUT with a negative fileSize = %d %s, want 400", w.Code, w.Body)
+	}
+
+	// A negative size cannot free headroom for later appends
+	w = quotaRequest(AppendToTable(nil), http.MethodPost, "release", `{"hash": "cccc", "fileSize": -1000}`)
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("append with a negative fileSize = %d %s, want 400", w.Code, w.Body)
+	}
+	w = quotaRequest(AppendToTable(nil), http.MethodPost, "release", `{"hash": "cccc", "fileSize": 50}`)
+	if w.Code != http.StatusForbidden {
+		t.Fatalf("append past the quota = %d %s, want 403", w.Code, w.Body)
+	}
+
+	if usage := namespaceUsage(models.DefaultNamespace); usage.Bytes != 60 {
+		t.Fatalf("usage = %d bytes, want 60", usage.Bytes)
+	}
+}
@@ -0,0 +1,346 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+
+	"ipfs-go-server/internal/auth"
+	"ipfs-go-server/internal/models"
+	"ipfs-go-server/internal/signing"
+
+	"github.com/gorilla/mux"
+)
+
+const publishersFile = "publishers.json"
+
+var (
+	// publishers holds the keys allowed to sign versions
+	publishers *signing.Registry
+
+	// requireSignedVersions rejects unsigned versions, and so uploads, when
+	// set
+	requireSignedVersions bool
+)
+
+// InitializePublishers loads the registered publisher keys. Signed versions
+// are always verified; unsigned ones are rejected only when requireSigned
+// is set.
+func InitializePublishers(requireSigned bool) error {
+	reg, err := signing.NewRegistry(dataPath(publishersFile))
+	if err != nil {
+		return err
+	}
+
+	publishers = reg
+	requireSignedVersions = requireSigned
+	log.Printf("[PUBLISHERS] Loaded %d publisher keys (signatures required: %v)", len(reg.List()), requireSigned)
+
+	// Publishers registered before owners were key IDs name their owner by
+	// key name
+	if apiKeys != nil {
+		converted, err := reg.ConvertOwners(liveKeyID)
+		if err != nil {
+			return fmt.Errorf("failed to save converted publisher owners: %w", err)
+		}
+		if converted > 0 {
+			log.Printf("[PUBLISHERS] Converted the owners of %d publishers from key names to key IDs", converted)
+		}
+	}
+	return nil
+}
+
+// checkUnsigned returns an error, and the HTTP status to fail the request
+// with, when the server does not accept unsigned versions
+func checkUnsigned() (int, error) {
+	if requireSignedVersions {
+		return http.StatusBadRequest, fmt.Errorf("this server only accepts signed versions")
+	}
+	return http.StatusOK, nil
+}
+
+// verifyAppendedItem checks the signature of an item about to be appended
+// to the table known by tableIDs, its ID followed by its former IDs, and
+// returns the item to store. A signed item is stored as the
+// parsed version with the signer key recorded, so nothing the signature
+// does not cover rides along. It returns the HTTP status to fail the
+// request with.
+func verifyAppendedItem(tableIDs []string, body []byte, item interface{}) (interface{}, int, error) {
+	fields, isObject := item.(map[string]interface{})
+	signed := isObject && fields["signature"] != nil
+	if !signed {
+		if status, err := checkUnsigned(); err != nil {
+			return nil, status, err
+		}
+		return item, http.StatusOK, nil
+	}
+	if publishers == nil {
+		return nil, http.StatusServiceUnavailable, fmt.Errorf("publisher keys are not loaded")
+	}
+
+	var version models.TorrentVersion
+	if err := json.Unmarshal(body, &version); err != nil {
+		return nil, http.StatusBadRequest, fmt.Errorf("signed item is not a valid version: %w", err)
+	}
+
+	publisher, err := publishers.Verify(tableIDs, &version)
+	if err != nil {
+		status := http.StatusBadRequest
+		if errors.Is(err, signing.ErrNotFound) || errors.Is(err, signing.ErrRevoked) {
+			status = http.StatusForbidden
+		}
+		return nil, status, err
+	}
+	log.Printf("[PUBLISHERS] Verified version for table %s signed by %s (%s)", tableIDs[0], publisher.Name, publisher.ID)
+
+	// Availability is only ever reported by this server
+	version.Availability = nil
+	stored, err := toItem(version)
+	if err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+	return stored, http.StatusOK, nil
+}
+
+// verifyTableData checks the versions of data, a table's whole version
+// list about to replace current, and returns the data to store. Versions
+// already in current were checked when they were added and are kept as
+// they are; every other version is checked like an appended one, so
+// replacing the list cannot bring in what an append would refuse.
+func verifyTableData(tableIDs []string, current, data string) (string, int, error) {
+	var raws []json.RawMessage
+	if err := json.Unmarshal([]byte(data), &raws); err != nil {
+		return "", http.StatusBadRequest, fmt.Errorf("data is not a list of versions: %w", err)
+	}
+
+	var existing []interface{}
+	json.Unmarshal([]byte(current), &existing)
+	known := make(map[string]bool, len(existing))
+	for _, item := range existing {
+		if encoded, err := json.Marshal(item); err == nil {
+			known[string(encoded)] = true
+		}
+	}
+
+	items := make([]interface{}, 0, len(raws))
+	for i, raw := range raws {
+		var item interface{}
+		if err := json.Unmarshal(raw, &item); err != nil {
+			return "", http.StatusBadRequest, fmt.Errorf("version %d: %w", i, err)
+		}
+		if encoded, err := json.Marshal(item); err == nil && known[string(encoded)] {
+			items = append(items, item)
+			continue
+		}
+
+		stored, status, err := verifyAppendedItem(tableIDs, raw, item)
+		if err != nil {
+			return "", status, fmt.Errorf("version %d: %w", i, err)
+		}
+		items = append(items, stored)
+	}
+
+	verified, err := json.MarshalIndent(items, "", "  ")
+	if err != nil {
+		return "", http.StatusInternalServerError, err
+	}
+	return string(verified), http.StatusOK, nil
+}
+
+// toItem converts a version to the generic form table data is appended in
+func toItem(version models.TorrentVersion) (interface{}, error) {
+	data, err := json.Marshal(version)
+	if err != nil {
+		return nil, err
+	}
+	var item interface{}
+	err = json.Unmarshal(data, &item)
+	return item, err
+}
+
+func requirePublishers(w http.ResponseWriter) bool {
+	if publishers == nil {
+		http.Error(w, "Publisher keys are not loaded", http.StatusServiceUnavailable)
+		return false
+	}
+	return true
+}
+
+func registerPublisherHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		log.Printf("[PUBLISHERS] Register handler called by %s", auth.Actor(r.Context()))
+		if !requirePublishers(w) {
+			return
+		}
+
+		var req struct {
+			Name      string `json:"name"`
+			PublicKey string `json:"publicKey"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			log.Printf("[PUBLISHERS] Error parsing JSON: %v", err)
+			http.Error(w, "Invalid JSON format", http.StatusBadRequest)
+			return
+		}
+		if req.Name == "" {
+			http.Error(w, "Publisher name is required", http.StatusBadRequest)
+			return
+		}
+
+		caller, _ := auth.FromContext(r.Context())
+		publisher, err := publishers.Register(req.Name, req.PublicKey, caller.Principal())
+		if err != nil {
+			log.Printf("[PUBLISHERS] Error registering publisher: %v", err)
+			switch {
+			case errors.Is(err, signing.ErrInvalidPublicKey):
+				http.Error(w, err.Error(), http.StatusBadRequest)
+			case errors.Is(err, signing.ErrExists):
+				http.Error(w, err.Error(), http.StatusConflict)
+			default:
+				http.Error(w, "Failed to register publisher: "+err.Error(), http.StatusInternalServerError)
+			}
+			return
+		}
+
+		log.Printf("[PUBLISHERS] Registered publisher %s (%s)", publisher.Name, publisher.ID)
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(publisher)
+	}
+}
+
+func listPublishersHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !requirePublishers(w) {
+			return
+		}
+
+		list := publishers.List()
+		response := map[string]interface{}{
+			"publishers": list,
+			"count":      len(list),
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(response)
+	}
+}
+
+func getPublisherHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !requirePublishers(w) {
+			return
+		}
+
+		publisher, err := publishers.Get(mux.Vars(r)["id"])
+		if err != nil {
+			http.Error(w, "Publisher not found", http.StatusNotFound)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(publisher)
+	}
+}
+
+func revokePublisherHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := mux.Vars(r)["id"]
+		log.Printf("[PUBLISHERS] Revoke handler called for ID: %s by %s", id, auth.Actor(r.Context()))
+		if !requirePublishers(w) {
+			return
+		}
+
+		publisher, err := publishers.Get(id)
+		if err != nil {
+			http.Error(w, "Publisher not found", http.StatusNotFound)
+			return
+		}
+
+		// Only whoever registered a key, or an admin, may revoke it
+		caller, _ := auth.FromContext(r.Context())
+		if publisher.Owner != caller.Principal() && !caller.HasScope(auth.ScopeAdmin) {
+			http.Error(w, "Only the key that registered this publisher can revoke it", http.StatusForbidden)
+			return
+		}
+
+		if err := publishers.Revoke(id); err != nil {
+			http.Error(w, "Failed to revoke publisher: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		response := map[string]interface{}{
+			"success": true,
+			"message": "Publisher revoked successfully",
+			"id":      id,
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(response)
+	}
+}
+
+// verifyTableHandler reports the signature status of every version in a
+// table
+func verifyTableHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := requestTableID(r)
+		log.Printf("[PUBLISHERS] Verify handler called for ID: %s", id)
+
+		stor, exists := lookupTable(id)
+		if !exists {
+			http.Error(w, "Table not found", http.StatusNotFound)
+			return
+		}
+		if !authorizeTable(w, r, id, models.RoleReader) {
+			return
+		}
+
+		table := stor.GetTable()
+		var versions []models.TorrentVersion
+		if err := json.Unmarshal([]byte(table.Data), &versions); err != nil {
+			http.Error(w, "Table data is not a list of versions: "+err.Error(), http.StatusUnprocessableEntity)
+			return
+		}
+
+		results := make([]map[string]interface{}, 0, len(versions))
+		verified := 0
+		for i, v := range versions {
+			result := map[string]interface{}{
+				"index":    i,
+				"hash":     v.Hash,
+				"signerId": v.SignerID,
+				"status":   "verified",
+			}
+			// Versions signed before the table's ID was migrated name a
+			// former ID
+			if err := signing.VerifyVersionAs(table.IDs(), v); err != nil {
+				result["status"] = "invalid"
+				if errors.Is(err, signing.ErrUnsigned) {
+					result["status"] = "unsigned"
+				}
+				result["error"] = err.Error()
+			} else {
+				verified++
+			}
+			results = append(results, result)
+		}
+
+		response := map[string]interface{}{
+			"id":       id,
+			"versions": results,
+			"count":    len(results),
+			"verified": verified,
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(response)
+	}
+}
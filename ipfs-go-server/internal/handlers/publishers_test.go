@@ -0,0 +1,154 @@
+package handlers
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"ipfs-go-server/internal/models"
+	"ipfs-go-server/internal/signing"
+)
+
+// usePublisher loads an empty publisher registry for the length of a test
+// and registers a fresh key in it
+func usePublisher(t *testing.T) (ed25519.PublicKey, ed25519.PrivateKey) {
+	t.Helper()
+
+	reg, err := signing.NewRegistry(filepath.Join(t.TempDir(), publishersFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	publishers = reg
+	t.Cleanup(func() { publishers, requireSignedVersions = nil, false })
+
+	publicKey, privateKey, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := reg.Register("ci", base64.StdEncoding.EncodeToString(publicKey), "admin"); err != nil {
+		t.Fatal(err)
+	}
+	return publicKey, privateKey
+}
+
+func TestVerifyAppendedItemStoresOnlySignedFields(t *testing.T) {
+	publicKey, privateKey := usePublisher(t)
+	encodedKey := base64.StdEncoding.EncodeToString(publicKey)
+
+	version := models.TorrentVersion{
+		Hash:       "c12fe1c06bba254a9dc9f519b335aa7c1367a88a",
+		MagnetLink: "magnet:?xt=urn:btih:c12fe1c06bba254a9dc9f519b335aa7c1367a88a",
+		FileName:   "release.tar.gz",
+		FileSize:   42,
+		CreatedAt:  time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC),
+		WebSeeds:   []string{"https://seed.example/release.tar.gz"},
+	}
+	payload, err := version.SigningPayload("release")
+	if err != nil {
+		t.Fatal(err)
+	}
+	version.Signature = base64.StdEncoding.EncodeToString(ed25519.Sign(privateKey, payload))
+	version.SignerID = signing.Fingerprint(publicKey)
+
+	// A field the signature does not cover rides along in the request
+	body, _ := json.Marshal(version)
+	var fields map[string]interface{}
+	json.Unmarshal(body, &fields)
+	fields["downloadUrl"] = "https://evil.example/payload"
+	body, _ = json.Marshal(fields)
+	var item interface{}
+	json.Unmarshal(body, &item)
+
+	stored, _, err := verifyAppendedItem([]string{"release"}, body, item)
+	if err != nil {
+		t.Fatalf("verifyAppendedItem: %v", err)
+	}
+	storedFields := stored.(map[string]interface{})
+	if _, ok := storedFields["downloadUrl"]; ok {
+		t.Fatal("unsigned field was stored")
+	}
+	if storedFields["signerKey"] != encodedKey {
+		t.Fatalf("signerKey = %v, want %s", storedFields["signerKey"], encodedKey)
+	}
+
+	// What is stored verifies offline
+	data, _ := json.Marshal(stored)
+	var got models.TorrentVersion
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatal(err)
+	}
+	if err := signing.VerifyVersion("release", got); err != nil {
+		t.Fatalf("VerifyVersion of stored item: %v", err)
+	}
+
+	// Changing a covered field after signing is refused
+	fields["webSeeds"] = []string{"https://evil.example/payload"}
+	body, _ = json.Marshal(fields)
+	json.Unmarshal(body, &item)
+	if _, _, err := verifyAppendedItem([]string{"release"}, body, item); err == nil {
+		t.Fatal("verifyAppendedItem accepted a version whose web seeds changed after signing")
+	}
+}
+
+func TestVerifyTableDataChecksNewVersions(t *testing.T) {
+	publicKey, privateKey := usePublisher(t)
+
+	sign := func(v models.TorrentVersion) models.TorrentVersion {
+		payload, err := v.SigningPayload("release")
+		if err != nil {
+			t.Fatal(err)
+		}
+		v.Signature = base64.StdEncoding.EncodeToString(ed25519.Sign(privateKey, payload))
+		v.SignerID = signing.Fingerprint(publicKey)
+		return v
+	}
+	encode := func(items ...interface{}) string {
+		data, _ := json.Marshal(items)
+		return string(data)
+	}
+
+	legacy := map[string]interface{}{"hash": "aaaa", "magnetLink": "magnet:?xt=urn:btih:aaaa", "fileSize": 1}
+	signed := sign(models.TorrentVersion{
+		Hash:       "c12fe1c06bba254a9dc9f519b335aa7c1367a88a",
+		MagnetLink: "magnet:?xt=urn:btih:c12fe1c06bba254a9dc9f519b335aa7c1367a88a",
+		FileName:   "release.tar.gz",
+		FileSize:   42,
+		CreatedAt:  time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC),
+	})
+	stored, _, err := verifyTableData([]string{"release"}, "", encode(signed))
+	if err != nil {
+		t.Fatalf("verifyTableData of a signed version: %v", err)
+	}
+	var storedItems []interface{}
+	json.Unmarshal([]byte(stored), &storedItems)
+	current := encode(legacy, storedItems[0])
+
+	// Versions already in the table pass even once signatures are required
+	requireSignedVersions = true
+	if _, _, err := verifyTableData([]string{"release"}, current, current); err != nil {
+		t.Fatalf("verifyTableData of the current versions: %v", err)
+	}
+
+	// A version that is not in the table is checked like an append
+	unsigned := map[string]interface{}{"hash": "bbbb", "magnetLink": "magnet:?xt=urn:btih:bbbb", "fileSize": 1}
+	if _, status, err := verifyTableData([]string{"release"}, current, encode(legacy, unsigned)); err == nil || status != http.StatusBadRequest {
+		t.Fatalf("verifyTableData of an unsigned version = %d, %v; want 400", status, err)
+	}
+	forged := signed
+	forged.MagnetLink = "magnet:?xt=urn:btih:bbbb"
+	if _, _, err := verifyTableData([]string{"release"}, current, encode(legacy, forged)); !errors.Is(err, signing.ErrBadSignature) {
+		t.Fatalf("verifyTableData of a changed signed version = %v, want ErrBadSignature", err)
+	}
+	if _, _, err := verifyTableData([]string{"other"}, "", encode(signed)); !errors.Is(err, signing.ErrBadSignature) {
+		t.Fatalf("verifyTableData of a version signed for another table = %v, want ErrBadSignature", err)
+	}
+
+	if _, status, err := verifyTableData([]string{"release"}, current, `{"hash":"aaaa"}`); err == nil || status != http.StatusBadRequest {
+		t.Fatalf("verifyTableData of an object = %d, %v; want 400", status, err)
+	}
+}
@@ -0,0 +1,206 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"sync"
+
+	"ipfs-go-server/internal/auth"
+	"ipfs-go-server/internal/ipfs"
+	"ipfs-go-server/internal/models"
+	"ipfs-go-server/internal/pubsub"
+	"ipfs-go-server/internal/storage"
+)
+
+// broker announces and follows table heads over pubsub when enabled
+var broker *pubsub.Broker
+
+// EnablePubsub turns on pubsub announcements for owned tables and instant
+// updates for mirrored ones. Call it before InitializeStorage.
+func EnablePubsub(ctx context.Context, ipfsClient *ipfs.IPFSClient) {
+	broker = pubsub.NewBroker(ctx, ipfsClient)
+
+	enabled, err := ipfsClient.IPNSPubsubEnabled(ctx)
+	switch {
+	case err != nil:
+		log.Printf("[PUBSUB] Warning: Could not query IPNS over pubsub state: %v", err)
+	case !enabled:
+		log.Printf("[PUBSUB] IPNS over pubsub is disabled; start the daemon with --enable-namesys-pubsub for faster IPNS propagation")
+	default:
+		log.Printf("[PUBSUB] IPNS over pubsub is enabled")
+	}
+}
+
+// followed holds the IPNS name whose topic each mirror is subscribed to.
+// A mirror's name changes when it follows its origin to a rotated key.
+var (
+	followMu sync.Mutex
+	followed = make(map[*storage.Storage]string)
+)
+
+// attachPubsub announces an owned table's publishes, or follows a mirror's
+// announcements
+func attachPubsub(stor *storage.Storage) {
+	if broker == nil {
+		return
+	}
+
+	if !stor.IsMirror() {
+		stor.SetAnnouncer(broker)
+		return
+	}
+	followMirror(stor)
+}
+
+// followMirror subscribes a mirror to the topic of its current IPNS name,
+// leaving the topic of the name it followed before, if that changed
+func followMirror(stor *storage.Storage) {
+	if broker == nil || !stor.IsMirror() {
+		return
+	}
+
+	name := stor.GetIPNSName()
+	followMu.Lock()
+	previous, exists := followed[stor]
+	followed[stor] = name
+	followMu.Unlock()
+
+	if exists && previous == name {
+		return
+	}
+	if exists {
+		log.Printf("[PUBSUB] Mirror %s moved from %s to %s", stor.GetTable().ID, previous, name)
+		broker.Unfollow(previous)
+	}
+	broker.Follow(name, func(announcement pubsub.Announcement) {
+		applyAnnouncement(stor, announcement)
+	})
+}
+
+// applyAnnouncement loads an announced head into a mirror
+func applyAnnouncement(stor *storage.Storage, announcement pubsub.Announcement) {
+	before := stor.GetTable().ID
+	applied, err := stor.ApplyAnnouncement(context.Background(), announcement.CID, announcement.Sequence)
+	if err != nil {
+		log.Printf("[PUBSUB] Failed to apply %s to table %s: %v", announcement.CID, announcement.TableID, err)
+		return
+	}
+	if !applied {
+		return
+	}
+	log.Printf("[PUBSUB] Table %s updated to %s", announcement.TableID, announcement.CID)
+
+	// The origin moved the table to a new ID
+	if after := stor.GetTable().ID; after != before {
+		if err := retargetTable(before, after); err != nil {
+			log.Printf("[PUBSUB] Warning: Mirror %s keeps its ID until the next start; its origin now calls it %s: %v", before, after, err)
+			return
+		}
+		log.Printf("[PUBSUB] Mirror %s is now %s, following its origin", before, after)
+		return
+	}
+
+	// The registry keeps the announcement sequence, so replays of older
+	// announcements stay refused after a restart
+	if err := saveRegistry(); err != nil {
+		log.Printf("[PUBSUB] Warning: Failed to save registry: %v", err)
+	}
+}
+
+// detachPubsub stops following a removed mirror
+func detachPubsub(stor *storage.Storage) {
+	followMu.Lock()
+	name, exists := followed[stor]
+	delete(followed, stor)
+	followMu.Unlock()
+
+	if broker != nil && exists {
+		broker.Unfollow(name)
+	}
+}
+
+func followTableHandler(ipfsClient *ipfs.IPFSClient) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		log.Printf("[FOLLOW] Handler called by %s", auth.Actor(r.Context()))
+
+		var req struct {
+			IPNSName string `json:"ipnsName"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			log.Printf("[FOLLOW] Error parsing JSON: %v", err)
+			http.Error(w, "Invalid JSON format", http.StatusBadRequest)
+			return
+		}
+		if req.IPNSName == "" {
+			http.Error(w, "ipnsName is required", http.StatusBadRequest)
+			return
+		}
+
+		// Mirrors have no key; they are only ever loaded, never published
+		stor := storage.NewStorageWithIPNS(ipfsClient.GetShell(), "", "", "", "", req.IPNSName)
+		if err := stor.LoadTable(r.Context()); err != nil {
+			log.Printf("[FOLLOW] Failed to load %s: %v", req.IPNSName, err)
+			http.Error(w, "Failed to resolve table: "+err.Error(), http.StatusBadGateway)
+			return
+		}
+
+		table := stor.GetTable()
+		if _, exists := lookupTable(table.ID); exists {
+			log.Printf("[FOLLOW] Table already exists: %s", table.ID)
+			http.Error(w, "Table with this ID already exists", http.StatusConflict)
+			return
+		}
+
+		// Mirrors keep the ID, and so the namespace, of the table they follow
+		ns, _ := models.SplitTableRef(table.ID)
+		if _, exists := lookupNamespace(ns); !exists {
+			http.Error(w, "Namespace "+ns+" not found; create it first", http.StatusNotFound)
+			return
+		}
+		if other, taken := tableNamed(ns, table.Name); taken {
+			log.Printf("[FOLLOW] Name %s is already used by table %s", table.Name, other)
+			http.Error(w, "Table with this name already exists", http.StatusConflict)
+			return
+		}
+		releaseQuota, ok := holdQuota(w, ns, 1, 0)
+		if !ok {
+			return
+		}
+		defer releaseQuota()
+
+		setACL(table.ID, ownedBy(r))
+		storeTable(table.ID, stor)
+		if err := saveRegistry(); err != nil {
+			log.Printf("[FOLLOW] Warning: Failed to save registry: %v", err)
+		}
+
+		log.Printf("[FOLLOW] Now mirroring table %s from %s", table.ID, req.IPNSName)
+
+		response := map[string]interface{}{
+			"id":          table.ID,
+			"name":        table.Name,
+			"description": table.Description,
+			"data":        table.Data,
+			"createdAt":   table.CreatedAt,
+			"updatedAt":   table.UpdatedAt,
+			"ipns_name":   stor.GetIPNSName(),
+			"status":      "mirror",
+			"success":     true,
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(response)
+	}
+}
+
+// rejectMirror answers 409 for mutations of a mirrored table
+func rejectMirror(w http.ResponseWriter, stor *storage.Storage) bool {
+	if !stor.IsMirror() {
+		return false
+	}
+	http.Error(w, "Table is a read-only mirror of another server", http.StatusConflict)
+	return true
+}
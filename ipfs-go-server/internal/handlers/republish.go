@@ -0,0 +1,264 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"ipfs-go-server/internal/auth"
+	"ipfs-go-server/internal/ipfs"
+	"ipfs-go-server/internal/models"
+)
+
+// republisherState is what the republisher loop reports to /health
+type republisherState struct {
+	mu        sync.Mutex
+	running   bool
+	interval  time.Duration
+	lastSweep time.Time
+	lastError string
+	owned     map[string]bool
+}
+
+var republisher = &republisherState{owned: make(map[string]bool)}
+
+// StartRepublisher re-signs the IPNS record of every table whose key is in
+// the local keystore before the record expires. It checks every interval
+// until ctx is cancelled.
+func StartRepublisher(ctx context.Context, ipfsClient *ipfs.IPFSClient, interval time.Duration) {
+	republisher.mu.Lock()
+	republisher.running = true
+	republisher.interval = interval
+	republisher.mu.Unlock()
+
+	log.Printf("[REPUBLISH] Republisher started, checking every %s", interval)
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			republishDue(ctx, ipfsClient)
+
+			select {
+			case <-ctx.Done():
+				republisher.mu.Lock()
+				republisher.running = false
+				republisher.mu.Unlock()
+				log.Printf("[REPUBLISH] Republisher stopped")
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// republishDue runs one republisher sweep
+func republishDue(ctx context.Context, ipfsClient *ipfs.IPFSClient) {
+	listCtx, cancel := ipfs.WithTimeout(ctx, ipfs.OpKeys)
+	keys, err := ipfsClient.GetShell().KeyList(listCtx)
+	cancel()
+
+	republisher.mu.Lock()
+	republisher.lastSweep = time.Now()
+	if err != nil {
+		republisher.lastError = fmt.Sprintf("failed to list keys: %v", err)
+		republisher.mu.Unlock()
+		log.Printf("[REPUBLISH] Failed to list keys: %v", err)
+		return
+	}
+	republisher.lastError = ""
+	republisher.mu.Unlock()
+
+	keyNames := make(map[string]bool, len(keys))
+	for _, key := range keys {
+		keyNames[key.Name] = true
+	}
+
+	owned := make(map[string]bool)
+	now := time.Now()
+	for id, stor := range allTables() {
+		// Tables followed from other nodes cannot be re-signed here
+		if !keyNames[stor.GetKeyName()] {
+			continue
+		}
+		owned[id] = true
+
+		if now.Before(stor.NextRepublish()) {
+			continue
+		}
+
+		if err := stor.Republish(ctx); err != nil {
+			log.Printf("[REPUBLISH] Failed to republish table %s: %v", id, err)
+			continue
+		}
+		log.Printf("[REPUBLISH] Republished table %s", id)
+	}
+
+	republisher.mu.Lock()
+	republisher.owned = owned
+	republisher.mu.Unlock()
+}
+
+// parsePublishSettings reads the optional recordLifetime and recordTtl fields
+func parsePublishSettings(req map[string]interface{}) (time.Duration, time.Duration, error) {
+	var lifetime, ttl time.Duration
+	var err error
+
+	if value := getStringField(req, "recordLifetime", ""); value != "" {
+		if lifetime, err = time.ParseDuration(value); err != nil || lifetime < 0 {
+			return 0, 0, fmt.Errorf("invalid recordLifetime %q", value)
+		}
+	}
+	if value := getStringField(req, "recordTtl", ""); value != "" {
+		if ttl, err = time.ParseDuration(value); err != nil || ttl < 0 {
+			return 0, 0, fmt.Errorf("invalid recordTtl %q", value)
+		}
+	}
+	return lifetime, ttl, nil
+}
+
+func getPublishSettingsHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := requestTableID(r)
+		log.Printf("[PUBLISH_SETTINGS] Handler called for ID: %s", id)
+
+		stor, exists := lookupTable(id)
+		if !exists {
+			log.Printf("[PUBLISH_SETTINGS] Table not found: %s", id)
+			http.Error(w, "Table not found", http.StatusNotFound)
+			return
+		}
+		if !authorizeTable(w, r, id, models.RoleReader) {
+			return
+		}
+
+		lifetime, ttl := stor.GetPublishSettings()
+		response := publishSettingsResponse(id, lifetime, ttl)
+		response["publish"] = stor.GetPublishStatus()
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(response)
+	}
+}
+
+func updatePublishSettingsHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := requestTableID(r)
+		log.Printf("[PUBLISH_SETTINGS] Update called for ID: %s by %s", id, auth.Actor(r.Context()))
+
+		var req map[string]interface{}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			log.Printf("[PUBLISH_SETTINGS] Error parsing JSON: %v", err)
+			http.Error(w, "Invalid JSON format", http.StatusBadRequest)
+			return
+		}
+
+		lifetime, ttl, err := parsePublishSettings(req)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		stor, exists := lookupTable(id)
+		if !exists {
+			log.Printf("[PUBLISH_SETTINGS] Table not found: %s", id)
+			http.Error(w, "Table not found", http.StatusNotFound)
+			return
+		}
+		if rejectMirror(w, stor) {
+			return
+		}
+
+		stor.SetPublishSettings(lifetime, ttl)
+		if err := saveRegistry(); err != nil {
+			log.Printf("[PUBLISH_SETTINGS] Warning: Failed to save registry: %v", err)
+		}
+
+		// Re-sign now so the new lifetime takes effect immediately
+		response := publishSettingsResponse(id, lifetime, ttl)
+		if err := stor.Republish(r.Context()); err != nil {
+			log.Printf("[PUBLISH_SETTINGS] Warning: Failed to republish table %s: %v", id, err)
+			response["republishError"] = err.Error()
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(response)
+	}
+}
+
+func publishSettingsResponse(id string, lifetime, ttl time.Duration) map[string]interface{} {
+	return map[string]interface{}{
+		"id":             id,
+		"recordLifetime": lifetime.String(),
+		"recordTtl":      ttl.String(),
+	}
+}
+
+func healthHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		log.Printf("[HEALTH] Handler called")
+
+		republisher.mu.Lock()
+		running := republisher.running
+		interval := republisher.interval
+		lastSweep := republisher.lastSweep
+		sweepError := republisher.lastError
+		owned := republisher.owned
+		republisher.mu.Unlock()
+
+		status := "ok"
+		if sweepError != "" {
+			status = "degraded"
+		}
+
+		tables := allTables()
+		ids := make([]string, 0, len(tables))
+		for id := range tables {
+			ids = append(ids, id)
+		}
+		sort.Strings(ids)
+
+		tableHealth := make([]map[string]interface{}, 0, len(ids))
+		for _, id := range ids {
+			stor := tables[id]
+			publish := stor.GetPublishStatus()
+			if publish.LastError != "" && owned[id] {
+				status = "degraded"
+			}
+
+			entry := map[string]interface{}{
+				"id":       id,
+				"owned":    owned[id],
+				"publish":  publish,
+				"ipnsName": stor.GetIPNSName(),
+			}
+			if next := stor.NextRepublish(); !next.IsZero() {
+				entry["nextRepublish"] = next
+			}
+			tableHealth = append(tableHealth, entry)
+		}
+
+		response := map[string]interface{}{
+			"status": status,
+			"republisher": map[string]interface{}{
+				"running":   running,
+				"interval":  interval.String(),
+				"lastSweep": lastSweep,
+				"lastError": sweepError,
+			},
+			"tables": tableHealth,
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(response)
+	}
+}
@@ -0,0 +1,92 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"ipfs-go-server/internal/audit"
+	"ipfs-go-server/internal/auth"
+	"ipfs-go-server/internal/chain"
+	"ipfs-go-server/internal/models"
+)
+
+// chainRegistry mirrors IPNS name changes on-chain when configured
+var chainRegistry *chain.Registry
+
+// SetChainRegistry enables on-chain updates of table IPNS records
+func SetChainRegistry(registry *chain.Registry) {
+	chainRegistry = registry
+}
+
+func rotateKeyHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := requestTableID(r)
+		log.Printf("[ROTATE_KEY] Handler called for ID: %s by %s", id, auth.Actor(r.Context()))
+
+		stor, exists := lookupTable(id)
+		if !exists {
+			log.Printf("[ROTATE_KEY] Table not found: %s", id)
+			http.Error(w, "Table not found", http.StatusNotFound)
+			return
+		}
+		if rejectMirror(w, stor) {
+			return
+		}
+
+		// The body is optional; an empty one rotates with defaults
+		var req struct {
+			RetireOldKey bool `json:"retireOldKey"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil && err != io.EOF {
+			log.Printf("[ROTATE_KEY] Error parsing JSON: %v", err)
+			http.Error(w, "Invalid JSON format", http.StatusBadRequest)
+			return
+		}
+
+		entry := newAuditEntry(r, audit.ActionRotateKey, id)
+		entry.BeforeCID = stor.GetPublishStatus().HeadCID
+
+		newKeyName := fmt.Sprintf("%s-%d", models.KeyName(id), time.Now().Unix())
+		rotation, err := stor.RotateKey(r.Context(), newKeyName, req.RetireOldKey)
+		if err != nil {
+			log.Printf("[ROTATE_KEY] Error rotating key: %v", err)
+			http.Error(w, "Failed to rotate key: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		entry.AfterCID = rotation.SnapshotCID
+		entry.Detail = fmt.Sprintf("%s -> %s", rotation.OldIPNSName, rotation.NewIPNSName)
+		recordAudit(entry)
+
+		if err := saveRegistry(); err != nil {
+			log.Printf("[ROTATE_KEY] Warning: Failed to save registry: %v", err)
+		}
+
+		response := map[string]interface{}{
+			"success":  true,
+			"id":       id,
+			"rotation": rotation,
+		}
+
+		if chainRegistry != nil {
+			txHash, err := chainRegistry.SetRecord(id, rotation.NewIPNSName)
+			if err != nil {
+				log.Printf("[ROTATE_KEY] Warning: Failed to update on-chain record: %v", err)
+				response["chainError"] = err.Error()
+			} else {
+				log.Printf("[ROTATE_KEY] On-chain record updated in tx %s", txHash)
+				response["chainTransaction"] = txHash
+			}
+		}
+
+		log.Printf("[ROTATE_KEY] Table %s now published at %s", id, rotation.NewIPNSName)
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(response)
+	}
+}
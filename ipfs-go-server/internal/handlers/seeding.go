@@ -0,0 +1,301 @@
+package handlers
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"ipfs-go-server/internal/models"
+	"ipfs-go-server/internal/torrent"
+)
+
+// SeedPolicy decides which versions held in torrentsDir keep seeding. A
+// version is seeded while any table it belongs to keeps it.
+type SeedPolicy struct {
+	KeepVersions int           // seed only the newest n versions of each table, 0 for all
+	MaxAge       time.Duration // stop seeding versions created longer ago, 0 for no limit
+	RatioLimit   float64       // stop once a version uploaded this many times its size, 0 for no limit
+}
+
+// seedRef is a table version whose payload is a seeded torrent
+type seedRef struct {
+	TableID string `json:"tableId"`
+	Version int    `json:"version"`
+}
+
+// seederState is the torrent client and what the last sweep decided
+type seederState struct {
+	mu        sync.Mutex
+	client    *torrent.Client
+	policy    SeedPolicy
+	wake      chan struct{}
+	lastSweep time.Time
+	refs      map[string][]seedRef // versions of each held payload, by info hash
+	stopped   map[string]string    // why a held payload is not seeded
+	retired   map[string]bool      // payloads that reached the ratio limit
+}
+
+var seeder = &seederState{
+	wake:    make(chan struct{}, 1),
+	retired: make(map[string]bool),
+}
+
+// StartSeeding seeds every table version whose payload is held locally,
+// listening for peers on listenAddr. Tables are checked against the policy
+// every interval and after each upload until ctx is cancelled.
+func StartSeeding(ctx context.Context, listenAddr string, policy SeedPolicy, interval time.Duration) error {
+	client, err := torrent.NewClient(listenAddr)
+	if err != nil {
+		return err
+	}
+
+	seeder.mu.Lock()
+	seeder.client = client
+	seeder.policy = policy
+	seeder.mu.Unlock()
+
+	log.Printf("[SEED] Seeding on %s, checking every %s", client.Addr(), interval)
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			seedSweep()
+
+			select {
+			case <-ctx.Done():
+				seeder.mu.Lock()
+				seeder.client = nil
+				seeder.mu.Unlock()
+				client.Close()
+				log.Printf("[SEED] Seeding stopped")
+				return
+			case <-ticker.C:
+			case <-seeder.wake:
+			}
+		}
+	}()
+	return nil
+}
+
+// requestSeedSweep has new versions picked up without waiting for the
+// next interval
+func requestSeedSweep() {
+	select {
+	case seeder.wake <- struct{}{}:
+	default:
+	}
+}
+
+// seedSweep starts seeding held versions the policy keeps and stops the rest
+func seedSweep() {
+	seeder.mu.Lock()
+	client, policy := seeder.client, seeder.policy
+	seeder.mu.Unlock()
+	if client == nil {
+		return
+	}
+
+	refs := make(map[string][]seedRef)
+	stopped := make(map[string]string)
+	keep := make(map[string]bool)
+	now := time.Now()
+	for id, stor := range allTables() {
+		var versions []models.TorrentVersion
+		if err := json.Unmarshal([]byte(stor.GetTable().Data), &versions); err != nil {
+			continue
+		}
+
+		for i, v := range versions {
+			if !payloadHeld(v.Hash) {
+				continue
+			}
+			refs[v.Hash] = append(refs[v.Hash], seedRef{TableID: id, Version: v.Version})
+
+			switch {
+			case policy.KeepVersions > 0 && i < len(versions)-policy.KeepVersions:
+				stopped[v.Hash] = fmt.Sprintf("not among the newest %d versions", policy.KeepVersions)
+			case policy.MaxAge > 0 && now.Sub(v.CreatedAt) > policy.MaxAge:
+				stopped[v.Hash] = fmt.Sprintf("created more than %s ago", policy.MaxAge)
+			default:
+				keep[v.Hash] = true
+			}
+		}
+	}
+
+	seeder.mu.Lock()
+	for hash := range keep {
+		if policy.RatioLimit > 0 {
+			if t := client.Torrent(hash); t != nil && t.Stats().Ratio >= policy.RatioLimit {
+				seeder.retired[hash] = true
+			}
+		}
+		if seeder.retired[hash] {
+			delete(keep, hash)
+			stopped[hash] = fmt.Sprintf("reached ratio limit %g", policy.RatioLimit)
+			continue
+		}
+		delete(stopped, hash)
+	}
+	seeder.mu.Unlock()
+
+	for _, t := range client.Torrents() {
+		hash := t.Meta.HexHash()
+		if keep[hash] {
+			continue
+		}
+		client.Remove(hash)
+		reason := stopped[hash]
+		if reason == "" {
+			reason = "no table lists it"
+		}
+		log.Printf("[SEED] Stopped seeding %s: %s", hash, reason)
+	}
+
+	for hash := range keep {
+		if client.Torrent(hash) != nil {
+			continue
+		}
+		if err := seedPayload(client, hash); err != nil {
+			log.Printf("[SEED] Failed to seed %s: %v", hash, err)
+			stopped[hash] = "failed to load payload: " + err.Error()
+			continue
+		}
+		log.Printf("[SEED] Seeding %s", hash)
+	}
+
+	seeder.mu.Lock()
+	seeder.lastSweep = now
+	seeder.refs = refs
+	seeder.stopped = stopped
+	seeder.mu.Unlock()
+}
+
+// payloadHeld reports whether torrentsDir holds the torrent with the given
+// info hash. Hashes come from table data, so only hex ones are looked up.
+func payloadHeld(infoHash string) bool {
+	if len(infoHash) != 40 && len(infoHash) != 64 {
+		return false
+	}
+	if _, err := hex.DecodeString(infoHash); err != nil {
+		return false
+	}
+	_, err := os.Stat(filepath.Join(payloadDir(infoHash), infoHash+torrentExtension))
+	return err == nil
+}
+
+// seedPayload loads a held torrent and its payload into the client
+func seedPayload(client *torrent.Client, infoHash string) error {
+	data, err := os.ReadFile(filepath.Join(payloadDir(infoHash), infoHash+torrentExtension))
+	if err != nil {
+		return err
+	}
+	meta, err := torrent.Parse(data)
+	if err != nil {
+		return err
+	}
+	if meta.HexHash() != infoHash || filepath.Base(meta.Name) != meta.Name {
+		return fmt.Errorf("torrent file does not match its directory")
+	}
+	_, err = client.Seed(meta, filepath.Join(payloadDir(infoHash), meta.Name))
+	return err
+}
+
+// visibleRefs filters versions down to tables the caller can read
+func visibleRefs(r *http.Request, refs []seedRef) []seedRef {
+	visible := make([]seedRef, 0, len(refs))
+	for _, ref := range refs {
+		if canAccess(r, ref.TableID, models.RoleReader) {
+			visible = append(visible, ref)
+		}
+	}
+	return visible
+}
+
+func listTorrentsHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		log.Printf("[TORRENTS] Handler called")
+
+		seeder.mu.Lock()
+		client := seeder.client
+		policy := seeder.policy
+		lastSweep := seeder.lastSweep
+		refs := seeder.refs
+		stopped := seeder.stopped
+		seeder.mu.Unlock()
+
+		if client == nil {
+			http.Error(w, "Seeding is not enabled", http.StatusServiceUnavailable)
+			return
+		}
+
+		torrents := make([]map[string]interface{}, 0)
+		for _, t := range client.Torrents() {
+			tables := visibleRefs(r, refs[t.Meta.HexHash()])
+			if len(tables) == 0 {
+				continue
+			}
+			stats := t.Stats()
+			torrents = append(torrents, map[string]interface{}{
+				"infoHash":   stats.InfoHash,
+				"infoHashV2": stats.InfoHashV2,
+				"name":       stats.Name,
+				"magnetURI":  t.Meta.MagnetLink(),
+				"length":     stats.Length,
+				"numPeers":   stats.Peers,
+				"uploaded":   stats.Uploaded,
+				"downloaded": stats.Downloaded,
+				"ratio":      stats.Ratio,
+				"progress":   stats.Progress,
+				"seeding":    stats.Seeding,
+				"addedAt":    stats.AddedAt,
+				"tables":     tables,
+			})
+		}
+
+		hashes := make([]string, 0, len(stopped))
+		for hash := range stopped {
+			hashes = append(hashes, hash)
+		}
+		sort.Strings(hashes)
+
+		notSeeded := make([]map[string]interface{}, 0)
+		for _, hash := range hashes {
+			tables := visibleRefs(r, refs[hash])
+			if len(tables) == 0 {
+				continue
+			}
+			notSeeded = append(notSeeded, map[string]interface{}{
+				"infoHash": hash,
+				"reason":   stopped[hash],
+				"tables":   tables,
+			})
+		}
+
+		response := map[string]interface{}{
+			"listenAddr": client.Addr().String(),
+			"policy": map[string]interface{}{
+				"keepVersions": policy.KeepVersions,
+				"maxAge":       policy.MaxAge.String(),
+				"ratioLimit":   policy.RatioLimit,
+			},
+			"lastSweep": lastSweep,
+			"torrents":  torrents,
+			"stopped":   notSeeded,
+			"count":     len(torrents),
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(response)
+	}
+}
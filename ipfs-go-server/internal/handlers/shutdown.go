@@ -0,0 +1,139 @@
+package handlers
+
+import (
+	"context"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"ipfs-go-server/internal/storage"
+)
+
+// pendingSaveReserve is the part of the shutdown budget kept for adding
+// snapshots of tables that are still unpublished once shutdown stops
+// waiting for background publishes. At most half the budget is kept.
+const pendingSaveReserve = 10 * time.Second
+
+// background tracks publishes that outlive the request that started them,
+// so shutdown can wait for them. Work started once shutdown began is not
+// waited for; its table is found unpublished instead. Shutdown cancels ctx
+// once it stops waiting.
+var background struct {
+	mu      sync.Mutex
+	wg      sync.WaitGroup
+	closing bool
+
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+func init() {
+	background.ctx, background.cancel = context.WithCancel(context.Background())
+}
+
+// goBackground runs fn in a goroutine that shutdown waits for. fn gets a
+// context carrying the values of parent, which is not cancelled with parent
+// but when shutdown stops waiting.
+func goBackground(parent context.Context, fn func(ctx context.Context)) {
+	ctx, cancel := context.WithCancel(context.WithoutCancel(parent))
+	stop := context.AfterFunc(background.ctx, cancel)
+	run := func() {
+		defer cancel()
+		defer stop()
+		fn(ctx)
+	}
+
+	background.mu.Lock()
+	defer background.mu.Unlock()
+
+	if background.closing {
+		go run()
+		return
+	}
+	background.wg.Add(1)
+	go func() {
+		defer background.wg.Done()
+		run()
+	}()
+}
+
+// publishPending retries publishing changes an earlier run left unpublished
+func publishPending(ctx context.Context, id string, stor *storage.Storage) {
+	hash, err := stor.PublishPending(ctx)
+	if err != nil {
+		log.Printf("[PERSISTENCE] Warning: Failed to publish unpublished changes of table %s: %v", id, err)
+		return
+	}
+	if hash == "" {
+		return
+	}
+	log.Printf("[PERSISTENCE] Published unpublished changes of table %s: %s", id, hash)
+	if err := saveRegistry(); err != nil {
+		log.Printf("[PERSISTENCE] Warning: Failed to save registry: %v", err)
+	}
+}
+
+// Shutdown waits for background publishes until they finish or most of
+// ctx's budget is spent, cancels the ones still running, then flushes the
+// registry. Tables with changes that are still unpublished get a snapshot
+// of them recorded in the registry, which is published on the next start.
+// All snapshots share what is left of ctx. It returns the IDs of those
+// tables, and of the tables whose changes are lost because no snapshot
+// could be added.
+func Shutdown(ctx context.Context) (pending, lost []string) {
+	background.mu.Lock()
+	background.closing = true
+	background.mu.Unlock()
+
+	drainCtx := ctx
+	if deadline, ok := ctx.Deadline(); ok {
+		reserve := min(pendingSaveReserve, time.Until(deadline)/2)
+		var cancel context.CancelFunc
+		drainCtx, cancel = context.WithDeadline(ctx, deadline.Add(-reserve))
+		defer cancel()
+	}
+
+	drained := make(chan struct{})
+	go func() {
+		background.wg.Wait()
+		close(drained)
+	}()
+
+	select {
+	case <-drained:
+		log.Println("[SHUTDOWN] Background publishes finished")
+	case <-drainCtx.Done():
+		log.Println("[SHUTDOWN] Warning: Stopped waiting for background publishes, cancelling them")
+	}
+	background.cancel()
+
+	for id, stor := range allTables() {
+		if stor.IsMirror() {
+			continue
+		}
+		cid, unpublished := stor.Unpublished()
+		if !unpublished {
+			continue
+		}
+		if cid == "" {
+			var err error
+			cid, err = stor.SaveUnpublished(ctx)
+			if err != nil {
+				log.Printf("[SHUTDOWN] Error: Changes to table %s are lost: %v", id, err)
+				lost = append(lost, id)
+				continue
+			}
+		}
+		log.Printf("[SHUTDOWN] Table %s is unpublished; %s will be published on the next start", id, cid)
+		pending = append(pending, id)
+	}
+
+	if err := saveRegistry(); err != nil {
+		log.Printf("[SHUTDOWN] Error: Failed to save registry: %v", err)
+	}
+
+	sort.Strings(pending)
+	sort.Strings(lost)
+	return pending, lost
+}
@@ -0,0 +1,156 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"regexp"
+	"sort"
+
+	"ipfs-go-server/internal/audit"
+	"ipfs-go-server/internal/models"
+	"ipfs-go-server/pkg/utils"
+)
+
+// Table IDs are generated by the server and never change, while names are
+// metadata that can be renamed. Tables created before IDs were generated
+// used their name as ID; MigrateTableIDs moves them to generated IDs.
+
+// generatedIDPattern matches the in-namespace part of a generated table ID
+var generatedIDPattern = regexp.MustCompile(`^[0-9a-f]{32}$`)
+
+// newTableID generates the server-wide ID of a new table in namespace ns
+func newTableID(ns string) (string, error) {
+	id, err := utils.GenerateUniqueID()
+	if err != nil {
+		return "", err
+	}
+	return models.TableRef(ns, id), nil
+}
+
+// generatedID reports whether a table ID was generated rather than taken
+// from the table's name
+func generatedID(id string) bool {
+	_, local := models.SplitTableRef(id)
+	return generatedIDPattern.MatchString(local)
+}
+
+// tableNamed returns the ID of the table called name in namespace ns.
+// Names are unique within a namespace.
+func tableNamed(ns, name string) (string, bool) {
+	for id, stor := range namespaceTables(ns) {
+		if stor.GetTable().Name == name {
+			return id, true
+		}
+	}
+	return "", false
+}
+
+// moveTable rekeys a table held in memory, and its ACL, under a new ID. It
+// returns false, and moves nothing, when newID is taken.
+func moveTable(oldID, newID string) bool {
+	tablesMu.Lock()
+	defer tablesMu.Unlock()
+
+	if _, taken := tableStorage[newID]; taken {
+		return false
+	}
+	if stor, exists := tableStorage[oldID]; exists {
+		tableStorage[newID] = stor
+		delete(tableStorage, oldID)
+	}
+	if acl, exists := tableACLs[oldID]; exists {
+		tableACLs[newID] = acl
+		delete(tableACLs, oldID)
+	}
+	return true
+}
+
+// retargetTable moves a table whose storage already goes by newID from
+// oldID to newID in memory, in the registry, and in its webhooks
+func retargetTable(oldID, newID string) error {
+	if !moveTable(oldID, newID) {
+		return fmt.Errorf("table %s already exists", newID)
+	}
+	if err := saveRegistry(); err != nil {
+		moveTable(newID, oldID)
+		return fmt.Errorf("failed to save registry: %w", err)
+	}
+
+	if webhookManager != nil {
+		if _, err := webhookManager.MoveTable(oldID, newID); err != nil {
+			log.Printf("[PERSISTENCE] Warning: Failed to move webhooks of table %s: %v", oldID, err)
+		}
+	}
+	recordAudit(audit.Entry{
+		Action:  audit.ActionMoveID,
+		TableID: newID,
+		Actor:   "server",
+		Detail:  "from " + oldID,
+	})
+	return nil
+}
+
+// MigrateTableIDs gives every table whose ID is its name a generated ID and
+// renames its IPNS key to match. The key, and so the table's IPNS name, is
+// unchanged; the old ID is kept among the table's former IDs, so versions
+// signed for it still verify and mirrors follow the change. Mirrors whose
+// origin migrated the table while this server was down take the new ID.
+// Tables whose key cannot be renamed keep their ID until the next start.
+// Call it after InitializeStorage, InitializeAudit and InitializeWebhooks.
+func MigrateTableIDs(ctx context.Context) {
+	tables := allTables()
+	var ids []string
+	for id, stor := range tables {
+		if current := stor.GetTable().ID; current != id {
+			if err := retargetTable(id, current); err != nil {
+				log.Printf("[PERSISTENCE] Warning: Mirror %s keeps its ID; its origin now calls it %s: %v", id, current, err)
+				continue
+			}
+			log.Printf("[PERSISTENCE] Mirror %s is now %s, following its origin", id, current)
+			continue
+		}
+		if !generatedID(id) && !stor.IsMirror() {
+			ids = append(ids, id)
+		}
+	}
+	if len(ids) == 0 {
+		return
+	}
+	sort.Strings(ids)
+	log.Printf("[PERSISTENCE] Moving %d tables from name-based IDs to generated IDs...", len(ids))
+
+	for _, id := range ids {
+		stor := tables[id]
+		ns, _ := models.SplitTableRef(id)
+		newID, err := newTableID(ns)
+		if err != nil {
+			log.Printf("[PERSISTENCE] Warning: Failed to generate an ID for table %s: %v", id, err)
+			continue
+		}
+
+		oldKeyName := stor.GetKeyName()
+		if err := stor.Reassign(ctx, newID, models.KeyName(newID)); err != nil {
+			log.Printf("[PERSISTENCE] Warning: Table %s keeps its ID for now: %v", id, err)
+			continue
+		}
+
+		// The renamed key is only found through the registry, so undo the
+		// move when it cannot be written
+		if err := retargetTable(id, newID); err != nil {
+			log.Printf("[PERSISTENCE] ERROR: Moving table %s back: %v", id, err)
+			if err := stor.Reassign(ctx, id, oldKeyName); err != nil {
+				log.Printf("[PERSISTENCE] ERROR: Key of table %s is now %s but the registry names %s: %v", id, models.KeyName(newID), oldKeyName, err)
+			}
+			return
+		}
+		log.Printf("[PERSISTENCE] Table %s is now %s, key %s", id, newID, models.KeyName(newID))
+
+		// Publish the new ID and its former one so mirrors follow
+		goBackground(ctx, func(ctx context.Context) {
+			if _, err := stor.BackgroundSaveToIPFS(ctx); err != nil {
+				log.Printf("[PERSISTENCE] Warning: Failed to publish table %s under its new ID: %v", newID, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,212 @@
+package handlers
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"ipfs-go-server/internal/models"
+	"ipfs-go-server/internal/signing"
+	"ipfs-go-server/internal/storage"
+
+	ipfs "github.com/ipfs/go-ipfs-api"
+)
+
+// fakeIPFS answers the parts of the IPFS API table migrations and key
+// backups use
+type fakeIPFS struct {
+	url string
+
+	mu        sync.Mutex
+	added     map[string][]byte
+	published map[string]string // key name to CID
+	renamed   map[string]string // old key name to new
+	keys      map[string][]byte // key name to private key
+}
+
+func newFakeIPFS(t *testing.T) (*fakeIPFS, *ipfs.Shell) {
+	t.Helper()
+
+	fake := &fakeIPFS{added: map[string][]byte{}, published: map[string]string{}, renamed: map[string]string{}, keys: map[string][]byte{}}
+	srv := httptest.NewServer(fake)
+	t.Cleanup(srv.Close)
+	fake.url = srv.URL
+	return fake, ipfs.NewShell(srv.URL)
+}
+
+// fakeKeyID derives the IPNS name of a private key held by fakeIPFS
+func fakeKeyID(key []byte) string {
+	sum := sha256.Sum256(key)
+	return "k51" + hex.EncodeToString(sum[:8])
+}
+
+func (f *fakeIPFS) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	args := r.URL.Query()["arg"]
+	switch r.URL.Path {
+	case "/api/v0/add":
+		reader, err := r.MultipartReader()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		part, err := reader.NextPart()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		data, _ := io.ReadAll(part)
+		sum := sha256.Sum256(data)
+		hash := "Qm" + hex.EncodeToString(sum[:16])
+		f.added[hash] = data
+		json.NewEncoder(w).Encode(map[string]string{"Hash": hash})
+	case "/api/v0/name/publish":
+		f.published[r.URL.Query().Get("key")] = args[0]
+		json.NewEncoder(w).Encode(map[string]string{"Name": "k51", "Value": "/ipfs/" + args[0]})
+	case "/api/v0/key/rename":
+		f.renamed[args[0]] = args[1]
+		json.NewEncoder(w).Encode(map[string]interface{}{"Was": args[0], "Now": args[1], "Id": "k51", "Overwrite": false})
+	case "/api/v0/key/export":
+		key, exists := f.keys[args[0]]
+		if !exists {
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(map[string]string{"Message": "no key named " + args[0], "Type": "error"})
+			return
+		}
+		w.Write(key)
+	case "/api/v0/key/list":
+		keys := make([]map[string]string, 0, len(f.keys))
+		for name, key := range f.keys {
+			keys = append(keys, map[string]string{"Name": name, "Id": fakeKeyID(key)})
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"Keys": keys})
+	case "/api/v0/version":
+		json.NewEncoder(w).Encode(map[string]string{"Version": "0.29.0"})
+	case "/api/v0/key/import":
+		reader, err := r.MultipartReader()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		part, err := reader.NextPart()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		key, _ := io.ReadAll(part)
+		f.keys[args[0]] = key
+		json.NewEncoder(w).Encode(map[string]string{"Name": args[0], "Id": fakeKeyID(key)})
+	default:
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"Message": "unexpected call to " + r.URL.Path, "Type": "error"})
+	}
+}
+
+// useTables replaces the tables held in memory for the length of a test
+func useTables(t *testing.T) {
+	t.Helper()
+
+	savedTables, savedACLs, savedFile := tableStorage, tableACLs, persistenceFile
+	tableStorage = make(map[string]*storage.Storage)
+	tableACLs = make(map[string]models.TableACL)
+	persistenceFile = filepath.Join(t.TempDir(), "tables_registry.json")
+	t.Cleanup(func() {
+		tableStorage, tableACLs, persistenceFile = savedTables, savedACLs, savedFile
+	})
+}
+
+func TestMigrateTableIDsKeepsSignaturesValid(t *testing.T) {
+	useTables(t)
+	fake, sh := newFakeIPFS(t)
+
+	publicKey, privateKey, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	version := models.TorrentVersion{
+		Hash:       "c12fe1c06bba254a9dc9f519b335aa7c1367a88a",
+		MagnetLink: "magnet:?xt=urn:btih:c12fe1c06bba254a9dc9f519b335aa7c1367a88a",
+		FileName:   "release.tar.gz",
+		FileSize:   42,
+		CreatedAt:  time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC),
+	}
+	payload, err := version.SigningPayload("release")
+	if err != nil {
+		t.Fatal(err)
+	}
+	version.Signature = base64.StdEncoding.EncodeToString(ed25519.Sign(privateKey, payload))
+	version.SignerKey = base64.StdEncoding.EncodeToString(publicKey)
+	version.SignerID = signing.Fingerprint(publicKey)
+
+	// A table from before generated IDs, holding a version signed for its name
+	stor := storage.NewStorageWithIPNS(sh, "release", "release", "", models.KeyName("release"), "k51release")
+	data, _ := json.Marshal([]models.TorrentVersion{version})
+	if err := stor.FastUpdateData(string(data)); err != nil {
+		t.Fatal(err)
+	}
+	storeTable("release", stor)
+
+	MigrateTableIDs(context.Background())
+	background.wg.Wait()
+
+	if _, exists := lookupTable("release"); exists {
+		t.Fatal("table is still held under its name-based ID")
+	}
+	table := stor.GetTable()
+	if !generatedID(table.ID) {
+		t.Fatalf("table ID = %s, want a generated ID", table.ID)
+	}
+	if moved, exists := lookupTable(table.ID); !exists || moved != stor {
+		t.Fatalf("table is not held under its new ID %s", table.ID)
+	}
+
+	// The version no longer verifies for the new ID alone, but does for the
+	// table's IDs
+	if err := signing.VerifyVersion(table.ID, version); !errors.Is(err, signing.ErrBadSignature) {
+		t.Fatalf("VerifyVersion for the new ID = %v, want ErrBadSignature", err)
+	}
+	if err := signing.VerifyVersionAs(table.IDs(), version); err != nil {
+		t.Fatalf("VerifyVersionAs after migration: %v", err)
+	}
+
+	// The registry and the published snapshot both carry the former ID
+	registry, err := os.ReadFile(persistenceFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(registry), `"formerIds":["release"]`) {
+		t.Fatalf("registry does not record the former ID: %s", registry)
+	}
+
+	fake.mu.Lock()
+	defer fake.mu.Unlock()
+	if renamed := fake.renamed[models.KeyName("release")]; renamed != models.KeyName(table.ID) {
+		t.Fatalf("key renamed to %q, want %q", renamed, models.KeyName(table.ID))
+	}
+	hash, published := fake.published[models.KeyName(table.ID)]
+	if !published {
+		t.Fatal("the table was not published under its new ID")
+	}
+	var snapshot models.Table
+	if err := json.Unmarshal(fake.added[hash], &snapshot); err != nil {
+		t.Fatal(err)
+	}
+	if snapshot.ID != table.ID || len(snapshot.FormerIDs) != 1 || snapshot.FormerIDs[0] != "release" {
+		t.Fatalf("published snapshot is %s with former IDs %v, want %s with [release]", snapshot.ID, snapshot.FormerIDs, table.ID)
+	}
+}
@@ -0,0 +1,239 @@
+package handlers
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/binary"
+	"encoding/json"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"ipfs-go-server/internal/audit"
+	"ipfs-go-server/internal/models"
+	"ipfs-go-server/internal/storage"
+	"ipfs-go-server/internal/torrent"
+	"ipfs-go-server/internal/tracker"
+)
+
+// embeddedTracker serves /announce, /scrape and UDP announces for the
+// torrents of this server's tables
+var embeddedTracker *tracker.Tracker
+
+// trackedHashes caches the info hashes of every table version. Table
+// events only mark it stale, since hooks run with the table locked.
+var trackedHashes struct {
+	stale  atomic.Bool
+	mu     sync.Mutex
+	hashes map[[sha1.Size]byte]bool
+}
+
+// StartTracker starts the embedded tracker, with UDP announces on udpAddr
+// unless it is empty. When passkey is set every request must carry it. It
+// returns announceURL with the passkey added, for torrents to announce to.
+func StartTracker(ctx context.Context, announceURL, passkey, udpAddr string) (string, error) {
+	t, err := tracker.New(isTracked, passkey)
+	if err != nil {
+		return "", err
+	}
+	embeddedTracker = t
+
+	trackedHashes.stale.Store(true)
+	storage.OnChange(func(storage.Event) { trackedHashes.stale.Store(true) })
+	go t.Run(ctx)
+
+	if udpAddr != "" {
+		conn, err := net.ListenPacket("udp", udpAddr)
+		if err != nil {
+			log.Printf("[TRACKER] Warning: Failed to listen for UDP announces on %s: %v", udpAddr, err)
+		} else {
+			log.Printf("[TRACKER] Listening for UDP announces on %s", conn.LocalAddr())
+			go func() {
+				<-ctx.Done()
+				conn.Close()
+			}()
+			go func() {
+				if err := t.ServeUDP(conn); err != nil {
+					log.Printf("[TRACKER] UDP tracker stopped: %v", err)
+				}
+			}()
+		}
+	}
+
+	if passkey != "" {
+		separator := "?"
+		if strings.Contains(announceURL, "?") {
+			separator = "&"
+		}
+		announceURL += separator + "passkey=" + url.QueryEscape(passkey)
+	}
+	log.Printf("[TRACKER] Tracker started (passkey required: %v)", passkey != "")
+	return announceURL, nil
+}
+
+// isTracked reports whether any table has a version with the info hash.
+// Hybrid torrents are tracked under their v1 hash and their truncated v2
+// hash, which v2 clients announce.
+func isTracked(infoHash [sha1.Size]byte) bool {
+	trackedHashes.mu.Lock()
+	defer trackedHashes.mu.Unlock()
+
+	if trackedHashes.stale.Swap(false) {
+		hashes := make(map[[sha1.Size]byte]bool)
+		for _, stor := range allTables() {
+			var versions []models.TorrentVersion
+			if err := json.Unmarshal([]byte(stor.GetTable().Data), &versions); err != nil {
+				continue
+			}
+			for _, v := range versions {
+				for _, hash := range []string{v.Hash, v.InfoHashV2} {
+					if h, ok := trackerHash(hash); ok {
+						hashes[h] = true
+					}
+				}
+			}
+		}
+		trackedHashes.hashes = hashes
+	}
+	return trackedHashes.hashes[infoHash]
+}
+
+// writeBencode sends a tracker response. Failures are reported in the
+// body with status 200, as BitTorrent clients expect.
+func writeBencode(w http.ResponseWriter, response map[string]interface{}) {
+	body, err := torrent.Encode(response)
+	if err != nil {
+		http.Error(w, "Failed to encode tracker response", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain")
+	w.WriteHeader(http.StatusOK)
+	w.Write(body)
+}
+
+func trackerFailure(w http.ResponseWriter, reason string) {
+	writeBencode(w, map[string]interface{}{"failure reason": reason})
+}
+
+func trackerAnnounceHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if embeddedTracker == nil {
+			http.Error(w, "Tracker is not enabled", http.StatusNotFound)
+			return
+		}
+
+		query := r.URL.Query()
+		if !embeddedTracker.CheckPasskey(query.Get("passkey")) {
+			trackerFailure(w, tracker.ErrBadPasskey.Error())
+			return
+		}
+
+		infoHash, peerID := query.Get("info_hash"), query.Get("peer_id")
+		port, portErr := strconv.Atoi(query.Get("port"))
+		left, leftErr := strconv.ParseInt(query.Get("left"), 10, 64)
+		ip := net.ParseIP(audit.SourceIP(r))
+		if len(infoHash) != sha1.Size || len(peerID) != sha1.Size || portErr != nil || port <= 0 || port > 65535 || leftErr != nil || ip == nil {
+			trackerFailure(w, "invalid announce")
+			return
+		}
+
+		numWant := -1
+		if raw := query.Get("numwant"); raw != "" {
+			if n, err := strconv.Atoi(raw); err == nil {
+				numWant = n
+			}
+		}
+
+		announce := tracker.Announce{
+			InfoHash: [sha1.Size]byte([]byte(infoHash)),
+			PeerID:   [sha1.Size]byte([]byte(peerID)),
+			IP:       ip,
+			Port:     port,
+			Left:     left,
+			Event:    query.Get("event"),
+			NumWant:  numWant,
+		}
+		log.Printf("[TRACKER] Announce of %x from %s (event: %q, left: %d)", announce.InfoHash, ip, announce.Event, left)
+
+		peers, stats, err := embeddedTracker.Announce(announce)
+		if err != nil {
+			trackerFailure(w, err.Error())
+			return
+		}
+
+		response := map[string]interface{}{
+			"interval":     int64(tracker.Interval / time.Second),
+			"min interval": int64(tracker.MinInterval / time.Second),
+			"complete":     stats.Seeders,
+			"incomplete":   stats.Leechers,
+		}
+		if query.Get("compact") == "0" {
+			list := make([]interface{}, 0, len(peers))
+			for _, p := range peers {
+				list = append(list, map[string]interface{}{"ip": p.IP.String(), "port": p.Port})
+			}
+			response["peers"] = list
+		} else {
+			var peers4, peers6 []byte
+			for _, p := range peers {
+				if ip4 := p.IP.To4(); ip4 != nil {
+					peers4 = binary.BigEndian.AppendUint16(append(peers4, ip4...), uint16(p.Port))
+				} else {
+					peers6 = binary.BigEndian.AppendUint16(append(peers6, p.IP.To16()...), uint16(p.Port))
+				}
+			}
+			response["peers"] = string(peers4)
+			if len(peers6) > 0 {
+				response["peers6"] = string(peers6)
+			}
+		}
+		writeBencode(w, response)
+	}
+}
+
+func trackerScrapeHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if embeddedTracker == nil {
+			http.Error(w, "Tracker is not enabled", http.StatusNotFound)
+			return
+		}
+
+		query := r.URL.Query()
+		if !embeddedTracker.CheckPasskey(query.Get("passkey")) {
+			trackerFailure(w, tracker.ErrBadPasskey.Error())
+			return
+		}
+
+		// Listing every tracked torrent would reveal private tables' hashes
+		hashes := query["info_hash"]
+		if len(hashes) == 0 {
+			trackerFailure(w, "info_hash is required")
+			return
+		}
+		log.Printf("[TRACKER] Scrape of %d torrents from %s", len(hashes), audit.SourceIP(r))
+
+		files := make(map[string]interface{})
+		for _, hash := range hashes {
+			if len(hash) != sha1.Size {
+				trackerFailure(w, "invalid info_hash")
+				return
+			}
+			stats, err := embeddedTracker.Scrape([sha1.Size]byte([]byte(hash)))
+			if err != nil {
+				continue
+			}
+			files[hash] = map[string]interface{}{
+				"complete":   stats.Seeders,
+				"downloaded": stats.Completed,
+				"incomplete": stats.Leechers,
+			}
+		}
+		writeBencode(w, map[string]interface{}{"files": files})
+	}
+}
@@ -0,0 +1,370 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"ipfs-go-server/internal/auth"
+	"ipfs-go-server/internal/encryption"
+	"ipfs-go-server/internal/ipfs"
+	"ipfs-go-server/internal/models"
+	"ipfs-go-server/internal/storage"
+	"ipfs-go-server/internal/torrent"
+)
+
+// Torrents built by the server are kept in torrentsDir/<info hash>/, which
+// holds the .torrent file and the payload under the torrent's name
+const (
+	torrentsDir      = "torrents"
+	maxPayloadSize   = 4 << 30
+	multipartMemory  = 32 << 20
+	torrentExtension = ".torrent"
+)
+
+// payloadDir is where a torrent's files are stored
+func payloadDir(infoHash string) string {
+	return dataPath(filepath.Join(torrentsDir, infoHash))
+}
+
+// storePayload moves a finished payload into place next to its .torrent file
+func storePayload(meta *torrent.MetaInfo, payloadPath string) error {
+	dir := payloadDir(meta.HexHash())
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(dir, meta.HexHash()+torrentExtension), meta.Torrent, 0600); err != nil {
+		return err
+	}
+	return os.Rename(payloadPath, filepath.Join(dir, meta.Name))
+}
+
+// nextVersionNumber numbers a version appended to the table's data
+func nextVersionNumber(stor *storage.Storage) int {
+	var items []interface{}
+	json.Unmarshal([]byte(stor.GetTable().Data), &items)
+	return len(items) + 1
+}
+
+// versionRecipients is who an encrypted payload is wrapped for: this server,
+// the recipients of a private table, and any listed in the request
+func versionRecipients(stor *storage.Storage, rawRecipients string) ([]encryption.Recipient, error) {
+	recipients := []encryption.Recipient{serverKeyring.Recipient()}
+	recipients = append(recipients, stor.GetRecipients()...)
+
+	if rawRecipients != "" {
+		var raw interface{}
+		if err := json.Unmarshal([]byte(rawRecipients), &raw); err != nil {
+			return nil, fmt.Errorf("recipients must be a JSON list of {name, publicKey}")
+		}
+		extra, err := parseRecipients(raw)
+		if err != nil {
+			return nil, err
+		}
+		recipients = append(recipients, extra...)
+	}
+	return encryption.UniqueRecipients(recipients), nil
+}
+
+// defaultTrackers are announced by server-built torrents unless an upload
+// names its own
+var defaultTrackers [][]string
+
+// gatewayURL is where clients reach this server's /ipfs/{cid} route, which
+// web seeds of payloads added to IPFS point at
+var gatewayURL string
+
+// InitializeTorrents sets the trackers server-built torrents announce to,
+// each in its own tier, and the base URL of their IPFS web seeds
+func InitializeTorrents(trackers []string, gateway string) {
+	defaultTrackers = announceTiers(trackers)
+	if len(defaultTrackers) > 0 {
+		log.Printf("[UPLOAD] Torrents announce to %d trackers", len(defaultTrackers))
+	}
+	gatewayURL = strings.TrimSuffix(gateway, "/")
+}
+
+func announceTiers(trackers []string) [][]string {
+	var tiers [][]string
+	for _, tracker := range trackers {
+		if tracker = strings.TrimSpace(tracker); tracker != "" {
+			tiers = append(tiers, []string{tracker})
+		}
+	}
+	return tiers
+}
+
+// torrentOptions reads the optional format, pieceLength and announce form
+// fields of an upload
+func torrentOptions(r *http.Request) (torrent.Options, error) {
+	var opts torrent.Options
+
+	format, err := torrent.ParseFormat(r.FormValue("format"))
+	if err != nil {
+		return opts, err
+	}
+	opts.Format = format
+
+	if raw := r.FormValue("pieceLength"); raw != "" {
+		length, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil || !torrent.ValidPieceLength(length) {
+			return opts, torrent.ErrInvalidPieceLength
+		}
+		opts.PieceLength = length
+	}
+
+	opts.Announce = defaultTrackers
+	if raw := r.FormValue("announce"); raw != "" {
+		opts.Announce = announceTiers(strings.Split(raw, ","))
+	}
+	return opts, nil
+}
+
+func uploadHandler(ipfsClient *ipfs.IPFSClient) http.HandlerFunc {
+	return handleUpload(ipfsClient, false)
+}
+
+// uploadEncryptedHandler encrypts an uploaded file under a fresh content
+// key, builds a torrent of the ciphertext and appends it as a version whose
+// content key is wrapped for each recipient
+func uploadEncryptedHandler(ipfsClient *ipfs.IPFSClient) http.HandlerFunc {
+	return handleUpload(ipfsClient, true)
+}
+
+// handleUpload builds a torrent of an uploaded file and appends it to the
+// table as a new version. With ipfs=true the payload is also added to IPFS
+// and the torrent lists it as a web seed.
+func handleUpload(ipfsClient *ipfs.IPFSClient, encrypt bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		tableID := requestTableID(r)
+		log.Printf("[UPLOAD] Upload called for table %s by %s (encrypted: %v)", tableID, auth.Actor(r.Context()), encrypt)
+
+		stor, exists := lookupTable(tableID)
+		if !exists {
+			http.Error(w, "Table not found", http.StatusNotFound)
+			return
+		}
+		if !authorizeTable(w, r, tableID, models.RoleWriter) {
+			return
+		}
+		if rejectMirror(w, stor) {
+			return
+		}
+		if encrypt && serverKeyring == nil {
+			http.Error(w, "Encryption is not enabled", http.StatusServiceUnavailable)
+			return
+		}
+		// The server builds the version, so no publisher has signed it
+		if status, err := checkUnsigned(); err != nil {
+			http.Error(w, "Uploads build unsigned versions: "+err.Error(), status)
+			return
+		}
+
+		// Uploads of large payloads outlive the server's read and write
+		// timeouts
+		rc := http.NewResponseController(w)
+		rc.SetReadDeadline(time.Time{})
+		rc.SetWriteDeadline(time.Time{})
+
+		r.Body = http.MaxBytesReader(w, r.Body, maxPayloadSize+multipartMemory)
+		if err := r.ParseMultipartForm(multipartMemory); err != nil {
+			log.Printf("[UPLOAD] Error parsing upload: %v", err)
+			http.Error(w, "Invalid upload: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		defer r.MultipartForm.RemoveAll()
+
+		file, header, err := r.FormFile("file")
+		if err != nil {
+			http.Error(w, "No file uploaded", http.StatusBadRequest)
+			return
+		}
+		defer file.Close()
+
+		ns, _ := models.SplitTableRef(tableID)
+		releaseQuota, ok := holdQuota(w, ns, 0, header.Size)
+		if !ok {
+			return
+		}
+		defer releaseQuota()
+
+		opts, err := torrentOptions(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		// Payloads are only added to IPFS on request, since anyone who
+		// knows the CID can fetch them
+		var payloadIPFS *ipfs.IPFSClient
+		if r.FormValue("ipfs") == "true" {
+			payloadIPFS = ipfsClient
+		}
+
+		var version models.TorrentVersion
+		var recipients []encryption.Recipient
+		if encrypt {
+			if recipients, err = versionRecipients(stor, r.FormValue("recipients")); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			version, err = buildEncryptedVersion(r.Context(), file, header.Filename, header.Size, opts, payloadIPFS, recipients)
+		} else {
+			version, err = buildVersion(r.Context(), file, header.Filename, header.Size, opts, payloadIPFS)
+		}
+		if err != nil {
+			log.Printf("[UPLOAD] Error building torrent: %v", err)
+			http.Error(w, "Failed to build torrent: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		version.Version = nextVersionNumber(stor)
+		version.Description = r.FormValue("description")
+
+		if _, err := appendItem(r, stor, version); err != nil {
+			http.Error(w, "Failed to append version: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		requestSeedSweep()
+		if encrypt {
+			log.Printf("[UPLOAD] Table %s version %d is encrypted torrent %s for %d recipients",
+				tableID, version.Version, version.Hash, len(recipients))
+		} else {
+			log.Printf("[UPLOAD] Table %s version %d is torrent %s", tableID, version.Version, version.Hash)
+		}
+		if version.CID != "" {
+			log.Printf("[UPLOAD] Payload of torrent %s is IPFS file %s", version.Hash, version.CID)
+		}
+
+		response := map[string]interface{}{
+			"success":    true,
+			"id":         tableID,
+			"version":    version,
+			"magnetLink": version.MagnetLink,
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(response)
+	}
+}
+
+// buildVersion copies the payload into torrentsDir and builds its torrent.
+// When ipfsClient is set the payload is also added to IPFS.
+func buildVersion(ctx context.Context, src io.Reader, fileName string, size int64, opts torrent.Options, ipfsClient *ipfs.IPFSClient) (models.TorrentVersion, error) {
+	meta, cid, err := stageTorrent(ctx, filepath.Base(fileName), size, opts, ipfsClient, func(dst io.Writer) error {
+		_, err := io.Copy(dst, src)
+		return err
+	})
+	if err != nil {
+		return models.TorrentVersion{}, err
+	}
+	return newVersion(meta, fileName, cid), nil
+}
+
+// buildEncryptedVersion encrypts the payload into torrentsDir, builds its
+// torrent and wraps the content key, bound to the info hash, for each
+// recipient
+func buildEncryptedVersion(ctx context.Context, src io.Reader, fileName string, size int64, opts torrent.Options, ipfsClient *ipfs.IPFSClient, recipients []encryption.Recipient) (models.TorrentVersion, error) {
+	key, err := encryption.NewContentKey()
+	if err != nil {
+		return models.TorrentVersion{}, err
+	}
+
+	name := filepath.Base(fileName) + ".enc"
+	meta, cid, err := stageTorrent(ctx, name, encryption.EncryptedSize(size), opts, ipfsClient, func(dst io.Writer) error {
+		return encryption.EncryptStream(dst, src, key)
+	})
+	if err != nil {
+		return models.TorrentVersion{}, err
+	}
+
+	wrapped := make([]models.WrappedKey, 0, len(recipients))
+	for _, r := range recipients {
+		k, err := encryption.WrapContentKey(key, r, meta.HexHash())
+		if err != nil {
+			return models.TorrentVersion{}, err
+		}
+		wrapped = append(wrapped, models.WrappedKey{RecipientID: r.ID, Name: r.Name, WrappedKey: k})
+	}
+
+	version := newVersion(meta, fileName, cid)
+	version.Encryption = &models.ContentEncryption{
+		Algorithm:     encryption.PayloadAlgorithm,
+		KeyAlgorithm:  encryption.ContentKeyAlgorithm,
+		PlaintextSize: size,
+		Keys:          wrapped,
+	}
+	return version, nil
+}
+
+// stageTorrent writes a payload of size bytes to a temporary file, builds
+// its torrent and moves both into the torrent's directory. When ipfsClient
+// is set the payload is added to IPFS first and the torrent lists this
+// server's gateway URL for it as a web seed; its CID is returned.
+func stageTorrent(ctx context.Context, name string, size int64, opts torrent.Options, ipfsClient *ipfs.IPFSClient, write func(io.Writer) error) (*torrent.MetaInfo, string, error) {
+	if err := os.MkdirAll(dataPath(torrentsDir), 0700); err != nil {
+		return nil, "", err
+	}
+	tmp, err := os.CreateTemp(dataPath(torrentsDir), "upload-*")
+	if err != nil {
+		return nil, "", err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	if err := write(tmp); err != nil {
+		return nil, "", fmt.Errorf("failed to write payload: %w", err)
+	}
+
+	var cid string
+	if ipfsClient != nil {
+		if _, err := tmp.Seek(0, io.SeekStart); err != nil {
+			return nil, "", err
+		}
+		if cid, err = ipfsClient.AddFile(ctx, tmp); err != nil {
+			return nil, "", err
+		}
+		opts.WebSeeds = append(opts.WebSeeds, webSeedURL(cid))
+	}
+
+	if _, err := tmp.Seek(0, io.SeekStart); err != nil {
+		return nil, "", err
+	}
+	meta, err := torrent.Build(tmp, name, size, opts)
+	if err != nil {
+		return nil, "", err
+	}
+	tmp.Close()
+
+	if err := storePayload(meta, tmp.Name()); err != nil {
+		return nil, "", fmt.Errorf("failed to store payload: %w", err)
+	}
+	return meta, cid, nil
+}
+
+// webSeedURL is the gateway URL of an IPFS file. It names the file itself,
+// so BEP-19 clients fetch it without appending the torrent's name.
+func webSeedURL(cid string) string {
+	return gatewayURL + "/ipfs/" + cid
+}
+
+func newVersion(meta *torrent.MetaInfo, fileName, cid string) models.TorrentVersion {
+	return models.TorrentVersion{
+		Hash:       meta.HexHash(),
+		InfoHashV2: meta.HexHashV2(),
+		MagnetLink: meta.MagnetLink(),
+		FileName:   filepath.Base(fileName),
+		FileSize:   meta.Length,
+		CreatedAt:  meta.CreatedAt,
+		CID:        cid,
+		WebSeeds:   meta.WebSeeds,
+	}
+}
@@ -0,0 +1,202 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+
+	"ipfs-go-server/internal/auth"
+	"ipfs-go-server/internal/storage"
+	"ipfs-go-server/internal/webhooks"
+
+	"github.com/gorilla/mux"
+)
+
+const webhooksFile = "webhooks.json"
+
+// webhookManager delivers table events to registered webhooks
+var webhookManager *webhooks.Manager
+
+// InitializeWebhooks loads registered webhooks and starts delivering table
+// events to them until ctx is cancelled
+func InitializeWebhooks(ctx context.Context) error {
+	manager, err := webhooks.NewManager(dataPath(webhooksFile))
+	if err != nil {
+		return err
+	}
+
+	webhookManager = manager
+	storage.OnChange(manager.Notify)
+	manager.Start(ctx)
+
+	log.Printf("[WEBHOOKS] Loaded %d webhooks", len(manager.List("")))
+	return nil
+}
+
+// redactWebhook hides the secret, which is only shown on registration
+func redactWebhook(hook webhooks.Webhook) webhooks.Webhook {
+	hook.Secret = ""
+	return hook
+}
+
+func requireWebhooks(w http.ResponseWriter) bool {
+	if webhookManager == nil {
+		http.Error(w, "Webhooks are not enabled", http.StatusServiceUnavailable)
+		return false
+	}
+	return true
+}
+
+func createWebhookHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		log.Printf("[WEBHOOKS] Create handler called by %s", auth.Actor(r.Context()))
+		if !requireWebhooks(w) {
+			return
+		}
+
+		var req webhooks.Webhook
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			log.Printf("[WEBHOOKS] Error parsing JSON: %v", err)
+			http.Error(w, "Invalid JSON format", http.StatusBadRequest)
+			return
+		}
+
+		if req.TableID != "" {
+			if _, exists := lookupTable(req.TableID); !exists {
+				http.Error(w, "Table not found", http.StatusNotFound)
+				return
+			}
+		}
+
+		hook, err := webhookManager.Register(req)
+		if err != nil {
+			log.Printf("[WEBHOOKS] Error registering webhook: %v", err)
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		log.Printf("[WEBHOOKS] Registered webhook %s for %s", hook.ID, hook.URL)
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(hook)
+	}
+}
+
+func listWebhooksHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !requireWebhooks(w) {
+			return
+		}
+
+		hooks := webhookManager.List(r.URL.Query().Get("table"))
+		for i := range hooks {
+			hooks[i] = redactWebhook(hooks[i])
+		}
+
+		response := map[string]interface{}{
+			"webhooks": hooks,
+			"count":    len(hooks),
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(response)
+	}
+}
+
+func getWebhookHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !requireWebhooks(w) {
+			return
+		}
+
+		hook, err := webhookManager.Get(mux.Vars(r)["id"])
+		if err != nil {
+			http.Error(w, "Webhook not found", http.StatusNotFound)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(redactWebhook(*hook))
+	}
+}
+
+func deleteWebhookHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := mux.Vars(r)["id"]
+		log.Printf("[WEBHOOKS] Delete handler called for ID: %s", id)
+		if !requireWebhooks(w) {
+			return
+		}
+
+		if err := webhookManager.Remove(id); err != nil {
+			if errors.Is(err, webhooks.ErrNotFound) {
+				http.Error(w, "Webhook not found", http.StatusNotFound)
+				return
+			}
+			http.Error(w, "Failed to delete webhook: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		response := map[string]interface{}{
+			"success": true,
+			"message": "Webhook deleted successfully",
+			"id":      id,
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(response)
+	}
+}
+
+func listDeliveriesHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := mux.Vars(r)["id"]
+		if !requireWebhooks(w) {
+			return
+		}
+		if _, err := webhookManager.Get(id); err != nil {
+			http.Error(w, "Webhook not found", http.StatusNotFound)
+			return
+		}
+
+		deliveries := webhookManager.Deliveries(id)
+		response := map[string]interface{}{
+			"deliveries": deliveries,
+			"count":      len(deliveries),
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(response)
+	}
+}
+
+func replayDeliveryHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		vars := mux.Vars(r)
+		log.Printf("[WEBHOOKS] Replay of delivery %s to webhook %s", vars["deliveryId"], vars["id"])
+		if !requireWebhooks(w) {
+			return
+		}
+
+		delivery, err := webhookManager.Replay(vars["id"], vars["deliveryId"])
+		if err != nil {
+			if errors.Is(err, webhooks.ErrNotFound) {
+				http.Error(w, "Delivery not found", http.StatusNotFound)
+				return
+			}
+			http.Error(w, "Failed to replay delivery: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusAccepted)
+		json.NewEncoder(w).Encode(delivery)
+	}
+}
@@ -2,6 +2,7 @@ package ipfs
 
 import (
 	"bytes"
+	"context"
 	"io"
 	"strings"
 
@@ -9,32 +10,43 @@ import (
 )
 
 type IPFSClient struct {
-	sh *ipfs.Shell
+	sh          *ipfs.Shell
+	maxReadSize int64
 }
 
 func NewIPFSClient(apiAddress string) *IPFSClient {
 	sh := ipfs.NewShell(apiAddress)
-	return &IPFSClient{sh: sh}
+	return &IPFSClient{sh: sh, maxReadSize: DefaultMaxReadSize}
 }
 
-func (client *IPFSClient) AddData(data string) (string, error) {
-	hash, err := client.sh.Add(strings.NewReader(data))
-	if err != nil {
-		return "", err
-	}
-	return hash, nil
+// SetMaxReadSize bounds reads made through the client
+func (client *IPFSClient) SetMaxReadSize(size int64) {
+	client.maxReadSize = size
 }
 
-func (client *IPFSClient) ResolveIPNS(name string) (string, error) {
-	resolved, err := client.sh.Resolve(name)
-	if err != nil {
-		return "", err
-	}
-	return resolved, nil
+// MaxReadSize returns the bound on reads made through the client
+func (client *IPFSClient) MaxReadSize() int64 {
+	return client.maxReadSize
+}
+
+// Cat streams the file at path, bounded by the client's maximum read size
+func (client *IPFSClient) Cat(ctx context.Context, path string) (io.ReadCloser, error) {
+	return Cat(ctx, client.sh, path, client.maxReadSize)
 }
 
-func (client *IPFSClient) GetData(hash string) (string, error) {
-	reader, err := client.sh.Cat(hash)
+func (client *IPFSClient) AddData(ctx context.Context, data string) (string, error) {
+	return Add(ctx, client.sh, strings.NewReader(data))
+}
+
+// ResolveIPNS returns the CID an IPNS name points at
+func (client *IPFSClient) ResolveIPNS(ctx context.Context, name string) (string, error) {
+	return ResolveName(ctx, client.sh, name)
+}
+
+// GetData reads a whole file, bounded by the client's maximum read size.
+// Use Cat to stream it instead.
+func (client *IPFSClient) GetData(ctx context.Context, hash string) (string, error) {
+	reader, err := client.Cat(ctx, hash)
 	if err != nil {
 		return "", err
 	}
@@ -51,10 +63,6 @@ func (client *IPFSClient) GetShell() *ipfs.Shell {
 	return client.sh
 }
 
-func (client *IPFSClient) Publish(data []byte) (string, error) {
-	hash, err := client.sh.Add(bytes.NewReader(data))
-	if err != nil {
-		return "", err
-	}
-	return hash, nil
+func (client *IPFSClient) Publish(ctx context.Context, data []byte) (string, error) {
+	return Add(ctx, client.sh, bytes.NewReader(data))
 }
@@ -0,0 +1,120 @@
+package ipfs
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	files "github.com/ipfs/boxo/files"
+	ipfs "github.com/ipfs/go-ipfs-api"
+)
+
+// Operation is a kind of call to the IPFS daemon. Each kind has its own
+// deadline, on top of whatever deadline the caller's context carries.
+type Operation string
+
+const (
+	OpAdd     Operation = "add"
+	OpCat     Operation = "cat"
+	OpResolve Operation = "resolve"
+	OpPublish Operation = "publish"
+	OpKeys    Operation = "keys"
+)
+
+var (
+	timeoutsMu sync.RWMutex
+	timeouts   = map[Operation]time.Duration{
+		OpAdd:     5 * time.Minute,
+		OpCat:     time.Minute,
+		OpResolve: 10 * time.Second,
+		OpPublish: 2 * time.Minute,
+		OpKeys:    30 * time.Second,
+	}
+)
+
+// SetTimeout changes the deadline of a kind of call. Zero removes it.
+func SetTimeout(op Operation, timeout time.Duration) {
+	timeoutsMu.Lock()
+	defer timeoutsMu.Unlock()
+
+	timeouts[op] = timeout
+}
+
+// Timeout returns the deadline of a kind of call
+func Timeout(op Operation) time.Duration {
+	timeoutsMu.RLock()
+	defer timeoutsMu.RUnlock()
+
+	return timeouts[op]
+}
+
+// WithTimeout bounds ctx by the deadline of op. An earlier deadline already
+// on ctx is kept.
+func WithTimeout(ctx context.Context, op Operation) (context.Context, context.CancelFunc) {
+	if timeout := Timeout(op); timeout > 0 {
+		return context.WithTimeout(ctx, timeout)
+	}
+	return context.WithCancel(ctx)
+}
+
+// Add adds and pins the contents of r, streaming it to the daemon
+func Add(ctx context.Context, sh *ipfs.Shell, r io.Reader, options ...ipfs.AddOpts) (string, error) {
+	ctx, cancel := WithTimeout(ctx, OpAdd)
+	defer cancel()
+
+	dir := files.NewSliceDirectory([]files.DirEntry{files.FileEntry("", files.NewReaderFile(r))})
+	req := sh.Request("add")
+	for _, option := range options {
+		option(req)
+	}
+
+	var out struct {
+		Hash string `json:"Hash"`
+	}
+	if err := req.Body(files.NewMultiFileReader(dir, true, false)).Exec(ctx, &out); err != nil {
+		return "", fmt.Errorf("failed to add to IPFS: %w", err)
+	}
+	return out.Hash, nil
+}
+
+// PublishName points the IPNS name of key at cid. Zero lifetime and ttl
+// leave the daemon defaults in place.
+func PublishName(ctx context.Context, sh *ipfs.Shell, cid, key string, lifetime, ttl time.Duration) error {
+	ctx, cancel := WithTimeout(ctx, OpPublish)
+	defer cancel()
+
+	req := sh.Request("name/publish", cid).Option("resolve", false)
+	if key != "" {
+		req.Option("key", key)
+	}
+	if lifetime > 0 {
+		req.Option("lifetime", lifetime)
+	}
+	if ttl > 0 {
+		req.Option("ttl", ttl)
+	}
+	return req.Exec(ctx, nil)
+}
+
+// ResolveName returns the CID an IPNS name points at. The daemon is told
+// the deadline too, so it stops searching when the caller gives up.
+func ResolveName(ctx context.Context, sh *ipfs.Shell, name string) (string, error) {
+	ctx, cancel := WithTimeout(ctx, OpResolve)
+	defer cancel()
+
+	req := sh.Request("name/resolve", name)
+	if deadline, ok := ctx.Deadline(); ok {
+		req.Option("timeout", time.Until(deadline).Round(time.Millisecond).String())
+	}
+
+	var out struct {
+		Path string `json:"Path"`
+	}
+	if err := req.Exec(ctx, &out); err != nil {
+		return "", fmt.Errorf("failed to resolve IPNS %s: %w", name, err)
+	}
+	return strings.TrimPrefix(out.Path, "/ipfs/"), nil
+}
@@ -0,0 +1,84 @@
+package ipfs
+
+import (
+	"context"
+	"errors"
+	"io"
+
+	ipfs "github.com/ipfs/go-ipfs-api"
+)
+
+// AddFile adds and pins a payload, streaming it to the daemon, and returns
+// its CIDv1 so gateway URLs are case-insensitive
+func (client *IPFSClient) AddFile(ctx context.Context, r io.Reader) (string, error) {
+	// Add closes readers that can be closed, so the caller's file is hidden
+	// behind a plain reader
+	return Add(ctx, client.sh, struct{ io.Reader }{r}, ipfs.Pin(true), ipfs.CidVersion(1), ipfs.RawLeaves(true))
+}
+
+// OpenFile returns a reader over a file of known size that fetches from
+// the daemon lazily, starting at the current offset, so seeking to serve a
+// byte range does not download what comes before it. Payloads can take
+// long to stream, so only ctx bounds it, not the cat deadline.
+func (client *IPFSClient) OpenFile(ctx context.Context, cid string, size int64) io.ReadSeekCloser {
+	return &fileReader{ctx: ctx, sh: client.sh, cid: cid, size: size}
+}
+
+type fileReader struct {
+	ctx    context.Context
+	sh     *ipfs.Shell
+	cid    string
+	size   int64
+	offset int64
+	body   io.ReadCloser
+}
+
+func (f *fileReader) Read(p []byte) (int, error) {
+	if f.offset >= f.size {
+		return 0, io.EOF
+	}
+	if f.body == nil {
+		resp, err := f.sh.Request("cat", f.cid).Option("offset", f.offset).Send(f.ctx)
+		if err != nil {
+			return 0, err
+		}
+		if resp.Error != nil {
+			resp.Close()
+			return 0, resp.Error
+		}
+		f.body = resp.Output
+	}
+
+	n, err := f.body.Read(p)
+	f.offset += int64(n)
+	if err == io.EOF && f.offset < f.size {
+		err = io.ErrUnexpectedEOF
+	}
+	return n, err
+}
+
+func (f *fileReader) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekCurrent:
+		offset += f.offset
+	case io.SeekEnd:
+		offset += f.size
+	}
+	if offset < 0 {
+		return 0, errors.New("seek before start of file")
+	}
+	if offset != f.offset {
+		f.Close()
+		f.offset = offset
+	}
+	return offset, nil
+}
+
+func (f *fileReader) Close() error {
+	if f.body == nil {
+		return nil
+	}
+	err := f.body.Close()
+	f.body = nil
+	return err
+}
@@ -1,6 +1,7 @@
 package ipfs
 
 import (
+	"context"
 	"fmt"
 	"strings"
 
@@ -19,13 +20,13 @@ func NewIPNSManager(ipfsClient *ipfs.Shell, ipnsName string) *IPNSManager {
 	}
 }
 
-func (m *IPNSManager) Publish(data string) error {
-	hash, err := m.ipfsClient.Add(strings.NewReader(data))
+func (m *IPNSManager) Publish(ctx context.Context, data string) error {
+	hash, err := Add(ctx, m.ipfsClient, strings.NewReader(data))
 	if err != nil {
 		return fmt.Errorf("failed to add data to IPFS: %w", err)
 	}
 
-	err = m.ipfsClient.Publish(m.ipnsName, hash)
+	err = PublishName(ctx, m.ipfsClient, hash, m.ipnsName, 0, 0)
 	if err != nil {
 		return fmt.Errorf("failed to publish IPNS record: %w", err)
 	}
@@ -33,10 +34,8 @@ func (m *IPNSManager) Publish(data string) error {
 	return nil
 }
 
-func (m *IPNSManager) Resolve() (string, error) {
-	// Removed unused context
-
-	hash, err := m.ipfsClient.Resolve(m.ipnsName)
+func (m *IPNSManager) Resolve(ctx context.Context) (string, error) {
+	hash, err := ResolveName(ctx, m.ipfsClient, m.ipnsName)
 	if err != nil {
 		return "", fmt.Errorf("failed to resolve IPNS name: %w", err)
 	}
@@ -0,0 +1,97 @@
+package ipfs
+
+import (
+	"bytes"
+	"context"
+	"encoding/base32"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// FindKey returns the IPNS name (peer ID) of the named key, or an empty
+// string if the daemon's keystore has no such key.
+func (client *IPFSClient) FindKey(ctx context.Context, name string) (string, error) {
+	ctx, cancel := WithTimeout(ctx, OpKeys)
+	defer cancel()
+
+	keys, err := client.sh.KeyList(ctx)
+	if err != nil {
+		return "", fmt.Errorf("failed to list keys: %w", err)
+	}
+
+	for _, key := range keys {
+		if key.Name == name {
+			return key.Id, nil
+		}
+	}
+	return "", nil
+}
+
+// ExportKey returns the private key stored under name in the
+// libp2p-protobuf-cleartext format accepted by ImportKey.
+//
+// Most daemons refuse key/export over the HTTP API, so when the RPC call
+// fails the key is read straight from the local keystore directory.
+func (client *IPFSClient) ExportKey(ctx context.Context, name string) ([]byte, error) {
+	ctx, cancel := WithTimeout(ctx, OpKeys)
+	defer cancel()
+
+	resp, err := client.sh.Request("key/export", name).Send(ctx)
+	if err == nil && resp.Error == nil {
+		defer resp.Close()
+		data, err := io.ReadAll(resp.Output)
+		if err == nil && len(data) > 0 {
+			return data, nil
+		}
+	} else if resp != nil {
+		resp.Close()
+	}
+
+	data, err := os.ReadFile(filepath.Join(keystoreDir(), keystoreFileName(name)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read key %s from keystore: %w", name, err)
+	}
+	return data, nil
+}
+
+// ImportKey adds a libp2p-protobuf-cleartext private key to the daemon's
+// keystore under name and returns the resulting IPNS name.
+func (client *IPFSClient) ImportKey(ctx context.Context, name string, key []byte) (string, error) {
+	importCtx, cancel := WithTimeout(ctx, OpKeys)
+	defer cancel()
+
+	if err := client.sh.KeyImport(importCtx, name, bytes.NewReader(key)); err != nil {
+		return "", fmt.Errorf("failed to import key %s: %w", name, err)
+	}
+
+	id, err := client.FindKey(ctx, name)
+	if err != nil {
+		return "", err
+	}
+	if id == "" {
+		return "", fmt.Errorf("key %s not found after import", name)
+	}
+	return id, nil
+}
+
+// keystoreDir mirrors the daemon's own lookup of $IPFS_PATH, falling back
+// to ~/.ipfs.
+func keystoreDir() string {
+	root := os.Getenv("IPFS_PATH")
+	if root == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			home = "."
+		}
+		root = filepath.Join(home, ".ipfs")
+	}
+	return filepath.Join(root, "keystore")
+}
+
+// keystoreFileName encodes a key name the same way the daemon's keystore does.
+func keystoreFileName(name string) string {
+	return "key_" + strings.ToLower(base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString([]byte(name)))
+}
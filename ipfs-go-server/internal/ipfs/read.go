@@ -0,0 +1,69 @@
+package ipfs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	ipfs "github.com/ipfs/go-ipfs-api"
+)
+
+// DefaultMaxReadSize bounds every read from IPFS unless configured
+// otherwise, so a huge object behind an IPNS name cannot exhaust memory
+const DefaultMaxReadSize = 64 << 20
+
+// ErrTooLarge is returned by reads that go past the maximum size
+var ErrTooLarge = errors.New("IPFS object is larger than the maximum read size")
+
+// Cat streams the file at path. Reading it fails with ErrTooLarge after
+// maxSize bytes, and the request is abandoned when ctx is cancelled or the
+// cat deadline passes.
+func Cat(ctx context.Context, sh *ipfs.Shell, path string, maxSize int64) (io.ReadCloser, error) {
+	ctx, cancel := WithTimeout(ctx, OpCat)
+
+	// One byte more than allowed is asked for, to tell a file of exactly
+	// maxSize bytes from a larger one
+	resp, err := sh.Request("cat", path).Option("length", maxSize+1).Send(ctx)
+	if err != nil {
+		cancel()
+		return nil, fmt.Errorf("failed to cat %s: %w", path, err)
+	}
+	if resp.Error != nil {
+		resp.Close()
+		cancel()
+		return nil, fmt.Errorf("failed to cat %s: %w", path, resp.Error)
+	}
+	return &boundedReader{body: resp.Output, closer: resp, cancel: cancel, remaining: maxSize}, nil
+}
+
+// boundedReader fails with ErrTooLarge instead of silently stopping at the
+// limit, as io.LimitReader would
+type boundedReader struct {
+	body      io.Reader
+	closer    io.Closer
+	cancel    context.CancelFunc
+	remaining int64
+}
+
+func (b *boundedReader) Read(p []byte) (int, error) {
+	if b.remaining <= 0 {
+		var probe [1]byte
+		n, err := b.body.Read(probe[:])
+		if n > 0 {
+			return 0, ErrTooLarge
+		}
+		return 0, err
+	}
+	if int64(len(p)) > b.remaining {
+		p = p[:b.remaining]
+	}
+	n, err := b.body.Read(p)
+	b.remaining -= int64(n)
+	return n, err
+}
+
+func (b *boundedReader) Close() error {
+	defer b.cancel()
+	return b.closer.Close()
+}
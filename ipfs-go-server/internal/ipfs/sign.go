@@ -0,0 +1,84 @@
+package ipfs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	files "github.com/ipfs/boxo/files"
+	ipfs "github.com/ipfs/go-ipfs-api"
+	"github.com/libp2p/go-libp2p/core/peer"
+	mbase "github.com/multiformats/go-multibase"
+)
+
+// signedMessagePrefix is prepended by the daemon to everything key/sign signs
+const signedMessagePrefix = "libp2p-key signed message:"
+
+// ErrBadSignature is returned when a signature does not verify.
+var ErrBadSignature = errors.New("signature does not match signer")
+
+// SignWithKey signs data with a keystore key using the daemon's key/sign
+// command and returns the multibase-encoded signature.
+func SignWithKey(ctx context.Context, sh *ipfs.Shell, keyName string, data []byte) (string, error) {
+	ctx, cancel := WithTimeout(ctx, OpKeys)
+	defer cancel()
+
+	dir := files.NewSliceDirectory([]files.DirEntry{files.FileEntry("", files.NewBytesFile(data))})
+	body := files.NewMultiFileReader(dir, true, false)
+
+	var out struct {
+		Signature string `json:"Signature"`
+	}
+	if err := sh.Request("key/sign").Option("key", keyName).Body(body).Exec(ctx, &out); err != nil {
+		return "", err
+	}
+	return out.Signature, nil
+}
+
+// VerifySignature checks a signature produced by SignWithKey against the
+// public key embedded in an IPNS name, without contacting the daemon.
+func VerifySignature(ipnsName string, data []byte, signature string) error {
+	id, err := peer.Decode(ipnsName)
+	if err != nil {
+		return fmt.Errorf("invalid IPNS name %s: %w", ipnsName, err)
+	}
+
+	pub, err := id.ExtractPublicKey()
+	if err != nil {
+		return fmt.Errorf("public key not embedded in %s: %w", ipnsName, err)
+	}
+
+	_, sig, err := mbase.Decode(signature)
+	if err != nil {
+		return fmt.Errorf("invalid signature encoding: %w", err)
+	}
+
+	ok, err := pub.Verify(append([]byte(signedMessagePrefix), data...), sig)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrBadSignature
+	}
+	return nil
+}
+
+// SignWithKey signs data with a keystore key.
+func (client *IPFSClient) SignWithKey(ctx context.Context, keyName string, data []byte) (string, error) {
+	return SignWithKey(ctx, client.sh, keyName, data)
+}
+
+// IPNSPubsubEnabled reports whether the daemon publishes and resolves IPNS
+// records over pubsub (ipfs daemon --enable-namesys-pubsub).
+func (client *IPFSClient) IPNSPubsubEnabled(ctx context.Context) (bool, error) {
+	ctx, cancel := WithTimeout(ctx, OpKeys)
+	defer cancel()
+
+	var out struct {
+		Enabled bool `json:"Enabled"`
+	}
+	if err := client.sh.Request("name/pubsub/state").Exec(ctx, &out); err != nil {
+		return false, err
+	}
+	return out.Enabled, nil
+}
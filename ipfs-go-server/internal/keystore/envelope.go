@@ -0,0 +1,118 @@
+package keystore
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"time"
+
+	"golang.org/x/crypto/scrypt"
+)
+
+const (
+	envelopeVersion = 1
+	kdfScrypt       = "scrypt"
+
+	scryptN      = 1 << 15
+	scryptR      = 8
+	scryptP      = 1
+	scryptKeyLen = 32
+	saltSize     = 16
+)
+
+// ErrBadPassphrase is returned when an envelope cannot be opened with the
+// supplied passphrase (or its ciphertext has been tampered with).
+var ErrBadPassphrase = errors.New("wrong passphrase or corrupted key envelope")
+
+// KeyEnvelope is a passphrase-encrypted IPNS private key together with the
+// table it belongs to, so it can be moved to another node.
+type KeyEnvelope struct {
+	Version    int       `json:"version"`
+	TableID    string    `json:"tableId"`
+	TableName  string    `json:"tableName"`
+	KeyName    string    `json:"keyName"`
+	IPNSName   string    `json:"ipnsName"`
+	KDF        string    `json:"kdf"`
+	Salt       []byte    `json:"salt"`
+	Nonce      []byte    `json:"nonce"`
+	Ciphertext []byte    `json:"ciphertext"`
+	CreatedAt  time.Time `json:"createdAt"`
+}
+
+// Seal encrypts key with a passphrase-derived AES-256-GCM key. The table
+// metadata is authenticated along with the key so it cannot be swapped.
+func Seal(tableID, tableName, keyName, ipnsName string, key []byte, passphrase string) (*KeyEnvelope, error) {
+	if passphrase == "" {
+		return nil, errors.New("passphrase is required")
+	}
+
+	env := &KeyEnvelope{
+		Version:   envelopeVersion,
+		TableID:   tableID,
+		TableName: tableName,
+		KeyName:   keyName,
+		IPNSName:  ipnsName,
+		KDF:       kdfScrypt,
+		Salt:      make([]byte, saltSize),
+		CreatedAt: time.Now().UTC(),
+	}
+	if _, err := rand.Read(env.Salt); err != nil {
+		return nil, fmt.Errorf("failed to generate salt: %w", err)
+	}
+
+	gcm, err := env.cipher(passphrase)
+	if err != nil {
+		return nil, err
+	}
+
+	env.Nonce = make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(env.Nonce); err != nil {
+		return nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+
+	env.Ciphertext = gcm.Seal(nil, env.Nonce, key, env.additionalData())
+	return env, nil
+}
+
+// Open decrypts the private key held in the envelope.
+func (env *KeyEnvelope) Open(passphrase string) ([]byte, error) {
+	if env.Version != envelopeVersion {
+		return nil, fmt.Errorf("unsupported key envelope version %d", env.Version)
+	}
+	if env.KDF != kdfScrypt {
+		return nil, fmt.Errorf("unsupported key derivation function %q", env.KDF)
+	}
+
+	gcm, err := env.cipher(passphrase)
+	if err != nil {
+		return nil, err
+	}
+	if len(env.Nonce) != gcm.NonceSize() {
+		return nil, ErrBadPassphrase
+	}
+
+	key, err := gcm.Open(nil, env.Nonce, env.Ciphertext, env.additionalData())
+	if err != nil {
+		return nil, ErrBadPassphrase
+	}
+	return key, nil
+}
+
+func (env *KeyEnvelope) cipher(passphrase string) (cipher.AEAD, error) {
+	derived, err := scrypt.Key([]byte(passphrase), env.Salt, scryptN, scryptR, scryptP, scryptKeyLen)
+	if err != nil {
+		return nil, fmt.Errorf("failed to derive key: %w", err)
+	}
+
+	block, err := aes.NewCipher(derived)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func (env *KeyEnvelope) additionalData() []byte {
+	return []byte(env.TableID + "\x00" + env.KeyName + "\x00" + env.IPNSName)
+}
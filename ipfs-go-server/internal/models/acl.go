@@ -0,0 +1,162 @@
+package models
+
+import "fmt"
+
+// Role is what a principal may do with a table. Roles are ordered: an owner
+// can do everything a writer can, and a writer everything a reader can.
+type Role string
+
+const (
+	RoleNone   Role = ""
+	RoleReader Role = "reader"
+	RoleWriter Role = "writer"
+	RoleOwner  Role = "owner"
+)
+
+// Everyone can be granted a role to give it to every principal
+const Everyone = "*"
+
+var roleRank = map[Role]int{
+	RoleNone:   0,
+	RoleReader: 1,
+	RoleWriter: 2,
+	RoleOwner:  3,
+}
+
+// Includes reports whether r grants at least the access of other
+func (r Role) Includes(other Role) bool {
+	return roleRank[r] >= roleRank[other]
+}
+
+// TableACL lists who may access a table. Principals are API key IDs, since
+// a revoked key's name can be given to a new key. ACLs written before that
+// hold key names and have KeyIDs unset until they are converted.
+type TableACL struct {
+	Owner   string   `json:"owner,omitempty"`
+	Writers []string `json:"writers,omitempty"`
+	Readers []string `json:"readers,omitempty"`
+	KeyIDs  bool     `json:"keyIds,omitempty"`
+}
+
+// Clone returns a copy that shares no slices with a
+func (a TableACL) Clone() TableACL {
+	return TableACL{
+		Owner:   a.Owner,
+		Writers: append([]string(nil), a.Writers...),
+		Readers: append([]string(nil), a.Readers...),
+		KeyIDs:  a.KeyIDs,
+	}
+}
+
+// ToKeyIDs converts an ACL of key names to key IDs. keyID maps a name to
+// the ID of the live key holding it; names no live key holds are kept, and
+// match no key from then on.
+func (a *TableACL) ToKeyIDs(keyID func(name string) (string, bool)) {
+	if a.KeyIDs {
+		return
+	}
+	convert := func(principal string) string {
+		if principal == Everyone || principal == "" {
+			return principal
+		}
+		if id, ok := keyID(principal); ok {
+			return id
+		}
+		return principal
+	}
+
+	a.Owner = convert(a.Owner)
+	for i, p := range a.Writers {
+		a.Writers[i] = convert(p)
+	}
+	for i, p := range a.Readers {
+		a.Readers[i] = convert(p)
+	}
+	a.KeyIDs = true
+}
+
+// Unowned reports whether the table predates ownership
+func (a TableACL) Unowned() bool {
+	return a.Owner == ""
+}
+
+// RoleOf returns the highest role granted to principal
+func (a TableACL) RoleOf(principal string) Role {
+	switch {
+	case a.Owner == principal:
+		return RoleOwner
+	case contains(a.Writers, principal) || contains(a.Writers, Everyone):
+		return RoleWriter
+	case contains(a.Readers, principal) || contains(a.Readers, Everyone):
+		return RoleReader
+	}
+	return RoleNone
+}
+
+// Grant gives principal a role, replacing any role it had. Granting owner
+// transfers ownership; the previous owner stays on as a writer.
+func (a *TableACL) Grant(principal string, role Role) error {
+	if principal == "" {
+		return fmt.Errorf("principal is required")
+	}
+
+	switch role {
+	case RoleOwner:
+		if principal == Everyone {
+			return fmt.Errorf("ownership cannot be granted to everyone")
+		}
+		if a.Owner != "" && a.Owner != principal {
+			a.Writers = appendUnique(a.Writers, a.Owner)
+		}
+		a.Revoke(principal)
+		a.Owner = principal
+	case RoleWriter:
+		if principal == a.Owner {
+			return fmt.Errorf("%s already owns the table", principal)
+		}
+		a.Revoke(principal)
+		a.Writers = appendUnique(a.Writers, principal)
+	case RoleReader:
+		if principal == a.Owner {
+			return fmt.Errorf("%s already owns the table", principal)
+		}
+		a.Revoke(principal)
+		a.Readers = appendUnique(a.Readers, principal)
+	default:
+		return fmt.Errorf("unknown role %q, expected reader, writer or owner", role)
+	}
+	return nil
+}
+
+// Revoke removes principal from the writers and readers. The owner cannot
+// be revoked, only replaced.
+func (a *TableACL) Revoke(principal string) {
+	a.Writers = remove(a.Writers, principal)
+	a.Readers = remove(a.Readers, principal)
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+func appendUnique(list []string, s string) []string {
+	if contains(list, s) {
+		return list
+	}
+	return append(list, s)
+}
+
+func remove(list []string, s string) []string {
+	var out []string
+	for _, item := range list {
+		if item != s {
+			out = append(out, item)
+		}
+	}
+	return out
+}
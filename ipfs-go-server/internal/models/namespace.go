@@ -0,0 +1,83 @@
+package models
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// DefaultNamespace holds the tables created outside of any namespace,
+// including every table from before namespaces existed. Its table IDs carry
+// no prefix.
+const DefaultNamespace = "default"
+
+// namespacePattern keeps namespace names usable in URLs and IPNS key names
+var namespacePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9-]{0,62}$`)
+
+// ValidateNamespace checks that name may be used for a namespace
+func ValidateNamespace(name string) error {
+	if !namespacePattern.MatchString(name) {
+		return fmt.Errorf("invalid namespace %q: use 1-63 lowercase letters, digits and hyphens", name)
+	}
+	return nil
+}
+
+// TableRef returns the server-wide ID of table id in namespace ns. Tables
+// in the default namespace keep their bare ID; others are "ns/id".
+func TableRef(ns, id string) string {
+	if ns == "" || ns == DefaultNamespace {
+		return id
+	}
+	return ns + "/" + id
+}
+
+// SplitTableRef returns the namespace and the in-namespace ID of a
+// server-wide table ID
+func SplitTableRef(ref string) (ns, id string) {
+	if i := strings.IndexByte(ref, '/'); i >= 0 {
+		return ref[:i], ref[i+1:]
+	}
+	return DefaultNamespace, ref
+}
+
+// KeyName returns the IPNS key name a new table is published under. Keys of
+// namespaced tables are prefixed with the namespace, since the keystore is
+// shared by every namespace and its names cannot contain slashes.
+func KeyName(ref string) string {
+	ns, id := SplitTableRef(ref)
+	if ns == DefaultNamespace {
+		return id
+	}
+	return ns + "." + id
+}
+
+// Quota limits what a namespace may hold. Zero leaves a limit off.
+type Quota struct {
+	MaxTables int   `json:"maxTables,omitempty"`
+	MaxBytes  int64 `json:"maxBytes,omitempty"` // payload bytes of every version
+}
+
+// Usage is what a namespace holds, counted against its quota
+type Usage struct {
+	Tables int   `json:"tables"`
+	Bytes  int64 `json:"bytes"`
+}
+
+// Allows reports whether usage may grow by tables and bytes within q
+func (q Quota) Allows(usage Usage, tables int, bytes int64) error {
+	if q.MaxTables > 0 && tables > 0 && usage.Tables+tables > q.MaxTables {
+		return fmt.Errorf("namespace holds %d of %d tables", usage.Tables, q.MaxTables)
+	}
+	if q.MaxBytes > 0 && bytes > 0 && usage.Bytes+bytes > q.MaxBytes {
+		return fmt.Errorf("namespace holds %d of %d bytes, %d more requested", usage.Bytes, q.MaxBytes, bytes)
+	}
+	return nil
+}
+
+// Validate checks that the limits are not negative
+func (q Quota) Validate() error {
+	if q.MaxTables < 0 || q.MaxBytes < 0 {
+		return fmt.Errorf("quota limits must not be negative")
+	}
+	return nil
+}
@@ -0,0 +1,31 @@
+package models
+
+import "time"
+
+// MovedPointerType marks an IPNS snapshot that redirects to another name.
+const MovedPointerType = "moved"
+
+// KeyRotation records an IPNS key a table was moved away from.
+type KeyRotation struct {
+	OldKeyName  string    `json:"oldKeyName"`
+	OldIPNSName string    `json:"oldIpnsName"`
+	NewKeyName  string    `json:"newKeyName"`
+	NewIPNSName string    `json:"newIpnsName"`
+	SnapshotCID string    `json:"snapshotCid"`
+	PointerCID  string    `json:"pointerCid"`
+	Retired     bool      `json:"retired"` // old key removed from the keystore
+	RotatedAt   time.Time `json:"rotatedAt"`
+}
+
+// MovedPointer is published at a retired IPNS name so followers resolving
+// the old name can find the table under its new one. Signature is made with
+// the old key over the pointer encoded without it.
+type MovedPointer struct {
+	Type      string    `json:"type"`
+	TableID   string    `json:"tableId"`
+	From      string    `json:"from"`
+	To        string    `json:"to"`
+	Snapshot  string    `json:"snapshot"`
+	MovedAt   time.Time `json:"movedAt"`
+	Signature string    `json:"signature,omitempty"`
+}
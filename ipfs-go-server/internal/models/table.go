@@ -1,6 +1,7 @@
 package models
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
 	"sync"
@@ -15,10 +16,125 @@ type TorrentVersion struct {
 	FileSize    int64     `json:"fileSize"`
 	Description string    `json:"description"`
 	CreatedAt   time.Time `json:"createdAt"`
+
+	// BEP-52 info hash of v2 and hybrid torrents. For v2-only torrents it
+	// is also the Hash.
+	InfoHashV2 string `json:"infoHashV2,omitempty"`
+
+	// Set when the payload was also added to IPFS: its CID, and the BEP-19
+	// web seed URLs the torrent lists so clients can fetch it from there
+	// when no peer has it
+	CID      string   `json:"cid,omitempty"`
+	WebSeeds []string `json:"webSeeds,omitempty"`
+
+	// Detached Ed25519 signature over SigningPayload, base64 encoded, and
+	// the publisher key that made it
+	Signature string `json:"signature,omitempty"`
+	SignerID  string `json:"signerId,omitempty"`
+	SignerKey string `json:"signerKey,omitempty"`
+
+	// Set when the torrent payload is ciphertext
+	Encryption *ContentEncryption `json:"encryption,omitempty"`
+
+	// What trackers last reported about the torrent's swarm
+	Availability *Availability `json:"availability,omitempty"`
+}
+
+// Availability statuses. A version is dead when trackers answered but none
+// knows a seeder, and unknown when no tracker answered.
+const (
+	AvailabilityAlive   = "alive"
+	AvailabilityDead    = "dead"
+	AvailabilityUnknown = "unknown"
+)
+
+// Availability is the result of asking a version's trackers for its swarm.
+// Counts are the highest any tracker reported.
+type Availability struct {
+	Status    string    `json:"status"`
+	Seeders   int       `json:"seeders"`
+	Leechers  int       `json:"leechers"`
+	Trackers  int       `json:"trackers"` // how many trackers answered
+	CheckedAt time.Time `json:"checkedAt"`
+	Error     string    `json:"error,omitempty"`
+}
+
+// ContentEncryption describes how a version's payload was encrypted and
+// holds its content key wrapped for each recipient
+type ContentEncryption struct {
+	Algorithm     string       `json:"algorithm"`
+	KeyAlgorithm  string       `json:"keyAlgorithm"`
+	PlaintextSize int64        `json:"plaintextSize"`
+	Keys          []WrappedKey `json:"keys"`
+}
+
+// WrappedKey is a content key encrypted for one recipient's public key
+type WrappedKey struct {
+	RecipientID string `json:"recipientId"`
+	Name        string `json:"name,omitempty"`
+	WrappedKey  string `json:"wrappedKey"`
+}
+
+// KeyFor returns the key wrapped for a recipient
+func (e *ContentEncryption) KeyFor(recipientID string) (WrappedKey, bool) {
+	for _, k := range e.Keys {
+		if k.RecipientID == recipientID {
+			return k, true
+		}
+	}
+	return WrappedKey{}, false
+}
+
+// SignedTimeFormat matches JavaScript's Date.toISOString
+const SignedTimeFormat = "2006-01-02T15:04:05.000Z"
+
+// SigningPayload is the canonical encoding a version signature covers:
+// compact JSON with these keys in this order, no HTML escaping, and
+// createdAt in UTC with millisecond precision. It covers every field a
+// publisher sets; cid, encryption, infoHashV2 and webSeeds are left out
+// when empty, so versions without them encode as before those fields
+// existed. The version number, availability and the signature fields are
+// left out because the server sets them; the table ID is included so a
+// signed version cannot be replayed into another table.
+func (v TorrentVersion) SigningPayload(tableID string) ([]byte, error) {
+	payload := struct {
+		CID         string             `json:"cid,omitempty"`
+		CreatedAt   string             `json:"createdAt"`
+		Description string             `json:"description"`
+		Encryption  *ContentEncryption `json:"encryption,omitempty"`
+		FileName    string             `json:"fileName"`
+		FileSize    int64              `json:"fileSize"`
+		Hash        string             `json:"hash"`
+		InfoHashV2  string             `json:"infoHashV2,omitempty"`
+		MagnetLink  string             `json:"magnetLink"`
+		TableID     string             `json:"tableId"`
+		WebSeeds    []string           `json:"webSeeds,omitempty"`
+	}{
+		CID:         v.CID,
+		CreatedAt:   v.CreatedAt.UTC().Format(SignedTimeFormat),
+		Description: v.Description,
+		Encryption:  v.Encryption,
+		FileName:    v.FileName,
+		FileSize:    v.FileSize,
+		Hash:        v.Hash,
+		InfoHashV2:  v.InfoHashV2,
+		MagnetLink:  v.MagnetLink,
+		TableID:     tableID,
+		WebSeeds:    v.WebSeeds,
+	}
+
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(payload); err != nil {
+		return nil, err
+	}
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n")), nil
 }
 
 type Table struct {
 	ID          string           `json:"id"`
+	FormerIDs   []string         `json:"formerIds,omitempty"` // IDs the table had before, oldest first
 	Name        string           `json:"name"`
 	Description string           `json:"description"`
 	Data        string           `json:"data"` // JSON string of TorrentVersion array
@@ -41,6 +157,12 @@ func NewTable(id, name, description string) *Table {
 	}
 }
 
+// IDs returns the table's ID followed by the IDs it had before. Versions
+// signed before an ID migration were signed for one of the former IDs.
+func (t *Table) IDs() []string {
+	return append([]string{t.ID}, t.FormerIDs...)
+}
+
 func (t *Table) AddVersion(version TorrentVersion) {
 	t.mu.Lock()
 	defer t.mu.Unlock()
@@ -0,0 +1,179 @@
+package pubsub
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"ipfs-go-server/internal/ipfs"
+)
+
+// topicPrefix namespaces the per-table announcement topics
+const topicPrefix = "/torrentchain/tables/"
+
+// Announcement tells followers that a table has a new head snapshot. It is
+// signed with the table's IPNS key so followers can check it came from the
+// publisher without resolving IPNS.
+type Announcement struct {
+	TableID     string    `json:"tableId"`
+	IPNSName    string    `json:"ipnsName"`
+	CID         string    `json:"cid"`
+	Sequence    uint64    `json:"sequence"`
+	PublishedAt time.Time `json:"publishedAt"`
+	Signature   string    `json:"signature,omitempty"`
+}
+
+// Topic returns the announcement topic for a table's IPNS name
+func Topic(ipnsName string) string {
+	return topicPrefix + ipnsName
+}
+
+// Broker publishes announcements for owned tables and follows the topics of
+// mirrored ones.
+type Broker struct {
+	ctx    context.Context
+	client *ipfs.IPFSClient
+
+	mu      sync.Mutex
+	follows map[string]context.CancelFunc
+}
+
+func NewBroker(ctx context.Context, client *ipfs.IPFSClient) *Broker {
+	return &Broker{
+		ctx:     ctx,
+		client:  client,
+		follows: make(map[string]context.CancelFunc),
+	}
+}
+
+// Announce signs and publishes a new head for a table
+func (b *Broker) Announce(ctx context.Context, tableID, keyName, ipnsName, cid string) error {
+	now := time.Now().UTC()
+	announcement := Announcement{
+		TableID:     tableID,
+		IPNSName:    ipnsName,
+		CID:         cid,
+		Sequence:    uint64(now.UnixNano()),
+		PublishedAt: now,
+	}
+
+	unsigned, err := json.Marshal(announcement)
+	if err != nil {
+		return err
+	}
+	if announcement.Signature, err = b.client.SignWithKey(ctx, keyName, unsigned); err != nil {
+		return fmt.Errorf("failed to sign announcement: %w", err)
+	}
+
+	data, err := json.Marshal(announcement)
+	if err != nil {
+		return err
+	}
+
+	if err := b.client.GetShell().PubSubPublish(Topic(ipnsName), string(data)); err != nil {
+		return fmt.Errorf("failed to publish announcement: %w", err)
+	}
+
+	log.Printf("[PUBSUB] Announced %s for table %s", cid, tableID)
+	return nil
+}
+
+// Follow subscribes to a table's topic and calls apply with every
+// announcement whose signature verifies against ipnsName. Following an
+// already followed name is a no-op.
+func (b *Broker) Follow(ipnsName string, apply func(Announcement)) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if _, exists := b.follows[ipnsName]; exists {
+		return
+	}
+
+	ctx, cancel := context.WithCancel(b.ctx)
+	b.follows[ipnsName] = cancel
+
+	// With IPNS over pubsub enabled, resolving once joins the name's topic
+	go b.client.ResolveIPNS(ctx, ipnsName)
+
+	go b.subscribe(ctx, ipnsName, apply)
+	log.Printf("[PUBSUB] Following %s", Topic(ipnsName))
+}
+
+// Unfollow stops following a table's topic
+func (b *Broker) Unfollow(ipnsName string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if cancel, exists := b.follows[ipnsName]; exists {
+		cancel()
+		delete(b.follows, ipnsName)
+		log.Printf("[PUBSUB] Stopped following %s", Topic(ipnsName))
+	}
+}
+
+// subscribe reads announcements until ctx is cancelled, resubscribing with
+// backoff when the daemon drops the subscription.
+func (b *Broker) subscribe(ctx context.Context, ipnsName string, apply func(Announcement)) {
+	backoff := time.Second
+	for ctx.Err() == nil {
+		sub, err := b.client.GetShell().PubSubSubscribe(Topic(ipnsName))
+		if err != nil {
+			log.Printf("[PUBSUB] Failed to subscribe to %s: %v", Topic(ipnsName), err)
+		} else {
+			backoff = time.Second
+			stop := context.AfterFunc(ctx, func() { sub.Cancel() })
+
+			for {
+				msg, err := sub.Next()
+				if err != nil {
+					if ctx.Err() == nil {
+						log.Printf("[PUBSUB] Subscription to %s ended: %v", Topic(ipnsName), err)
+					}
+					break
+				}
+
+				announcement, err := verifyAnnouncement(ipnsName, msg.Data)
+				if err != nil {
+					log.Printf("[PUBSUB] Dropping announcement from %s: %v", msg.From, err)
+					continue
+				}
+				apply(announcement)
+			}
+			stop()
+		}
+
+		select {
+		case <-ctx.Done():
+		case <-time.After(backoff):
+		}
+		if backoff < time.Minute {
+			backoff *= 2
+		}
+	}
+}
+
+func verifyAnnouncement(ipnsName string, data []byte) (Announcement, error) {
+	var announcement Announcement
+	if err := json.Unmarshal(data, &announcement); err != nil {
+		return announcement, fmt.Errorf("invalid announcement: %w", err)
+	}
+	if announcement.IPNSName != ipnsName {
+		return announcement, fmt.Errorf("announcement for %s on topic of %s", announcement.IPNSName, ipnsName)
+	}
+
+	signature := announcement.Signature
+	announcement.Signature = ""
+	unsigned, err := json.Marshal(announcement)
+	if err != nil {
+		return announcement, err
+	}
+	if err := ipfs.VerifySignature(ipnsName, unsigned, signature); err != nil {
+		return announcement, err
+	}
+
+	announcement.Signature = signature
+	return announcement, nil
+}
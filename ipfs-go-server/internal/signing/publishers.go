@@ -0,0 +1,288 @@
+package signing
+
+import (
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"ipfs-go-server/internal/models"
+)
+
+var (
+	ErrNotFound         = errors.New("publisher not found")
+	ErrRevoked          = errors.New("publisher key has been revoked")
+	ErrExists           = errors.New("publisher key is already registered")
+	ErrInvalidPublicKey = errors.New("public key must be a base64 encoded 32 byte Ed25519 key")
+	ErrUnsigned         = errors.New("version is not signed")
+	ErrBadSignature     = errors.New("version signature is invalid")
+)
+
+// Publisher is a registered Ed25519 key allowed to sign versions. Owner is
+// the ID of the API key that registered it; publishers registered before
+// that held the key's name and have OwnerKeyID unset until they are
+// converted.
+type Publisher struct {
+	ID         string     `json:"id"`
+	Name       string     `json:"name"`
+	PublicKey  string     `json:"publicKey"`
+	Owner      string     `json:"owner"`
+	OwnerKeyID bool       `json:"ownerKeyId,omitempty"`
+	CreatedAt  time.Time  `json:"createdAt"`
+	RevokedAt  *time.Time `json:"revokedAt,omitempty"`
+}
+
+// Fingerprint derives a publisher ID from its public key, so a signer ID
+// can be checked against the key it names without asking the server
+func Fingerprint(publicKey ed25519.PublicKey) string {
+	sum := sha256.Sum256(publicKey)
+	return hex.EncodeToString(sum[:16])
+}
+
+// ParsePublicKey decodes a base64 Ed25519 public key
+func ParsePublicKey(encoded string) (ed25519.PublicKey, error) {
+	raw, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil || len(raw) != ed25519.PublicKeySize {
+		return nil, ErrInvalidPublicKey
+	}
+	return ed25519.PublicKey(raw), nil
+}
+
+// VerifyVersion checks a version's signature against the key it carries.
+// It needs no server state, so consumers who resolved a table over IPNS can
+// run it offline; whether they trust the signer is up to them.
+func VerifyVersion(tableID string, v models.TorrentVersion) error {
+	if v.Signature == "" || v.SignerKey == "" {
+		return ErrUnsigned
+	}
+
+	publicKey, err := ParsePublicKey(v.SignerKey)
+	if err != nil {
+		return err
+	}
+	if Fingerprint(publicKey) != v.SignerID {
+		return fmt.Errorf("%w: signer ID does not match signer key", ErrBadSignature)
+	}
+
+	signature, err := base64.StdEncoding.DecodeString(v.Signature)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrBadSignature, err)
+	}
+	payload, err := v.SigningPayload(tableID)
+	if err != nil {
+		return err
+	}
+	if !ed25519.Verify(publicKey, payload, signature) {
+		return ErrBadSignature
+	}
+	return nil
+}
+
+// VerifyVersionAs checks a version like VerifyVersion for a table known by
+// several IDs, such as a table's ID and its former IDs. The version verifies
+// if it was signed for any of them.
+func VerifyVersionAs(tableIDs []string, v models.TorrentVersion) error {
+	var firstErr error
+	for _, tableID := range tableIDs {
+		err := VerifyVersion(tableID, v)
+		if err == nil || !errors.Is(err, ErrBadSignature) {
+			return err
+		}
+		if firstErr == nil {
+			firstErr = err
+		}
+	}
+	if firstErr == nil {
+		return ErrBadSignature
+	}
+	return firstErr
+}
+
+// Registry keeps publisher keys in a JSON file
+type Registry struct {
+	path string
+
+	mu         sync.RWMutex
+	publishers map[string]*Publisher
+}
+
+// NewRegistry loads the publishers saved at path, if any
+func NewRegistry(path string) (*Registry, error) {
+	reg := &Registry{
+		path:       path,
+		publishers: make(map[string]*Publisher),
+	}
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return reg, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read publishers: %w", err)
+	}
+
+	var publishers []*Publisher
+	if err := json.Unmarshal(data, &publishers); err != nil {
+		return nil, fmt.Errorf("failed to parse publishers: %w", err)
+	}
+	for _, p := range publishers {
+		reg.publishers[p.ID] = p
+	}
+	return reg, nil
+}
+
+// Register adds a publisher key owned by the API key with ID owner
+func (reg *Registry) Register(name, publicKey, owner string) (*Publisher, error) {
+	key, err := ParsePublicKey(publicKey)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &Publisher{
+		ID:         Fingerprint(key),
+		Name:       name,
+		PublicKey:  publicKey,
+		Owner:      owner,
+		OwnerKeyID: true,
+		CreatedAt:  time.Now().UTC(),
+	}
+
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+
+	if _, exists := reg.publishers[p.ID]; exists {
+		return nil, ErrExists
+	}
+	reg.publishers[p.ID] = p
+	if err := reg.save(); err != nil {
+		delete(reg.publishers, p.ID)
+		return nil, err
+	}
+
+	out := *p
+	return &out, nil
+}
+
+// ConvertOwners moves publishers whose owner is an API key name over to
+// the key's ID and returns how many it converted. keyID maps a name to the
+// ID of the live key holding it; names no live key holds are kept, and
+// match no key from then on.
+func (reg *Registry) ConvertOwners(keyID func(name string) (string, bool)) (int, error) {
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+
+	converted := 0
+	for _, p := range reg.publishers {
+		if p.OwnerKeyID {
+			continue
+		}
+		if id, ok := keyID(p.Owner); ok {
+			p.Owner = id
+		}
+		p.OwnerKeyID = true
+		converted++
+	}
+	if converted == 0 {
+		return 0, nil
+	}
+	return converted, reg.save()
+}
+
+// Revoke stops a publisher from signing new versions. The key is kept so
+// versions it signed before stay verifiable.
+func (reg *Registry) Revoke(id string) error {
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+
+	p, exists := reg.publishers[id]
+	if !exists {
+		return ErrNotFound
+	}
+	if p.RevokedAt != nil {
+		return nil
+	}
+
+	now := time.Now().UTC()
+	p.RevokedAt = &now
+	if err := reg.save(); err != nil {
+		p.RevokedAt = nil
+		return err
+	}
+	return nil
+}
+
+// Get returns a publisher by ID
+func (reg *Registry) Get(id string) (*Publisher, error) {
+	reg.mu.RLock()
+	defer reg.mu.RUnlock()
+
+	p, exists := reg.publishers[id]
+	if !exists {
+		return nil, ErrNotFound
+	}
+	out := *p
+	return &out, nil
+}
+
+// List returns every publisher, oldest first
+func (reg *Registry) List() []Publisher {
+	reg.mu.RLock()
+	defer reg.mu.RUnlock()
+
+	publishers := make([]Publisher, 0, len(reg.publishers))
+	for _, p := range reg.publishers {
+		publishers = append(publishers, *p)
+	}
+	sort.Slice(publishers, func(i, j int) bool {
+		return publishers[i].CreatedAt.Before(publishers[j].CreatedAt)
+	})
+	return publishers
+}
+
+// Verify checks a version appended to the table known by tableIDs, its ID
+// followed by its former IDs. The signer must be a registered, unrevoked
+// publisher; its key is filled into the version so the stored copy
+// verifies offline.
+func (reg *Registry) Verify(tableIDs []string, v *models.TorrentVersion) (*Publisher, error) {
+	if v.Signature == "" || v.SignerID == "" {
+		return nil, ErrUnsigned
+	}
+
+	p, err := reg.Get(v.SignerID)
+	if err != nil {
+		return nil, err
+	}
+	if p.RevokedAt != nil {
+		return nil, ErrRevoked
+	}
+
+	v.SignerKey = p.PublicKey
+	if err := VerifyVersionAs(tableIDs, *v); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// save writes the registry to disk. Must be called with reg.mu held.
+func (reg *Registry) save() error {
+	publishers := make([]*Publisher, 0, len(reg.publishers))
+	for _, p := range reg.publishers {
+		publishers = append(publishers, p)
+	}
+	sort.Slice(publishers, func(i, j int) bool {
+		return publishers[i].CreatedAt.Before(publishers[j].CreatedAt)
+	})
+
+	data, err := json.MarshalIndent(publishers, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(reg.path, data, 0644)
+}
@@ -0,0 +1,170 @@
+package signing
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"ipfs-go-server/internal/models"
+)
+
+// signVersion signs v for tableID with a fresh key, as a publisher would
+func signVersion(t *testing.T, tableID string, v models.TorrentVersion) models.TorrentVersion {
+	t.Helper()
+
+	publicKey, privateKey, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	payload, err := v.SigningPayload(tableID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	v.Signature = base64.StdEncoding.EncodeToString(ed25519.Sign(privateKey, payload))
+	v.SignerKey = base64.StdEncoding.EncodeToString(publicKey)
+	v.SignerID = Fingerprint(publicKey)
+	return v
+}
+
+func testVersion() models.TorrentVersion {
+	return models.TorrentVersion{
+		Hash:        "c12fe1c06bba254a9dc9f519b335aa7c1367a88a",
+		MagnetLink:  "magnet:?xt=urn:btih:c12fe1c06bba254a9dc9f519b335aa7c1367a88a",
+		FileName:    "release.tar.gz",
+		FileSize:    1 << 20,
+		Description: "v1.0 <stable>",
+		CreatedAt:   time.Date(2026, 3, 1, 12, 30, 0, 250_000_000, time.UTC),
+		InfoHashV2:  "8e3b2c1a0f9d7e6b5a4c3b2a1f0e9d8c7b6a5f4e3d2c1b0a9f8e7d6c5b4a3f2e",
+		CID:         "bafybeigdyrzt5sfp7udm7hu76uh7y26nf3efuylqabf3oclgtqy55fbzdi",
+		WebSeeds:    []string{"https://seed.example/ipfs/bafybeigdyrzt5sfp7udm7hu76uh7y26nf3efuylqabf3oclgtqy55fbzdi"},
+		Encryption: &models.ContentEncryption{
+			Algorithm:     "AES-256-GCM",
+			KeyAlgorithm:  "RSA-OAEP-256",
+			PlaintextSize: 1<<20 - 64,
+			Keys:          []models.WrappedKey{{RecipientID: "r1", Name: "ops", WrappedKey: "d3JhcHBlZA=="}},
+		},
+	}
+}
+
+func TestSigningPayloadWithoutOptionalFields(t *testing.T) {
+	v := testVersion()
+	v.CID, v.WebSeeds, v.InfoHashV2, v.Encryption = "", nil, "", nil
+
+	payload, err := v.SigningPayload("release")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Signatures made before the optional fields were covered stay valid
+	want := `{"createdAt":"2026-03-01T12:30:00.250Z","description":"v1.0 <stable>","fileName":"release.tar.gz","fileSize":1048576,` +
+		`"hash":"c12fe1c06bba254a9dc9f519b335aa7c1367a88a","magnetLink":"magnet:?xt=urn:btih:c12fe1c06bba254a9dc9f519b335aa7c1367a88a","tableId":"release"}`
+	if string(payload) != want {
+		t.Fatalf("payload =\n%s\nwant\n%s", payload, want)
+	}
+}
+
+func TestVerifyVersion(t *testing.T) {
+	signed := signVersion(t, "release", testVersion())
+	if err := VerifyVersion("release", signed); err != nil {
+		t.Fatalf("VerifyVersion: %v", err)
+	}
+
+	// Availability and the version number are set by the server
+	signed.Version = 7
+	signed.Availability = &models.Availability{Status: models.AvailabilityAlive, Seeders: 3}
+	if err := VerifyVersion("release", signed); err != nil {
+		t.Fatalf("VerifyVersion after server-set fields changed: %v", err)
+	}
+
+	if err := VerifyVersion("other", signed); !errors.Is(err, ErrBadSignature) {
+		t.Fatalf("VerifyVersion for another table = %v, want ErrBadSignature", err)
+	}
+	if err := VerifyVersion("release", testVersion()); !errors.Is(err, ErrUnsigned) {
+		t.Fatalf("VerifyVersion of unsigned version = %v, want ErrUnsigned", err)
+	}
+}
+
+func TestVerifyVersionDetectsChanges(t *testing.T) {
+	tests := []struct {
+		name   string
+		change func(v *models.TorrentVersion)
+	}{
+		{"hash", func(v *models.TorrentVersion) { v.Hash = "0000000000000000000000000000000000000000" }},
+		{"createdAt", func(v *models.TorrentVersion) { v.CreatedAt = v.CreatedAt.Add(time.Millisecond) }},
+		{"webSeeds", func(v *models.TorrentVersion) { v.WebSeeds = []string{"https://evil.example/payload"} }},
+		{"webSeeds added", func(v *models.TorrentVersion) { v.WebSeeds = append(v.WebSeeds, "https://evil.example/payload") }},
+		{"cid", func(v *models.TorrentVersion) { v.CID = "bafkreievil" }},
+		{"infoHashV2", func(v *models.TorrentVersion) { v.InfoHashV2 = "" }},
+		{"encryption removed", func(v *models.TorrentVersion) { v.Encryption = nil }},
+		{"wrapped key", func(v *models.TorrentVersion) {
+			v.Encryption = &models.ContentEncryption{
+				Algorithm:     v.Encryption.Algorithm,
+				KeyAlgorithm:  v.Encryption.KeyAlgorithm,
+				PlaintextSize: v.Encryption.PlaintextSize,
+				Keys:          []models.WrappedKey{{RecipientID: "r1", Name: "ops", WrappedKey: "ZXZpbA=="}},
+			}
+		}},
+		{"plaintext size", func(v *models.TorrentVersion) {
+			enc := *v.Encryption
+			enc.PlaintextSize++
+			v.Encryption = &enc
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := signVersion(t, "release", testVersion())
+			tt.change(&v)
+			if err := VerifyVersion("release", v); !errors.Is(err, ErrBadSignature) {
+				t.Fatalf("VerifyVersion = %v, want ErrBadSignature", err)
+			}
+		})
+	}
+}
+
+func TestConvertOwnersToKeyIDs(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "publishers.json")
+	legacy := `[{"id":"a1","name":"ci","publicKey":"k1","owner":"release-team","createdAt":"2026-01-01T00:00:00Z"},` +
+		`{"id":"b2","name":"old","publicKey":"k2","owner":"retired-team","createdAt":"2026-01-02T00:00:00Z"}]`
+	if err := os.WriteFile(path, []byte(legacy), 0644); err != nil {
+		t.Fatal(err)
+	}
+	reg, err := NewRegistry(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	liveKeys := map[string]string{"release-team": "key-7"}
+	keyID := func(name string) (string, bool) {
+		id, ok := liveKeys[name]
+		return id, ok
+	}
+	converted, err := reg.ConvertOwners(keyID)
+	if err != nil || converted != 2 {
+		t.Fatalf("ConvertOwners = %d, %v; want 2", converted, err)
+	}
+
+	// Owners are read back as key IDs; a name no key holds stays as it was
+	reg, err = NewRegistry(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p, _ := reg.Get("a1"); p.Owner != "key-7" || !p.OwnerKeyID {
+		t.Fatalf("owner of a1 = %q (key ID: %v), want key-7", p.Owner, p.OwnerKeyID)
+	}
+	if p, _ := reg.Get("b2"); p.Owner != "retired-team" || !p.OwnerKeyID {
+		t.Fatalf("owner of b2 = %q (key ID: %v), want retired-team", p.Owner, p.OwnerKeyID)
+	}
+
+	// A key created later under an old name does not take over
+	liveKeys["retired-team"] = "key-9"
+	if converted, err := reg.ConvertOwners(keyID); err != nil || converted != 0 {
+		t.Fatalf("second ConvertOwners = %d, %v; want 0", converted, err)
+	}
+	if p, _ := reg.Get("b2"); p.Owner != "retired-team" {
+		t.Fatalf("owner of b2 = %q after a second conversion", p.Owner)
+	}
+}
@@ -0,0 +1,118 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"ipfs-go-server/internal/models"
+)
+
+// Announcer broadcasts a table's new head as soon as it is published
+type Announcer interface {
+	Announce(ctx context.Context, tableID, keyName, ipnsName, cid string) error
+}
+
+// SetAnnouncer makes every successful publish also be announced
+func (s *Storage) SetAnnouncer(announcer Announcer) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.announcer = announcer
+}
+
+// IsMirror reports whether the table is followed from another node, in
+// which case this server has no key to publish it with.
+func (s *Storage) IsMirror() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.keyName == ""
+}
+
+// AnnounceSequence returns the sequence announcements must exceed to be
+// applied. Mirrors keep it with their registry entry.
+func (s *Storage) AnnounceSequence() uint64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.announceSeq
+}
+
+// SetAnnounceSequence restores the sequence saved with a mirror's registry
+// entry. It never lowers the sequence.
+func (s *Storage) SetAnnounceSequence(sequence uint64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.announceSeq = max(s.announceSeq, sequence)
+}
+
+// raiseAnnounceSeq keeps announcements published before the loaded
+// snapshot was written from being applied. Sequences are the origin's
+// publish times, so the snapshot's UpdatedAt bounds those of older heads.
+// Must be called with s.mu held.
+func (s *Storage) raiseAnnounceSeq(loaded *models.Table) {
+	if updated := loaded.UpdatedAt.UnixNano(); !loaded.UpdatedAt.IsZero() && updated > 0 {
+		s.announceSeq = max(s.announceSeq, uint64(updated))
+	}
+}
+
+// ApplyAnnouncement loads the snapshot at cid as the table's new head.
+// Announcements carrying a sequence no newer than the last applied one, or
+// than the time the loaded snapshot was written, are ignored, so replays
+// and reordering cannot roll the table back, across restarts too. It
+// reports whether the snapshot was applied.
+func (s *Storage) ApplyAnnouncement(ctx context.Context, cid string, sequence uint64) (bool, error) {
+	s.mu.Lock()
+	if sequence <= s.announceSeq || cid == s.status.HeadCID {
+		s.mu.Unlock()
+		return false, nil
+	}
+	s.mu.Unlock()
+
+	doc, err := s.readSnapshot(ctx, cid)
+	if err != nil {
+		return false, err
+	}
+
+	loadedTable, dataKey, err := decodeSnapshot(doc)
+	if err != nil {
+		return false, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// A newer announcement may have been applied while fetching
+	if sequence <= s.announceSeq {
+		return false, nil
+	}
+	// The origin may have moved the table to a new ID since
+	if !s.reconcileID(loadedTable) {
+		return false, fmt.Errorf("announced snapshot is for table %s, not %s", loadedTable.ID, s.table.ID)
+	}
+	s.announceSeq = sequence
+	s.status.HeadCID = cid
+	s.table = loadedTable
+	s.dataKey = dataKey
+	s.markPublished(s.changes)
+	s.emit(EventPublished, cid)
+	return true, nil
+}
+
+// announce broadcasts hash in the background, detached from the publish
+// that triggered it. Must be called with s.mu held.
+func (s *Storage) announce(hash string) {
+	if s.announcer == nil || s.keyName == "" {
+		return
+	}
+
+	announcer := s.announcer
+	tableID, keyName, ipnsName := s.table.ID, s.keyName, s.ipnsName
+	go func() {
+		if err := announcer.Announce(context.Background(), tableID, keyName, ipnsName, hash); err != nil {
+			log.Printf("[STORAGE] Warning: Failed to announce %s for table %s: %v", hash, tableID, err)
+		}
+	}()
+}
@@ -0,0 +1,138 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"ipfs-go-server/internal/models"
+
+	ipfs "github.com/ipfs/go-ipfs-api"
+)
+
+// catServer serves snapshots by CID the way the IPFS cat API does
+func catServer(t *testing.T, snapshots map[string]*models.Table) *ipfs.Shell {
+	t.Helper()
+
+	docs := make(map[string]interface{}, len(snapshots))
+	for cid, table := range snapshots {
+		docs[cid] = table
+	}
+	return ipfsServer(t, nil, docs)
+}
+
+// ipfsServer resolves IPNS names to CIDs and serves documents by CID the
+// way the IPFS name/resolve and cat APIs do
+func ipfsServer(t *testing.T, names map[string]string, docs map[string]interface{}) *ipfs.Shell {
+	t.Helper()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		arg := r.URL.Query().Get("arg")
+		switch cid, resolved := names[arg]; {
+		case r.URL.Path == "/api/v0/name/resolve" && resolved:
+			json.NewEncoder(w).Encode(map[string]string{"Path": "/ipfs/" + cid})
+		case r.URL.Path == "/api/v0/cat" && docs[arg] != nil:
+			json.NewEncoder(w).Encode(docs[arg])
+		default:
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(map[string]string{"Message": "not found", "Type": "error"})
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return ipfs.NewShell(srv.URL)
+}
+
+func TestApplyAnnouncementFollowsIDChange(t *testing.T) {
+	const oldID, newID = "release", "6f1d0c2e9a4b4d8f8e3a7c5b1d2e3f40"
+
+	migrated := models.NewTable(newID, "release", "")
+	migrated.FormerIDs = []string{oldID}
+	stale := models.NewTable(oldID, "release", "")
+	unrelated := models.NewTable("other", "other", "")
+
+	sh := catServer(t, map[string]*models.Table{"QmMigrated": migrated, "QmStale": stale, "QmOther": unrelated})
+	mirror := NewStorageWithIPNS(sh, oldID, "release", "", "", "k51mirror")
+
+	applied, err := mirror.ApplyAnnouncement(context.Background(), "QmMigrated", 1)
+	if err != nil || !applied {
+		t.Fatalf("ApplyAnnouncement of migrated snapshot = %v, %v; want applied", applied, err)
+	}
+	if table := mirror.GetTable(); table.ID != newID || len(table.FormerIDs) != 1 || table.FormerIDs[0] != oldID {
+		t.Fatalf("mirror is %s with former IDs %v, want %s with [%s]", table.ID, table.FormerIDs, newID, oldID)
+	}
+
+	// A snapshot from before the migration no longer changes the ID
+	if _, err := mirror.ApplyAnnouncement(context.Background(), "QmStale", 2); err != nil {
+		t.Fatalf("ApplyAnnouncement of pre-migration snapshot: %v", err)
+	}
+	if id := mirror.GetTable().ID; id != newID {
+		t.Fatalf("mirror ID = %s after pre-migration snapshot, want %s", id, newID)
+	}
+
+	if _, err := mirror.ApplyAnnouncement(context.Background(), "QmOther", 3); err == nil {
+		t.Fatal("ApplyAnnouncement accepted a snapshot of another table")
+	}
+}
+
+func TestReassignKeepsFormerIDs(t *testing.T) {
+	stor := NewStorageWithIPNS(nil, "release", "release", "", "", "k51owner")
+	ctx := context.Background()
+
+	if err := stor.Reassign(ctx, "first", ""); err != nil {
+		t.Fatal(err)
+	}
+	if err := stor.Reassign(ctx, "second", ""); err != nil {
+		t.Fatal(err)
+	}
+	if ids := stor.GetTable().IDs(); len(ids) != 3 || ids[0] != "second" || ids[1] != "release" || ids[2] != "first" {
+		t.Fatalf("IDs = %v, want [second release first]", ids)
+	}
+	if _, unpublished := stor.Unpublished(); !unpublished {
+		t.Fatal("a new ID is not marked for publishing")
+	}
+
+	// Moving back undoes the last migration
+	if err := stor.Reassign(ctx, "first", ""); err != nil {
+		t.Fatal(err)
+	}
+	if ids := stor.GetTable().IDs(); len(ids) != 2 || ids[0] != "first" || ids[1] != "release" {
+		t.Fatalf("IDs after moving back = %v, want [first release]", ids)
+	}
+}
+
+func TestAnnouncementsOlderThanRestartAreRefused(t *testing.T) {
+	const id = "6f1d0c2e9a4b4d8f8e3a7c5b1d2e3f40"
+
+	published := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	old := models.NewTable(id, "release", "")
+	old.UpdatedAt = published
+	head := models.NewTable(id, "release", "")
+	head.Data = `[{"hash": "bbbb"}]`
+	head.UpdatedAt = published.Add(time.Hour)
+
+	sh := ipfsServer(t, map[string]string{"k51origin": "QmHead"}, map[string]interface{}{"QmOld": old, "QmHead": head})
+	ctx := context.Background()
+
+	// A restarted mirror loads the head; the announcement of the snapshot
+	// before it was published earlier than the head was written
+	mirror := NewStorageWithIPNS(sh, id, "release", "", "", "k51origin")
+	if err := mirror.LoadTable(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if applied, err := mirror.ApplyAnnouncement(ctx, "QmOld", uint64(published.Add(time.Second).UnixNano())); err != nil || applied {
+		t.Fatalf("replayed announcement after load = %v, %v; want refused", applied, err)
+	}
+
+	// The sequence saved with the registry entry holds without a load
+	restarted := NewStorageWithIPNS(sh, id, "release", "", "", "k51origin")
+	restarted.SetAnnounceSequence(mirror.AnnounceSequence())
+	if applied, err := restarted.ApplyAnnouncement(ctx, "QmOld", uint64(published.Add(time.Second).UnixNano())); err != nil || applied {
+		t.Fatalf("replayed announcement after restore = %v, %v; want refused", applied, err)
+	}
+	if applied, err := restarted.ApplyAnnouncement(ctx, "QmHead", uint64(head.UpdatedAt.Add(time.Second).UnixNano())); err != nil || !applied {
+		t.Fatalf("newer announcement = %v, %v; want applied", applied, err)
+	}
+}
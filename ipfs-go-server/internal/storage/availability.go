@@ -0,0 +1,54 @@
+package storage
+
+import (
+	"encoding/json"
+
+	"ipfs-go-server/internal/models"
+)
+
+// RecordAvailability sets the availability of every version whose hash is
+// in results, keeping the rest of each item as it is. Checks are not edits,
+// so no event is emitted and nothing is saved; it reports whether any
+// version's status changed, which is when a save is worth publishing.
+func (s *Storage) RecordAvailability(results map[string]models.Availability) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var items []interface{}
+	if err := json.Unmarshal([]byte(s.table.Data), &items); err != nil {
+		return false
+	}
+
+	recorded, changed := false, false
+	for _, raw := range items {
+		item, ok := raw.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		hash, _ := item["hash"].(string)
+		result, ok := results[hash]
+		if !ok {
+			continue
+		}
+
+		previous, _ := item["availability"].(map[string]interface{})
+		if status, _ := previous["status"].(string); status != result.Status {
+			changed = true
+		}
+		item["availability"] = result
+		recorded = true
+	}
+	if !recorded {
+		return false
+	}
+
+	data, err := json.MarshalIndent(items, "", "  ")
+	if err != nil {
+		return false
+	}
+	s.table.Data = string(data)
+	if changed {
+		s.changes++
+	}
+	return changed
+}
@@ -0,0 +1,105 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"ipfs-go-server/internal/encryption"
+	"ipfs-go-server/internal/models"
+)
+
+// keyring decrypts private tables this server is a recipient of
+var keyring *encryption.Keyring
+
+// SetKeyring sets the private key used to read private tables
+func SetKeyring(ring *encryption.Keyring) {
+	keyring = ring
+}
+
+// SetEncryption makes the table private: later snapshots are encrypted with
+// key instead of being published as plain JSON
+func (s *Storage) SetEncryption(key *encryption.TableKey) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.dataKey = key
+}
+
+// IsPrivate reports whether the table's snapshots are encrypted
+func (s *Storage) IsPrivate() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.dataKey != nil
+}
+
+// GetRecipients returns who can decrypt the table, or nil if it is public
+func (s *Storage) GetRecipients() []encryption.Recipient {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.dataKey == nil {
+		return nil
+	}
+	return s.dataKey.Recipients()
+}
+
+// SetRecipients changes who the table is encrypted for and publishes a
+// snapshot sealed for the new list
+func (s *Storage) SetRecipients(ctx context.Context, recipients []encryption.Recipient) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.dataKey == nil {
+		return fmt.Errorf("table %s is not private", s.table.ID)
+	}
+
+	key, err := s.dataKey.WithRecipients(recipients)
+	if err != nil {
+		return err
+	}
+
+	previous := s.dataKey
+	s.dataKey = key
+	if _, err := s.saveAndEmit(ctx, EventUpdated); err != nil {
+		s.dataKey = previous
+		return err
+	}
+	return nil
+}
+
+// encodeSnapshot serializes the table, sealing it if the table is private.
+// Must be called with s.mu held.
+func (s *Storage) encodeSnapshot() ([]byte, error) {
+	data, err := json.Marshal(s.table)
+	if err != nil {
+		return nil, err
+	}
+	if s.dataKey == nil {
+		return data, nil
+	}
+	return s.dataKey.Seal(s.table.ID, data)
+}
+
+// decodeSnapshot returns the table in a snapshot, decrypting it when it is
+// sealed. The returned key is nil for public snapshots.
+func decodeSnapshot(doc *snapshotDocument) (*models.Table, *encryption.TableKey, error) {
+	table := &doc.Table
+	var key *encryption.TableKey
+	if doc.Type == encryption.SnapshotType {
+		plaintext, tableKey, err := encryption.Open(&doc.SealedSnapshot, keyring)
+		if err != nil {
+			return nil, nil, err
+		}
+		table, key = &models.Table{}, tableKey
+		if err := json.Unmarshal(plaintext, table); err != nil {
+			return nil, nil, fmt.Errorf("failed to unmarshal table: %w", err)
+		}
+	}
+
+	if err := table.LoadVersionsFromData(); err != nil {
+		return nil, nil, fmt.Errorf("failed to load versions from data: %w", err)
+	}
+	return table, key, nil
+}
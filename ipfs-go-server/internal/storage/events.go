@@ -0,0 +1,82 @@
+package storage
+
+import (
+	"encoding/json"
+	"sync"
+	"time"
+)
+
+// EventType names a change to a table
+type EventType string
+
+const (
+	EventCreated   EventType = "created"
+	EventAppended  EventType = "appended"
+	EventUpdated   EventType = "updated"
+	EventDeleted   EventType = "deleted"
+	EventPublished EventType = "published"
+)
+
+// Event describes a change made through a Storage mutation method. CID is
+// the snapshot the change produced, when one exists yet: appends are
+// published in the background and followed by a separate published event.
+type Event struct {
+	Type    EventType `json:"type"`
+	TableID string    `json:"tableId"`
+	Version int       `json:"version"`
+	CID     string    `json:"cid,omitempty"`
+	Time    time.Time `json:"time"`
+}
+
+// Hook receives table events. Hooks run synchronously while the table is
+// locked, so they must not block or call back into the Storage.
+type Hook func(Event)
+
+var (
+	hooksMu sync.RWMutex
+	hooks   []Hook
+)
+
+// OnChange registers a hook for events from every table
+func OnChange(hook Hook) {
+	hooksMu.Lock()
+	defer hooksMu.Unlock()
+
+	hooks = append(hooks, hook)
+}
+
+// Delete marks the table as removed from this server
+func (s *Storage) Delete() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.emit(EventDeleted, s.status.HeadCID)
+}
+
+// emit sends an event to every hook. Must be called with s.mu held.
+func (s *Storage) emit(eventType EventType, cid string) {
+	event := Event{
+		Type:    eventType,
+		TableID: s.table.ID,
+		Version: s.versionCount(),
+		CID:     cid,
+		Time:    time.Now().UTC(),
+	}
+
+	hooksMu.RLock()
+	defer hooksMu.RUnlock()
+
+	for _, hook := range hooks {
+		hook(event)
+	}
+}
+
+// versionCount counts the entries in the table's data. Data is the source
+// of truth: the append fast path updates it without touching Versions.
+func (s *Storage) versionCount() int {
+	var items []json.RawMessage
+	if err := json.Unmarshal([]byte(s.table.Data), &items); err != nil {
+		return len(s.table.Versions)
+	}
+	return len(items)
+}
@@ -0,0 +1,121 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+)
+
+// Changes are counted so a publish can tell which of them it covered: a
+// background save encodes the table, and more changes may land before its
+// publish finishes. The table is unpublished while changes made in memory
+// are not all behind its IPNS name. A snapshot that was added to IPFS but
+// not published is kept so publishing it can be retried, on a later
+// start if need be.
+
+// markAdded records hash as the snapshot holding the first changes. Must be
+// called with s.mu held.
+func (s *Storage) markAdded(hash string, changes uint64) {
+	if changes > s.publishedChanges && changes >= s.pendingChanges {
+		s.pendingCID, s.pendingChanges = hash, changes
+	}
+}
+
+// markPublished records that the first changes are behind the IPNS name.
+// Must be called with s.mu held.
+func (s *Storage) markPublished(changes uint64) {
+	if changes > s.publishedChanges {
+		s.publishedChanges = changes
+	}
+	if s.pendingChanges <= s.publishedChanges {
+		s.pendingCID, s.pendingChanges = "", 0
+	}
+}
+
+// publishChanges publishes hash, the snapshot holding the first changes
+func (s *Storage) publishChanges(ctx context.Context, hash string, changes uint64) error {
+	if err := s.publishUnlocked(ctx, hash); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.markPublished(changes)
+	return nil
+}
+
+// Unpublished reports whether the table has changes that are not published
+// yet, and the snapshot holding all of them if one was added to IPFS
+func (s *Storage) Unpublished() (string, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.changes <= s.publishedChanges {
+		return "", false
+	}
+	if s.pendingChanges == s.changes {
+		return s.pendingCID, true
+	}
+	return "", true
+}
+
+// SaveUnpublished adds a snapshot of the table to IPFS without publishing
+// it, so unpublished changes survive a restart. It returns the snapshot
+// holding them, or "" when everything is published.
+func (s *Storage) SaveUnpublished(ctx context.Context) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.changes <= s.publishedChanges {
+		return "", nil
+	}
+	if s.pendingChanges == s.changes {
+		return s.pendingCID, nil
+	}
+
+	hash, err := s.addSnapshot(ctx)
+	if err != nil {
+		return "", fmt.Errorf("failed to add snapshot: %w", err)
+	}
+	s.markAdded(hash, s.changes)
+	return hash, nil
+}
+
+// RestoreUnpublished loads the snapshot at cid, saved by SaveUnpublished
+// before a shutdown, as the table's unpublished state
+func (s *Storage) RestoreUnpublished(ctx context.Context, cid string) error {
+	doc, err := s.readSnapshot(ctx, cid)
+	if err != nil {
+		return err
+	}
+	loadedTable, dataKey, err := decodeSnapshot(doc)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.reconcileID(loadedTable) {
+		return fmt.Errorf("unpublished snapshot is for table %s, not %s", loadedTable.ID, s.table.ID)
+	}
+	s.table = loadedTable
+	s.dataKey = dataKey
+	s.changes++
+	s.markAdded(cid, s.changes)
+	s.emit(EventUpdated, cid)
+	return nil
+}
+
+// PublishPending publishes the snapshot that was added but not published,
+// if there is one
+func (s *Storage) PublishPending(ctx context.Context) (string, error) {
+	s.mu.Lock()
+	hash, changes := s.pendingCID, s.pendingChanges
+	s.mu.Unlock()
+
+	if hash == "" {
+		return "", nil
+	}
+	return hash, s.publishChanges(ctx, hash, changes)
+}
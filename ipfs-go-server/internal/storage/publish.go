@@ -0,0 +1,135 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	ipfsclient "ipfs-go-server/internal/ipfs"
+)
+
+// DefaultRecordLifetime is assumed when a table has no lifetime of its own.
+// It matches the shortest default used by IPFS daemons, so republishing
+// stays ahead of expiry whichever daemon is in use.
+const DefaultRecordLifetime = 24 * time.Hour
+
+// PublishStatus describes the IPNS record last published for a table
+type PublishStatus struct {
+	HeadCID       string    `json:"headCid"`
+	LastPublished time.Time `json:"lastPublished"`
+	LastAttempt   time.Time `json:"lastAttempt"`
+	LastError     string    `json:"lastError,omitempty"`
+	Failures      int       `json:"consecutiveFailures"`
+}
+
+// SetPublishSettings sets the lifetime and TTL of future IPNS records. Zero
+// values leave the daemon defaults in place.
+func (s *Storage) SetPublishSettings(lifetime, ttl time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.lifetime = lifetime
+	s.ttl = ttl
+}
+
+// GetPublishSettings returns the configured record lifetime and TTL
+func (s *Storage) GetPublishSettings() (time.Duration, time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.lifetime, s.ttl
+}
+
+// GetPublishStatus returns the outcome of the most recent publish
+func (s *Storage) GetPublishStatus() PublishStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.status
+}
+
+// NextRepublish returns when the record should be re-signed: halfway
+// through its lifetime, or immediately if it was never published by us.
+func (s *Storage) NextRepublish() time.Time {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.status.LastPublished.IsZero() {
+		return time.Time{}
+	}
+	return s.status.LastPublished.Add(s.effectiveLifetime() / 2)
+}
+
+// Republish re-signs the current head under the table's key so the record
+// does not expire.
+func (s *Storage) Republish(ctx context.Context) error {
+	s.mu.Lock()
+	hash := s.status.HeadCID
+	history := append(s.keyHistory[:0:0], s.keyHistory...)
+	lifetime := s.effectiveLifetime()
+	s.mu.Unlock()
+
+	if hash == "" {
+		return errors.New("table has no published snapshot to republish")
+	}
+
+	if err := s.publishUnlocked(ctx, hash); err != nil {
+		return err
+	}
+
+	// Keep moved pointers at unretired old names resolvable too
+	for _, rotation := range history {
+		if rotation.Retired || rotation.PointerCID == "" {
+			continue
+		}
+		if err := ipfsclient.PublishName(ctx, s.ipfsClient, rotation.PointerCID, rotation.OldKeyName, lifetime, 0); err != nil {
+			log.Printf("[STORAGE] Warning: Failed to republish moved pointer at %s: %v", rotation.OldIPNSName, err)
+		}
+	}
+	return nil
+}
+
+// publishUnlocked publishes hash without holding the table lock for the
+// duration of the (slow) IPNS publish.
+func (s *Storage) publishUnlocked(ctx context.Context, hash string) error {
+	s.mu.Lock()
+	keyName, lifetime, ttl := s.keyName, s.lifetime, s.ttl
+	s.mu.Unlock()
+
+	err := ipfsclient.PublishName(ctx, s.ipfsClient, hash, keyName, lifetime, ttl)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	previous := s.status.HeadCID
+	s.recordPublish(hash, err)
+	if err == nil && hash != previous {
+		s.emit(EventPublished, hash)
+	}
+	return err
+}
+
+// recordPublish updates the publish status. Must be called with s.mu held.
+func (s *Storage) recordPublish(hash string, err error) {
+	now := time.Now()
+	s.status.LastAttempt = now
+	if err != nil {
+		s.status.LastError = err.Error()
+		s.status.Failures++
+		return
+	}
+
+	s.status.HeadCID = hash
+	s.status.LastPublished = now
+	s.status.LastError = ""
+	s.status.Failures = 0
+	s.announce(hash)
+}
+
+func (s *Storage) effectiveLifetime() time.Duration {
+	if s.lifetime > 0 {
+		return s.lifetime
+	}
+	return DefaultRecordLifetime
+}
@@ -0,0 +1,147 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	ipfsclient "ipfs-go-server/internal/ipfs"
+	"ipfs-go-server/internal/models"
+)
+
+// maxMovedHops bounds how many rotations LoadTable follows
+const maxMovedHops = 8
+
+// RotateKey moves the table to a freshly generated IPNS key. The current
+// snapshot is published under the new key and a signed moved pointer is
+// published at the old name so followers keep finding the table. When
+// retireOld is set the old key is removed from the keystore afterwards.
+//
+// On failure the table stays on its old key; a new key that was already
+// generated is left in the keystore.
+func (s *Storage) RotateKey(ctx context.Context, newKeyName string, retireOld bool) (*models.KeyRotation, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if newKeyName == s.keyName {
+		return nil, fmt.Errorf("new key name must differ from current key %s", s.keyName)
+	}
+
+	oldKeyName, oldIPNSName := s.keyName, s.ipnsName
+	if oldIPNSName == "" {
+		if err := s.ensureKey(ctx); err != nil {
+			return nil, fmt.Errorf("failed to resolve current key: %w", err)
+		}
+		oldIPNSName = s.ipnsName
+	}
+
+	s.keyName = newKeyName
+	if err := s.ensureKey(ctx); err != nil {
+		s.keyName, s.ipnsName = oldKeyName, oldIPNSName
+		return nil, fmt.Errorf("failed to generate new key: %w", err)
+	}
+	newIPNSName := s.ipnsName
+
+	snapshot, err := s.addSnapshot(ctx)
+	if err == nil {
+		err = s.publishIPNS(ctx, snapshot)
+	}
+	if err != nil {
+		s.keyName, s.ipnsName = oldKeyName, oldIPNSName
+		return nil, fmt.Errorf("failed to publish snapshot under new key: %w", err)
+	}
+
+	pointer := models.MovedPointer{
+		Type:     models.MovedPointerType,
+		TableID:  s.table.ID,
+		From:     oldIPNSName,
+		To:       newIPNSName,
+		Snapshot: snapshot,
+		MovedAt:  time.Now().UTC(),
+	}
+	pointerCID, err := s.publishMovedPointer(ctx, oldKeyName, &pointer)
+	if err != nil {
+		s.keyName, s.ipnsName = oldKeyName, oldIPNSName
+		return nil, fmt.Errorf("failed to publish moved pointer at old name: %w", err)
+	}
+
+	rotation := models.KeyRotation{
+		OldKeyName:  oldKeyName,
+		OldIPNSName: oldIPNSName,
+		NewKeyName:  newKeyName,
+		NewIPNSName: newIPNSName,
+		SnapshotCID: snapshot,
+		PointerCID:  pointerCID,
+		RotatedAt:   pointer.MovedAt,
+	}
+
+	if retireOld {
+		rmCtx, cancel := ipfsclient.WithTimeout(ctx, ipfsclient.OpKeys)
+		_, err := s.ipfsClient.KeyRm(rmCtx, oldKeyName)
+		cancel()
+		if err != nil {
+			log.Printf("[STORAGE] Warning: Failed to remove retired key %s: %v", oldKeyName, err)
+		} else {
+			rotation.Retired = true
+		}
+	}
+
+	s.keyHistory = append(s.keyHistory, rotation)
+	log.Printf("[STORAGE] Rotated table %s from %s to %s", s.table.ID, oldIPNSName, newIPNSName)
+	return &rotation, nil
+}
+
+// verifyMovedPointer checks that a pointer resolved from name was made for
+// name and signed by its key, so only the table's publisher can move it
+func verifyMovedPointer(name string, pointer models.MovedPointer) error {
+	if pointer.From != name {
+		return fmt.Errorf("moved pointer at %s is from %s", name, pointer.From)
+	}
+	if pointer.Signature == "" {
+		return fmt.Errorf("moved pointer at %s is not signed", name)
+	}
+
+	signature := pointer.Signature
+	pointer.Signature = ""
+	unsigned, err := json.Marshal(pointer)
+	if err != nil {
+		return err
+	}
+	if err := ipfsclient.VerifySignature(name, unsigned, signature); err != nil {
+		return fmt.Errorf("moved pointer at %s: %w", name, err)
+	}
+	return nil
+}
+
+// publishMovedPointer signs pointer with keyName, adds it to IPFS and
+// publishes it under keyName.
+func (s *Storage) publishMovedPointer(ctx context.Context, keyName string, pointer *models.MovedPointer) (string, error) {
+	unsigned, err := json.Marshal(pointer)
+	if err != nil {
+		return "", err
+	}
+
+	signature, err := ipfsclient.SignWithKey(ctx, s.ipfsClient, keyName, unsigned)
+	if err != nil {
+		return "", fmt.Errorf("failed to sign moved pointer: %w", err)
+	}
+	pointer.Signature = signature
+
+	data, err := json.Marshal(pointer)
+	if err != nil {
+		return "", err
+	}
+
+	hash, err := ipfsclient.Add(ctx, s.ipfsClient, bytes.NewReader(data))
+	if err != nil {
+		return "", err
+	}
+
+	if err := ipfsclient.PublishName(ctx, s.ipfsClient, hash, keyName, s.lifetime, s.ttl); err != nil {
+		return "", err
+	}
+	return hash, nil
+}
@@ -0,0 +1,101 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"ipfs-go-server/internal/models"
+
+	"github.com/libp2p/go-libp2p/core/crypto"
+	"github.com/libp2p/go-libp2p/core/peer"
+	mbase "github.com/multiformats/go-multibase"
+)
+
+// testKey returns a signing key and the IPNS name it publishes under
+func testKey(t *testing.T) (crypto.PrivKey, string) {
+	t.Helper()
+
+	priv, pub, err := crypto.GenerateEd25519Key(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	id, err := peer.IDFromPublicKey(pub)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return priv, id.String()
+}
+
+// signPointer signs pointer the way the daemon's key/sign does
+func signPointer(t *testing.T, priv crypto.PrivKey, pointer models.MovedPointer) models.MovedPointer {
+	t.Helper()
+
+	pointer.Signature = ""
+	unsigned, err := json.Marshal(pointer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sig, err := priv.Sign(append([]byte("libp2p-key signed message:"), unsigned...))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pointer.Signature, err = mbase.Encode(mbase.Base64url, sig); err != nil {
+		t.Fatal(err)
+	}
+	return pointer
+}
+
+func TestLoadTableVerifiesMovedPointers(t *testing.T) {
+	oldKey, oldName := testKey(t)
+	otherKey, otherName := testKey(t)
+	const newName = "k51new"
+
+	pointer := models.MovedPointer{
+		Type:     models.MovedPointerType,
+		TableID:  "release",
+		From:     oldName,
+		To:       newName,
+		Snapshot: "QmTable",
+		MovedAt:  time.Now().UTC(),
+	}
+	forged := pointer
+	forged.To = "k51attacker"
+
+	tests := []struct {
+		name    string
+		pointer models.MovedPointer
+		ok      bool
+	}{
+		{"signed by the old key", signPointer(t, oldKey, pointer), true},
+		{"unsigned", pointer, false},
+		{"signed by another key", signPointer(t, otherKey, pointer), false},
+		{"altered after signing", func() models.MovedPointer {
+			p := signPointer(t, oldKey, pointer)
+			p.To = forged.To
+			return p
+		}(), false},
+		{"made for another name", func() models.MovedPointer {
+			p := pointer
+			p.From = otherName
+			return signPointer(t, otherKey, p)
+		}(), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sh := ipfsServer(t,
+				map[string]string{oldName: "QmPointer", newName: "QmTable", forged.To: "QmTable"},
+				map[string]interface{}{"QmPointer": tt.pointer, "QmTable": models.NewTable("release", "release", "")})
+			stor := NewStorageWithIPNS(sh, "release", "release", "", "", oldName)
+
+			err := stor.LoadTable(context.Background())
+			if (err == nil) != tt.ok {
+				t.Fatalf("LoadTable = %v, want ok: %v", err, tt.ok)
+			}
+			if want := map[bool]string{true: newName, false: oldName}[tt.ok]; stor.GetIPNSName() != want {
+				t.Fatalf("table follows %s, want %s", stor.GetIPNSName(), want)
+			}
+		})
+	}
+}
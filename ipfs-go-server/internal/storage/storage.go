@@ -6,12 +6,13 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
-	"io"
 	"log"
 	"strings"
 	"sync"
 	"time"
 
+	"ipfs-go-server/internal/encryption"
+	ipfsclient "ipfs-go-server/internal/ipfs"
 	"ipfs-go-server/internal/models"
 
 	ipfs "github.com/ipfs/go-ipfs-api"
@@ -21,15 +22,35 @@ type Storage struct {
 	ipfsClient *ipfs.Shell
 	ipnsName   string
 	keyName    string
+	keyHistory []models.KeyRotation
 	table      *models.Table
 	mu         sync.Mutex
+
+	// IPNS record settings and the state of the last publish
+	lifetime time.Duration
+	ttl      time.Duration
+	status   PublishStatus
+
+	// Pubsub announcements of new heads
+	announcer   Announcer
+	announceSeq uint64
+
+	// Data key of a private table; nil for public tables
+	dataKey *encryption.TableKey
+
+	// Which changes made it to IPNS, and a snapshot added but not yet
+	// published; see pending.go
+	changes          uint64
+	publishedChanges uint64
+	pendingCID       string
+	pendingChanges   uint64
 }
 
 func NewStorage(ipfsClient *ipfs.Shell, tableID, tableName, description string) *Storage {
 	return &Storage{
 		ipfsClient: ipfsClient,
 		ipnsName:   "",
-		keyName:    tableID,
+		keyName:    models.KeyName(tableID),
 		table:      models.NewTable(tableID, tableName, description),
 	}
 }
@@ -43,19 +64,19 @@ func NewStorageWithIPNS(ipfsClient *ipfs.Shell, tableID, tableName, description,
 	}
 }
 
-func (s *Storage) SaveInitialTable() (string, error) {
+func (s *Storage) SaveInitialTable(ctx context.Context) (string, error) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
 	// Generate a key for this table if it doesn't exist
-	if err := s.ensureKey(); err != nil {
+	if err := s.ensureKey(ctx); err != nil {
 		return "", fmt.Errorf("failed to ensure IPNS key: %w", err)
 	}
 
-	return s.saveTable()
+	return s.saveAndEmit(ctx, EventCreated)
 }
 
-func (s *Storage) AddTorrentVersion(hash, magnetLink, fileName, description string, fileSize int64) error {
+func (s *Storage) AddTorrentVersion(ctx context.Context, hash, magnetLink, fileName, description string, fileSize int64) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
@@ -68,11 +89,11 @@ func (s *Storage) AddTorrentVersion(hash, magnetLink, fileName, description stri
 	}
 
 	s.table.AddVersion(version)
-	_, err := s.saveTable()
+	_, err := s.saveAndEmit(ctx, EventAppended)
 	return err
 }
 
-func (s *Storage) UpdateTableData(name, description, data string) error {
+func (s *Storage) UpdateTableData(ctx context.Context, name, description, data string) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
@@ -108,7 +129,7 @@ func (s *Storage) UpdateTableData(name, description, data string) error {
 	}
 
 	s.table.UpdatedAt = time.Now()
-	_, err := s.saveTable()
+	_, err := s.saveAndEmit(ctx, EventUpdated)
 	return err
 }
 
@@ -129,28 +150,39 @@ func (s *Storage) FastUpdateData(data string) error {
 	}
 
 	log.Printf("[STORAGE] Fast update completed for table %s", s.table.ID)
+	s.changes++
+	s.emit(EventAppended, "")
 	return nil
 }
 
-// BackgroundSaveToIPFS saves to IPFS/IPNS in the background
-func (s *Storage) BackgroundSaveToIPFS() error {
+// BackgroundSaveToIPFS saves to IPFS/IPNS in the background and returns the
+// new snapshot CID. Callers running it detached from a request should pass a
+// context that outlives the request.
+func (s *Storage) BackgroundSaveToIPFS(ctx context.Context) (string, error) {
 	s.mu.Lock()
-	data := s.table.Data
+	data, err := s.encodeSnapshot()
+	changes := s.changes
 	s.mu.Unlock()
+	if err != nil {
+		return "", fmt.Errorf("failed to encode snapshot: %w", err)
+	}
 
 	// Add to IPFS
-	hash, err := s.ipfsClient.Add(strings.NewReader(data))
+	hash, err := ipfsclient.Add(ctx, s.ipfsClient, bytes.NewReader(data))
 	if err != nil {
-		return fmt.Errorf("failed to add to IPFS: %w", err)
+		return "", err
 	}
+	s.mu.Lock()
+	s.markAdded(hash, changes)
+	s.mu.Unlock()
 
 	// Update IPNS to point to new hash (this is the only slow operation)
-	if err := s.ipfsClient.Publish(s.keyName, hash); err != nil {
-		return fmt.Errorf("failed to update IPNS: %w", err)
+	if err := s.publishChanges(ctx, hash, changes); err != nil {
+		return hash, fmt.Errorf("failed to update IPNS: %w", err)
 	}
 
 	log.Printf("[STORAGE] Background save completed - IPNS %s now points to %s", s.ipnsName, hash)
-	return nil
+	return hash, nil
 }
 
 func (s *Storage) GetLatestVersion() *models.TorrentVersion {
@@ -177,8 +209,104 @@ func (s *Storage) GetKeyName() string {
 	return s.keyName
 }
 
-func (s *Storage) ensureKey() error {
-	ctx := context.Background()
+// GetKeyHistory returns the keys this table was rotated away from, oldest first
+func (s *Storage) GetKeyHistory() []models.KeyRotation {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]models.KeyRotation(nil), s.keyHistory...)
+}
+
+// SetKeyHistory restores the rotation history recorded in the registry
+func (s *Storage) SetKeyHistory(history []models.KeyRotation) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.keyHistory = append([]models.KeyRotation(nil), history...)
+}
+
+// SetFormerIDs restores the IDs the table had before, as recorded in the
+// registry
+func (s *Storage) SetFormerIDs(ids []string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.table.FormerIDs = mergeIDs(nil, ids, s.table.ID)
+}
+
+// Reassign gives the table a new ID and renames its IPNS key to keyName.
+// The key, and so the IPNS name, stays the same; the next snapshot carries
+// the new ID, with the old one among its former IDs so versions signed for
+// it still verify and mirrors accept the change.
+func (s *Storage) Reassign(ctx context.Context, id, keyName string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if keyName != s.keyName {
+		ctx, cancel := ipfsclient.WithTimeout(ctx, ipfsclient.OpKeys)
+		defer cancel()
+
+		if _, err := s.ipfsClient.KeyRename(ctx, s.keyName, keyName, false); err != nil {
+			return fmt.Errorf("failed to rename key %s to %s: %w", s.keyName, keyName, err)
+		}
+	}
+
+	if id != s.table.ID {
+		// Moving back to a former ID undoes a migration that did not stick
+		if containsID(s.table.FormerIDs, id) {
+			s.table.FormerIDs = mergeIDs(s.table.FormerIDs, nil, id)
+		} else {
+			s.table.FormerIDs = mergeIDs(s.table.FormerIDs, []string{s.table.ID}, id)
+		}
+		s.table.ID = id
+		s.changes++
+	}
+	s.keyName = keyName
+	return nil
+}
+
+// reconcileID decides which ID a table loaded from a snapshot goes by and
+// records the former IDs of both. A snapshot whose former IDs include the
+// current ID was published after its origin migrated the table, so its ID
+// is taken; one whose ID is among the current former IDs predates the
+// migration, so the current ID is kept. It returns false for a snapshot of
+// an unrelated table. Must be called with s.mu held.
+func (s *Storage) reconcileID(loaded *models.Table) bool {
+	current := s.table.ID
+	switch {
+	case current == "" || loaded.ID == current || containsID(loaded.FormerIDs, current):
+	case containsID(s.table.FormerIDs, loaded.ID):
+		loaded.ID = current
+	default:
+		return false
+	}
+	loaded.FormerIDs = mergeIDs(s.table.FormerIDs, loaded.FormerIDs, loaded.ID)
+	return true
+}
+
+// mergeIDs appends the IDs in more to ids, skipping duplicates and except
+func mergeIDs(ids, more []string, except string) []string {
+	var merged []string
+	for _, id := range append(append([]string(nil), ids...), more...) {
+		if id != "" && id != except && !containsID(merged, id) {
+			merged = append(merged, id)
+		}
+	}
+	return merged
+}
+
+func containsID(ids []string, id string) bool {
+	for _, other := range ids {
+		if other == id {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Storage) ensureKey(ctx context.Context) error {
+	ctx, cancel := ipfsclient.WithTimeout(ctx, ipfsclient.OpKeys)
+	defer cancel()
 
 	// Check if key already exists
 	keys, err := s.ipfsClient.KeyList(ctx)
@@ -203,35 +331,62 @@ func (s *Storage) ensureKey() error {
 	return nil
 }
 
-func (s *Storage) saveTable() (string, error) {
-	data, err := json.Marshal(s.table)
-	if err != nil {
-		return "", err
-	}
-
-	hash, err := s.ipfsClient.Add(strings.NewReader(string(data)))
+func (s *Storage) saveTable(ctx context.Context) (string, error) {
+	// Every save follows a change to the table
+	s.changes++
+	hash, err := s.addSnapshot(ctx)
 	if err != nil {
 		return "", err
 	}
+	s.markAdded(hash, s.changes)
 
 	// Publish to IPNS using the key name
 	if s.keyName != "" {
-		if err := s.publishIPNS(hash); err != nil {
+		if err := s.publishIPNS(ctx, hash); err != nil {
 			// If IPNS publish fails, still return the hash
 			fmt.Printf("Warning: IPNS publish failed: %v\n", err)
+		} else {
+			s.markPublished(s.changes)
 		}
 	}
 
 	return hash, nil
 }
 
-func (s *Storage) publishIPNS(hash string) error {
+// saveAndEmit saves the table and reports the change, followed by a
+// published event if the new snapshot made it to IPNS
+func (s *Storage) saveAndEmit(ctx context.Context, eventType EventType) (string, error) {
+	previous := s.status.HeadCID
+	hash, err := s.saveTable(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	s.emit(eventType, hash)
+	if s.status.HeadCID == hash && hash != previous {
+		s.emit(EventPublished, hash)
+	}
+	return hash, nil
+}
+
+// addSnapshot adds the current table to IPFS without publishing it
+func (s *Storage) addSnapshot(ctx context.Context) (string, error) {
+	data, err := s.encodeSnapshot()
+	if err != nil {
+		return "", err
+	}
+
+	return ipfsclient.Add(ctx, s.ipfsClient, bytes.NewReader(data))
+}
+
+func (s *Storage) publishIPNS(ctx context.Context, hash string) error {
 	// Use key name for publishing
-	_, err := s.ipfsClient.PublishWithDetails(hash, s.keyName, 0, 0, false)
+	err := ipfsclient.PublishName(ctx, s.ipfsClient, hash, s.keyName, s.lifetime, s.ttl)
+	s.recordPublish(hash, err)
 	return err
 }
 
-func (s *Storage) LoadTable() error {
+func (s *Storage) LoadTable(ctx context.Context) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
@@ -239,53 +394,106 @@ func (s *Storage) LoadTable() error {
 		return errors.New("no IPNS name available")
 	}
 
-	// Add timeout context for IPNS resolution
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-	defer cancel()
+	// Follow "moved" pointers left behind by key rotations
+	name := s.ipnsName
+	for hops := 0; ; hops++ {
+		hash, err := ipfsclient.ResolveName(ctx, s.ipfsClient, name)
+		if err != nil {
+			return err
+		}
 
-	resolved, err := s.ipfsClient.Request("name/resolve", s.ipnsName).Option("timeout", "10s").Send(ctx)
-	if err != nil {
-		return fmt.Errorf("failed to resolve IPNS %s: %w", s.ipnsName, err)
-	}
-	defer resolved.Close()
+		doc, err := s.readSnapshot(ctx, hash)
+		if err != nil {
+			return err
+		}
 
-	// Add nil check for resolved.Output
-	if resolved.Output == nil {
-		return fmt.Errorf("IPNS resolution returned nil output for %s", s.ipnsName)
-	}
+		if doc.Type == models.MovedPointerType {
+			pointer := doc.movedPointer()
+			if hops >= maxMovedHops {
+				return fmt.Errorf("too many moved pointers starting at %s", s.ipnsName)
+			}
+			if err := verifyMovedPointer(name, pointer); err != nil {
+				return err
+			}
+			log.Printf("[STORAGE] Table %s moved from %s to %s", pointer.TableID, name, pointer.To)
+			name = pointer.To
+			continue
+		}
 
-	var resolveResp struct {
-		Path string `json:"Path"`
-	}
-	if err := json.NewDecoder(resolved.Output).Decode(&resolveResp); err != nil {
-		return fmt.Errorf("failed to decode resolve response: %w", err)
+		loadedTable, dataKey, err := decodeSnapshot(doc)
+		if err != nil {
+			return err
+		}
+
+		if name != s.ipnsName {
+			log.Printf("[STORAGE] Following table %s at its new IPNS name %s", loadedTable.ID, name)
+			s.ipnsName = name
+		}
+		// A table keeps the ID it was registered under. Snapshots published
+		// at its name under another ID predate a migration that was not
+		// recorded, so that ID is taken as a former one.
+		if !s.reconcileID(loadedTable) {
+			log.Printf("[STORAGE] Snapshot at %s carries ID %s; keeping %s", name, loadedTable.ID, s.table.ID)
+			loadedTable.FormerIDs = mergeIDs(s.table.FormerIDs, append([]string{loadedTable.ID}, loadedTable.FormerIDs...), s.table.ID)
+			loadedTable.ID = s.table.ID
+		}
+		s.status.HeadCID = hash
+		s.table = loadedTable
+		s.dataKey = dataKey
+		s.raiseAnnounceSeq(loadedTable)
+		s.markPublished(s.changes)
+		return nil
 	}
+}
 
-	// Extract hash from path like /ipfs/QmHash
-	hash := strings.TrimPrefix(resolveResp.Path, "/ipfs/")
+// maxSnapshotSize bounds how much is read from IPFS for one snapshot
+var maxSnapshotSize int64 = ipfsclient.DefaultMaxReadSize
 
-	data, err := s.ipfsClient.Cat(hash)
-	if err != nil {
-		return fmt.Errorf("failed to cat %s: %w", hash, err)
-	}
-	defer data.Close()
+// SetMaxSnapshotSize bounds how much is read from IPFS for one snapshot
+func SetMaxSnapshotSize(size int64) {
+	maxSnapshotSize = size
+}
 
-	var buf bytes.Buffer
-	_, err = io.Copy(&buf, data)
-	if err != nil {
-		return fmt.Errorf("failed to read data: %w", err)
-	}
+// snapshotDocument is anything published at a table's IPNS name: a table,
+// the sealed snapshot of a private table, or a moved pointer left by a key
+// rotation. Apart from type and tableId their fields are distinct, so one
+// pass over the stream decodes whichever it is.
+type snapshotDocument struct {
+	models.Table
+	encryption.SealedSnapshot
+
+	// The rest of a moved pointer; its type and tableId are the sealed
+	// snapshot's fields
+	From      string    `json:"from"`
+	To        string    `json:"to"`
+	Snapshot  string    `json:"snapshot"`
+	MovedAt   time.Time `json:"movedAt"`
+	Signature string    `json:"signature,omitempty"`
+}
 
-	var loadedTable models.Table
-	if err := json.Unmarshal(buf.Bytes(), &loadedTable); err != nil {
-		return fmt.Errorf("failed to unmarshal table: %w", err)
+func (doc *snapshotDocument) movedPointer() models.MovedPointer {
+	return models.MovedPointer{
+		Type:      doc.Type,
+		TableID:   doc.TableID,
+		From:      doc.From,
+		To:        doc.To,
+		Snapshot:  doc.Snapshot,
+		MovedAt:   doc.MovedAt,
+		Signature: doc.Signature,
 	}
+}
 
-	// Load versions from data field
-	if err := loadedTable.LoadVersionsFromData(); err != nil {
-		return fmt.Errorf("failed to load versions from data: %w", err)
+// readSnapshot decodes the document at hash straight from the IPFS stream
+func (s *Storage) readSnapshot(ctx context.Context, hash string) (*snapshotDocument, error) {
+	reader, err := ipfsclient.Cat(ctx, s.ipfsClient, hash, maxSnapshotSize)
+	if err != nil {
+		return nil, err
 	}
+	defer reader.Close()
 
-	s.table = &loadedTable
-	return nil
+	var doc snapshotDocument
+	if err := json.NewDecoder(reader).Decode(&doc); err != nil {
+		return nil, fmt.Errorf("failed to read snapshot %s: %w", hash, err)
+	}
+	return &doc, nil
 }
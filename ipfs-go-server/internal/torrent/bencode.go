@@ -0,0 +1,149 @@
+package torrent
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+)
+
+// Encode bencodes strings, byte slices, integers, lists and dictionaries
+// with string keys. Dictionary keys are written in sorted order, as BEP-3
+// requires, so the same info dictionary always hashes the same.
+func Encode(v interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := encode(&buf, v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func encode(buf *bytes.Buffer, v interface{}) error {
+	switch v := v.(type) {
+	case string:
+		buf.WriteString(strconv.Itoa(len(v)))
+		buf.WriteByte(':')
+		buf.WriteString(v)
+	case []byte:
+		buf.WriteString(strconv.Itoa(len(v)))
+		buf.WriteByte(':')
+		buf.Write(v)
+	case int:
+		fmt.Fprintf(buf, "i%de", v)
+	case int64:
+		fmt.Fprintf(buf, "i%de", v)
+	case []interface{}:
+		buf.WriteByte('l')
+		for _, item := range v {
+			if err := encode(buf, item); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte('e')
+	case []string:
+		buf.WriteByte('l')
+		for _, item := range v {
+			encode(buf, item)
+		}
+		buf.WriteByte('e')
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+
+		buf.WriteByte('d')
+		for _, k := range keys {
+			encode(buf, k)
+			if err := encode(buf, v[k]); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte('e')
+	default:
+		return fmt.Errorf("bencode: unsupported type %T", v)
+	}
+	return nil
+}
+
+var errMalformed = errors.New("bencode: malformed input")
+
+// Decode parses a bencoded value. Strings decode to string, integers to
+// int64, lists to []interface{} and dictionaries to map[string]interface{},
+// so a decoded value encodes back to the same bytes.
+func Decode(data []byte) (interface{}, error) {
+	v, rest, err := decode(data)
+	if err != nil {
+		return nil, err
+	}
+	if len(rest) != 0 {
+		return nil, fmt.Errorf("bencode: %d trailing bytes", len(rest))
+	}
+	return v, nil
+}
+
+func decode(data []byte) (interface{}, []byte, error) {
+	if len(data) == 0 {
+		return nil, nil, errMalformed
+	}
+	switch c := data[0]; {
+	case c == 'i':
+		end := bytes.IndexByte(data, 'e')
+		if end < 0 {
+			return nil, nil, errMalformed
+		}
+		n, err := strconv.ParseInt(string(data[1:end]), 10, 64)
+		if err != nil {
+			return nil, nil, errMalformed
+		}
+		return n, data[end+1:], nil
+	case c == 'l':
+		list := []interface{}{}
+		data = data[1:]
+		for len(data) > 0 && data[0] != 'e' {
+			item, rest, err := decode(data)
+			if err != nil {
+				return nil, nil, err
+			}
+			list = append(list, item)
+			data = rest
+		}
+		if len(data) == 0 {
+			return nil, nil, errMalformed
+		}
+		return list, data[1:], nil
+	case c == 'd':
+		dict := map[string]interface{}{}
+		data = data[1:]
+		for len(data) > 0 && data[0] != 'e' {
+			key, rest, err := decode(data)
+			if err != nil {
+				return nil, nil, err
+			}
+			k, ok := key.(string)
+			if !ok {
+				return nil, nil, errMalformed
+			}
+			if dict[k], data, err = decode(rest); err != nil {
+				return nil, nil, err
+			}
+		}
+		if len(data) == 0 {
+			return nil, nil, errMalformed
+		}
+		return dict, data[1:], nil
+	case c >= '0' && c <= '9':
+		colon := bytes.IndexByte(data, ':')
+		if colon < 0 {
+			return nil, nil, errMalformed
+		}
+		n, err := strconv.Atoi(string(data[:colon]))
+		if err != nil || n < 0 || n > len(data)-colon-1 {
+			return nil, nil, errMalformed
+		}
+		return string(data[colon+1 : colon+1+n]), data[colon+1+n:], nil
+	}
+	return nil, nil, errMalformed
+}
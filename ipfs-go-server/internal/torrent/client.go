@@ -0,0 +1,756 @@
+package torrent
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	handshakeTimeout = 30 * time.Second
+	peerIdleTimeout  = 3 * time.Minute
+	stoppedTimeout   = 5 * time.Second
+	maxPeers         = 50 // connections per torrent
+	pipelineDepth    = 16 // block requests outstanding per peer
+
+	// Azureus-style client prefix of our peer IDs
+	peerIDPrefix = "-GS0001-"
+)
+
+var (
+	ErrClosed       = errors.New("torrent client is closed")
+	ErrAlreadyAdded = errors.New("torrent is already added")
+	errPeerIsSelf   = errors.New("connected to self")
+	errWrongTorrent = errors.New("peer answered for another torrent")
+)
+
+// Client seeds and downloads single-file torrents over the BitTorrent peer
+// wire protocol. A torrent being downloaded serves the pieces it already
+// has, and keeps seeding once complete until it is removed.
+type Client struct {
+	peerID   [sha1.Size]byte
+	listener net.Listener
+	port     int
+
+	mu       sync.Mutex
+	torrents map[[sha1.Size]byte]*Torrent
+	closed   bool
+}
+
+// Stats is a snapshot of a torrent's transfer state
+type Stats struct {
+	InfoHash   string
+	InfoHashV2 string
+	Name       string
+	Length     int64
+	Peers      int
+	Uploaded   int64
+	Downloaded int64
+	Ratio      float64 // bytes uploaded per byte of payload
+	Progress   float64
+	Seeding    bool
+	AddedAt    time.Time
+}
+
+// NewClient listens for peers on listenAddr, such as ":6881"
+func NewClient(listenAddr string) (*Client, error) {
+	ln, err := net.Listen("tcp", listenAddr)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &Client{
+		listener: ln,
+		port:     ln.Addr().(*net.TCPAddr).Port,
+		torrents: make(map[[sha1.Size]byte]*Torrent),
+	}
+	copy(c.peerID[:], peerIDPrefix)
+	if _, err := rand.Read(c.peerID[len(peerIDPrefix):]); err != nil {
+		ln.Close()
+		return nil, err
+	}
+
+	go c.acceptLoop()
+	return c, nil
+}
+
+// Addr is the address peers connect to
+func (c *Client) Addr() net.Addr {
+	return c.listener.Addr()
+}
+
+// Close stops every torrent and the listener
+func (c *Client) Close() error {
+	c.mu.Lock()
+	c.closed = true
+	torrents := c.torrents
+	c.torrents = make(map[[sha1.Size]byte]*Torrent)
+	c.mu.Unlock()
+
+	err := c.listener.Close()
+	for _, t := range torrents {
+		t.close()
+	}
+	return err
+}
+
+// Seed serves a complete payload stored at path. The payload is trusted to
+// match the torrent; only its size is checked.
+func (c *Client) Seed(meta *MetaInfo, path string) (*Torrent, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	if info.Size() != meta.Length {
+		file.Close()
+		return nil, fmt.Errorf("payload is %d bytes, torrent expects %d", info.Size(), meta.Length)
+	}
+
+	have := newBitfield(meta.NumPieces())
+	for i := 0; i < meta.NumPieces(); i++ {
+		have.set(i)
+	}
+	return c.add(meta, file, have, 0)
+}
+
+// Download fetches a payload into path from peers and from any peers its
+// trackers return, then keeps seeding it. It returns once every piece is
+// verified, or removes the torrent and fails when ctx is done first.
+func (c *Client) Download(ctx context.Context, meta *MetaInfo, path string, peers []string) (*Torrent, error) {
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, err
+	}
+	if err := file.Truncate(meta.Length); err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	t, err := c.add(meta, file, newBitfield(meta.NumPieces()), meta.NumPieces())
+	if err != nil {
+		return nil, err
+	}
+	for _, addr := range peers {
+		t.connect(addr)
+	}
+
+	select {
+	case <-t.complete:
+		return t, nil
+	case <-ctx.Done():
+		c.Remove(meta.HexHash())
+		return nil, ctx.Err()
+	}
+}
+
+// Remove stops seeding or downloading the torrent with the given info hash
+func (c *Client) Remove(infoHash string) bool {
+	c.mu.Lock()
+	var found *Torrent
+	for key, t := range c.torrents {
+		if t.Meta.HexHash() == infoHash {
+			found = t
+			delete(c.torrents, key)
+			break
+		}
+	}
+	c.mu.Unlock()
+
+	if found == nil {
+		return false
+	}
+	found.close()
+	return true
+}
+
+// Torrent returns the torrent with the given info hash, or nil
+func (c *Client) Torrent(infoHash string) *Torrent {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, t := range c.torrents {
+		if t.Meta.HexHash() == infoHash {
+			return t
+		}
+	}
+	return nil
+}
+
+// Torrents returns every torrent, ordered by name
+func (c *Client) Torrents() []*Torrent {
+	c.mu.Lock()
+	torrents := make([]*Torrent, 0, len(c.torrents))
+	for _, t := range c.torrents {
+		torrents = append(torrents, t)
+	}
+	c.mu.Unlock()
+
+	sort.Slice(torrents, func(i, j int) bool { return torrents[i].Meta.Name < torrents[j].Meta.Name })
+	return torrents
+}
+
+func (c *Client) add(meta *MetaInfo, file *os.File, have bitfield, missing int) (*Torrent, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.closed {
+		file.Close()
+		return nil, ErrClosed
+	}
+	key := meta.PeerHash()
+	if _, exists := c.torrents[key]; exists {
+		file.Close()
+		return nil, ErrAlreadyAdded
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t := &Torrent{
+		Meta:     meta,
+		client:   c,
+		file:     file,
+		addedAt:  time.Now(),
+		ctx:      ctx,
+		cancel:   cancel,
+		complete: make(chan struct{}),
+		have:     have,
+		missing:  missing,
+		pending:  make(map[int]*peer),
+		peers:    make(map[*peer]struct{}),
+		dialed:   make(map[string]bool),
+	}
+	if missing == 0 {
+		close(t.complete)
+	}
+	c.torrents[key] = t
+
+	go t.announceLoop()
+	return t, nil
+}
+
+func (c *Client) lookup(infoHash [sha1.Size]byte) *Torrent {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.torrents[infoHash]
+}
+
+func (c *Client) acceptLoop() {
+	for {
+		conn, err := c.listener.Accept()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return
+			}
+			log.Printf("[TORRENT] Failed to accept peer: %v", err)
+			time.Sleep(time.Second)
+			continue
+		}
+		go c.handleConn(conn)
+	}
+}
+
+// handleConn answers the handshake of an incoming peer
+func (c *Client) handleConn(conn net.Conn) {
+	conn.SetDeadline(time.Now().Add(handshakeTimeout))
+	r := bufio.NewReader(conn)
+	infoHash, peerID, err := readHandshake(r)
+	if err != nil || peerID == c.peerID {
+		conn.Close()
+		return
+	}
+
+	t := c.lookup(infoHash)
+	if t == nil {
+		conn.Close()
+		return
+	}
+	if err := writeHandshake(conn, infoHash, c.peerID, t.Meta.hasV2()); err != nil {
+		conn.Close()
+		return
+	}
+	conn.SetDeadline(time.Time{})
+	t.run(conn, r)
+}
+
+// Torrent is a payload a Client is seeding or downloading
+type Torrent struct {
+	Meta *MetaInfo
+
+	client   *Client
+	file     *os.File
+	addedAt  time.Time
+	ctx      context.Context
+	cancel   context.CancelFunc
+	complete chan struct{} // closed once every piece is present
+
+	mu         sync.Mutex
+	have       bitfield
+	missing    int
+	pending    map[int]*peer // pieces being downloaded, by the peer fetching them
+	peers      map[*peer]struct{}
+	dialed     map[string]bool
+	uploaded   int64
+	downloaded int64
+	closed     bool
+}
+
+// Stats returns the torrent's current transfer state
+func (t *Torrent) Stats() Stats {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	pieces := t.Meta.NumPieces()
+	progress := 1.0
+	if pieces > 0 {
+		progress = float64(pieces-t.missing) / float64(pieces)
+	}
+	var ratio float64
+	if t.Meta.Length > 0 {
+		ratio = float64(t.uploaded) / float64(t.Meta.Length)
+	}
+
+	return Stats{
+		InfoHash:   t.Meta.HexHash(),
+		InfoHashV2: t.Meta.HexHashV2(),
+		Name:       t.Meta.Name,
+		Length:     t.Meta.Length,
+		Peers:      len(t.peers),
+		Uploaded:   t.uploaded,
+		Downloaded: t.downloaded,
+		Ratio:      ratio,
+		Progress:   progress,
+		Seeding:    t.missing == 0,
+		AddedAt:    t.addedAt,
+	}
+}
+
+func (t *Torrent) close() {
+	t.mu.Lock()
+	t.closed = true
+	peers := t.peers
+	t.peers = make(map[*peer]struct{})
+	t.mu.Unlock()
+
+	t.cancel()
+	for p := range peers {
+		p.conn.Close()
+	}
+	t.file.Close()
+}
+
+// left is how many payload bytes are still missing
+func (t *Torrent) left() int64 {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	var left int64
+	for i := 0; i < t.Meta.NumPieces(); i++ {
+		if !t.have.has(i) {
+			left += t.Meta.PieceSize(i)
+		}
+	}
+	return left
+}
+
+// announceLoop reports the torrent to each of its trackers until it is
+// removed, connecting to the peers they return while pieces are missing
+func (t *Torrent) announceLoop() {
+	var trackers []string
+	for _, tier := range t.Meta.Announce {
+		trackers = append(trackers, tier...)
+	}
+	if len(trackers) == 0 {
+		return
+	}
+
+	// A download announces again as soon as it completes
+	var completed <-chan struct{}
+	if t.left() > 0 {
+		completed = t.complete
+	}
+
+	event := "started"
+	for {
+		// Retry soon when no tracker answered, such as one still starting
+		interval, answered := minAnnounceInterval, false
+		for _, tracker := range trackers {
+			resp, err := Announce(t.ctx, tracker, t.announceRequest(event))
+			if err != nil {
+				if t.ctx.Err() != nil {
+					break
+				}
+				log.Printf("[TORRENT] Failed to announce %s to %s: %v", t.Meta.HexHash(), tracker, err)
+				continue
+			}
+			if !answered || resp.Interval < interval {
+				interval = max(resp.Interval, minAnnounceInterval)
+			}
+			answered = true
+			if t.left() > 0 {
+				for _, addr := range resp.Peers {
+					t.connect(addr)
+				}
+			}
+		}
+		event = ""
+
+		select {
+		case <-t.ctx.Done():
+			ctx, cancel := context.WithTimeout(context.Background(), stoppedTimeout)
+			for _, tracker := range trackers {
+				Announce(ctx, tracker, t.announceRequest("stopped"))
+			}
+			cancel()
+			return
+		case <-completed:
+			completed = nil
+			event = "completed"
+		case <-time.After(interval):
+		}
+	}
+}
+
+func (t *Torrent) announceRequest(event string) AnnounceRequest {
+	t.mu.Lock()
+	uploaded, downloaded := t.uploaded, t.downloaded
+	t.mu.Unlock()
+
+	return AnnounceRequest{
+		InfoHash:   t.Meta.PeerHash(),
+		PeerID:     t.client.peerID,
+		Port:       t.client.port,
+		Uploaded:   uploaded,
+		Downloaded: downloaded,
+		Left:       t.left(),
+		Event:      event,
+	}
+}
+
+// connect dials a peer once, unless it is already connected
+func (t *Torrent) connect(addr string) {
+	t.mu.Lock()
+	if t.closed || t.dialed[addr] {
+		t.mu.Unlock()
+		return
+	}
+	t.dialed[addr] = true
+	t.mu.Unlock()
+
+	go func() {
+		defer func() {
+			t.mu.Lock()
+			delete(t.dialed, addr)
+			t.mu.Unlock()
+		}()
+
+		dialer := net.Dialer{Timeout: handshakeTimeout}
+		conn, err := dialer.DialContext(t.ctx, "tcp", addr)
+		if err != nil {
+			return
+		}
+		conn.SetDeadline(time.Now().Add(handshakeTimeout))
+		r := bufio.NewReader(conn)
+		if err := t.handshake(conn, r); err != nil {
+			conn.Close()
+			return
+		}
+		conn.SetDeadline(time.Time{})
+		t.run(conn, r)
+	}()
+}
+
+func (t *Torrent) handshake(conn net.Conn, r *bufio.Reader) error {
+	if err := writeHandshake(conn, t.Meta.PeerHash(), t.client.peerID, t.Meta.hasV2()); err != nil {
+		return err
+	}
+	infoHash, peerID, err := readHandshake(r)
+	if err != nil {
+		return err
+	}
+	if infoHash != t.Meta.PeerHash() {
+		return errWrongTorrent
+	}
+	if peerID == t.client.peerID {
+		return errPeerIsSelf
+	}
+	return nil
+}
+
+// peer is a connection to another client. Everything but the connection
+// is only touched by the goroutine reading from it.
+type peer struct {
+	conn net.Conn
+	wmu  sync.Mutex
+
+	has    bitfield
+	choked bool // whether the peer is refusing our requests
+
+	// the piece being downloaded from the peer, or -1
+	piece     int
+	buf       []byte
+	nextBegin int
+	received  int
+	inflight  int
+}
+
+func (p *peer) send(id byte, payload []byte) error {
+	p.wmu.Lock()
+	defer p.wmu.Unlock()
+	p.conn.SetWriteDeadline(time.Now().Add(peerIdleTimeout))
+	return writeMessage(p.conn, id, payload)
+}
+
+// run exchanges messages with a connected peer until either side hangs up
+func (t *Torrent) run(conn net.Conn, r *bufio.Reader) {
+	p := &peer{
+		conn:   conn,
+		has:    newBitfield(t.Meta.NumPieces()),
+		choked: true,
+		piece:  -1,
+	}
+
+	t.mu.Lock()
+	if t.closed || len(t.peers) >= maxPeers {
+		t.mu.Unlock()
+		conn.Close()
+		return
+	}
+	t.peers[p] = struct{}{}
+	have := append(bitfield(nil), t.have...)
+	missing := t.missing
+	t.mu.Unlock()
+
+	defer func() {
+		conn.Close()
+		t.mu.Lock()
+		delete(t.peers, p)
+		t.mu.Unlock()
+		t.release(p)
+	}()
+
+	if missing < t.Meta.NumPieces() {
+		if err := p.send(msgBitfield, have); err != nil {
+			return
+		}
+	}
+	if missing > 0 {
+		if err := p.send(msgInterested, nil); err != nil {
+			return
+		}
+	}
+
+	for {
+		conn.SetReadDeadline(time.Now().Add(peerIdleTimeout))
+		msg, err := readMessage(r)
+		if err != nil {
+			return
+		}
+		if msg == nil {
+			continue
+		}
+		if err := t.handle(p, msg); err != nil {
+			if !errors.Is(err, net.ErrClosed) {
+				log.Printf("[TORRENT] Dropping peer %s of %s: %v", conn.RemoteAddr(), t.Meta.HexHash(), err)
+			}
+			return
+		}
+	}
+}
+
+func (t *Torrent) handle(p *peer, msg *message) error {
+	switch msg.id {
+	case msgChoke:
+		p.choked = true
+		t.release(p)
+	case msgUnchoke:
+		p.choked = false
+		return t.requestMore(p)
+	case msgInterested:
+		// Every connected peer is unchoked; maxPeers bounds the load
+		return p.send(msgUnchoke, nil)
+	case msgHave:
+		if len(msg.payload) != 4 {
+			return errBadMessage
+		}
+		if i := int(binary.BigEndian.Uint32(msg.payload)); i < t.Meta.NumPieces() {
+			p.has.set(i)
+		}
+		return t.requestMore(p)
+	case msgBitfield:
+		if len(msg.payload) != len(p.has) {
+			return errBadMessage
+		}
+		copy(p.has, msg.payload)
+		return t.requestMore(p)
+	case msgRequest:
+		return t.serveBlock(p, msg.payload)
+	case msgPiece:
+		return t.receiveBlock(p, msg.payload)
+	case msgHashRequest:
+		// Piece layers are in the .torrent, so they are not served here
+		return p.send(msgHashReject, msg.payload)
+	}
+	return nil
+}
+
+// serveBlock answers a request for part of a piece we have
+func (t *Torrent) serveBlock(p *peer, payload []byte) error {
+	if len(payload) != 12 {
+		return errBadMessage
+	}
+	index := int(binary.BigEndian.Uint32(payload))
+	begin := int64(binary.BigEndian.Uint32(payload[4:]))
+	length := int64(binary.BigEndian.Uint32(payload[8:]))
+	if index >= t.Meta.NumPieces() || length == 0 || length > maxRequestLength || begin+length > t.Meta.PieceSize(index) {
+		return errBadMessage
+	}
+
+	t.mu.Lock()
+	have := t.have.has(index)
+	t.mu.Unlock()
+	if !have {
+		return nil
+	}
+
+	block := make([]byte, 8+length)
+	copy(block, payload[:8])
+	if _, err := t.file.ReadAt(block[8:], int64(index)*t.Meta.PieceLength+begin); err != nil {
+		return fmt.Errorf("failed to read piece %d: %w", index, err)
+	}
+	if err := p.send(msgPiece, block); err != nil {
+		return err
+	}
+
+	t.mu.Lock()
+	t.uploaded += length
+	t.mu.Unlock()
+	return nil
+}
+
+// requestMore keeps pipelineDepth block requests outstanding, starting a
+// new piece the peer has when the current one is fully requested
+func (t *Torrent) requestMore(p *peer) error {
+	if p.choked {
+		return nil
+	}
+	if p.piece < 0 {
+		if p.piece = t.reservePiece(p); p.piece < 0 {
+			return nil
+		}
+		p.buf = make([]byte, t.Meta.PieceSize(p.piece))
+		p.nextBegin, p.received, p.inflight = 0, 0, 0
+	}
+
+	for p.inflight < pipelineDepth && p.nextBegin < len(p.buf) {
+		length := min(blockLength, len(p.buf)-p.nextBegin)
+		if err := p.send(msgRequest, blockPayload(p.piece, p.nextBegin, length)); err != nil {
+			return err
+		}
+		p.nextBegin += length
+		p.inflight++
+	}
+	return nil
+}
+
+// reservePiece picks a missing piece the peer has that no other peer is
+// downloading, or returns -1
+func (t *Torrent) reservePiece(p *peer) int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for i := 0; i < t.Meta.NumPieces(); i++ {
+		if !t.have.has(i) && p.has.has(i) && t.pending[i] == nil {
+			t.pending[i] = p
+			return i
+		}
+	}
+	return -1
+}
+
+// release gives up the piece being downloaded from a peer
+func (t *Torrent) release(p *peer) {
+	if p.piece < 0 {
+		return
+	}
+	t.mu.Lock()
+	if t.pending[p.piece] == p {
+		delete(t.pending, p.piece)
+	}
+	t.mu.Unlock()
+	p.piece, p.buf = -1, nil
+}
+
+// receiveBlock stores a block of the piece being downloaded, and verifies
+// and writes the piece once all of it has arrived
+func (t *Torrent) receiveBlock(p *peer, payload []byte) error {
+	if len(payload) < 8 {
+		return errBadMessage
+	}
+	index := int(binary.BigEndian.Uint32(payload))
+	begin := int(binary.BigEndian.Uint32(payload[4:]))
+	block := payload[8:]
+	if index != p.piece || begin+len(block) > len(p.buf) {
+		// A block requested before a choke
+		return nil
+	}
+
+	copy(p.buf[begin:], block)
+	p.received += len(block)
+	p.inflight--
+	t.mu.Lock()
+	t.downloaded += int64(len(block))
+	t.mu.Unlock()
+	if p.received < len(p.buf) {
+		return t.requestMore(p)
+	}
+
+	data := p.buf
+	if !t.Meta.VerifyPiece(index, data) {
+		t.release(p)
+		return fmt.Errorf("piece %d failed verification", index)
+	}
+	if _, err := t.file.WriteAt(data, int64(index)*t.Meta.PieceLength); err != nil {
+		t.release(p)
+		return fmt.Errorf("failed to write piece %d: %w", index, err)
+	}
+	t.release(p)
+	t.markHave(index)
+	return t.requestMore(p)
+}
+
+// markHave records a verified piece and tells every peer about it
+func (t *Torrent) markHave(index int) {
+	t.mu.Lock()
+	if t.have.has(index) {
+		t.mu.Unlock()
+		return
+	}
+	t.have.set(index)
+	t.missing--
+	done := t.missing == 0
+	peers := make([]*peer, 0, len(t.peers))
+	for p := range t.peers {
+		peers = append(peers, p)
+	}
+	t.mu.Unlock()
+
+	payload := binary.BigEndian.AppendUint32(nil, uint32(index))
+	for _, p := range peers {
+		p.send(msgHave, payload)
+	}
+	if done {
+		log.Printf("[TORRENT] Finished downloading %s (%s)", t.Meta.Name, t.Meta.HexHash())
+		close(t.complete)
+	}
+}
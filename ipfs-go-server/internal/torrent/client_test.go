@@ -0,0 +1,155 @@
+package torrent
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// testPayload writes size random bytes to a file in dir
+func testPayload(t *testing.T, dir string, size int) (string, []byte) {
+	t.Helper()
+
+	data := make([]byte, size)
+	if _, err := rand.Read(data); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "payload.bin")
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path, data
+}
+
+// newTestClient starts a client listening on loopback
+func newTestClient(t *testing.T) *Client {
+	t.Helper()
+
+	c, err := NewClient("127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { c.Close() })
+	return c
+}
+
+// waitFor polls cond until it holds or the timeout passes
+func waitFor(t *testing.T, timeout time.Duration, cond func() bool) bool {
+	t.Helper()
+
+	deadline := time.Now().Add(timeout)
+	for !cond() {
+		if time.Now().After(deadline) {
+			return false
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	return true
+}
+
+func TestClientSeedsToLeecher(t *testing.T) {
+	// Five full pieces of four blocks and a short last piece
+	const pieceLength = 4 * blockSize
+	const size = 5*pieceLength + 12345
+
+	for _, format := range []Format{FormatV1, FormatV2, FormatHybrid} {
+		t.Run(string(format), func(t *testing.T) {
+			dir := t.TempDir()
+			path, data := testPayload(t, dir, size)
+			meta, err := Build(bytes.NewReader(data), "payload.bin", size, Options{Format: format, PieceLength: pieceLength})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			seeder, leecher := newTestClient(t), newTestClient(t)
+			if _, err := seeder.Seed(meta, path); err != nil {
+				t.Fatalf("Seed: %v", err)
+			}
+
+			ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+			defer cancel()
+			dst := filepath.Join(dir, "download.bin")
+			downloaded, err := leecher.Download(ctx, meta, dst, []string{seeder.Addr().String()})
+			if err != nil {
+				t.Fatalf("Download: %v", err)
+			}
+
+			got, err := os.ReadFile(dst)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got, data) {
+				t.Fatal("downloaded payload differs from the seeded one")
+			}
+
+			stats := downloaded.Stats()
+			if !stats.Seeding || stats.Progress != 1 || stats.Downloaded != size {
+				t.Fatalf("leecher stats = %+v, want seeding with %d bytes downloaded", stats, size)
+			}
+			seeding := seeder.Torrent(meta.HexHash())
+			if !waitFor(t, 5*time.Second, func() bool { return seeding.Stats().Uploaded == size }) {
+				t.Fatalf("seeder uploaded %d bytes, want %d", seeding.Stats().Uploaded, size)
+			}
+		})
+	}
+}
+
+func TestClientRejectsCorruptPieces(t *testing.T) {
+	const size = 3 * minPieceLength
+	dir := t.TempDir()
+	path, data := testPayload(t, dir, size)
+	meta, err := Build(bytes.NewReader(data), "payload.bin", size, Options{PieceLength: minPieceLength})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The seeder trusts its payload, which no longer matches the torrent
+	data[minPieceLength+1] ^= 0xff
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+	seeder, leecher := newTestClient(t), newTestClient(t)
+	if _, err := seeder.Seed(meta, path); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	_, err = leecher.Download(ctx, meta, filepath.Join(dir, "download.bin"), []string{seeder.Addr().String()})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Download from a corrupt seeder = %v, want a deadline error", err)
+	}
+	if leecher.Torrent(meta.HexHash()) != nil {
+		t.Fatal("a failed download was not removed")
+	}
+}
+
+func TestClientRefusesDuplicatesAndClosed(t *testing.T) {
+	dir := t.TempDir()
+	path, data := testPayload(t, dir, minPieceLength)
+	meta, err := Build(bytes.NewReader(data), "payload.bin", minPieceLength, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c := newTestClient(t)
+	if _, err := c.Seed(meta, path); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Seed(meta, path); !errors.Is(err, ErrAlreadyAdded) {
+		t.Fatalf("second Seed = %v, want ErrAlreadyAdded", err)
+	}
+	if !c.Remove(meta.HexHash()) || c.Remove(meta.HexHash()) {
+		t.Fatal("Remove did not remove the torrent exactly once")
+	}
+
+	c.Close()
+	if _, err := c.Seed(meta, path); !errors.Is(err, ErrClosed) {
+		t.Fatalf("Seed after Close = %v, want ErrClosed", err)
+	}
+}
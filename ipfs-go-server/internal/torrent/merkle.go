@@ -0,0 +1,92 @@
+package torrent
+
+import "crypto/sha256"
+
+// BEP-52 hashes each file as a merkle tree of SHA-256 hashes of 16KiB
+// blocks. Leaves past the end of the file are zero, and the layer whose
+// nodes each cover one piece is published as the file's piece layer.
+const blockSize = 16 << 10
+
+type hash = [sha256.Size]byte
+
+type merkleTree struct {
+	pieceLength int64
+	singlePiece bool   // the file fits in one piece, so it has no piece layer
+	leaves      []hash // block hashes, kept only for a single-piece file
+	pieces      []hash // piece subtree roots
+}
+
+func newMerkleTree(size, pieceLength int64) *merkleTree {
+	return &merkleTree{pieceLength: pieceLength, singlePiece: size <= pieceLength}
+}
+
+func (t *merkleTree) addPiece(data []byte) {
+	var leaves []hash
+	for off := 0; off < len(data); off += blockSize {
+		end := off + blockSize
+		if end > len(data) {
+			end = len(data)
+		}
+		leaves = append(leaves, sha256.Sum256(data[off:end]))
+	}
+
+	if t.singlePiece {
+		t.leaves = append(t.leaves, leaves...)
+		return
+	}
+	t.pieces = append(t.pieces, merkleRoot(leaves, int(t.pieceLength/blockSize), hash{}))
+}
+
+// root returns the file's pieces root
+func (t *merkleTree) root() hash {
+	if t.singlePiece {
+		return merkleRoot(t.leaves, nextPowerOfTwo(len(t.leaves)), hash{})
+	}
+
+	// Pieces past the end of the file are subtrees of zero leaves
+	padding := merkleRoot(nil, int(t.pieceLength/blockSize), hash{})
+	return merkleRoot(t.pieces, nextPowerOfTwo(len(t.pieces)), padding)
+}
+
+// pieceLayer returns the concatenated piece hashes, or nil when the file
+// fits in a single piece
+func (t *merkleTree) pieceLayer() []byte {
+	if t.singlePiece {
+		return nil
+	}
+	layer := make([]byte, 0, len(t.pieces)*sha256.Size)
+	for _, h := range t.pieces {
+		layer = append(layer, h[:]...)
+	}
+	return layer
+}
+
+// merkleRoot hashes nodes, padded with pad to width (a power of two), up to
+// a single root
+func merkleRoot(nodes []hash, width int, pad hash) hash {
+	layer := make([]hash, width)
+	copy(layer, nodes)
+	for i := len(nodes); i < width; i++ {
+		layer[i] = pad
+	}
+
+	for len(layer) > 1 {
+		next := make([]hash, len(layer)/2)
+		for i := range next {
+			var pair [2 * sha256.Size]byte
+			copy(pair[:], layer[2*i][:])
+			copy(pair[sha256.Size:], layer[2*i+1][:])
+			next[i] = sha256.Sum256(pair[:])
+		}
+		layer = next
+	}
+	return layer[0]
+}
+
+func nextPowerOfTwo(n int) int {
+	p := 1
+	for p < n {
+		p *= 2
+	}
+	return p
+}
@@ -0,0 +1,125 @@
+package torrent
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+)
+
+// knownPayload is size bytes counting up modulo 251, so blocks and pieces
+// differ from each other
+func knownPayload(size int) []byte {
+	data := make([]byte, size)
+	for i := range data {
+		data[i] = byte(i % 251)
+	}
+	return data
+}
+
+// Expected hashes were computed independently from BEP-52: SHA-256 leaves
+// of 16KiB blocks, the last block unpadded, with zero hashes filling the
+// tree past the end of the file.
+var merkleTests = []struct {
+	name        string
+	size        int
+	pieceLength int64
+	root        string
+	pieceLayer  string // "" for a file of a single piece
+}{
+	{
+		name:        "one byte",
+		size:        1,
+		pieceLength: blockSize,
+		root:        "6e340b9cffb37a989ca544e6bb780a2c78901d3fb33738768511a30617afa01d",
+	},
+	{
+		// Four leaves, the last of them short, in one piece of eight blocks
+		name:        "single piece",
+		size:        3*blockSize + 100,
+		pieceLength: 8 * blockSize,
+		root:        "b1962ec2f88a1abe430091423d0d9763a8b5c28c5968fbf85f35b3a967ef9028",
+	},
+	{
+		name:        "whole pieces",
+		size:        4 * blockSize,
+		pieceLength: 2 * blockSize,
+		root:        "2d6b546231225a7132a38ab354f03e9132e4b9141da89f1784b71ab2fb34fae3",
+		pieceLayer: "d9e13d0b676ad681164ef0b7b5910d1328ea83a047cad57e619d76bbe3a08525" +
+			"e28097eaaa55956702cf8195d1a551dbabb63e3d679b294cf33d506a6b5ef479",
+	},
+	{
+		// The last piece holds one short block and a zero leaf, and three
+		// subtrees of zero leaves pad the five pieces to eight
+		name:        "short last piece",
+		size:        8*blockSize + 1000,
+		pieceLength: 2 * blockSize,
+		root:        "dab018b8b11a5f3588f256c326410c26f99fb510674aa9b1835219917b479d77",
+		pieceLayer: "d9e13d0b676ad681164ef0b7b5910d1328ea83a047cad57e619d76bbe3a08525" +
+			"e28097eaaa55956702cf8195d1a551dbabb63e3d679b294cf33d506a6b5ef479" +
+			"c652249676984ba0be8db1d26efa9e0c67cd14299b02eaab326419a0f91a1aec" +
+			"ac13964b51d3110275d8500b340b92fd2ac4bab61bd18eef21651b1e26ec4a78" +
+			"a5bced0a0f0b199439aa85b1d1b500c29dfd3dd1a4aabc46b3e0db174c482721",
+	},
+}
+
+func TestMerkleTreeKnownAnswers(t *testing.T) {
+	for _, tt := range merkleTests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := knownPayload(tt.size)
+			tree := newMerkleTree(int64(tt.size), tt.pieceLength)
+			for off := 0; off < len(data); off += int(tt.pieceLength) {
+				tree.addPiece(data[off:min(off+int(tt.pieceLength), len(data))])
+			}
+
+			root := tree.root()
+			if got := hex.EncodeToString(root[:]); got != tt.root {
+				t.Errorf("root = %s, want %s", got, tt.root)
+			}
+			if got := hex.EncodeToString(tree.pieceLayer()); got != tt.pieceLayer {
+				t.Errorf("piece layer = %s, want %s", got, tt.pieceLayer)
+			}
+		})
+	}
+}
+
+func TestBuildV2KnownAnswers(t *testing.T) {
+	for _, tt := range merkleTests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := knownPayload(tt.size)
+			meta, err := Build(bytes.NewReader(data), "payload.bin", int64(tt.size), Options{Format: FormatV2, PieceLength: tt.pieceLength})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := hex.EncodeToString(meta.piecesRoot[:]); got != tt.root {
+				t.Errorf("pieces root = %s, want %s", got, tt.root)
+			}
+			if got := hex.EncodeToString(meta.pieceLayer); got != tt.pieceLayer {
+				t.Errorf("piece layer = %s, want %s", got, tt.pieceLayer)
+			}
+
+			// Every piece verifies, the short last one included, and a
+			// changed byte does not
+			for i := 0; i < meta.NumPieces(); i++ {
+				off := int64(i) * meta.PieceLength
+				piece := data[off : off+meta.PieceSize(i)]
+				if !meta.VerifyPiece(i, piece) {
+					t.Fatalf("piece %d does not verify", i)
+				}
+				changed := append([]byte(nil), piece...)
+				changed[len(changed)-1] ^= 1
+				if meta.VerifyPiece(i, changed) {
+					t.Fatalf("piece %d verifies with a changed byte", i)
+				}
+			}
+
+			// The torrent carries the same hashes for other clients
+			parsed, err := Parse(meta.Torrent)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if parsed.piecesRoot != meta.piecesRoot || !bytes.Equal(parsed.pieceLayer, meta.pieceLayer) {
+				t.Fatal("parsed torrent has other hashes than the built one")
+			}
+		})
+	}
+}
@@ -0,0 +1,453 @@
+package torrent
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Piece sizes are picked so a torrent has roughly targetPieces pieces
+const (
+	minPieceLength = 16 << 10
+	maxPieceLength = 16 << 20
+	targetPieces   = 1500
+)
+
+// Format is the BitTorrent metainfo version a torrent is built for
+type Format string
+
+const (
+	FormatV1     Format = "v1"
+	FormatV2     Format = "v2"     // BEP-52
+	FormatHybrid Format = "hybrid" // both, readable by v1 and v2 clients
+)
+
+var (
+	ErrEmptyName          = errors.New("torrent name is required")
+	ErrInvalidPieceLength = fmt.Errorf("piece length must be a power of two between %d and %d", minPieceLength, maxPieceLength)
+)
+
+// Options control how a torrent is built. The zero value builds a v1
+// torrent with an automatic piece length and no trackers.
+type Options struct {
+	Format      Format
+	PieceLength int64      // 0 picks one from the payload size
+	Announce    [][]string // tiers of tracker URLs, as in BEP-12
+	WebSeeds    []string   // HTTP URLs of the payload, as in BEP-19
+	Comment     string
+}
+
+// ParseFormat reads a format name, defaulting to v1
+func ParseFormat(s string) (Format, error) {
+	switch Format(strings.ToLower(s)) {
+	case "", FormatV1:
+		return FormatV1, nil
+	case FormatV2:
+		return FormatV2, nil
+	case FormatHybrid:
+		return FormatHybrid, nil
+	}
+	return "", fmt.Errorf("unknown torrent format %q, expected v1, v2 or hybrid", s)
+}
+
+// MetaInfo is a single-file torrent
+type MetaInfo struct {
+	Name        string
+	Length      int64
+	PieceLength int64
+	Format      Format
+	Announce    [][]string
+	WebSeeds    []string
+	CreatedAt   time.Time
+
+	// InfoHash is set for v1 and hybrid torrents, InfoHashV2 for v2 and
+	// hybrid ones
+	InfoHash   [sha1.Size]byte
+	InfoHashV2 [sha256.Size]byte
+
+	// Torrent is the bencoded .torrent file
+	Torrent []byte
+
+	// Piece hashes used to verify downloaded pieces: SHA-1 per piece for
+	// v1, the pieces root and piece layer for v2
+	pieces     []byte
+	piecesRoot hash
+	pieceLayer []byte
+}
+
+func (m *MetaInfo) hasV1() bool { return m.Format != FormatV2 }
+func (m *MetaInfo) hasV2() bool { return m.Format != FormatV1 }
+
+// HexHash identifies the torrent: the v1 info hash as lowercase hex, or the
+// v2 one for v2-only torrents
+func (m *MetaInfo) HexHash() string {
+	if m.hasV1() {
+		return hex.EncodeToString(m.InfoHash[:])
+	}
+	return m.HexHashV2()
+}
+
+// HexHashV2 returns the v2 info hash, or "" for v1 torrents
+func (m *MetaInfo) HexHashV2() string {
+	if !m.hasV2() {
+		return ""
+	}
+	return hex.EncodeToString(m.InfoHashV2[:])
+}
+
+// PeerHash is the info hash sent in peer handshakes: the v1 hash, or the v2
+// hash truncated to 20 bytes for v2-only torrents
+func (m *MetaInfo) PeerHash() [sha1.Size]byte {
+	if m.hasV1() {
+		return m.InfoHash
+	}
+	var h [sha1.Size]byte
+	copy(h[:], m.InfoHashV2[:])
+	return h
+}
+
+// NumPieces returns how many pieces the payload is split into
+func (m *MetaInfo) NumPieces() int {
+	return int((m.Length + m.PieceLength - 1) / m.PieceLength)
+}
+
+// PieceSize returns the length of piece i, which is shorter for the last
+func (m *MetaInfo) PieceSize(i int) int64 {
+	if size := m.Length - int64(i)*m.PieceLength; size < m.PieceLength {
+		return size
+	}
+	return m.PieceLength
+}
+
+// VerifyPiece reports whether data is piece i of the payload
+func (m *MetaInfo) VerifyPiece(i int, data []byte) bool {
+	if i < 0 || i >= m.NumPieces() || int64(len(data)) != m.PieceSize(i) {
+		return false
+	}
+	if m.hasV1() {
+		sum := sha1.Sum(data)
+		return bytes.Equal(sum[:], m.pieces[i*sha1.Size:(i+1)*sha1.Size])
+	}
+
+	tree := newMerkleTree(m.Length, m.PieceLength)
+	tree.addPiece(data)
+	if tree.singlePiece {
+		return tree.root() == m.piecesRoot
+	}
+	return bytes.Equal(tree.pieces[0][:], m.pieceLayer[i*sha256.Size:(i+1)*sha256.Size])
+}
+
+// MagnetLink returns a magnet URI for the torrent, listing its trackers
+// and web seeds
+func (m *MetaInfo) MagnetLink() string {
+	params := []string{}
+	if m.hasV1() {
+		params = append(params, "xt=urn:btih:"+hex.EncodeToString(m.InfoHash[:]))
+	}
+	if m.hasV2() {
+		// 0x12 0x20 is the multihash prefix of a SHA-256 digest
+		params = append(params, "xt=urn:btmh:1220"+m.HexHashV2())
+	}
+	params = append(params, "dn="+url.QueryEscape(m.Name))
+	for _, tier := range m.Announce {
+		for _, tracker := range tier {
+			params = append(params, "tr="+url.QueryEscape(tracker))
+		}
+	}
+	for _, seed := range m.WebSeeds {
+		params = append(params, "ws="+url.QueryEscape(seed))
+	}
+	return "magnet:?" + strings.Join(params, "&")
+}
+
+// PieceLength picks a power of two piece size for a payload of size bytes
+func PieceLength(size int64) int64 {
+	length := int64(minPieceLength)
+	for length < maxPieceLength && size/length > targetPieces {
+		length *= 2
+	}
+	return length
+}
+
+// ValidPieceLength reports whether length can be used as a piece length
+func ValidPieceLength(length int64) bool {
+	return length >= minPieceLength && length <= maxPieceLength && length&(length-1) == 0
+}
+
+// Build hashes a payload of the given size read from r into a single-file
+// torrent called name
+func Build(r io.Reader, name string, size int64, opts Options) (*MetaInfo, error) {
+	if name == "" {
+		return nil, ErrEmptyName
+	}
+	format, err := ParseFormat(string(opts.Format))
+	if err != nil {
+		return nil, err
+	}
+	pieceLength := opts.PieceLength
+	if pieceLength == 0 {
+		pieceLength = PieceLength(size)
+	}
+	if !ValidPieceLength(pieceLength) {
+		return nil, ErrInvalidPieceLength
+	}
+
+	meta := &MetaInfo{
+		Name:        name,
+		PieceLength: pieceLength,
+		Format:      format,
+		Announce:    opts.Announce,
+		WebSeeds:    opts.WebSeeds,
+		CreatedAt:   time.Now(),
+	}
+
+	var pieces []byte
+	tree := newMerkleTree(size, pieceLength)
+	buf := make([]byte, pieceLength)
+	for {
+		n, err := io.ReadFull(r, buf)
+		if n > 0 {
+			if meta.hasV1() {
+				sum := sha1.Sum(buf[:n])
+				pieces = append(pieces, sum[:]...)
+			}
+			if meta.hasV2() {
+				tree.addPiece(buf[:n])
+			}
+			meta.Length += int64(n)
+		}
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to hash payload: %w", err)
+		}
+	}
+	if meta.Length != size {
+		return nil, fmt.Errorf("payload is %d bytes, expected %d", meta.Length, size)
+	}
+
+	info := map[string]interface{}{
+		"name":         name,
+		"piece length": pieceLength,
+	}
+	if meta.hasV1() {
+		info["length"] = meta.Length
+		info["pieces"] = pieces
+	}
+	torrentFile := map[string]interface{}{
+		"created by":    "ipfs-go-server",
+		"creation date": meta.CreatedAt.Unix(),
+	}
+	meta.pieces = pieces
+	if meta.hasV2() {
+		fileEntry := map[string]interface{}{"length": meta.Length}
+		if meta.Length > 0 {
+			root := tree.root()
+			meta.piecesRoot = root
+			meta.pieceLayer = tree.pieceLayer()
+			fileEntry["pieces root"] = root[:]
+			if layer := meta.pieceLayer; layer != nil {
+				torrentFile["piece layers"] = map[string]interface{}{string(root[:]): layer}
+			}
+		}
+		info["meta version"] = 2
+		info["file tree"] = map[string]interface{}{
+			name: map[string]interface{}{"": fileEntry},
+		}
+	}
+
+	infoBytes, err := Encode(info)
+	if err != nil {
+		return nil, err
+	}
+	if meta.hasV1() {
+		meta.InfoHash = sha1.Sum(infoBytes)
+	}
+	if meta.hasV2() {
+		meta.InfoHashV2 = sha256.Sum256(infoBytes)
+	}
+
+	torrentFile["info"] = info
+	if len(opts.Announce) > 0 && len(opts.Announce[0]) > 0 {
+		torrentFile["announce"] = opts.Announce[0][0]
+		tiers := make([]interface{}, 0, len(opts.Announce))
+		for _, tier := range opts.Announce {
+			tiers = append(tiers, tier)
+		}
+		torrentFile["announce-list"] = tiers
+	}
+	// url-list is outside the info dict, so web seeds do not change the
+	// info hash
+	if len(opts.WebSeeds) > 0 {
+		seeds := make([]interface{}, 0, len(opts.WebSeeds))
+		for _, seed := range opts.WebSeeds {
+			seeds = append(seeds, seed)
+		}
+		torrentFile["url-list"] = seeds
+	}
+	if opts.Comment != "" {
+		torrentFile["comment"] = opts.Comment
+	}
+	if meta.Torrent, err = Encode(torrentFile); err != nil {
+		return nil, err
+	}
+	return meta, nil
+}
+
+// Parse reads a single-file .torrent built by Build or any other v1, v2 or
+// hybrid client
+func Parse(data []byte) (*MetaInfo, error) {
+	decoded, err := Decode(data)
+	if err != nil {
+		return nil, err
+	}
+	file, ok := decoded.(map[string]interface{})
+	if !ok {
+		return nil, errNotTorrent
+	}
+	info, ok := file["info"].(map[string]interface{})
+	if !ok {
+		return nil, errNotTorrent
+	}
+	if _, multi := info["files"]; multi {
+		return nil, errors.New("multi-file torrents are not supported")
+	}
+
+	meta := &MetaInfo{Torrent: data}
+	meta.Name, _ = info["name"].(string)
+	meta.PieceLength, _ = info["piece length"].(int64)
+	if meta.Name == "" || meta.PieceLength <= 0 {
+		return nil, errNotTorrent
+	}
+	if created, ok := file["creation date"].(int64); ok {
+		meta.CreatedAt = time.Unix(created, 0)
+	}
+	meta.Announce = parseAnnounce(file)
+	meta.WebSeeds = parseWebSeeds(file)
+
+	pieces, hasV1 := info["pieces"].(string)
+	version, _ := info["meta version"].(int64)
+	switch {
+	case hasV1 && version == 2:
+		meta.Format = FormatHybrid
+	case hasV1:
+		meta.Format = FormatV1
+	case version == 2:
+		meta.Format = FormatV2
+	default:
+		return nil, errNotTorrent
+	}
+
+	if meta.hasV1() {
+		meta.Length, _ = info["length"].(int64)
+		meta.pieces = []byte(pieces)
+		if len(meta.pieces) != meta.NumPieces()*sha1.Size {
+			return nil, errors.New("torrent has the wrong number of piece hashes")
+		}
+	}
+	if meta.hasV2() {
+		if err := parseFileTree(meta, file, info); err != nil {
+			return nil, err
+		}
+	}
+
+	infoBytes, err := Encode(info)
+	if err != nil {
+		return nil, err
+	}
+	if meta.hasV1() {
+		meta.InfoHash = sha1.Sum(infoBytes)
+	}
+	if meta.hasV2() {
+		meta.InfoHashV2 = sha256.Sum256(infoBytes)
+	}
+	return meta, nil
+}
+
+var errNotTorrent = errors.New("not a torrent file")
+
+// parseFileTree reads the length, pieces root and piece layer of the single
+// file in a v2 file tree
+func parseFileTree(meta *MetaInfo, file, info map[string]interface{}) error {
+	tree, _ := info["file tree"].(map[string]interface{})
+	node, _ := tree[meta.Name].(map[string]interface{})
+	entry, ok := node[""].(map[string]interface{})
+	if !ok || len(tree) != 1 {
+		return errors.New("multi-file torrents are not supported")
+	}
+
+	length, _ := entry["length"].(int64)
+	if meta.hasV1() && length != meta.Length {
+		return errors.New("v1 and v2 file lengths differ")
+	}
+	meta.Length = length
+	if length == 0 {
+		return nil
+	}
+
+	root, _ := entry["pieces root"].(string)
+	if len(root) != sha256.Size {
+		return errors.New("torrent has no pieces root")
+	}
+	copy(meta.piecesRoot[:], root)
+	if length > meta.PieceLength {
+		layers, _ := file["piece layers"].(map[string]interface{})
+		layer, _ := layers[root].(string)
+		if len(layer) != meta.NumPieces()*sha256.Size {
+			return errors.New("torrent has the wrong number of piece layer hashes")
+		}
+		meta.pieceLayer = []byte(layer)
+	}
+	return nil
+}
+
+// parseAnnounce reads the announce-list, falling back to announce
+func parseAnnounce(file map[string]interface{}) [][]string {
+	var tiers [][]string
+	list, _ := file["announce-list"].([]interface{})
+	for _, rawTier := range list {
+		var tier []string
+		items, _ := rawTier.([]interface{})
+		for _, item := range items {
+			if tracker, ok := item.(string); ok {
+				tier = append(tier, tracker)
+			}
+		}
+		if len(tier) > 0 {
+			tiers = append(tiers, tier)
+		}
+	}
+	if len(tiers) == 0 {
+		if tracker, ok := file["announce"].(string); ok && tracker != "" {
+			tiers = append(tiers, []string{tracker})
+		}
+	}
+	return tiers
+}
+
+// parseWebSeeds reads url-list, which BEP-19 allows to be a single URL
+func parseWebSeeds(file map[string]interface{}) []string {
+	switch list := file["url-list"].(type) {
+	case string:
+		if list != "" {
+			return []string{list}
+		}
+	case []interface{}:
+		var seeds []string
+		for _, item := range list {
+			if seed, ok := item.(string); ok && seed != "" {
+				seeds = append(seeds, seed)
+			}
+		}
+		return seeds
+	}
+	return nil
+}
@@ -0,0 +1,128 @@
+package torrent
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Peer wire message IDs from BEP-3, plus the hash request and reject
+// messages of BEP-52
+const (
+	msgChoke byte = iota
+	msgUnchoke
+	msgInterested
+	msgNotInterested
+	msgHave
+	msgBitfield
+	msgRequest
+	msgPiece
+	msgCancel
+
+	msgHashRequest byte = 21
+	msgHashReject  byte = 23
+)
+
+const (
+	protocolName = "BitTorrent protocol"
+
+	blockLength      = 16 << 10  // size of the blocks requested from peers
+	maxRequestLength = 128 << 10 // largest block a peer may request
+	maxMessageLength = 1 << 20
+)
+
+var errBadMessage = errors.New("malformed peer message")
+
+// message is a length-prefixed peer message. A nil message is a keep-alive.
+type message struct {
+	id      byte
+	payload []byte
+}
+
+// writeHandshake sends the BEP-3 handshake. v2 sets the BEP-52 reserved
+// bit so v2 clients know the torrent can be upgraded.
+func writeHandshake(w io.Writer, infoHash, peerID [sha1.Size]byte, v2 bool) error {
+	buf := make([]byte, 0, 1+len(protocolName)+8+2*sha1.Size)
+	buf = append(buf, byte(len(protocolName)))
+	buf = append(buf, protocolName...)
+	reserved := make([]byte, 8)
+	if v2 {
+		reserved[7] |= 0x10
+	}
+	buf = append(buf, reserved...)
+	buf = append(buf, infoHash[:]...)
+	buf = append(buf, peerID[:]...)
+	_, err := w.Write(buf)
+	return err
+}
+
+func readHandshake(r io.Reader) (infoHash, peerID [sha1.Size]byte, err error) {
+	buf := make([]byte, 1+len(protocolName)+8+2*sha1.Size)
+	if _, err = io.ReadFull(r, buf); err != nil {
+		return
+	}
+	if int(buf[0]) != len(protocolName) || !bytes.Equal(buf[1:1+len(protocolName)], []byte(protocolName)) {
+		err = errors.New("peer does not speak the BitTorrent protocol")
+		return
+	}
+	rest := buf[1+len(protocolName)+8:]
+	copy(infoHash[:], rest[:sha1.Size])
+	copy(peerID[:], rest[sha1.Size:])
+	return
+}
+
+func readMessage(r io.Reader) (*message, error) {
+	var prefix [4]byte
+	if _, err := io.ReadFull(r, prefix[:]); err != nil {
+		return nil, err
+	}
+	length := binary.BigEndian.Uint32(prefix[:])
+	if length == 0 {
+		return nil, nil
+	}
+	if length > maxMessageLength {
+		return nil, fmt.Errorf("peer message of %d bytes is too long", length)
+	}
+
+	buf := make([]byte, length)
+	if _, err := io.ReadFull(r, buf); err != nil {
+		return nil, err
+	}
+	return &message{id: buf[0], payload: buf[1:]}, nil
+}
+
+// writeMessage sends a message in a single write
+func writeMessage(w io.Writer, id byte, payload []byte) error {
+	buf := make([]byte, 5, 5+len(payload))
+	binary.BigEndian.PutUint32(buf, uint32(1+len(payload)))
+	buf[4] = id
+	_, err := w.Write(append(buf, payload...))
+	return err
+}
+
+// blockPayload encodes the index, begin and length fields of a request
+func blockPayload(index, begin, length int) []byte {
+	payload := make([]byte, 12)
+	binary.BigEndian.PutUint32(payload, uint32(index))
+	binary.BigEndian.PutUint32(payload[4:], uint32(begin))
+	binary.BigEndian.PutUint32(payload[8:], uint32(length))
+	return payload
+}
+
+// bitfield marks the pieces a peer has, high bit first
+type bitfield []byte
+
+func newBitfield(pieces int) bitfield {
+	return make(bitfield, (pieces+7)/8)
+}
+
+func (b bitfield) has(i int) bool {
+	return i >= 0 && i/8 < len(b) && b[i/8]&(0x80>>(i%8)) != 0
+}
+
+func (b bitfield) set(i int) {
+	b[i/8] |= 0x80 >> (i % 8)
+}
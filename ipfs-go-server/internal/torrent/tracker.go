@@ -0,0 +1,222 @@
+package torrent
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	trackerTimeout          = 15 * time.Second
+	defaultAnnounceInterval = 30 * time.Minute
+	minAnnounceInterval     = time.Minute
+)
+
+var trackerClient = &http.Client{Timeout: trackerTimeout}
+
+// AnnounceRequest is what a client tells a tracker about one torrent
+type AnnounceRequest struct {
+	InfoHash   [sha1.Size]byte
+	PeerID     [sha1.Size]byte
+	Port       int
+	Uploaded   int64
+	Downloaded int64
+	Left       int64
+	Event      string // "started", "completed", "stopped" or "" for a regular update
+}
+
+// AnnounceResponse lists the peers a tracker knows for a torrent
+type AnnounceResponse struct {
+	Interval time.Duration
+	Seeders  int
+	Leechers int
+	Peers    []string // host:port
+}
+
+// ScrapeResult is a tracker's count of a torrent's swarm
+type ScrapeResult struct {
+	Seeders   int
+	Leechers  int
+	Completed int // downloads the tracker has seen finish
+}
+
+// ErrScrapeUnsupported is returned for HTTP trackers whose announce URL has
+// no scrape counterpart
+var ErrScrapeUnsupported = errors.New("tracker does not support scrape")
+
+// Announce reports a torrent to an HTTP or UDP tracker and returns its peers
+func Announce(ctx context.Context, tracker string, req AnnounceRequest) (*AnnounceResponse, error) {
+	u, err := url.Parse(tracker)
+	if err != nil {
+		return nil, err
+	}
+	switch u.Scheme {
+	case "http", "https":
+		return announceHTTP(ctx, u, req)
+	case "udp":
+		return announceUDP(ctx, u, req)
+	}
+	return nil, fmt.Errorf("unsupported tracker scheme %q", u.Scheme)
+}
+
+// Scrape asks an HTTP or UDP tracker how many peers a torrent has without
+// joining its swarm
+func Scrape(ctx context.Context, tracker string, infoHash [sha1.Size]byte) (*ScrapeResult, error) {
+	u, err := url.Parse(tracker)
+	if err != nil {
+		return nil, err
+	}
+	switch u.Scheme {
+	case "http", "https":
+		return scrapeHTTP(ctx, u, infoHash)
+	case "udp":
+		return scrapeUDP(ctx, u, infoHash)
+	}
+	return nil, fmt.Errorf("unsupported tracker scheme %q", u.Scheme)
+}
+
+func announceHTTP(ctx context.Context, u *url.URL, req AnnounceRequest) (*AnnounceResponse, error) {
+	// info_hash and peer_id are raw bytes, which url.Values would not
+	// leave alone, so the query is built by hand after any passkey
+	params := []string{
+		"info_hash=" + url.QueryEscape(string(req.InfoHash[:])),
+		"peer_id=" + url.QueryEscape(string(req.PeerID[:])),
+		"port=" + strconv.Itoa(req.Port),
+		"uploaded=" + strconv.FormatInt(req.Uploaded, 10),
+		"downloaded=" + strconv.FormatInt(req.Downloaded, 10),
+		"left=" + strconv.FormatInt(req.Left, 10),
+		"compact=1",
+	}
+	if req.Event != "" {
+		params = append(params, "event="+req.Event)
+	}
+	if u.RawQuery != "" {
+		params = append([]string{u.RawQuery}, params...)
+	}
+	u.RawQuery = strings.Join(params, "&")
+
+	dict, err := getTracker(ctx, u)
+	if err != nil {
+		return nil, err
+	}
+	return parseAnnounceResponse(dict), nil
+}
+
+// scrapeHTTP uses the scrape URL convention of BEP-48: the last path
+// segment of the announce URL with "announce" replaced by "scrape"
+func scrapeHTTP(ctx context.Context, u *url.URL, infoHash [sha1.Size]byte) (*ScrapeResult, error) {
+	slash := strings.LastIndex(u.Path, "/")
+	if slash < 0 || !strings.HasPrefix(u.Path[slash+1:], "announce") {
+		return nil, ErrScrapeUnsupported
+	}
+	u.Path = u.Path[:slash+1] + "scrape" + strings.TrimPrefix(u.Path[slash+1:], "announce")
+
+	param := "info_hash=" + url.QueryEscape(string(infoHash[:]))
+	if u.RawQuery != "" {
+		param = u.RawQuery + "&" + param
+	}
+	u.RawQuery = param
+
+	dict, err := getTracker(ctx, u)
+	if err != nil {
+		return nil, err
+	}
+	files, _ := dict["files"].(map[string]interface{})
+	file, ok := files[string(infoHash[:])].(map[string]interface{})
+	if !ok {
+		// Trackers leave out torrents they have never seen
+		return &ScrapeResult{}, nil
+	}
+
+	complete, _ := file["complete"].(int64)
+	incomplete, _ := file["incomplete"].(int64)
+	downloaded, _ := file["downloaded"].(int64)
+	return &ScrapeResult{Seeders: int(complete), Leechers: int(incomplete), Completed: int(downloaded)}, nil
+}
+
+// getTracker fetches a bencoded dictionary from an HTTP tracker
+func getTracker(ctx context.Context, u *url.URL) (map[string]interface{}, error) {
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := trackerClient.Do(httpReq)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxMessageLength))
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("tracker returned %s", resp.Status)
+	}
+
+	decoded, err := Decode(body)
+	if err != nil {
+		return nil, fmt.Errorf("invalid tracker response: %w", err)
+	}
+	dict, ok := decoded.(map[string]interface{})
+	if !ok {
+		return nil, errors.New("invalid tracker response")
+	}
+	if reason, ok := dict["failure reason"].(string); ok {
+		return nil, fmt.Errorf("tracker refused request: %s", reason)
+	}
+	return dict, nil
+}
+
+func parseAnnounceResponse(dict map[string]interface{}) *AnnounceResponse {
+	resp := &AnnounceResponse{Interval: defaultAnnounceInterval}
+	if interval, ok := dict["interval"].(int64); ok && interval > 0 {
+		resp.Interval = time.Duration(interval) * time.Second
+	}
+	if complete, ok := dict["complete"].(int64); ok {
+		resp.Seeders = int(complete)
+	}
+	if incomplete, ok := dict["incomplete"].(int64); ok {
+		resp.Leechers = int(incomplete)
+	}
+
+	switch peers := dict["peers"].(type) {
+	case string:
+		resp.Peers = compactPeers([]byte(peers), net.IPv4len)
+	case []interface{}:
+		for _, raw := range peers {
+			peer, _ := raw.(map[string]interface{})
+			ip, _ := peer["ip"].(string)
+			port, _ := peer["port"].(int64)
+			if ip != "" && port > 0 {
+				resp.Peers = append(resp.Peers, net.JoinHostPort(ip, strconv.FormatInt(port, 10)))
+			}
+		}
+	}
+	if peers6, ok := dict["peers6"].(string); ok {
+		resp.Peers = append(resp.Peers, compactPeers([]byte(peers6), net.IPv6len)...)
+	}
+	return resp
+}
+
+// compactPeers decodes BEP-23 compact peers: an address of ipLen bytes
+// followed by a big-endian port
+func compactPeers(data []byte, ipLen int) []string {
+	var peers []string
+	for len(data) >= ipLen+2 {
+		ip := net.IP(data[:ipLen])
+		port := binary.BigEndian.Uint16(data[ipLen:])
+		peers = append(peers, net.JoinHostPort(ip.String(), strconv.Itoa(int(port))))
+		data = data[ipLen+2:]
+	}
+	return peers
+}
@@ -0,0 +1,374 @@
+package torrent
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha1"
+	"encoding/binary"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+var (
+	testInfoHash = sha1.Sum([]byte("tracker test torrent"))
+	testPeerID   = [sha1.Size]byte{'-', 'G', 'S', '0', '0', '0', '1', '-', 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12}
+)
+
+func testAnnounce(event string) AnnounceRequest {
+	return AnnounceRequest{
+		InfoHash:   testInfoHash,
+		PeerID:     testPeerID,
+		Port:       6881,
+		Uploaded:   100,
+		Downloaded: 200,
+		Left:       300,
+		Event:      event,
+	}
+}
+
+// compact encodes peers the way BEP-23 trackers list them
+func compact(peers ...string) string {
+	var out []byte
+	for _, peer := range peers {
+		addr := netip.MustParseAddrPort(peer)
+		out = append(out, addr.Addr().AsSlice()...)
+		out = binary.BigEndian.AppendUint16(out, addr.Port())
+	}
+	return string(out)
+}
+
+// trackerServer answers HTTP announces and scrapes with bencoded
+// dictionaries from respond
+func trackerServer(t *testing.T, respond func(r *http.Request) (int, interface{})) *httptest.Server {
+	t.Helper()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		status, body := respond(r)
+		w.WriteHeader(status)
+		switch body := body.(type) {
+		case string:
+			w.Write([]byte(body))
+		default:
+			data, err := Encode(body)
+			if err != nil {
+				t.Errorf("encoding tracker response: %v", err)
+			}
+			w.Write(data)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestAnnounceHTTP(t *testing.T) {
+	srv := trackerServer(t, func(r *http.Request) (int, interface{}) {
+		q := r.URL.Query()
+		want := map[string]string{
+			"passkey":    "s3cret",
+			"info_hash":  string(testInfoHash[:]),
+			"peer_id":    string(testPeerID[:]),
+			"port":       "6881",
+			"uploaded":   "100",
+			"downloaded": "200",
+			"left":       "300",
+			"compact":    "1",
+			"event":      "started",
+		}
+		for key, value := range want {
+			if got := q.Get(key); got != value {
+				t.Errorf("%s = %q, want %q", key, got, value)
+			}
+		}
+		if r.URL.Path != "/announce" {
+			t.Errorf("path = %s, want /announce", r.URL.Path)
+		}
+		return http.StatusOK, map[string]interface{}{
+			"interval":   int64(900),
+			"complete":   int64(3),
+			"incomplete": int64(1),
+			"peers":      compact("10.0.0.1:6881", "192.168.1.20:51413"),
+			"peers6":     compact("[2001:db8::1]:6882"),
+		}
+	})
+
+	resp, err := Announce(context.Background(), srv.URL+"/announce?passkey=s3cret", testAnnounce("started"))
+	if err != nil {
+		t.Fatalf("Announce: %v", err)
+	}
+	want := &AnnounceResponse{
+		Interval: 900 * time.Second,
+		Seeders:  3,
+		Leechers: 1,
+		Peers:    []string{"10.0.0.1:6881", "192.168.1.20:51413", "[2001:db8::1]:6882"},
+	}
+	if !reflect.DeepEqual(resp, want) {
+		t.Fatalf("Announce = %+v, want %+v", resp, want)
+	}
+}
+
+func TestAnnounceHTTPPeerDictionaries(t *testing.T) {
+	srv := trackerServer(t, func(r *http.Request) (int, interface{}) {
+		if r.URL.Query().Has("event") {
+			t.Errorf("regular update sent event %q", r.URL.Query().Get("event"))
+		}
+		return http.StatusOK, map[string]interface{}{
+			"peers": []interface{}{
+				map[string]interface{}{"ip": "10.0.0.2", "port": int64(6881), "peer id": "ignored"},
+				map[string]interface{}{"ip": "", "port": int64(6881)},
+				map[string]interface{}{"ip": "10.0.0.3", "port": int64(0)},
+			},
+		}
+	})
+
+	resp, err := Announce(context.Background(), srv.URL+"/announce", testAnnounce(""))
+	if err != nil {
+		t.Fatalf("Announce: %v", err)
+	}
+	if resp.Interval != defaultAnnounceInterval {
+		t.Fatalf("Interval = %v, want the default %v", resp.Interval, defaultAnnounceInterval)
+	}
+	if want := []string{"10.0.0.2:6881"}; !reflect.DeepEqual(resp.Peers, want) {
+		t.Fatalf("Peers = %v, want %v", resp.Peers, want)
+	}
+}
+
+func TestAnnounceHTTPErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		status  int
+		body    interface{}
+		wantErr string
+	}{
+		{"failure reason", http.StatusOK, map[string]interface{}{"failure reason": "unregistered torrent"}, "tracker refused request: unregistered torrent"},
+		{"status", http.StatusServiceUnavailable, "", "tracker returned 503"},
+		{"not bencode", http.StatusOK, "<html>maintenance</html>", "invalid tracker response"},
+		{"not a dictionary", http.StatusOK, []interface{}{"peers"}, "invalid tracker response"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := trackerServer(t, func(*http.Request) (int, interface{}) { return tt.status, tt.body })
+			_, err := Announce(context.Background(), srv.URL+"/announce", testAnnounce(""))
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("Announce = %v, want an error containing %q", err, tt.wantErr)
+			}
+		})
+	}
+
+	if _, err := Announce(context.Background(), "wss://tracker.example/announce", testAnnounce("")); err == nil {
+		t.Fatal("Announce accepted an unsupported tracker scheme")
+	}
+}
+
+func TestScrapeHTTP(t *testing.T) {
+	srv := trackerServer(t, func(r *http.Request) (int, interface{}) {
+		if r.URL.Path != "/x/scrape.php" || r.URL.Query().Get("passkey") != "s3cret" {
+			t.Errorf("scrape URL = %s, want /x/scrape.php with the passkey", r.URL)
+		}
+		files := map[string]interface{}{}
+		if r.URL.Query().Get("info_hash") == string(testInfoHash[:]) {
+			files[string(testInfoHash[:])] = map[string]interface{}{
+				"complete": int64(4), "incomplete": int64(2), "downloaded": int64(17),
+			}
+		}
+		return http.StatusOK, map[string]interface{}{"files": files}
+	})
+
+	got, err := Scrape(context.Background(), srv.URL+"/x/announce.php?passkey=s3cret", testInfoHash)
+	if err != nil {
+		t.Fatalf("Scrape: %v", err)
+	}
+	if want := (ScrapeResult{Seeders: 4, Leechers: 2, Completed: 17}); *got != want {
+		t.Fatalf("Scrape = %+v, want %+v", *got, want)
+	}
+
+	// A torrent the tracker has never seen has an empty swarm
+	got, err = Scrape(context.Background(), srv.URL+"/x/announce.php?passkey=s3cret", sha1.Sum([]byte("unknown")))
+	if err != nil || *got != (ScrapeResult{}) {
+		t.Fatalf("Scrape of unknown torrent = %+v, %v; want an empty result", got, err)
+	}
+
+	if _, err := Scrape(context.Background(), srv.URL+"/tracker", testInfoHash); !errors.Is(err, ErrScrapeUnsupported) {
+		t.Fatalf("Scrape without an announce path = %v, want ErrScrapeUnsupported", err)
+	}
+}
+
+// udpTrackerServer is a BEP-15 tracker on loopback. Announces for
+// refusedHash get an error response.
+type udpTrackerServer struct {
+	conn   net.PacketConn
+	connID uint64
+
+	announced chan []byte // announce bodies, after the connection header
+}
+
+var refusedHash = sha1.Sum([]byte("refused"))
+
+func newUDPTrackerServer(t *testing.T) *udpTrackerServer {
+	t.Helper()
+
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &udpTrackerServer{conn: conn, connID: 0x0123456789abcdef, announced: make(chan []byte, 8)}
+	t.Cleanup(func() { conn.Close() })
+	go s.serve(t)
+	return s
+}
+
+func (s *udpTrackerServer) url(path string) string {
+	return "udp://" + s.conn.LocalAddr().String() + path
+}
+
+func (s *udpTrackerServer) serve(t *testing.T) {
+	buf := make([]byte, 2048)
+	for {
+		n, addr, err := s.conn.ReadFrom(buf)
+		if err != nil {
+			return
+		}
+		if n < 16 {
+			t.Errorf("short request of %d bytes", n)
+			continue
+		}
+		connID, action, tid := binary.BigEndian.Uint64(buf), binary.BigEndian.Uint32(buf[8:]), buf[12:16]
+		body := append([]byte(nil), buf[16:n]...)
+
+		reply := func(action uint32, payload []byte) {
+			out := binary.BigEndian.AppendUint32(nil, action)
+			out = append(out, tid...)
+			s.conn.WriteTo(append(out, payload...), addr)
+		}
+
+		if action == udpActionConnect {
+			if connID != udpProtocolID {
+				t.Errorf("connect with protocol ID %#x", connID)
+			}
+			reply(udpActionConnect, binary.BigEndian.AppendUint64(nil, s.connID))
+			continue
+		}
+		if connID != s.connID {
+			reply(udpActionError, []byte("bad connection ID"))
+			continue
+		}
+
+		switch action {
+		case udpActionAnnounce:
+			if bytes.Equal(body[:20], refusedHash[:]) {
+				reply(udpActionError, []byte("torrent not registered"))
+				continue
+			}
+			s.announced <- body
+			resp := binary.BigEndian.AppendUint32(nil, 600) // interval
+			resp = binary.BigEndian.AppendUint32(resp, 2)   // leechers
+			resp = binary.BigEndian.AppendUint32(resp, 5)   // seeders
+			resp = append(resp, compact("10.0.0.1:6881", "10.0.0.2:6882")...)
+			reply(udpActionAnnounce, resp)
+		case udpActionScrape:
+			resp := binary.BigEndian.AppendUint32(nil, 5) // seeders
+			resp = binary.BigEndian.AppendUint32(resp, 9) // completed
+			resp = binary.BigEndian.AppendUint32(resp, 2) // leechers
+			reply(udpActionScrape, resp)
+		}
+	}
+}
+
+func TestAnnounceUDP(t *testing.T) {
+	srv := newUDPTrackerServer(t)
+
+	resp, err := Announce(context.Background(), srv.url("/announce?passkey=s3cret"), testAnnounce("completed"))
+	if err != nil {
+		t.Fatalf("Announce: %v", err)
+	}
+	want := &AnnounceResponse{
+		Interval: 600 * time.Second,
+		Seeders:  5,
+		Leechers: 2,
+		Peers:    []string{"10.0.0.1:6881", "10.0.0.2:6882"},
+	}
+	if !reflect.DeepEqual(resp, want) {
+		t.Fatalf("Announce = %+v, want %+v", resp, want)
+	}
+
+	body := <-srv.announced
+	if !bytes.Equal(body[:20], testInfoHash[:]) || !bytes.Equal(body[20:40], testPeerID[:]) {
+		t.Fatal("announce carries the wrong info hash or peer ID")
+	}
+	if downloaded, left, uploaded := binary.BigEndian.Uint64(body[40:]), binary.BigEndian.Uint64(body[48:]), binary.BigEndian.Uint64(body[56:]); downloaded != 200 || left != 300 || uploaded != 100 {
+		t.Fatalf("announce counts downloaded=%d left=%d uploaded=%d", downloaded, left, uploaded)
+	}
+	if event := binary.BigEndian.Uint32(body[64:]); event != udpEvents["completed"] {
+		t.Fatalf("event = %d, want %d", event, udpEvents["completed"])
+	}
+	if port := binary.BigEndian.Uint16(body[80:]); port != 6881 {
+		t.Fatalf("port = %d, want 6881", port)
+	}
+	if options := body[82:]; !bytes.Equal(options, urlDataOptions("/announce?passkey=s3cret")) {
+		t.Fatalf("URL data options = %q", options)
+	}
+}
+
+func TestAnnounceUDPError(t *testing.T) {
+	srv := newUDPTrackerServer(t)
+
+	req := testAnnounce("started")
+	req.InfoHash = refusedHash
+	_, err := Announce(context.Background(), srv.url("/announce"), req)
+	if err == nil || !strings.Contains(err.Error(), "tracker refused request: torrent not registered") {
+		t.Fatalf("Announce = %v, want the tracker's error", err)
+	}
+}
+
+func TestAnnounceUDPCancelled(t *testing.T) {
+	// Nothing answers on this socket
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if _, err := Announce(ctx, "udp://"+conn.LocalAddr().String(), testAnnounce("")); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Announce to a silent tracker = %v, want a deadline error", err)
+	}
+	if elapsed := time.Since(start); elapsed > udpTimeout {
+		t.Fatalf("Announce returned after %v, past its context", elapsed)
+	}
+}
+
+func TestScrapeUDP(t *testing.T) {
+	srv := newUDPTrackerServer(t)
+
+	got, err := Scrape(context.Background(), srv.url(""), testInfoHash)
+	if err != nil {
+		t.Fatalf("Scrape: %v", err)
+	}
+	if want := (ScrapeResult{Seeders: 5, Leechers: 2, Completed: 9}); *got != want {
+		t.Fatalf("Scrape = %+v, want %+v", *got, want)
+	}
+}
+
+func TestURLDataOptions(t *testing.T) {
+	if options := urlDataOptions("/"); options != nil {
+		t.Fatalf("urlDataOptions(/) = %q, want none", options)
+	}
+
+	long := "/announce?passkey=" + strings.Repeat("k", 300)
+	options := urlDataOptions(long)
+	if options[0] != optionURLData || options[1] != 255 || options[257] != optionURLData || int(options[258]) != len(long)-255 {
+		t.Fatalf("urlDataOptions split %d bytes wrongly", len(long))
+	}
+	if got := string(options[2:257]) + string(options[259:]); got != long {
+		t.Fatalf("urlDataOptions carries %q, want %q", got, long)
+	}
+}
@@ -0,0 +1,198 @@
+package torrent
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"time"
+)
+
+// UDP tracker protocol from BEP-15
+const (
+	udpProtocolID = 0x41727101980
+
+	udpActionConnect  = 0
+	udpActionAnnounce = 1
+	udpActionScrape   = 2
+	udpActionError    = 3
+
+	optionURLData = 2 // BEP-41
+
+	udpTimeout  = 5 * time.Second
+	udpAttempts = 3
+)
+
+var udpEvents = map[string]uint32{"": 0, "completed": 1, "started": 2, "stopped": 3}
+
+// udpTracker is a connection to a UDP tracker, holding the connection ID
+// the tracker handed out
+type udpTracker struct {
+	conn   net.Conn
+	connID uint64
+}
+
+func dialUDPTracker(ctx context.Context, u *url.URL) (*udpTracker, error) {
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "udp", u.Host)
+	if err != nil {
+		return nil, err
+	}
+
+	t := &udpTracker{conn: conn, connID: udpProtocolID}
+	resp, err := t.roundTrip(ctx, udpActionConnect, nil)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if len(resp) < 8 {
+		conn.Close()
+		return nil, errors.New("short connect response from tracker")
+	}
+	t.connID = binary.BigEndian.Uint64(resp)
+	return t, nil
+}
+
+// roundTrip sends a request and returns the body of the matching response,
+// resending it when the tracker does not answer in time
+func (t *udpTracker) roundTrip(ctx context.Context, action uint32, body []byte) ([]byte, error) {
+	var tid [4]byte
+	if _, err := rand.Read(tid[:]); err != nil {
+		return nil, err
+	}
+	req := binary.BigEndian.AppendUint64(nil, t.connID)
+	req = binary.BigEndian.AppendUint32(req, action)
+	req = append(req, tid[:]...)
+	req = append(req, body...)
+
+	stop := context.AfterFunc(ctx, func() { t.conn.Close() })
+	defer stop()
+
+	buf := make([]byte, 64<<10)
+	for attempt := 0; attempt < udpAttempts; attempt++ {
+		if _, err := t.conn.Write(req); err != nil {
+			return nil, err
+		}
+		t.conn.SetReadDeadline(time.Now().Add(udpTimeout))
+
+		for {
+			n, err := t.conn.Read(buf)
+			if err != nil {
+				if ctx.Err() != nil {
+					return nil, ctx.Err()
+				}
+				var netErr net.Error
+				if errors.As(err, &netErr) && netErr.Timeout() {
+					break
+				}
+				return nil, err
+			}
+			if n < 8 || [4]byte(buf[4:8]) != tid {
+				continue
+			}
+
+			switch got := binary.BigEndian.Uint32(buf); got {
+			case action:
+				return append([]byte(nil), buf[8:n]...), nil
+			case udpActionError:
+				return nil, fmt.Errorf("tracker refused request: %s", buf[8:n])
+			default:
+				return nil, fmt.Errorf("tracker answered action %d to action %d", got, action)
+			}
+		}
+	}
+	return nil, errors.New("tracker did not answer")
+}
+
+func (t *udpTracker) close() {
+	t.conn.Close()
+}
+
+func announceUDP(ctx context.Context, u *url.URL, req AnnounceRequest) (*AnnounceResponse, error) {
+	t, err := dialUDPTracker(ctx, u)
+	if err != nil {
+		return nil, err
+	}
+	defer t.close()
+
+	var key [4]byte
+	if _, err := rand.Read(key[:]); err != nil {
+		return nil, err
+	}
+	body := append([]byte(nil), req.InfoHash[:]...)
+	body = append(body, req.PeerID[:]...)
+	body = binary.BigEndian.AppendUint64(body, uint64(req.Downloaded))
+	body = binary.BigEndian.AppendUint64(body, uint64(req.Left))
+	body = binary.BigEndian.AppendUint64(body, uint64(req.Uploaded))
+	body = binary.BigEndian.AppendUint32(body, udpEvents[req.Event])
+	body = binary.BigEndian.AppendUint32(body, 0) // IP: the sender's
+	body = append(body, key[:]...)
+	body = binary.BigEndian.AppendUint32(body, ^uint32(0)) // as many peers as the tracker likes
+	body = binary.BigEndian.AppendUint16(body, uint16(req.Port))
+	body = append(body, urlDataOptions(u.RequestURI())...)
+
+	resp, err := t.roundTrip(ctx, udpActionAnnounce, body)
+	if err != nil {
+		return nil, err
+	}
+	if len(resp) < 12 {
+		return nil, errors.New("short announce response from tracker")
+	}
+
+	// Peers are IPv6 when the tracker was reached over IPv6
+	ipLen := net.IPv4len
+	if addr, ok := t.conn.RemoteAddr().(*net.UDPAddr); ok && addr.IP.To4() == nil {
+		ipLen = net.IPv6len
+	}
+	interval := time.Duration(binary.BigEndian.Uint32(resp)) * time.Second
+	if interval <= 0 {
+		interval = defaultAnnounceInterval
+	}
+	return &AnnounceResponse{
+		Interval: interval,
+		Leechers: int(binary.BigEndian.Uint32(resp[4:])),
+		Seeders:  int(binary.BigEndian.Uint32(resp[8:])),
+		Peers:    compactPeers(resp[12:], ipLen),
+	}, nil
+}
+
+func scrapeUDP(ctx context.Context, u *url.URL, infoHash [sha1.Size]byte) (*ScrapeResult, error) {
+	t, err := dialUDPTracker(ctx, u)
+	if err != nil {
+		return nil, err
+	}
+	defer t.close()
+
+	resp, err := t.roundTrip(ctx, udpActionScrape, infoHash[:])
+	if err != nil {
+		return nil, err
+	}
+	if len(resp) < 12 {
+		return nil, errors.New("short scrape response from tracker")
+	}
+	return &ScrapeResult{
+		Seeders:   int(binary.BigEndian.Uint32(resp)),
+		Completed: int(binary.BigEndian.Uint32(resp[4:])),
+		Leechers:  int(binary.BigEndian.Uint32(resp[8:])),
+	}, nil
+}
+
+// urlDataOptions carries the path and query of a tracker URL, such as a
+// passkey, in BEP-41 URL data options of at most 255 bytes each
+func urlDataOptions(requestURI string) []byte {
+	if requestURI == "/" {
+		return nil
+	}
+	var options []byte
+	for data := []byte(requestURI); len(data) > 0; {
+		chunk := data[:min(len(data), 255)]
+		options = append(options, optionURLData, byte(len(chunk)))
+		options = append(options, chunk...)
+		data = data[len(chunk):]
+	}
+	return options
+}
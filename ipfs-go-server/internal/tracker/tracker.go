@@ -0,0 +1,211 @@
+package tracker
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/subtle"
+	"errors"
+	"net"
+	"sync"
+	"time"
+)
+
+const (
+	// Interval is how often peers are asked to announce. Peers that miss
+	// two announces are forgotten.
+	Interval    = 30 * time.Minute
+	MinInterval = 5 * time.Minute
+
+	peerTimeout      = 2*Interval + time.Minute
+	maxPeersReturned = 50
+)
+
+var (
+	ErrUnknownTorrent = errors.New("torrent is not tracked here")
+	ErrBadPasskey     = errors.New("invalid passkey")
+)
+
+// Announce is a peer's report about one torrent
+type Announce struct {
+	InfoHash [sha1.Size]byte
+	PeerID   [sha1.Size]byte
+	IP       net.IP
+	Port     int
+	Left     int64
+	Event    string // "started", "completed", "stopped" or ""
+	NumWant  int    // peers wanted, or -1 for the default
+}
+
+// Peer is a peer's address as handed to other peers
+type Peer struct {
+	IP   net.IP
+	Port int
+}
+
+// Stats counts a torrent's swarm
+type Stats struct {
+	Seeders   int
+	Leechers  int
+	Completed int // announces of a finished download
+}
+
+type peerEntry struct {
+	Peer
+	left     int64
+	lastSeen time.Time
+}
+
+type swarm struct {
+	peers     map[[sha1.Size]byte]*peerEntry
+	completed int
+}
+
+func (s *swarm) stats() Stats {
+	stats := Stats{Completed: s.completed}
+	for _, p := range s.peers {
+		if p.left == 0 {
+			stats.Seeders++
+		} else {
+			stats.Leechers++
+		}
+	}
+	return stats
+}
+
+// Tracker is a BitTorrent tracker for the torrents allowed reports as
+// tracked. Swarms are kept in memory only.
+type Tracker struct {
+	allowed func(infoHash [sha1.Size]byte) bool
+	passkey string
+	secret  []byte // signs UDP connection IDs
+
+	mu     sync.Mutex
+	swarms map[[sha1.Size]byte]*swarm
+}
+
+// New creates a tracker. When passkey is set every request must carry it.
+func New(allowed func(infoHash [sha1.Size]byte) bool, passkey string) (*Tracker, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, err
+	}
+	return &Tracker{
+		allowed: allowed,
+		passkey: passkey,
+		secret:  secret,
+		swarms:  make(map[[sha1.Size]byte]*swarm),
+	}, nil
+}
+
+// CheckPasskey reports whether a request's passkey is accepted
+func (t *Tracker) CheckPasskey(passkey string) bool {
+	return t.passkey == "" || subtle.ConstantTimeCompare([]byte(passkey), []byte(t.passkey)) == 1
+}
+
+// Announce records a peer's report and returns other peers of the torrent,
+// seeders left out for peers that are seeding themselves
+func (t *Tracker) Announce(a Announce) ([]Peer, Stats, error) {
+	if !t.allowed(a.InfoHash) {
+		return nil, Stats{}, ErrUnknownTorrent
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	s := t.swarms[a.InfoHash]
+	if s == nil {
+		s = &swarm{peers: make(map[[sha1.Size]byte]*peerEntry)}
+		t.swarms[a.InfoHash] = s
+	}
+
+	if a.Event == "stopped" {
+		delete(s.peers, a.PeerID)
+		return nil, s.stats(), nil
+	}
+	if a.Event == "completed" {
+		s.completed++
+	}
+	s.peers[a.PeerID] = &peerEntry{
+		Peer:     Peer{IP: a.IP, Port: a.Port},
+		left:     a.Left,
+		lastSeen: time.Now(),
+	}
+
+	want := a.NumWant
+	if want < 0 || want > maxPeersReturned {
+		want = maxPeersReturned
+	}
+	peers := make([]Peer, 0, want)
+	for id, p := range s.peers {
+		if len(peers) >= want {
+			break
+		}
+		if id == a.PeerID || (a.Left == 0 && p.left == 0) {
+			continue
+		}
+		peers = append(peers, p.Peer)
+	}
+	return peers, s.stats(), nil
+}
+
+// Scrape counts a torrent's swarm
+func (t *Tracker) Scrape(infoHash [sha1.Size]byte) (Stats, error) {
+	if !t.allowed(infoHash) {
+		return Stats{}, ErrUnknownTorrent
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if s := t.swarms[infoHash]; s != nil {
+		return s.stats(), nil
+	}
+	return Stats{}, nil
+}
+
+// Run forgets silent peers and swarms of torrents no longer tracked until
+// ctx is cancelled
+func (t *Tracker) Run(ctx context.Context) {
+	ticker := time.NewTicker(MinInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			t.prune()
+		}
+	}
+}
+
+func (t *Tracker) prune() {
+	t.mu.Lock()
+	hashes := make([][sha1.Size]byte, 0, len(t.swarms))
+	for hash := range t.swarms {
+		hashes = append(hashes, hash)
+	}
+	t.mu.Unlock()
+
+	// allowed may be slow, so it is not called with the lock held
+	gone := make(map[[sha1.Size]byte]bool)
+	for _, hash := range hashes {
+		if !t.allowed(hash) {
+			gone[hash] = true
+		}
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	cutoff := time.Now().Add(-peerTimeout)
+	for hash, s := range t.swarms {
+		for id, p := range s.peers {
+			if p.lastSeen.Before(cutoff) {
+				delete(s.peers, id)
+			}
+		}
+		if gone[hash] || (len(s.peers) == 0 && s.completed == 0) {
+			delete(t.swarms, hash)
+		}
+	}
+}
@@ -0,0 +1,202 @@
+package tracker
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"net"
+	"net/url"
+	"time"
+)
+
+// UDP tracker protocol from BEP-15, with the URL data option of BEP-41
+// carrying the passkey
+const (
+	udpProtocolID = 0x41727101980
+
+	udpActionConnect  = 0
+	udpActionAnnounce = 1
+	udpActionScrape   = 2
+	udpActionError    = 3
+
+	udpAnnounceLength = 98
+	udpMaxScrape      = 74 // info hashes per scrape, as BEP-15 allows
+
+	optionEnd     = 0
+	optionNOP     = 1
+	optionURLData = 2
+
+	// Connection IDs are signed with the client's address and the current
+	// window, and accepted for this window and the one before
+	connectionWindow = time.Minute
+)
+
+var udpEvents = map[uint32]string{0: "", 1: "completed", 2: "started", 3: "stopped"}
+
+// ServeUDP answers UDP tracker requests on conn until it is closed
+func (t *Tracker) ServeUDP(conn net.PacketConn) error {
+	buf := make([]byte, 2048)
+	for {
+		n, addr, err := conn.ReadFrom(buf)
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+			return err
+		}
+		udpAddr, ok := addr.(*net.UDPAddr)
+		if !ok {
+			continue
+		}
+		if resp := t.handleUDP(buf[:n], udpAddr); resp != nil {
+			conn.WriteTo(resp, addr)
+		}
+	}
+}
+
+func (t *Tracker) handleUDP(req []byte, addr *net.UDPAddr) []byte {
+	if len(req) < 16 {
+		return nil
+	}
+	connID := binary.BigEndian.Uint64(req)
+	action := binary.BigEndian.Uint32(req[8:])
+	tid := req[12:16]
+
+	if action == udpActionConnect {
+		if connID != udpProtocolID {
+			return nil
+		}
+		resp := udpHeader(udpActionConnect, tid)
+		return binary.BigEndian.AppendUint64(resp, t.connectionID(addr, time.Now()))
+	}
+	if !t.validConnectionID(connID, addr) {
+		return udpError(tid, "invalid connection ID")
+	}
+
+	switch action {
+	case udpActionAnnounce:
+		return t.udpAnnounce(req, addr, tid)
+	case udpActionScrape:
+		return t.udpScrape(req, tid)
+	}
+	return udpError(tid, "unknown action")
+}
+
+func (t *Tracker) udpAnnounce(req []byte, addr *net.UDPAddr, tid []byte) []byte {
+	if len(req) < udpAnnounceLength {
+		return udpError(tid, "short announce")
+	}
+	if !t.CheckPasskey(passkeyFromURLData(req[udpAnnounceLength:])) {
+		return udpError(tid, ErrBadPasskey.Error())
+	}
+
+	a := Announce{
+		IP:      addr.IP,
+		Left:    int64(binary.BigEndian.Uint64(req[64:])),
+		Event:   udpEvents[binary.BigEndian.Uint32(req[80:])],
+		NumWant: int(int32(binary.BigEndian.Uint32(req[92:]))),
+		Port:    int(binary.BigEndian.Uint16(req[96:])),
+	}
+	copy(a.InfoHash[:], req[16:36])
+	copy(a.PeerID[:], req[36:56])
+
+	peers, stats, err := t.Announce(a)
+	if err != nil {
+		return udpError(tid, err.Error())
+	}
+
+	resp := udpHeader(udpActionAnnounce, tid)
+	resp = binary.BigEndian.AppendUint32(resp, uint32(Interval/time.Second))
+	resp = binary.BigEndian.AppendUint32(resp, uint32(stats.Leechers))
+	resp = binary.BigEndian.AppendUint32(resp, uint32(stats.Seeders))
+
+	// Peers are sent in the address family the request came in over
+	v4 := addr.IP.To4() != nil
+	for _, p := range peers {
+		ip := p.IP.To4()
+		if !v4 {
+			ip = p.IP.To16()
+		} else if ip == nil {
+			continue
+		}
+		resp = append(resp, ip...)
+		resp = binary.BigEndian.AppendUint16(resp, uint16(p.Port))
+	}
+	return resp
+}
+
+func (t *Tracker) udpScrape(req []byte, tid []byte) []byte {
+	// A scrape has no room for URL data, so it cannot carry a passkey
+	if t.passkey != "" {
+		return udpError(tid, "scrape requires a passkey, use the HTTP tracker")
+	}
+
+	hashes := req[16:]
+	if len(hashes) == 0 || len(hashes)%sha1.Size != 0 || len(hashes)/sha1.Size > udpMaxScrape {
+		return udpError(tid, "malformed scrape")
+	}
+
+	resp := udpHeader(udpActionScrape, tid)
+	for i := 0; i < len(hashes); i += sha1.Size {
+		stats, err := t.Scrape([sha1.Size]byte(hashes[i : i+sha1.Size]))
+		if err != nil {
+			return udpError(tid, err.Error())
+		}
+		resp = binary.BigEndian.AppendUint32(resp, uint32(stats.Seeders))
+		resp = binary.BigEndian.AppendUint32(resp, uint32(stats.Completed))
+		resp = binary.BigEndian.AppendUint32(resp, uint32(stats.Leechers))
+	}
+	return resp
+}
+
+func (t *Tracker) connectionID(addr *net.UDPAddr, now time.Time) uint64 {
+	mac := hmac.New(sha256.New, t.secret)
+	mac.Write(addr.IP.To16())
+	binary.Write(mac, binary.BigEndian, uint16(addr.Port))
+	binary.Write(mac, binary.BigEndian, now.Unix()/int64(connectionWindow/time.Second))
+	return binary.BigEndian.Uint64(mac.Sum(nil))
+}
+
+func (t *Tracker) validConnectionID(id uint64, addr *net.UDPAddr) bool {
+	now := time.Now()
+	return id == t.connectionID(addr, now) || id == t.connectionID(addr, now.Add(-connectionWindow))
+}
+
+// passkeyFromURLData reads the passkey query parameter from the URL data
+// options that follow an announce
+func passkeyFromURLData(options []byte) string {
+	var data []byte
+	for len(options) > 0 {
+		switch options[0] {
+		case optionEnd:
+			options = nil
+		case optionNOP:
+			options = options[1:]
+		case optionURLData:
+			if len(options) < 2 || len(options) < 2+int(options[1]) {
+				options = nil
+				break
+			}
+			data = append(data, options[2:2+int(options[1])]...)
+			options = options[2+int(options[1]):]
+		default:
+			options = nil
+		}
+	}
+
+	u, err := url.Parse(string(data))
+	if err != nil {
+		return ""
+	}
+	return u.Query().Get("passkey")
+}
+
+func udpHeader(action uint32, tid []byte) []byte {
+	return append(binary.BigEndian.AppendUint32(nil, action), tid...)
+}
+
+func udpError(tid []byte, message string) []byte {
+	return append(udpHeader(udpActionError, tid), message...)
+}
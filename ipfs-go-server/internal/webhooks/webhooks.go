@@ -0,0 +1,480 @@
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"ipfs-go-server/internal/storage"
+	"ipfs-go-server/pkg/utils"
+)
+
+const (
+	// Headers sent with every delivery
+	SignatureHeader = "X-Torrentchain-Signature"
+	TimestampHeader = "X-Torrentchain-Timestamp"
+	EventHeader     = "X-Torrentchain-Event"
+	DeliveryHeader  = "X-Torrentchain-Delivery"
+
+	maxAttempts     = 6
+	maxLogSize      = 1000
+	deliveryTimeout = 10 * time.Second
+	queueSize       = 256
+)
+
+// initialBackoff is the wait before the first retry of a failed delivery
+var initialBackoff = 2 * time.Second
+
+// ErrNotFound is returned for unknown webhook or delivery IDs.
+var ErrNotFound = errors.New("not found")
+
+// Delivery states
+const (
+	StatusPending   = "pending"
+	StatusDelivered = "delivered"
+	StatusFailed    = "failed"
+)
+
+// Webhook is an endpoint notified of table events. An empty TableID
+// receives events for every table, and empty Events means every type.
+type Webhook struct {
+	ID        string              `json:"id"`
+	URL       string              `json:"url"`
+	TableID   string              `json:"tableId,omitempty"`
+	Events    []storage.EventType `json:"events,omitempty"`
+	Secret    string              `json:"secret"`
+	CreatedAt time.Time           `json:"createdAt"`
+}
+
+func (h *Webhook) matches(event storage.Event) bool {
+	if h.TableID != "" && h.TableID != event.TableID {
+		return false
+	}
+	if len(h.Events) == 0 {
+		return true
+	}
+	for _, t := range h.Events {
+		if t == event.Type {
+			return true
+		}
+	}
+	return false
+}
+
+// Payload is the JSON body POSTed to a webhook
+type Payload struct {
+	DeliveryID string `json:"deliveryId"`
+	WebhookID  string `json:"webhookId"`
+	storage.Event
+}
+
+// Delivery is one event sent (or being sent) to one webhook
+type Delivery struct {
+	ID           string        `json:"id"`
+	WebhookID    string        `json:"webhookId"`
+	Event        storage.Event `json:"event"`
+	Status       string        `json:"status"`
+	Attempts     int           `json:"attempts"`
+	ResponseCode int           `json:"responseCode,omitempty"`
+	LastError    string        `json:"lastError,omitempty"`
+	ReplayOf     string        `json:"replayOf,omitempty"`
+	CreatedAt    time.Time     `json:"createdAt"`
+	UpdatedAt    time.Time     `json:"updatedAt"`
+}
+
+type state struct {
+	Webhooks   map[string]*Webhook `json:"webhooks"`
+	Deliveries []*Delivery         `json:"deliveries"`
+}
+
+// Manager stores webhooks and delivers events to them
+type Manager struct {
+	path   string
+	client *http.Client
+	queue  chan storage.Event
+	ctx    context.Context
+
+	mu    sync.Mutex
+	state state
+}
+
+// NewManager loads webhooks and the delivery log from path, if it exists
+func NewManager(path string) (*Manager, error) {
+	m := &Manager{
+		path:   path,
+		client: &http.Client{Timeout: deliveryTimeout},
+		queue:  make(chan storage.Event, queueSize),
+		state: state{
+			Webhooks: make(map[string]*Webhook),
+		},
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	if err == nil {
+		if err := json.Unmarshal(data, &m.state); err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+		}
+		if m.state.Webhooks == nil {
+			m.state.Webhooks = make(map[string]*Webhook)
+		}
+	}
+	return m, nil
+}
+
+// Start delivers queued events until ctx is cancelled. Deliveries left
+// pending by a previous run are retried.
+func (m *Manager) Start(ctx context.Context) {
+	m.mu.Lock()
+	m.ctx = ctx
+	for _, d := range m.state.Deliveries {
+		if d.Status == StatusPending {
+			go m.deliver(d)
+		}
+	}
+	m.mu.Unlock()
+
+	go func() {
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case event := <-m.queue:
+				for _, d := range m.newDeliveries(event) {
+					go m.deliver(d)
+				}
+			}
+		}
+	}()
+}
+
+// Notify queues an event for delivery without blocking. It can be
+// registered directly with storage.OnChange.
+func (m *Manager) Notify(event storage.Event) {
+	select {
+	case m.queue <- event:
+	default:
+		log.Printf("[WEBHOOKS] Queue full, dropping %s event for table %s", event.Type, event.TableID)
+	}
+}
+
+// Register adds a webhook. A secret is generated when none is given.
+func (m *Manager) Register(hook Webhook) (*Webhook, error) {
+	u, err := url.Parse(hook.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("invalid webhook URL %q", hook.URL)
+	}
+
+	if hook.ID, err = utils.GenerateUniqueID(); err != nil {
+		return nil, err
+	}
+	if hook.Secret == "" {
+		if hook.Secret, err = utils.GenerateUniqueID(); err != nil {
+			return nil, err
+		}
+	}
+	hook.CreatedAt = time.Now().UTC()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.state.Webhooks[hook.ID] = &hook
+	if err := m.save(); err != nil {
+		return nil, err
+	}
+
+	registered := hook
+	return &registered, nil
+}
+
+// Remove deletes a webhook. Its delivery log is kept.
+func (m *Manager) Remove(id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, exists := m.state.Webhooks[id]; !exists {
+		return ErrNotFound
+	}
+	delete(m.state.Webhooks, id)
+	return m.save()
+}
+
+// MoveTable points the webhooks of table oldID at newID and returns how
+// many it moved
+func (m *Manager) MoveTable(oldID, newID string) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	moved := 0
+	for _, hook := range m.state.Webhooks {
+		if hook.TableID == oldID {
+			hook.TableID = newID
+			moved++
+		}
+	}
+	if moved == 0 {
+		return 0, nil
+	}
+	return moved, m.save()
+}
+
+// Get returns a copy of a webhook
+func (m *Manager) Get(id string) (*Webhook, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	hook, exists := m.state.Webhooks[id]
+	if !exists {
+		return nil, ErrNotFound
+	}
+	copied := *hook
+	return &copied, nil
+}
+
+// List returns copies of all webhooks, optionally only those for a table
+// (global webhooks included), ordered by creation time
+func (m *Manager) List(tableID string) []Webhook {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	hooks := make([]Webhook, 0, len(m.state.Webhooks))
+	for _, hook := range m.state.Webhooks {
+		if tableID != "" && hook.TableID != "" && hook.TableID != tableID {
+			continue
+		}
+		hooks = append(hooks, *hook)
+	}
+	sort.Slice(hooks, func(i, j int) bool { return hooks[i].CreatedAt.Before(hooks[j].CreatedAt) })
+	return hooks
+}
+
+// Deliveries returns the delivery log of a webhook, newest first
+func (m *Manager) Deliveries(webhookID string) []Delivery {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	deliveries := make([]Delivery, 0)
+	for i := len(m.state.Deliveries) - 1; i >= 0; i-- {
+		if d := m.state.Deliveries[i]; d.WebhookID == webhookID {
+			deliveries = append(deliveries, *d)
+		}
+	}
+	return deliveries
+}
+
+// Replay sends the event of an earlier delivery again as a new delivery
+func (m *Manager) Replay(webhookID, deliveryID string) (*Delivery, error) {
+	m.mu.Lock()
+	var original *Delivery
+	for _, d := range m.state.Deliveries {
+		if d.ID == deliveryID && d.WebhookID == webhookID {
+			original = d
+			break
+		}
+	}
+	_, hookExists := m.state.Webhooks[webhookID]
+	m.mu.Unlock()
+
+	if original == nil || !hookExists {
+		return nil, ErrNotFound
+	}
+
+	d, err := m.addDelivery(webhookID, original.Event, original.ID)
+	if err != nil {
+		return nil, err
+	}
+	go m.deliver(d)
+
+	m.mu.Lock()
+	copied := *d
+	m.mu.Unlock()
+	return &copied, nil
+}
+
+// Sign computes the signature header value for a delivery body. Receivers
+// recompute it with their secret to authenticate the request; the timestamp
+// is covered so captured requests cannot be replayed later.
+func Sign(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func (m *Manager) newDeliveries(event storage.Event) []*Delivery {
+	m.mu.Lock()
+	var targets []string
+	for id, hook := range m.state.Webhooks {
+		if hook.matches(event) {
+			targets = append(targets, id)
+		}
+	}
+	m.mu.Unlock()
+
+	deliveries := make([]*Delivery, 0, len(targets))
+	for _, id := range targets {
+		d, err := m.addDelivery(id, event, "")
+		if err != nil {
+			log.Printf("[WEBHOOKS] Failed to record delivery to %s: %v", id, err)
+			continue
+		}
+		deliveries = append(deliveries, d)
+	}
+	return deliveries
+}
+
+func (m *Manager) addDelivery(webhookID string, event storage.Event, replayOf string) (*Delivery, error) {
+	id, err := utils.GenerateUniqueID()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+	d := &Delivery{
+		ID:        id,
+		WebhookID: webhookID,
+		Event:     event,
+		Status:    StatusPending,
+		ReplayOf:  replayOf,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.state.Deliveries = append(m.state.Deliveries, d)
+	if len(m.state.Deliveries) > maxLogSize {
+		m.state.Deliveries = m.state.Deliveries[len(m.state.Deliveries)-maxLogSize:]
+	}
+	return d, m.save()
+}
+
+// deliver POSTs a delivery until it succeeds or runs out of attempts,
+// doubling the wait between attempts
+func (m *Manager) deliver(d *Delivery) {
+	ctx := m.ctx
+	backoff := initialBackoff
+	for {
+		m.mu.Lock()
+		hook, exists := m.state.Webhooks[d.WebhookID]
+		var target Webhook
+		if exists {
+			target = *hook
+		}
+		attempts := d.Attempts
+		m.mu.Unlock()
+
+		if !exists {
+			m.finish(d, StatusFailed, 0, "webhook was removed")
+			return
+		}
+		if attempts >= maxAttempts {
+			m.mu.Lock()
+			code, lastError := d.ResponseCode, d.LastError
+			m.mu.Unlock()
+			m.finish(d, StatusFailed, code, lastError)
+			return
+		}
+
+		code, err := m.post(ctx, &target, d)
+		if err == nil {
+			m.finish(d, StatusDelivered, code, "")
+			log.Printf("[WEBHOOKS] Delivered %s event for table %s to %s", d.Event.Type, d.Event.TableID, target.URL)
+			return
+		}
+
+		m.mu.Lock()
+		d.Attempts++
+		d.ResponseCode = code
+		d.LastError = err.Error()
+		d.UpdatedAt = time.Now().UTC()
+		attempts = d.Attempts
+		m.save()
+		m.mu.Unlock()
+		log.Printf("[WEBHOOKS] Delivery %s to %s failed (attempt %d): %v", d.ID, target.URL, attempts, err)
+
+		if attempts >= maxAttempts {
+			m.finish(d, StatusFailed, code, err.Error())
+			return
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
+
+func (m *Manager) post(ctx context.Context, hook *Webhook, d *Delivery) (int, error) {
+	body, err := json.Marshal(Payload{DeliveryID: d.ID, WebhookID: hook.ID, Event: d.Event})
+	if err != nil {
+		return 0, err
+	}
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, hook.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "torrentchain-webhooks/1")
+	req.Header.Set(EventHeader, string(d.Event.Type))
+	req.Header.Set(DeliveryHeader, d.ID)
+	req.Header.Set(TimestampHeader, timestamp)
+	req.Header.Set(SignatureHeader, Sign(hook.Secret, timestamp, body))
+
+	resp, err := m.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("receiver returned %s", resp.Status)
+	}
+	return resp.StatusCode, nil
+}
+
+func (m *Manager) finish(d *Delivery, status string, code int, lastError string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	d.Status = status
+	d.ResponseCode = code
+	d.LastError = lastError
+	d.UpdatedAt = time.Now().UTC()
+	if err := m.save(); err != nil {
+		log.Printf("[WEBHOOKS] Warning: Failed to save delivery log: %v", err)
+	}
+}
+
+// save writes webhooks and the delivery log to disk. Must be called with
+// m.mu held.
+func (m *Manager) save() error {
+	data, err := json.Marshal(m.state)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(m.path, data, 0600)
+}
@@ -0,0 +1,239 @@
+package webhooks
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"ipfs-go-server/internal/storage"
+)
+
+// receiver records the deliveries POSTed to it and answers each with the
+// next status in statuses, then 200
+type receiver struct {
+	t        *testing.T
+	mu       sync.Mutex
+	statuses []int
+	requests []*http.Request
+	bodies   [][]byte
+}
+
+func newReceiver(t *testing.T, statuses ...int) (*receiver, *httptest.Server) {
+	t.Helper()
+
+	rcv := &receiver{t: t, statuses: statuses}
+	srv := httptest.NewServer(rcv)
+	t.Cleanup(srv.Close)
+	return rcv, srv
+}
+
+func (rcv *receiver) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		rcv.t.Errorf("reading delivery: %v", err)
+	}
+
+	rcv.mu.Lock()
+	defer rcv.mu.Unlock()
+	rcv.requests = append(rcv.requests, r)
+	rcv.bodies = append(rcv.bodies, body)
+	status := http.StatusOK
+	if len(rcv.statuses) > 0 {
+		status, rcv.statuses = rcv.statuses[0], rcv.statuses[1:]
+	}
+	w.WriteHeader(status)
+}
+
+func (rcv *receiver) received() int {
+	rcv.mu.Lock()
+	defer rcv.mu.Unlock()
+	return len(rcv.requests)
+}
+
+// startManager runs a manager persisting to a temporary file with short
+// retry waits
+func startManager(t *testing.T) *Manager {
+	t.Helper()
+
+	backoff := initialBackoff
+	initialBackoff = 10 * time.Millisecond
+	t.Cleanup(func() { initialBackoff = backoff })
+
+	m, err := NewManager(filepath.Join(t.TempDir(), "webhooks.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	m.Start(ctx)
+	return m
+}
+
+// waitForDelivery returns the only delivery of a webhook once it is no
+// longer pending
+func waitForDelivery(t *testing.T, m *Manager, webhookID string) Delivery {
+	t.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if deliveries := m.Deliveries(webhookID); len(deliveries) == 1 && deliveries[0].Status != StatusPending {
+			return deliveries[0]
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("delivery to %s still pending: %+v", webhookID, m.Deliveries(webhookID))
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func testEvent(tableID string) storage.Event {
+	return storage.Event{
+		Type:    storage.EventAppended,
+		TableID: tableID,
+		Version: 3,
+		CID:     "bafybeigdyrzt5sfp7udm7hu76uh7y26nf3efuylqabf3oclgtqy55fbzdi",
+		Time:    time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC),
+	}
+}
+
+func TestSign(t *testing.T) {
+	// HMAC-SHA256 over "<timestamp>.<body>", computed independently
+	got := Sign("whsec_test", "1700000000", []byte(`{"type":"appended"}`))
+	want := "sha256=bf0567490745acb2ffa51ff3ec42ade3ac31096dd9ab9fd127408e196432c6e8"
+	if got != want {
+		t.Fatalf("Sign = %s, want %s", got, want)
+	}
+}
+
+func TestDeliverySignedForReceiver(t *testing.T) {
+	rcv, srv := newReceiver(t)
+	m := startManager(t)
+
+	hook, err := m.Register(Webhook{URL: srv.URL + "/hook", TableID: "release", Secret: "whsec_test"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Only the event of the webhook's table is delivered
+	m.Notify(testEvent("other"))
+	m.Notify(testEvent("release"))
+
+	d := waitForDelivery(t, m, hook.ID)
+	if d.Status != StatusDelivered || d.ResponseCode != http.StatusOK || d.Attempts != 0 {
+		t.Fatalf("delivery = %+v, want delivered on the first attempt", d)
+	}
+	if n := rcv.received(); n != 1 {
+		t.Fatalf("receiver got %d deliveries, want 1", n)
+	}
+
+	r, body := rcv.requests[0], rcv.bodies[0]
+	if r.URL.Path != "/hook" || r.Header.Get("Content-Type") != "application/json" {
+		t.Fatalf("delivery went to %s as %s", r.URL.Path, r.Header.Get("Content-Type"))
+	}
+	if r.Header.Get(EventHeader) != string(storage.EventAppended) || r.Header.Get(DeliveryHeader) != d.ID {
+		t.Fatalf("event header %q, delivery header %q", r.Header.Get(EventHeader), r.Header.Get(DeliveryHeader))
+	}
+
+	// The receiver authenticates the delivery with the shared secret
+	mac := hmac.New(sha256.New, []byte("whsec_test"))
+	mac.Write([]byte(r.Header.Get(TimestampHeader) + "."))
+	mac.Write(body)
+	if want := "sha256=" + hex.EncodeToString(mac.Sum(nil)); r.Header.Get(SignatureHeader) != want {
+		t.Fatalf("signature = %s, want %s", r.Header.Get(SignatureHeader), want)
+	}
+
+	var payload Payload
+	if err := json.Unmarshal(body, &payload); err != nil {
+		t.Fatal(err)
+	}
+	if payload.DeliveryID != d.ID || payload.WebhookID != hook.ID || payload.Event != testEvent("release") {
+		t.Fatalf("payload = %+v", payload)
+	}
+}
+
+func TestDeliveryRetriedUntilAccepted(t *testing.T) {
+	rcv, srv := newReceiver(t, http.StatusInternalServerError, http.StatusBadGateway)
+	m := startManager(t)
+
+	hook, err := m.Register(Webhook{URL: srv.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.Notify(testEvent("release"))
+
+	d := waitForDelivery(t, m, hook.ID)
+	if d.Status != StatusDelivered || d.Attempts != 2 || d.LastError != "" {
+		t.Fatalf("delivery = %+v, want delivered after 2 failed attempts", d)
+	}
+	if n := rcv.received(); n != 3 {
+		t.Fatalf("receiver got %d requests, want 3", n)
+	}
+	// Every attempt is the same delivery, signed afresh
+	for _, r := range rcv.requests {
+		if r.Header.Get(DeliveryHeader) != d.ID {
+			t.Fatalf("attempt carried delivery %s, want %s", r.Header.Get(DeliveryHeader), d.ID)
+		}
+		if r.Header.Get(SignatureHeader) == "" {
+			t.Fatal("attempt was not signed")
+		}
+	}
+}
+
+func TestDeliveryFailsAfterMaxAttempts(t *testing.T) {
+	statuses := make([]int, maxAttempts+1)
+	for i := range statuses {
+		statuses[i] = http.StatusServiceUnavailable
+	}
+	rcv, srv := newReceiver(t, statuses...)
+	m := startManager(t)
+
+	hook, err := m.Register(Webhook{URL: srv.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.Notify(testEvent("release"))
+
+	d := waitForDelivery(t, m, hook.ID)
+	if d.Status != StatusFailed || d.Attempts != maxAttempts || d.ResponseCode != http.StatusServiceUnavailable {
+		t.Fatalf("delivery = %+v, want failed after %d attempts with 503", d, maxAttempts)
+	}
+	if d.LastError != "receiver returned 503 Service Unavailable" {
+		t.Fatalf("LastError = %q", d.LastError)
+	}
+	if n := rcv.received(); n != maxAttempts {
+		t.Fatalf("receiver got %d requests, want %d", n, maxAttempts)
+	}
+
+	// A replay is a new delivery of the same event
+	replay, err := m.Replay(hook.ID, d.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if replay.ID == d.ID || replay.ReplayOf != d.ID || replay.Event != d.Event {
+		t.Fatalf("replay = %+v of %+v", replay, d)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for rcv.received() != maxAttempts+1 {
+		if time.Now().After(deadline) {
+			t.Fatal("replay was not delivered")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestRegisterRejectsInvalidURLs(t *testing.T) {
+	m := startManager(t)
+	for _, u := range []string{"", "ftp://example.com/hook", "http://", "not a url"} {
+		if _, err := m.Register(Webhook{URL: u}); err == nil {
+			t.Errorf("Register accepted %q", u)
+		}
+	}
+}
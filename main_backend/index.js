@@ -9,6 +9,8 @@ const port = 3000;
 
 // Server configurations
 const IPFS_SERVER_URL = 'http://localhost:8081';
+// API key for the IPFS server; needs tables:admin to delete tables
+const IPFS_SERVER_API_KEY = process.env.IPFS_SERVER_API_KEY;
 const BLOCKCHAIN_SERVER_URL = 'http://localhost:3001';
 
 // Middleware
@@ -53,13 +55,20 @@ async function proxyToIPFS(endpoint, method = 'GET', body = null) {
                 'Content-Type': 'application/json',
             },
         };
+
+        if (IPFS_SERVER_API_KEY) {
+            options.headers['Authorization'] = `Bearer ${IPFS_SERVER_API_KEY}`;
+        }
         
         if (body) {
             options.body = JSON.stringify(body);
             console.log(`[PROXY] Serialized body:`, options.body);
         }
 
-        console.log(`[PROXY] Making fetch request with options:`, options);
+        console.log(`[PROXY] Making fetch request with options:`, {
+            ...options,
+            headers: { ...options.headers, ...(IPFS_SERVER_API_KEY && { Authorization: '[REDACTED]' }) },
+        });
         const response = await fetch(`${IPFS_SERVER_URL}${endpoint}`, options);
         
         console.log(`[PROXY] Response status: ${response.status}`);
@@ -28,6 +28,18 @@ async function initCreateTorrent() {
   return createTorrentFile;
 }
 
+// Torrents announce only to the Go server's embedded tracker, so private
+// builds are never announced publicly
+function trackerAnnounceURL() {
+  const announceURL = process.env.TRACKER_ANNOUNCE_URL || 'http://localhost:8081/announce';
+  const passkey = process.env.TRACKER_PASSKEY;
+  if (!passkey) {
+    return announceURL;
+  }
+  const separator = announceURL.includes('?') ? '&' : '?';
+  return `${announceURL}${separator}passkey=${encodeURIComponent(passkey)}`;
+}
+
 // Create a torrent from a file
 async function createTorrent(filePath) {
   const createTorrentFn = await initCreateTorrent();
@@ -35,27 +47,7 @@ async function createTorrent(filePath) {
   return new Promise((resolve, reject) => {
     createTorrentFn(filePath, {
       name: path.basename(filePath),
-      announceList: [
-        // Original trackers
-        ['udp://tracker.opentrackr.org:1337'],
-        ['udp://tracker.leechers-paradise.org:6969'],
-        ['udp://tracker.coppersurfer.tk:6969'],
-        ['udp://open.demonii.si:1337'],
-        ['udp://denis.stalker.upeer.me:6969'],
-        
-        // New trackers
-        ['udp://wambo.club:1337/announce'],
-        ['udp://tracker.dutchtracking.com:6969/announce'],
-        ['udp://tc.animereactor.ru:8082/announce'],
-        ['https://tracker.nitrix.me:443/announce'],
-        ['http://novaopcj.icu:10325/announce'],
-        ['udp://aaa.army:8866/announce'],
-        ['https://tracker.imgoingto.icu:443/announce'],
-        ['udp://tracker.shkinev.me:6969/announce'],
-        ['udp://blokas.io:6969/announce'],
-        ['udp://api.bitumconference.ru:6969/announce'],
-        ['udp://ln.mtahost.co:6969/announce']
-      ]
+      announceList: [[trackerAnnounceURL()]]
     }, async (err, torrent) => {
       if (err) return reject(err);
 